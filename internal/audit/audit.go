@@ -0,0 +1,245 @@
+// Package audit implements an append-only log of calls executing programs
+// or changing sessions. Records are stored as JSON lines and chained by hashes;
+// each record contains the hash of the previous one. Modifying, removing,
+// or reordering records breaks the chain, which is detected by [Verify].
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dirMode  = 0o700
+	fileMode = 0o600
+)
+
+type Type string
+
+const (
+	TypeExecute        Type = "execute"
+	TypeResolveProgram Type = "resolve_program"
+	TypeSessionCreate  Type = "session_create"
+	TypeSessionUpdate  Type = "session_update"
+	TypeSessionDelete  Type = "session_delete"
+)
+
+// Phase tells whether a record was written before or after a call was handled.
+// A call without the finished record was interrupted, for example, by a crash.
+type Phase string
+
+const (
+	PhaseStarted  Phase = "started"
+	PhaseFinished Phase = "finished"
+)
+
+// Record describes a single call. Seq, Time, and PrevHash are set by [Log.Append].
+type Record struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	PrevHash string    `json:"prev_hash"`
+
+	Type  Type  `json:"type"`
+	Phase Phase `json:"phase"`
+	// CallID is the same in records of the same call.
+	CallID string `json:"call_id"`
+	Method string `json:"method"`
+	// Who made the call.
+	Actor Actor `json:"actor"`
+
+	// Where the program comes from.
+	SessionID string `json:"session_id,omitempty"`
+	Project   string `json:"project,omitempty"`
+	Document  string `json:"document,omitempty"`
+	CellID    string `json:"cell_id,omitempty"`
+	CellName  string `json:"cell_name,omitempty"`
+
+	// What was executed or resolved.
+	Program *Program `json:"program,omitempty"`
+	// EnvSet contains names of env variables set in the session
+	// by the call. Values are omitted as they might be secrets.
+	EnvSet []string `json:"env_set,omitempty"`
+
+	// Outcome is set in records of the finished phase.
+	Outcome *Outcome `json:"outcome,omitempty"`
+}
+
+type Actor struct {
+	// Client is the common name of the TLS client certificate.
+	Client       string `json:"client,omitempty"`
+	ClientSerial string `json:"client_serial,omitempty"`
+	// Addr is the remote address of the connection.
+	Addr string `json:"addr,omitempty"`
+	// Process is set for connections to a unix socket.
+	Process *Process `json:"process,omitempty"`
+}
+
+// Process identifies the process connected to a unix socket.
+type Process struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+	PID int32  `json:"pid"`
+}
+
+// Program is a redacted program config. The script is replaced by its hash.
+type Program struct {
+	Name         string   `json:"name,omitempty"`
+	Args         []string `json:"args,omitempty"`
+	Cwd          string   `json:"cwd,omitempty"`
+	Language     string   `json:"language,omitempty"`
+	Mode         string   `json:"mode,omitempty"`
+	Interactive  bool     `json:"interactive,omitempty"`
+	Background   bool     `json:"background,omitempty"`
+	ScriptSHA256 string   `json:"script_sha256,omitempty"`
+}
+
+type Outcome struct {
+	// Code is the gRPC status code, for example, "OK" or "PermissionDenied".
+	Code  string `json:"code"`
+	Error string `json:"error,omitempty"`
+	// ExitCode is set for executed programs which exited.
+	ExitCode   *int  `json:"exit_code,omitempty"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// line is a single line of the log. Hash is computed over
+// the raw bytes of Record, which include the previous hash.
+type line struct {
+	Record json.RawMessage `json:"record"`
+	Hash   string          `json:"hash"`
+}
+
+// Log appends records to a file. It is safe for concurrent use.
+// Only a single process should write to the file at a time.
+type Log struct {
+	mu       sync.Mutex
+	f        *os.File
+	seq      uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// Open opens the log at path, creating it if it does not exist.
+// New records continue the chain of the existing ones.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return nil, errors.Wrap(err, "failed to create audit log dir")
+	}
+
+	seq, lastHash, err := readLast(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, fileMode)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit log")
+	}
+
+	return &Log{f: f, seq: seq, lastHash: lastHash, now: time.Now}, nil
+}
+
+// readLast returns the sequence number and the hash of the last record.
+func readLast(path string) (seq uint64, hash string, _ error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var last []byte
+	r := bufio.NewReader(f)
+	for {
+		data, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			last = data
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, "", errors.WithStack(err)
+		}
+	}
+	if last == nil {
+		return 0, "", nil
+	}
+
+	var l line
+	if err := json.Unmarshal(last, &l); err != nil {
+		return 0, "", errors.Wrap(err, "failed to parse the last audit record")
+	}
+	var rec Record
+	if err := json.Unmarshal(l.Record, &rec); err != nil {
+		return 0, "", errors.Wrap(err, "failed to parse the last audit record")
+	}
+	return rec.Seq, l.Hash, nil
+}
+
+// Append sets the sequence number, time, and the previous hash
+// of rec and writes it to the log.
+func (l *Log) Append(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.Seq = l.seq + 1
+	rec.Time = l.now().UTC()
+	rec.PrevHash = l.lastHash
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	hash := hashRecord(data)
+
+	// The line is assembled manually to store exactly the bytes which were hashed.
+	out := make([]byte, 0, len(data)+len(hash)+24)
+	out = append(out, `{"record":`...)
+	out = append(out, data...)
+	out = append(out, `,"hash":"`...)
+	out = append(out, hash...)
+	out = append(out, "\"}\n"...)
+
+	// A single write with O_APPEND does not interleave with other writes.
+	if _, err := l.f.Write(out); err != nil {
+		return errors.Wrap(err, "failed to write audit record")
+	}
+	if err := l.f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync audit log")
+	}
+
+	l.seq, l.lastHash = rec.Seq, hash
+	return nil
+}
+
+func (l *Log) Close() error {
+	return errors.WithStack(l.f.Close())
+}
+
+func hashRecord(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashScript returns the hex-encoded SHA-256 of a script.
+func HashScript(script string) string {
+	if script == "" {
+		return ""
+	}
+	return hashRecord([]byte(script))
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestLog(t *testing.T, path string, records ...Record) {
+	t.Helper()
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	l.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	for _, rec := range records {
+		require.NoError(t, l.Append(rec))
+	}
+	require.NoError(t, l.Close())
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+
+	writeTestLog(t, path,
+		Record{Type: TypeSessionCreate, Method: "/runme.runner.v2.RunnerService/CreateSession", Outcome: &Outcome{Code: "OK"}},
+		Record{Type: TypeExecute, Method: "/runme.runner.v2.RunnerService/Execute", Program: &Program{Name: "bash", ScriptSHA256: HashScript("echo <hi> & bye")}, Outcome: &Outcome{Code: "OK"}},
+	)
+	// Reopening continues the chain.
+	writeTestLog(t, path, Record{Type: TypeSessionDelete, Outcome: &Outcome{Code: "OK"}})
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(fileMode), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	n, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], `{"record":{"seq":1,"time":"2024-01-02T03:04:05Z","prev_hash":"","type":"session_create"`), lines[0])
+
+	t.Run("Modified", func(t *testing.T) {
+		modified := strings.Replace(string(data), `"name":"bash"`, `"name":"zsh"`, 1)
+		n, err := Verify(strings.NewReader(modified))
+		var verr *VerifyError
+		require.ErrorAs(t, err, &verr)
+		assert.Equal(t, 2, verr.Line)
+		assert.Contains(t, verr.Reason, "hash mismatch")
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		removed := lines[0] + "\n" + lines[2] + "\n"
+		_, err := Verify(strings.NewReader(removed))
+		require.ErrorContains(t, err, "line 2: previous hash mismatch")
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		// Removing records from the beginning is detected as well.
+		_, err := Verify(strings.NewReader(lines[1] + "\n"))
+		require.ErrorContains(t, err, "line 1: previous hash mismatch")
+	})
+}
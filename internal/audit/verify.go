@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyError describes the first line breaking the chain.
+type VerifyError struct {
+	// Line is the 1-based line number.
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Verify checks that records read from r form an unbroken chain
+// starting from the first record. It returns the number of verified records.
+// If the chain is broken, the error is a [*VerifyError].
+func Verify(r io.Reader) (int, error) {
+	var (
+		n        int
+		lineNo   int
+		prevSeq  uint64
+		prevHash string
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		data, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return n, errors.WithStack(readErr)
+		}
+
+		if len(data) > 0 {
+			lineNo++
+		}
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			seq, hash, err := verifyLine(data, prevSeq, prevHash)
+			if err != nil {
+				return n, &VerifyError{Line: lineNo, Reason: err.Error()}
+			}
+			prevSeq, prevHash = seq, hash
+			n++
+		}
+
+		if readErr == io.EOF {
+			return n, nil
+		}
+	}
+}
+
+// verifyLine checks a single line against the previous record
+// and returns its sequence number and hash.
+func verifyLine(data []byte, prevSeq uint64, prevHash string) (uint64, string, error) {
+	var l line
+	if err := json.Unmarshal(data, &l); err != nil {
+		return 0, "", errors.Wrap(err, "invalid JSON")
+	}
+	if len(l.Record) == 0 {
+		return 0, "", errors.New("missing record")
+	}
+
+	if got := hashRecord(l.Record); got != l.Hash {
+		return 0, "", errors.Errorf("hash mismatch: record was modified (expected %s, got %s)", l.Hash, got)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(l.Record, &rec); err != nil {
+		return 0, "", errors.Wrap(err, "invalid record")
+	}
+
+	if rec.PrevHash != prevHash {
+		return 0, "", errors.Errorf("previous hash mismatch: record was removed or reordered (expected %q, got %q)", prevHash, rec.PrevHash)
+	}
+	if rec.Seq != prevSeq+1 {
+		return 0, "", errors.Errorf("unexpected sequence number %d, expected %d", rec.Seq, prevSeq+1)
+	}
+
+	return rec.Seq, l.Hash, nil
+}
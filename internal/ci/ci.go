@@ -0,0 +1,360 @@
+// Package ci generates CI workflows which run project tasks.
+package ci
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stateful/runme/v3/internal/shell"
+	"github.com/stateful/runme/v3/internal/tasks"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+type Provider string
+
+const (
+	ProviderGitHub Provider = "github"
+	ProviderGitLab Provider = "gitlab"
+)
+
+var Providers = []Provider{ProviderGitHub, ProviderGitLab}
+
+func ParseProvider(s string) (Provider, error) {
+	p := Provider(strings.ToLower(s))
+	if !slices.Contains(Providers, p) {
+		return "", errors.Errorf("unsupported provider %q", s)
+	}
+	return p, nil
+}
+
+// FileName returns the conventional path of the workflow file
+// relative to the repository root.
+func (p Provider) FileName(name string) string {
+	switch p {
+	case ProviderGitHub:
+		return filepath.Join(".github", "workflows", name+".yml")
+	case ProviderGitLab:
+		return ".gitlab-ci.yml"
+	default:
+		return ""
+	}
+}
+
+// Step is a task run in the workflow.
+type Step struct {
+	// Task is a reference to the task accepted by "runme run".
+	Task string
+	// Dir is a working directory relative to the repository root.
+	Dir         string
+	Interpreter string
+	Lines       []string
+	Env         map[string]string
+}
+
+type Workflow struct {
+	Name  string
+	Steps []Step
+}
+
+type Options struct {
+	// Inline makes steps run scripts of tasks directly
+	// instead of invoking "runme run".
+	Inline bool
+}
+
+const header = "Code generated by runme ci generate. DO NOT EDIT."
+
+// NewSteps converts tasks into steps with working directories relative
+// to root. Composite tasks are replaced by their steps recursively and
+// their env is passed to the steps. Steps of parallel composite tasks
+// run sequentially too.
+func NewSteps(tasks, selected []project.Task, composites []project.CompositeTask, root string) ([]Step, error) {
+	byName := make(map[string]project.CompositeTask, len(composites))
+	for _, c := range composites {
+		byName[c.Name] = c
+	}
+
+	var (
+		result []Step
+		expand func(task project.Task, env map[string]string, depth int) error
+	)
+
+	expand = func(task project.Task, env map[string]string, depth int) error {
+		if depth > len(composites) {
+			return errors.Errorf("composite task %q references itself", task.CodeBlock.Name())
+		}
+
+		if task.Source == project.TaskSourceConfig {
+			c, ok := byName[task.CodeBlock.Name()]
+			if !ok {
+				return errors.Errorf("unknown composite task %q", task.CodeBlock.Name())
+			}
+
+			// Env of the inner composite task takes precedence
+			// like in nested "runme run".
+			merged := make(map[string]string, len(env)+len(c.Env))
+			for k, v := range env {
+				merged[k] = v
+			}
+			for k, v := range c.Env {
+				merged[k] = v
+			}
+
+			for _, ref := range c.Steps {
+				step, err := lookupTask(tasks, task.Root, ref)
+				if err != nil {
+					return errors.WithMessagef(err, "composite task %q", c.Name)
+				}
+				if err := expand(step, merged, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		step, err := newStep(tasks, task, root)
+		if err != nil {
+			return err
+		}
+		if len(env) > 0 {
+			step.Env = env
+		}
+		result = append(result, step)
+		return nil
+	}
+
+	for _, task := range selected {
+		if err := expand(task, nil, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// lookupTask finds a task by a reference like "build" or "README.md/build".
+func lookupTask(tasks []project.Task, root, ref string) (project.Task, error) {
+	file, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		file, name = "", ref
+	}
+
+	var matches []project.Task
+	for _, task := range tasks {
+		if task.Root != root || task.CodeBlock.Name() != name {
+			continue
+		}
+		if file != "" && !strings.Contains(task.RelDocumentPath, file) {
+			continue
+		}
+		matches = append(matches, task)
+	}
+
+	switch len(matches) {
+	case 0:
+		return project.Task{}, errors.Errorf("unknown task %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return project.Task{}, errors.Errorf("multiple tasks match %q", ref)
+	}
+}
+
+func newStep(all []project.Task, task project.Task, root string) (Step, error) {
+	dir, err := filepath.Rel(root, tasks.Dir(task))
+	if err != nil {
+		return Step{}, errors.WithStack(err)
+	}
+
+	// Qualify the name with the file name if it is ambiguous.
+	// "runme run" accepts only the first segment as the file.
+	name := task.QualifiedName()
+	ref := name
+	for _, other := range all {
+		if other.QualifiedName() != name || other.DocumentPath == task.DocumentPath {
+			continue
+		}
+		if strings.Contains(other.DocumentPath, filepath.Base(task.DocumentPath)) {
+			return Step{}, errors.Errorf("task %q in %s cannot be referenced unambiguously; rename it", name, task.RelDocumentPath)
+		}
+		ref = filepath.Base(task.DocumentPath) + "/" + name
+	}
+
+	return Step{
+		Task:        ref,
+		Dir:         filepath.ToSlash(dir),
+		Interpreter: tasks.Interpreter(task),
+		Lines:       task.CodeBlock.Lines(),
+	}, nil
+}
+
+// Generate writes the workflow in the format of the provider to w.
+func Generate(w io.Writer, provider Provider, wf Workflow, opts Options) error {
+	var doc any
+
+	switch provider {
+	case ProviderGitHub:
+		doc = newGitHubWorkflow(wf, opts)
+	case ProviderGitLab:
+		doc = newGitLabPipeline(wf, opts)
+	default:
+		return errors.Errorf("unsupported provider %q", provider)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", header)
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := encoder.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return errors.WithStack(err)
+}
+
+type githubWorkflow struct {
+	Name string               `yaml:"name"`
+	On   githubTriggers       `yaml:"on"`
+	Jobs map[string]githubJob `yaml:"jobs"`
+}
+
+type githubTriggers struct {
+	Push        struct{} `yaml:"push"`
+	PullRequest struct{} `yaml:"pull_request"`
+}
+
+type githubJob struct {
+	Name   string       `yaml:"name"`
+	RunsOn string       `yaml:"runs-on"`
+	Steps  []githubStep `yaml:"steps"`
+}
+
+type githubStep struct {
+	Name             string            `yaml:"name,omitempty"`
+	Uses             string            `yaml:"uses,omitempty"`
+	With             map[string]string `yaml:"with,omitempty"`
+	WorkingDirectory string            `yaml:"working-directory,omitempty"`
+	Shell            string            `yaml:"shell,omitempty"`
+	Run              string            `yaml:"run,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+}
+
+func newGitHubWorkflow(wf Workflow, opts Options) githubWorkflow {
+	steps := []githubStep{{Name: "Checkout", Uses: "actions/checkout@v4"}}
+
+	for _, step := range wf.Steps {
+		s := githubStep{Name: step.Task, Env: step.Env}
+		if opts.Inline {
+			s.WorkingDirectory = step.Dir
+			// GitHub runs custom shells with the path to a script file.
+			s.Shell = step.Interpreter + " {0}"
+			s.Run = githubExpressionEscape(strings.Join(step.Lines, "\n"))
+		} else {
+			s.Uses = "stateful/runme-action@v2"
+			s.With = map[string]string{"workflows": step.Task}
+		}
+		steps = append(steps, s)
+	}
+
+	return githubWorkflow{
+		Name: wf.Name,
+		Jobs: map[string]githubJob{
+			jobName(wf.Name): {
+				Name:   wf.Name,
+				RunsOn: "ubuntu-latest",
+				Steps:  steps,
+			},
+		},
+	}
+}
+
+// githubExpressionEscape prevents GitHub from evaluating
+// "${{ }}" which might appear in scripts.
+func githubExpressionEscape(s string) string {
+	return strings.ReplaceAll(s, "${{", "${{ '${{' }}")
+}
+
+type gitlabJob struct {
+	Image        string   `yaml:"image,omitempty"`
+	BeforeScript []string `yaml:"before_script,omitempty"`
+	Script       []string `yaml:"script"`
+}
+
+func newGitLabPipeline(wf Workflow, opts Options) map[string]gitlabJob {
+	job := gitlabJob{}
+
+	if !opts.Inline {
+		job.Image = "node:lts"
+		job.BeforeScript = []string{"npm install -g runme"}
+	}
+
+	for _, step := range wf.Steps {
+		job.Script = append(job.Script, gitlabScript(step, opts))
+	}
+
+	return map[string]gitlabJob{jobName(wf.Name): job}
+}
+
+// gitlabScript returns a script running the step in a subshell,
+// so the directory and env do not leak to other steps.
+func gitlabScript(step Step, opts Options) string {
+	var env []string
+	for _, key := range sortedKeys(step.Env) {
+		env = append(env, key+"="+shell.Quote(step.Env[key]))
+	}
+
+	if !opts.Inline {
+		return strings.Join(append(env, "runme", "run", shell.Quote(step.Task)), " ")
+	}
+
+	delimiter := "RUNME_EOF"
+	for slices.Contains(step.Lines, delimiter) {
+		delimiter += "_"
+	}
+
+	command := strings.Join(append(env, step.Interpreter), " ")
+
+	lines := []string{
+		fmt.Sprintf("(cd %s && %s <<'%s'", shell.Quote(step.Dir), command, delimiter),
+	}
+	lines = append(lines, step.Lines...)
+	lines = append(lines, delimiter, ")")
+	return strings.Join(lines, "\n")
+}
+
+func jobName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			_, _ = b.WriteRune(r)
+		} else {
+			_, _ = b.WriteRune('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "runme"
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
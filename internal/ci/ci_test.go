@@ -0,0 +1,192 @@
+package ci
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+var testWorkflow = Workflow{
+	Name: "ci",
+	Steps: []Step{
+		{
+			Task:        "build",
+			Dir:         ".",
+			Interpreter: "bash",
+			Lines:       []string{`echo "${{ github.sha }}"`},
+			Env:         map[string]string{"CI": "true"},
+		},
+		{
+			Task:        "README.md/test",
+			Dir:         "web app",
+			Interpreter: "python3",
+			Lines:       []string{"print('RUNME_EOF')", "RUNME_EOF"},
+		},
+	},
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("GitHub", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Generate(&buf, ProviderGitHub, testWorkflow, Options{}))
+		assert.Equal(t, `# Code generated by runme ci generate. DO NOT EDIT.
+
+name: ci
+"on":
+  push: {}
+  pull_request: {}
+jobs:
+  ci:
+    name: ci
+    runs-on: ubuntu-latest
+    steps:
+      - name: Checkout
+        uses: actions/checkout@v4
+      - name: build
+        uses: stateful/runme-action@v2
+        with:
+          workflows: build
+        env:
+          CI: "true"
+      - name: README.md/test
+        uses: stateful/runme-action@v2
+        with:
+          workflows: README.md/test
+`, buf.String())
+	})
+
+	t.Run("GitHubInline", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Generate(&buf, ProviderGitHub, testWorkflow, Options{Inline: true}))
+		assert.Equal(t, `# Code generated by runme ci generate. DO NOT EDIT.
+
+name: ci
+"on":
+  push: {}
+  pull_request: {}
+jobs:
+  ci:
+    name: ci
+    runs-on: ubuntu-latest
+    steps:
+      - name: Checkout
+        uses: actions/checkout@v4
+      - name: build
+        working-directory: .
+        shell: bash {0}
+        run: echo "${{ '${{' }} github.sha }}"
+        env:
+          CI: "true"
+      - name: README.md/test
+        working-directory: web app
+        shell: python3 {0}
+        run: |-
+          print('RUNME_EOF')
+          RUNME_EOF
+`, buf.String())
+	})
+
+	t.Run("GitLab", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Generate(&buf, ProviderGitLab, testWorkflow, Options{}))
+		assert.Equal(t, `# Code generated by runme ci generate. DO NOT EDIT.
+
+ci:
+  image: node:lts
+  before_script:
+    - npm install -g runme
+  script:
+    - CI=true runme run build
+    - runme run README.md/test
+`, buf.String())
+	})
+
+	t.Run("GitLabInline", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Generate(&buf, ProviderGitLab, testWorkflow, Options{Inline: true}))
+		assert.Equal(t, `# Code generated by runme ci generate. DO NOT EDIT.
+
+ci:
+  script:
+    - |-
+      (cd . && CI=true bash <<'RUNME_EOF'
+      echo "${{ github.sha }}"
+      RUNME_EOF
+      )
+    - |-
+      (cd 'web app' && python3 <<'RUNME_EOF_'
+      print('RUNME_EOF')
+      RUNME_EOF
+      RUNME_EOF_
+      )
+`, buf.String())
+	})
+}
+
+func TestNewSteps(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":        "```sh {\"name\":\"build\"}\necho build\n```\n\n```js {\"name\":\"test\",\"cwd\":\"web\"}\nconsole.log(1)\n```\n",
+		"docs/GUIDE.md":    "```sh {\"name\":\"test\"}\necho docs\n```\n",
+		"infra/DEPLOY.md":  "---\ncwd: ..\n---\n\n```bash {\"name\":\"deploy\"}\necho deploy\n```\n",
+		"web/package.json": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	composites := []project.CompositeTask{
+		{Name: "release", Steps: []string{"build", "ship"}, Env: map[string]string{"STAGE": "prod", "CI": "true"}},
+		{Name: "ship", Steps: []string{"deploy"}, Env: map[string]string{"STAGE": "ship"}},
+	}
+
+	proj, err := project.NewDirProject(dir, project.WithCompositeTasks("runme.yaml", composites...))
+	require.NoError(t, err)
+
+	tasks, err := project.LoadTasks(context.Background(), proj)
+	require.NoError(t, err)
+
+	find := func(name, file string) project.Task {
+		for _, task := range tasks {
+			if task.CodeBlock.Name() == name && (file == "" || task.RelDocumentPath == file) {
+				return task
+			}
+		}
+		t.Fatalf("task %q not found", name)
+		return project.Task{}
+	}
+
+	steps, err := NewSteps(tasks, []project.Task{find("test", "README.md"), find("release", "")}, composites, proj.Root())
+	require.NoError(t, err)
+	assert.Equal(t, []Step{
+		{Task: "README.md/test", Dir: "web", Interpreter: "node", Lines: []string{"console.log(1)"}},
+		{Task: "build", Dir: ".", Interpreter: "bash", Lines: []string{"echo build"}, Env: map[string]string{"STAGE": "prod", "CI": "true"}},
+		{Task: "deploy", Dir: ".", Interpreter: "bash", Lines: []string{"echo deploy"}, Env: map[string]string{"STAGE": "ship", "CI": "true"}},
+	}, steps)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "README.md"), []byte("```sh {\"name\":\"test\"}\necho docs\n```\n"), 0o600))
+	tasks, err = project.LoadTasks(context.Background(), proj)
+	require.NoError(t, err)
+	_, err = NewSteps(tasks, []project.Task{find("test", "README.md")}, nil, proj.Root())
+	require.ErrorContains(t, err, `task "test" in README.md cannot be referenced unambiguously`)
+}
+
+func TestParseProvider(t *testing.T) {
+	p, err := ParseProvider("GitLab")
+	require.NoError(t, err)
+	assert.Equal(t, ProviderGitLab, p)
+	assert.Equal(t, ".gitlab-ci.yml", p.FileName("ci"))
+	assert.Equal(t, filepath.Join(".github", "workflows", "ci.yml"), ProviderGitHub.FileName("ci"))
+
+	_, err = ParseProvider("jenkins")
+	require.Error(t, err)
+}
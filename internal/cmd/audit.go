@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/audit"
+)
+
+func auditCmd() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "audit",
+		Short: "Inspect audit logs of runme servers",
+	}
+
+	cmd.AddCommand(auditVerifyCmd())
+
+	return &cmd
+}
+
+func auditVerifyCmd() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "verify [file]",
+		Short: "Verify the hash chain of an audit log",
+		Long: `Verify that records of an audit log form an unbroken hash chain. Modified,
+removed, or reordered records are reported with the line number of the first
+record breaking the chain.
+
+The audit log is written by the server when "server.audit.enabled" is set in runme.yaml.
+By default, the log in the user config directory is verified.`,
+		Example: `runme audit verify
+runme audit verify /var/log/runme/audit.jsonl`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := filepath.Join(GetUserConfigHome(), "audit", "audit.jsonl")
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer f.Close()
+
+			n, err := audit.Verify(f)
+			if err != nil {
+				return errors.Wrapf(err, "audit log %s is corrupted after %d valid records", path, n)
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Verified %d records in %s\n", n, path)
+			return nil
+		},
+	}
+
+	return &cmd
+}
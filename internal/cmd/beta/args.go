@@ -19,7 +19,9 @@ func createProjectFilterFromPatterns(patterns []string) (project.Filter, error)
 
 	return func(t project.Task) (bool, error) {
 		for _, g := range globs {
-			if g.Match(t.CodeBlock.Name()) {
+			// Tasks from workspace roots can be also matched
+			// by their qualified names like "infra:deploy".
+			if g.Match(t.CodeBlock.Name()) || g.Match(t.QualifiedName()) {
 				return true, nil
 			}
 		}
@@ -39,12 +39,18 @@ List all blocks from the "setup" and "teardown" tags:
 			return autoconfig.Invoke(
 				func(
 					proj *project.Project,
+					ws *project.Workspace,
 					filters []project.Filter,
 					logger *zap.Logger,
 				) error {
 					defer logger.Sync()
 
-					tasks, err := project.LoadTasks(cmd.Context(), proj)
+					var loader project.Loader = proj
+					if ws != nil {
+						loader = ws
+					}
+
+					tasks, err := project.LoadTasks(cmd.Context(), loader)
 					if err != nil {
 						return err
 					}
@@ -108,7 +114,7 @@ func renderTasksAsTableForCmd(cmd *cobra.Command, tasks []project.Task) error {
 			named = "No"
 		}
 
-		name := task.CodeBlock.Name()
+		name := task.QualifiedName()
 		if !task.CodeBlock.ExcludeFromRunAll() {
 			name = name + "*"
 		}
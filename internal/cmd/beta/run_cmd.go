@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"os"
+	"os/exec"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/pkg/errors"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/stateful/runme/v3/internal/command"
 	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/internal/runevents"
 	rcontext "github.com/stateful/runme/v3/internal/runner/context"
 	"github.com/stateful/runme/v3/internal/runnerv2client"
 	"github.com/stateful/runme/v3/internal/session"
@@ -21,7 +24,10 @@ import (
 )
 
 func runCmd(*commonFlags) *cobra.Command {
-	var remote bool
+	var (
+		remote bool
+		output string
+	)
 
 	cmd := cobra.Command{
 		Use:     "run [command1 command2 ...]",
@@ -47,10 +53,29 @@ Run all blocks from the "setup" and "teardown" tags:
 					filters []project.Filter,
 					logger *zap.Logger,
 					proj *project.Project,
+					ws *project.Workspace,
 				) error {
 					defer logger.Sync()
 
-					tasks, err := project.LoadTasks(cmd.Context(), proj)
+					outputFormat, err := runevents.ParseFormat(output)
+					if err != nil {
+						return err
+					}
+
+					var emitter runevents.Emitter
+					if outputFormat != runevents.FormatText {
+						emitter, err = runevents.New(cmd.OutOrStdout(), outputFormat)
+						if err != nil {
+							return err
+						}
+					}
+
+					var loader project.Loader = proj
+					if ws != nil {
+						loader = ws
+					}
+
+					tasks, err := project.LoadTasks(cmd.Context(), loader)
 					if err != nil {
 						return err
 					}
@@ -76,51 +101,93 @@ Run all blocks from the "setup" and "teardown" tags:
 
 					ctx := cmd.Context()
 
+					if emitter != nil {
+						emitter.Plan(tasks)
+					}
+
+					// Tasks from workspace roots are run in sessions
+					// created for their roots' projects, for example,
+					// to load their env files.
+					projectOf := func(t project.Task) *project.Project {
+						if ws != nil {
+							if rootProj, ok := ws.Project(t.Root); ok {
+								return rootProj
+							}
+						}
+						return proj
+					}
+
 					if remote {
 						client, err := clientFactory()
 						if err != nil {
 							return err
 						}
 
-						sessionResp, err := client.CreateSession(
-							ctx,
-							&runnerv2.CreateSessionRequest{
-								Project: &runnerv2.Project{
-									Root:         proj.Root(),
-									EnvLoadOrder: proj.EnvFilesReadOrder(),
-								},
-							},
-						)
-						if err != nil {
-							return errors.WithMessage(err, "failed to create session")
-						}
+						sessionIDs := make(map[*project.Project]string)
 
 						for _, t := range tasks {
-							err := runCodeBlockWithClient(
-								ctx,
-								cmd,
-								client,
-								t.CodeBlock,
-								sessionResp.GetSession().GetId(),
-							)
+							taskProj := projectOf(t)
+
+							sessionID, ok := sessionIDs[taskProj]
+							if !ok {
+								sessionResp, err := client.CreateSession(
+									ctx,
+									&runnerv2.CreateSessionRequest{
+										Project: &runnerv2.Project{
+											Root:         taskProj.Root(),
+											EnvLoadOrder: taskProj.EnvFilesReadOrder(),
+										},
+									},
+								)
+								if err != nil {
+									return errors.WithMessage(err, "failed to create session")
+								}
+								sessionID = sessionResp.GetSession().GetId()
+								sessionIDs[taskProj] = sessionID
+							}
+
+							// Env of remote sessions is not reported.
+							err := runTaskWithEmitter(emitter, t, nil, func(stdout, stderr io.Writer) error {
+								return runCodeBlockWithClient(
+									ctx,
+									cmd,
+									client,
+									t,
+									sessionID,
+									stdout,
+									stderr,
+								)
+							})
 							if err != nil {
 								return err
 							}
 						}
 					} else {
-						session, err := session.New(
-							session.WithOwl(false),
-							session.WithProject(proj),
-							session.WithSeedEnv(nil),
-						)
-						if err != nil {
-							return err
-						}
-
-						options := createCommandOptions(cmd, session)
+						sessions := make(map[*project.Project]*session.Session)
 
 						for _, t := range tasks {
-							err := runCodeBlock(ctx, t.CodeBlock, cmdFactory, options)
+							taskProj := projectOf(t)
+
+							sess, ok := sessions[taskProj]
+							if !ok {
+								sess, err = session.New(
+									session.WithOwl(false),
+									session.WithProject(taskProj),
+									session.WithSeedEnv(nil),
+								)
+								if err != nil {
+									return err
+								}
+								sessions[taskProj] = sess
+							}
+
+							err := runTaskWithEmitter(emitter, t, sess.GetAllEnv, func(stdout, stderr io.Writer) error {
+								options := createCommandOptions(cmd, sess)
+								if stdout != nil {
+									options.Stdout, options.Stderr = stdout, stderr
+								}
+								return runCodeBlock(ctx, t.CodeBlock, cmdFactory, options)
+							})
 							if err != nil {
 								return err
 							}
@@ -134,10 +201,55 @@ Run all blocks from the "setup" and "teardown" tags:
 	}
 
 	cmd.Flags().BoolVarP(&remote, "remote", "r", false, "Run commands on a remote server.")
+	cmd.Flags().StringVar(&output, "output", string(runevents.FormatText), "Output format; one of: text, jsonl, github.")
 
 	return &cmd
 }
 
+// runTaskWithEmitter calls run with writers for stdout and stderr of the task.
+// If emitter is not nil, they report the task's output to the emitter
+// along with its start, env changes, and exit code.
+func runTaskWithEmitter(
+	emitter runevents.Emitter,
+	task project.Task,
+	getEnv func() []string,
+	run func(stdout, stderr io.Writer) error,
+) error {
+	if emitter == nil {
+		return run(nil, nil)
+	}
+
+	var before []string
+	if getEnv != nil {
+		before = getEnv()
+	}
+
+	emitter.TaskStart(task)
+	start := time.Now()
+
+	err := run(emitter.Stdout(task), emitter.Stderr(task))
+
+	exitCode, runErr := 0, err
+	var (
+		execErr   *exec.ExitError
+		clientErr *runnerv2client.ExitError
+	)
+	switch {
+	case errors.As(err, &execErr):
+		exitCode, runErr = execErr.ExitCode(), nil
+	case errors.As(err, &clientErr):
+		exitCode, runErr = clientErr.Code, nil
+	}
+
+	if getEnv != nil {
+		emitter.EnvChanged(task, before, getEnv())
+	}
+
+	emitter.TaskEnd(task, exitCode, time.Since(start), runErr)
+
+	return err
+}
+
 func createCommandOptions(
 	cmd *cobra.Command,
 	sess *session.Session,
@@ -199,21 +311,30 @@ func runCodeBlockWithClient(
 	ctx context.Context,
 	cobraCommand *cobra.Command,
 	client *runnerv2client.Client,
-	block *document.CodeBlock,
+	task project.Task,
 	sessionID string,
+	stdout io.Writer,
+	stderr io.Writer,
 ) error {
-	cfg, err := createProgramConfigFromCodeBlock(block, command.WithInteractiveLegacy())
+	cfg, err := createProgramConfigFromCodeBlock(task.CodeBlock, command.WithInteractiveLegacy())
 	if err != nil {
 		return err
 	}
 
+	cfg.KnownId = task.CodeBlock.ID()
+	cfg.KnownName = task.CodeBlock.Name()
+
 	opts := runnerv2client.ExecuteProgramOptions{
+		DocumentPath:     task.DocumentPath,
 		SessionID:        sessionID,
 		Stdin:            io.NopCloser(cobraCommand.InOrStdin()),
 		Stdout:           cobraCommand.OutOrStdout(),
 		Stderr:           cobraCommand.ErrOrStderr(),
 		StoreStdoutInEnv: true,
 	}
+	if stdout != nil {
+		opts.Stdout, opts.Stderr = stdout, stderr
+	}
 
 	if stdin, ok := cobraCommand.InOrStdin().(*os.File); ok {
 		size, err := pty.GetsizeFull(stdin)
@@ -2,6 +2,7 @@ package server
 
 import (
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -9,15 +10,25 @@ import (
 
 	"github.com/stateful/runme/v3/internal/config"
 	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/internal/daemon"
 	"github.com/stateful/runme/v3/internal/server"
 	"github.com/stateful/runme/v3/internal/telemetry"
 )
 
 func serverStartCmd() *cobra.Command {
+	var (
+		daemonMode  bool
+		idleTimeout time.Duration
+	)
+
 	cmd := cobra.Command{
 		Use:   "start",
 		Short: "Start a server.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if daemonMode {
+				return daemon.New(daemon.WithIdleTimeout(idleTimeout)).Start(cmd.Context())
+			}
+
 			return autoconfig.Invoke(
 				func(
 					cfg *config.Config,
@@ -29,9 +40,9 @@ func serverStartCmd() *cobra.Command {
 					_ = telemetry.ReportUnlessNoTracking(logger)
 
 					// When using a unix socket, we want to create a file with server's PID.
-					if path := pidFileNameFromAddr(cfg.Server.Address); path != "" {
+					if path := daemon.PIDFileFromAddress(cfg.Server.Address); path != "" {
 						logger.Debug("creating PID file", zap.String("path", path))
-						if err := createFileWithPID(path); err != nil {
+						if err := daemon.WritePIDFile(path); err != nil {
 							return errors.WithStack(err)
 						}
 						defer os.Remove(cfg.Server.Address)
@@ -43,5 +54,8 @@ func serverStartCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().BoolVar(&daemonMode, "daemon", false, "Start the daemon used by \"runme run --use-daemon\" in the background")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", daemon.DefaultIdleTimeout, "Shut down the daemon after the duration without calls")
+
 	return &cmd
 }
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/stateful/runme/v3/internal/config"
+	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/internal/daemon"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func serverStatusCmd() *cobra.Command {
+	var daemonMode bool
+
+	cmd := cobra.Command{
+		Use:   "status",
+		Short: "Show status of a server.",
+		Long: `Show status of a server including the number of sessions and executions.
+
+With --daemon, it shows status of the daemon started by "runme run --use-daemon".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if daemonMode {
+				status, err := daemon.New().Status(cmd.Context())
+				if errors.Is(err, daemon.ErrNotRunning) {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Daemon is not running.")
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				return printServerStatus(cmd.OutOrStdout(), status.Address, status.PID, status.GetServerStatusResponse)
+			}
+
+			return autoconfig.Invoke(
+				func(
+					cfg *config.Config,
+					clientFactory autoconfig.ClientFactory,
+					logger *zap.Logger,
+				) error {
+					defer logger.Sync()
+
+					client, err := clientFactory()
+					if err != nil {
+						return err
+					}
+					if client == nil {
+						return errors.New("server is not configured")
+					}
+
+					status, err := client.GetServerStatus(cmd.Context(), &runnerv2.GetServerStatusRequest{})
+					if err != nil {
+						return errors.Wrap(err, "failed to get server status")
+					}
+
+					return printServerStatus(cmd.OutOrStdout(), cfg.Server.Address, 0, status)
+				},
+			)
+		},
+	}
+
+	cmd.Flags().BoolVar(&daemonMode, "daemon", false, "Show status of the daemon")
+
+	return &cmd
+}
+
+func printServerStatus(out io.Writer, addr string, pid int, status *runnerv2.GetServerStatusResponse) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Address:\t%s\n", addr)
+	if pid > 0 {
+		_, _ = fmt.Fprintf(w, "PID:\t%d\n", pid)
+	}
+	_, _ = fmt.Fprintf(w, "Version:\t%s\n", status.Version)
+	_, _ = fmt.Fprintf(w, "Sessions:\t%d\n", status.Sessions)
+	_, _ = fmt.Fprintf(w, "Active executions:\t%d\n", status.ActiveExecutions)
+	_, _ = fmt.Fprintf(w, "Queued executions:\t%d\n", status.QueuedExecutions)
+	return errors.WithStack(w.Flush())
+}
@@ -10,9 +10,12 @@ import (
 
 	"github.com/stateful/runme/v3/internal/config"
 	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/internal/daemon"
 )
 
 func serverStopCmd() *cobra.Command {
+	var daemonMode bool
+
 	cmd := cobra.Command{
 		Use:   "stop",
 		Short: "Stop a server.",
@@ -25,9 +28,13 @@ func serverStopCmd() *cobra.Command {
 					logger = logger.Named("cmd:beta_server_stop")
 					defer logger.Sync()
 
+					if daemonMode {
+						return daemon.New(daemon.WithLogger(logger)).Stop(cmd.Context())
+					}
+
 					logger.Debug("stopping the server by looking for runme.pid")
 
-					path := pidFileNameFromAddr(cfg.Server.Address)
+					path := daemon.PIDFileFromAddress(cfg.Server.Address)
 					if path == "" {
 						return errors.New("server address is not a unix socket")
 					}
@@ -53,5 +60,7 @@ func serverStopCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().BoolVar(&daemonMode, "daemon", false, "Stop the daemon started by \"runme run --use-daemon\"")
+
 	return &cmd
 }
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/ci"
+	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+func ciCmd() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "ci",
+		Short: "Generate CI workflows from tasks",
+	}
+
+	cmd.AddCommand(ciGenerateCmd())
+
+	return &cmd
+}
+
+func ciGenerateCmd() *cobra.Command {
+	var (
+		providerName string
+		tags         []string
+		name         string
+		inline       bool
+		output       string
+		check        bool
+	)
+
+	cmd := cobra.Command{
+		Use:   "generate [task...]",
+		Short: "Generate a GitHub Actions or GitLab CI workflow",
+		Long: `Generate a GitHub Actions or GitLab CI workflow which runs tasks tagged with --tag
+or the tasks passed as arguments, in order of the project.
+
+Composite tasks from runme.yaml are expanded into their steps. Filters from the
+"project.filters" section of runme.yaml apply to the selected tasks. By default,
+each step invokes "runme run <task>"; with --inline, scripts of tasks are inlined
+and run with their interpreters in their working directories.
+
+With --check, the generated file is compared with the existing one and the command fails
+if they differ. It is useful in CI to make sure the workflow is up to date.`,
+		Example: `runme ci generate --provider github --tag ci
+runme ci generate --provider gitlab --inline --output .gitlab-ci.yml
+runme ci generate --provider github build test --check`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			provider, err := ci.ParseProvider(providerName)
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = "runme"
+				if len(tags) > 0 && len(args) == 0 {
+					name = tags[0]
+				}
+			}
+
+			proj, err := getProject()
+			if err != nil {
+				return err
+			}
+
+			// The workflow is checked in the repository root by default.
+			path := output
+			if check && output == "" {
+				output = provider.FileName(name)
+				path = filepath.Join(proj.Root(), output)
+			} else if output != "" && !filepath.IsAbs(path) {
+				path = filepath.Join(getCwd(), path)
+			}
+
+			projTasks, err := getProjectTasks(cmd)
+			if err != nil {
+				return err
+			}
+
+			selected, err := selectTasks(projTasks, args, tags)
+			if err != nil {
+				return err
+			}
+
+			selected, err = filterCITasks(proj.Root(), selected)
+			if err != nil {
+				return err
+			}
+
+			if len(selected) == 0 {
+				return errors.New("no tasks to run in the workflow")
+			}
+
+			steps, err := ci.NewSteps(projTasks, selected, proj.CompositeTasks(), proj.Root())
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			wf := ci.Workflow{Name: name, Steps: steps}
+			if err := ci.Generate(&buf, provider, wf, ci.Options{Inline: inline}); err != nil {
+				return errors.Wrapf(err, "failed to generate %s workflow", provider)
+			}
+
+			switch {
+			case check:
+				current, err := os.ReadFile(path)
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return errors.WithStack(err)
+				}
+				if !bytes.Equal(current, buf.Bytes()) {
+					return errors.Errorf("%s is out of date; regenerate it with \"runme ci generate --output %s\"", output, output)
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s is up to date\n", output)
+				return nil
+			case output != "":
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					return errors.WithStack(err)
+				}
+				return errors.WithStack(os.WriteFile(path, buf.Bytes(), 0o644))
+			default:
+				_, err := cmd.OutOrStdout().Write(buf.Bytes())
+				return errors.WithStack(err)
+			}
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	cmd.Flags().StringVar(&providerName, "provider", string(ci.ProviderGitHub), "CI provider; one of: github, gitlab.")
+	cmd.Flags().StringSliceVarP(&tags, "tag", "t", []string{"ci"}, "Select tasks with the tags. Ignored if tasks are passed as arguments.")
+	cmd.Flags().StringVar(&name, "name", "", "Name of the workflow. Defaults to the first tag.")
+	cmd.Flags().BoolVar(&inline, "inline", false, "Inline scripts of tasks instead of invoking \"runme run\".")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Path to the workflow file. If empty, the result is written to stdout.")
+	cmd.Flags().BoolVar(&check, "check", false, "Fail if the workflow file is out of date instead of writing it.")
+
+	return &cmd
+}
+
+// filterCITasks applies filters from runme.yaml in dir to tasks.
+func filterCITasks(dir string, tasks []project.Task) ([]project.Task, error) {
+	_, cfg, err := getProjectConfig(dir)
+	if err != nil || cfg == nil {
+		return tasks, err
+	}
+
+	filters, err := autoconfig.NewProjectFilters(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return project.FilterTasksByFn(tasks, filters...)
+}
@@ -11,8 +11,12 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 
+	"github.com/stateful/runme/v3/internal/config"
+	"github.com/stateful/runme/v3/internal/config/autoconfig"
+	"github.com/stateful/runme/v3/internal/daemon"
 	"github.com/stateful/runme/v3/internal/runner/client"
 	"github.com/stateful/runme/v3/internal/tui"
 	"github.com/stateful/runme/v3/internal/tui/prompt"
@@ -67,6 +71,16 @@ func getProjectWithEnvDir(enabled bool) (*project.Project, error) {
 			projDir = "."
 		}
 
+		// Remote sources are fetched into a cache first.
+		remote := project.IsRemoteSource(projDir)
+		if remote {
+			dir, err := project.FetchSource(context.Background(), projDir)
+			if err != nil {
+				return nil, err
+			}
+			projDir = dir
+		}
+
 		opts = append(
 			opts,
 			project.WithIgnoreFilePatterns(fProjectIgnorePatterns...),
@@ -74,7 +88,7 @@ func getProjectWithEnvDir(enabled bool) (*project.Project, error) {
 		)
 
 		// By default, commands try to find repo upward unless project is non-empty.
-		if fProject == "" {
+		if fProject == "" && !remote {
 			opts = append(
 				opts,
 				project.WithFindRepoUpward(),
@@ -85,7 +99,39 @@ func getProjectWithEnvDir(enabled bool) (*project.Project, error) {
 			opts = append(opts, project.WithEnvFilesReadOrder(fEnvOrder))
 		}
 
-		var err error
+		if fTaskProviders {
+			opts = append(opts, project.WithTaskProviders(project.DefaultTaskProviders()...))
+		}
+
+		if fProjectCache {
+			// The cache is an optimization; without it, all files are parsed.
+			if dir, err := project.DefaultTaskIndexDir(); err == nil {
+				opts = append(opts, project.WithTaskIndex(dir))
+			}
+		}
+
+		source, tasks, err := getCompositeTasks(projDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(tasks) > 0 {
+			opts = append(opts, project.WithCompositeTasks(source, tasks...))
+
+			// Steps are run from the directory of runme.yaml, so
+			// the project is passed only if it was set explicitly.
+			var args []string
+			if fProject != "" {
+				absDir, err := filepath.Abs(projDir)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				args = append(args, "--project="+absDir)
+			}
+			if runme, ok := runmeCommand(args...); ok {
+				opts = append(opts, project.WithRunmeCommand(runme[0], runme[1:]...))
+			}
+		}
+
 		proj, err = project.NewDirProject(projDir, opts...)
 		if err != nil {
 			return nil, err
@@ -95,6 +141,137 @@ func getProjectWithEnvDir(enabled bool) (*project.Project, error) {
 	return proj, nil
 }
 
+// forwardedFlags are flags which composite tasks pass
+// to "runme run" running their steps, if they were set.
+var forwardedFlags = []string{
+	"cache",
+	"direnv",
+	"env-order",
+	"git-ignore",
+	"ignore-pattern",
+	"insecure",
+	"load-env",
+	"server",
+	"task-providers",
+	"tls",
+	"use-daemon",
+}
+
+// runmeCommand returns the current executable followed by args and
+// forwarded flags of the executed command. It returns false if
+// the executable cannot be determined.
+func runmeCommand(args ...string) ([]string, bool) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, false
+	}
+
+	result := append([]string{exe}, args...)
+
+	if fFlags == nil {
+		return result, true
+	}
+
+	for _, name := range forwardedFlags {
+		flag := fFlags.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		if values, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, v := range values.GetSlice() {
+				result = append(result, "--"+name+"="+v)
+			}
+			continue
+		}
+		result = append(result, "--"+name+"="+flag.Value.String())
+	}
+
+	return result, true
+}
+
+// getCompositeTasks returns tasks defined in the "tasks" section
+// of runme.yaml in dir along with the path to the file, if exists.
+func getCompositeTasks(dir string) (string, []project.CompositeTask, error) {
+	path, cfg, err := getProjectConfig(dir)
+	if err != nil || cfg == nil {
+		return "", nil, err
+	}
+	return path, autoconfig.NewCompositeTasks(cfg.Tasks), nil
+}
+
+// getProjectConfig parses runme.yaml in dir. It returns nil
+// if the file does not exist.
+func getProjectConfig(dir string) (string, *config.Config, error) {
+	path, err := filepath.Abs(filepath.Join(dir, "runme.yaml"))
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	cfg, err := config.ParseYAML(data)
+	if err != nil {
+		return "", nil, errors.WithMessagef(err, "failed to parse %s", path)
+	}
+
+	return path, cfg, nil
+}
+
+// getWorkspace returns a workspace configured in the file passed
+// via --workspace. If the flag is not set, it returns nil.
+func getWorkspace() (*project.Workspace, error) {
+	if fWorkspace == "" {
+		return nil, nil
+	}
+
+	path := fWorkspace
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(fChdir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cfg, err := config.ParseYAML(data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to parse %s", path)
+	}
+	if cfg.Workspace == nil {
+		return nil, errors.Errorf("no workspace roots in %s", path)
+	}
+
+	logger, err := getLogger(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []project.ProjectOption
+	if runme, ok := runmeCommand(); ok {
+		opts = append(opts, project.WithRunmeCommand(runme[0], runme[1:]...))
+	}
+
+	return autoconfig.NewWorkspace(cfg.Workspace, filepath.Dir(path), logger, opts...)
+}
+
+func getProjectOrWorkspace() (project.Loader, error) {
+	ws, err := getWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	if ws != nil {
+		return ws, nil
+	}
+	return getProject()
+}
+
 func getProjectFiles(cmd *cobra.Command) ([]string, error) {
 	proj, err := getProject()
 	if err != nil {
@@ -110,7 +287,7 @@ func getProjectFiles(cmd *cobra.Command) ([]string, error) {
 }
 
 func getProjectTasks(cmd *cobra.Command) ([]project.Task, error) {
-	proj, err := getProject()
+	proj, err := getProjectOrWorkspace()
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +301,7 @@ func getProjectTasks(cmd *cobra.Command) ([]project.Task, error) {
 }
 
 func getAllProjectTasks(cmd *cobra.Command) ([]project.Task, error) {
-	proj, err := getProject()
+	proj, err := getProjectOrWorkspace()
 	if err != nil {
 		return nil, err
 	}
@@ -265,6 +442,7 @@ func setRunnerFlags(cmd *cobra.Command, serverAddr *string) func() ([]client.Run
 		SessionStrategy           string
 		TLSDir                    string
 		EnableBackgroundProcesses bool
+		UseDaemon                 bool
 	)
 
 	cmd.Flags().StringVarP(serverAddr, "server", "s", os.Getenv("RUNME_SERVER_ADDR"), "Server address to connect runner to")
@@ -275,6 +453,8 @@ func setRunnerFlags(cmd *cobra.Command, serverAddr *string) func() ([]client.Run
 
 	cmd.Flags().BoolVar(&EnableBackgroundProcesses, "background", false, "Enable running background blocks as background processes")
 
+	cmd.Flags().BoolVar(&UseDaemon, "use-daemon", os.Getenv("RUNME_USE_DAEMON") == "true", "Run commands in a background daemon started on demand, unless --server is set")
+
 	cmd.Flags().StringVar(&SessionStrategy, "session-strategy", func() string {
 		if val, ok := os.LookupEnv("RUNME_SESSION_STRATEGY"); ok {
 			return val
@@ -324,6 +504,16 @@ func setRunnerFlags(cmd *cobra.Command, serverAddr *string) func() ([]client.Run
 			client.WithEnvStoreType(runnerv1.SessionEnvStoreType_SESSION_ENV_STORE_TYPE_UNSPECIFIED),
 		}
 
+		if UseDaemon && *serverAddr == "" {
+			addr, err := daemon.New().Ensure(cmd.Context())
+			if err != nil {
+				return nil, err
+			}
+			*serverAddr = addr
+			// The daemon socket is accessible only by its owner.
+			runOpts = append(runOpts, client.WithInsecure(true))
+		}
+
 		switch strings.ToLower(SessionStrategy) {
 		case "manual":
 			runOpts = append(runOpts, client.WithSessionStrategy(runnerv1.SessionStrategy_SESSION_STRATEGY_UNSPECIFIED))
@@ -457,3 +647,37 @@ func captureVariable(cmd *cobra.Command, ip *prompt.InputParams) (string, error)
 
 	return val, nil
 }
+
+// selectTasks returns tasks with the names, if any, or tasks matching
+// the tags, similarly to "runme run --tag".
+func selectTasks(tasks []project.Task, names, tags []string) ([]project.Task, error) {
+	var result []project.Task
+
+	if len(names) > 0 {
+		for _, name := range names {
+			queryFile, queryName, err := splitRunArgument(name)
+			if err != nil {
+				return nil, err
+			}
+			matches, err := filterTasksByFileAndTaskName(tasks, queryFile, queryName)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) > 1 {
+				return nil, errors.Errorf("multiple matches found for %q; please use a file specifier in the form \"{file}%s{task-name}\"", name, fileNameSeparator)
+			}
+			result = append(result, matches[0])
+		}
+		return result, nil
+	}
+
+	for _, task := range tasks {
+		block := task.CodeBlock
+		if block.ExcludeFromRunAll() || !matchesTags(block, tags) {
+			continue
+		}
+		result = append(result, task)
+	}
+
+	return result, nil
+}
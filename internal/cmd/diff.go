@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/notebook"
+	"github.com/stateful/runme/v3/pkg/document/editor"
+)
+
+func diffCmd() *cobra.Command {
+	var ignoreOutputs bool
+
+	cmd := cobra.Command{
+		Use:   "diff <old> <new>",
+		Short: "Compare two Markdown files cell by cell",
+		Long: `Compare two Markdown files cell by cell.
+
+Cells are matched by their "id" attribute, then by name, and finally by content.
+Values, attributes, and outputs are compared separately, and moved cells are reported
+as such instead of as deleted and added.
+
+It can be used as a git diff driver which passes seven arguments:
+
+  git config diff.runme.command "runme diff"
+  echo "*.md diff=runme" >> .gitattributes`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 && len(args) != 7 {
+				return errors.Errorf("accepts 2 or 7 arg(s), received %d", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldPath, newPath := args[0], args[1]
+			oldLabel, newLabel := oldPath, newPath
+			if len(args) == 7 {
+				// Arguments passed by git: path old-file old-hex old-mode new-file new-hex new-mode.
+				oldPath, newPath = args[1], args[4]
+				oldLabel, newLabel = "a/"+args[0], "b/"+args[0]
+			}
+
+			a, err := os.ReadFile(oldPath)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			b, err := os.ReadFile(newPath)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			changes, err := notebook.Diff(a, b, notebook.DiffOptions{IgnoreOutputs: ignoreOutputs})
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				return nil
+			}
+
+			w := bulkWriter{Writer: cmd.OutOrStdout()}
+			w.Write([]byte(fmt.Sprintf("--- %s\n+++ %s\n", oldLabel, newLabel)))
+			for _, change := range changes {
+				writeCellChange(&w, change)
+			}
+			return errors.Wrap(w.Err(), "failed to write to stdout")
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	cmd.Flags().BoolVar(&ignoreOutputs, "ignore-outputs", false, "Ignore changes of cell outputs.")
+
+	return &cmd
+}
+
+func writeCellChange(w *bulkWriter, change notebook.CellChange) {
+	switch change.Kind {
+	case notebook.CellAdded:
+		w.Write([]byte(fmt.Sprintf("+ %d: %s\n", change.NewIndex+1, describeCell(change.New))))
+		writeLines(w, "  +", change.New.Value)
+	case notebook.CellDeleted:
+		w.Write([]byte(fmt.Sprintf("- %d: %s\n", change.OldIndex+1, describeCell(change.Old))))
+		writeLines(w, "  -", change.Old.Value)
+	case notebook.CellModified:
+		w.Write([]byte(fmt.Sprintf("~ %d: %s", change.NewIndex+1, describeCell(change.New))))
+		if change.Moved {
+			w.Write([]byte(fmt.Sprintf(" (moved from %d)", change.OldIndex+1)))
+		}
+		w.Write([]byte{'\n'})
+
+		for _, attr := range change.Attributes {
+			w.Write([]byte(fmt.Sprintf("  attribute %s: %q → %q\n", attr.Name, attr.Old, attr.New)))
+		}
+		if change.OutputsChanged {
+			w.Write([]byte("  outputs changed\n"))
+		}
+		if change.ValueChanged {
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:       difflib.SplitLines(change.Old.Value),
+				B:       difflib.SplitLines(change.New.Value),
+				Context: 2,
+			})
+			writeLines(w, "  ", strings.TrimRight(diff, "\n"))
+		}
+	}
+}
+
+func writeLines(w *bulkWriter, prefix, value string) {
+	for _, line := range strings.Split(value, "\n") {
+		w.Write([]byte(prefix + line + "\n"))
+	}
+}
+
+// describeCell returns a short description of the cell
+// like "code cell "build" (01JJDCG2SPRDWGQ1F4Z6EH69EJ)".
+func describeCell(cell *editor.Cell) string {
+	if cell.Kind != editor.CodeKind {
+		return "markup cell"
+	}
+
+	var b strings.Builder
+	_, _ = b.WriteString("code cell")
+	if name := cell.Metadata[editor.PrefixAttributeName(editor.InternalAttributePrefix, "name")]; name != "" {
+		_, _ = fmt.Fprintf(&b, " %q", name)
+	}
+	if id := cell.Metadata[editor.CellID]; id != "" {
+		_, _ = fmt.Fprintf(&b, " (%s)", id)
+	}
+	return b.String()
+}
+
+func mergeDriverCmd() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "merge-driver <base> <ours> <theirs> [path]",
+		Short: "Merge Markdown files cell by cell as a git merge driver",
+		Long: `Merge Markdown files cell by cell as a git merge driver.
+
+The result is written to <ours>. Cells are matched the same way as in "runme diff".
+Conflicting changes of attributes, outputs, and frontmatter are resolved in favor of ours.
+Conflicting changes of cell values are marked with conflict markers and the command fails.
+
+To configure it in a repository:
+
+  git config merge.runme.driver "runme merge-driver %O %A %B %P"
+  echo "*.md merge=runme" >> .gitattributes`,
+		Args: cobra.RangeArgs(3, 4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			basePath, oursPath, theirsPath := args[0], args[1], args[2]
+			path := oursPath
+			if len(args) == 4 {
+				path = args[3]
+			}
+
+			files := make([][]byte, 0, 3)
+			for _, p := range []string{basePath, oursPath, theirsPath} {
+				data, err := os.ReadFile(p)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				files = append(files, data)
+			}
+
+			result, err := notebook.Merge(files[0], files[1], files[2])
+			if err != nil {
+				return errors.Wrapf(err, "failed to merge %s", path)
+			}
+
+			info, err := os.Stat(oursPath)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := os.WriteFile(oursPath, result.Source, info.Mode().Perm()); err != nil {
+				return errors.WithStack(err)
+			}
+
+			writeMergeSummary(cmd.ErrOrStderr(), path, result)
+
+			if len(result.Conflicts) > 0 {
+				return errors.Errorf("%d conflict(s) in %s", len(result.Conflicts), path)
+			}
+			return nil
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	return &cmd
+}
+
+func writeMergeSummary(w io.Writer, path string, result *notebook.MergeResult) {
+	if result.Resolved > 0 {
+		_, _ = fmt.Fprintf(w, "%s: resolved %d metadata conflict(s) in favor of ours\n", path, result.Resolved)
+	}
+	for _, conflict := range result.Conflicts {
+		_, _ = fmt.Fprintf(w, "%s: conflict in %s: %s\n", path, describeCell(conflict.Cell), conflict.Reason)
+	}
+}
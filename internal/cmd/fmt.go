@@ -12,6 +12,7 @@ import (
 )
 
 var (
+	check       bool
 	flatten     bool
 	formatJSON  bool
 	identityStr string
@@ -20,6 +21,10 @@ var (
 
 func buildFmtCmd(cmd *cobra.Command, reset bool) *cobra.Command {
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if check && (write || formatJSON) {
+			return errors.New("invalid usage of --check with --write or --json")
+		}
+
 		if formatJSON {
 			if write {
 				return errors.New("invalid usage of --json with --write")
@@ -44,6 +49,10 @@ func buildFmtCmd(cmd *cobra.Command, reset bool) *cobra.Command {
 			identityResolver = strToIdentityResolver(identityStr)
 		}
 
+		if check {
+			return checkFiles(cmd, files, identityResolver)
+		}
+
 		return project.FormatFiles(files, &project.FormatOptions{
 			FormatJSON:       formatJSON,
 			IdentityResolver: identityResolver,
@@ -60,6 +69,7 @@ func buildFmtCmd(cmd *cobra.Command, reset bool) *cobra.Command {
 	}
 	setDefaultFlags(cmd)
 
+	cmd.Flags().BoolVar(&check, "check", false, "Report invalid cell attributes and unformatted files without changing them.")
 	cmd.Flags().BoolVar(&flatten, "flatten", true, "Flatten nested blocks in the output. WARNING: This can currently break frontmatter if turned off.")
 	cmd.Flags().BoolVar(&formatJSON, "json", false, "Print out data as JSON. Only possible with --flatten and not allowed with --write.")
 	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write result to the source file instead of stdout.")
@@ -69,6 +79,32 @@ func buildFmtCmd(cmd *cobra.Command, reset bool) *cobra.Command {
 	return cmd
 }
 
+func checkFiles(cmd *cobra.Command, files []string, identityResolver *idt.IdentityResolver) error {
+	results, err := project.CheckFiles(files, &project.FormatOptions{IdentityResolver: identityResolver})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	out := cmd.OutOrStdout()
+	for _, result := range results {
+		for _, d := range result.Diagnostics {
+			_, _ = fmt.Fprintf(out, "%s:%s\n", result.File, d)
+		}
+		if result.Unformatted {
+			_, _ = fmt.Fprintf(out, "%s: file is not formatted\n", result.File)
+		}
+		if result.HasErrors() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d file(s) failed the check", failed)
+	}
+	return nil
+}
+
 func fmtCmd() *cobra.Command {
 	cmd := buildFmtCmd(&cobra.Command{
 		Use:   "fmt",
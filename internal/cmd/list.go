@@ -24,6 +24,7 @@ type row struct {
 	Description  string `json:"description"`
 	Named        bool   `json:"named"`
 	RunAll       bool   `json:"run_all"`
+	Source       string `json:"source"`
 }
 
 func listCmd() *cobra.Command {
@@ -62,12 +63,13 @@ func listCmd() *cobra.Command {
 				lines := block.Lines()
 				relPath := project.GetRelativePath(getCwd(), task.DocumentPath)
 				r := row{
-					Name:         block.Name(),
+					Name:         task.QualifiedName(),
 					File:         relPath,
 					FirstCommand: shell.TryGetNonCommentLine(lines),
 					Description:  block.Intro(),
 					Named:        !block.IsUnnamed(),
 					RunAll:       !block.ExcludeFromRunAll(),
+					Source:       string(task.Source),
 				}
 				rows = append(rows, r)
 			}
@@ -130,8 +132,8 @@ func displayTable(cmd *cobra.Command, rows []row) error {
 	return errors.Wrap(table.Render(), "failed to render")
 }
 
-func displayJSON(io *iostreams.IOStreams, rows []row) error {
-	by, err := json.Marshal(&rows)
+func displayJSON(io *iostreams.IOStreams, rows any) error {
+	by, err := json.Marshal(rows)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/lsp"
+	"github.com/stateful/runme/v3/internal/owl"
+	"github.com/stateful/runme/v3/internal/runner/client"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+func lspCmd() *cobra.Command {
+	var (
+		serverAddr    string
+		getRunnerOpts func() ([]client.RunnerOption, error)
+	)
+
+	cmd := cobra.Command{
+		Use:   "lsp",
+		Short: "Start a Language Server Protocol server over stdio",
+		Long:  "Start a Language Server Protocol server over stdio providing diagnostics, completions, hovers, symbols, and code lenses for runnable Markdown files.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := getLogger(false)
+			if err != nil {
+				return err
+			}
+			defer logger.Sync()
+
+			store, err := owl.NewStore(owl.WithLogger(logger))
+			if err != nil {
+				return err
+			}
+
+			var envVars []lsp.EnvVar
+			for _, key := range store.SpecKeys() {
+				envVars = append(envVars, lsp.EnvVar{
+					Name:        key.Name,
+					Detail:      key.Spec + " (" + key.Atomic + ")",
+					Description: key.Description,
+				})
+			}
+
+			runnerOpts, err := getRunnerOpts()
+			if err != nil {
+				return err
+			}
+
+			runnerOpts = append(
+				runnerOpts,
+				client.WithinShellMaybe(),
+				client.WithStdin(bytes.NewReader(nil)),
+				client.WithStdout(io.Discard),
+				client.WithStderr(io.Discard),
+			)
+
+			if proj, err := getProject(); err == nil {
+				runnerOpts = append(runnerOpts, client.WithProject(proj))
+			}
+
+			runner, err := client.New(cmd.Context(), serverAddr, fSkipRunnerFallback, runnerOpts)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = runner.Cleanup(cmd.Context()) }()
+
+			executor := func(ctx context.Context, task project.Task, w io.Writer) error {
+				r := runner.Clone()
+				if err := client.ApplyOptions(r, client.WithStdout(w), client.WithStderr(w)); err != nil {
+					return err
+				}
+				return r.RunTask(ctx, task)
+			}
+
+			server := lsp.NewServer(cmd.InOrStdin(), cmd.OutOrStdout(), lsp.Options{
+				EnvVars:  envVars,
+				Executor: executor,
+				Logger:   logger,
+			})
+			return server.Serve(cmd.Context())
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	getRunnerOpts = setRunnerFlags(&cmd, &serverAddr)
+
+	return &cmd
+}
@@ -42,12 +42,12 @@ func newProjectLoader(cmd *cobra.Command, allowUnknown, allowUnnamed bool) (*pro
 	}, nil
 }
 
-func (pl projectLoader) LoadFiles(proj *project.Project) ([]string, error) {
+func (pl projectLoader) LoadFiles(proj project.Loader) ([]string, error) {
 	files, _, err := pl.load(proj, true)
 	return files, err
 }
 
-func (pl projectLoader) loadSorted(proj *project.Project) ([]project.Task, error) {
+func (pl projectLoader) loadSorted(proj project.Loader) ([]project.Task, error) {
 	_, tasks, err := pl.load(proj, false)
 
 	project.SortByProximity(tasks, pl.cwd)
@@ -55,7 +55,7 @@ func (pl projectLoader) loadSorted(proj *project.Project) ([]project.Task, error
 	return tasks, err
 }
 
-func (pl projectLoader) LoadTasks(proj *project.Project) ([]project.Task, error) {
+func (pl projectLoader) LoadTasks(proj project.Loader) ([]project.Task, error) {
 	tasks, err := pl.loadSorted(proj)
 	if err != nil {
 		return nil, err
@@ -82,19 +82,19 @@ func (pl projectLoader) LoadTasks(proj *project.Project) ([]project.Task, error)
 	return filtered, nil
 }
 
-func (pl projectLoader) LoadAllTasks(proj *project.Project) ([]project.Task, error) {
+func (pl projectLoader) LoadAllTasks(proj project.Loader) ([]project.Task, error) {
 	tasks, err := pl.loadSorted(proj)
 	return tasks, err
 }
 
-func (pl projectLoader) load(proj *project.Project, onlyFiles bool) ([]string, []project.Task, error) {
+func (pl projectLoader) load(proj project.Loader, onlyFiles bool) ([]string, []project.Task, error) {
 	if pl.isTerminal {
 		return pl.loadInTerminal(proj, onlyFiles)
 	}
 	return pl.loadWithoutTerminal(proj, onlyFiles)
 }
 
-func (pl projectLoader) loadInTerminal(proj *project.Project, onlyFiles bool) ([]string, []project.Task, error) {
+func (pl projectLoader) loadInTerminal(proj project.Loader, onlyFiles bool) ([]string, []project.Task, error) {
 	eventc := make(chan project.LoadEvent)
 
 	go proj.LoadWithOptions(pl.ctx, eventc, project.LoadOptions{OnlyFiles: onlyFiles})
@@ -124,10 +124,10 @@ func (pl projectLoader) loadInTerminal(proj *project.Project, onlyFiles bool) ([
 	return resultModel.files, resultModel.tasks, nil
 }
 
-func (pl projectLoader) loadWithoutTerminal(proj *project.Project, onlyFiles bool) ([]string, []project.Task, error) {
+func (pl projectLoader) loadWithoutTerminal(proj project.Loader, onlyFiles bool) ([]string, []project.Task, error) {
 	eventc := make(chan project.LoadEvent)
 
-	go proj.Load(pl.ctx, eventc, onlyFiles)
+	go proj.LoadWithOptions(pl.ctx, eventc, project.LoadOptions{OnlyFiles: onlyFiles})
 
 	var (
 		files []string
@@ -22,6 +22,9 @@ var (
 	fProject               string
 	fProjectIgnorePatterns []string
 	fProjectDirEnv         bool
+	fProjectCache          bool
+	fWorkspace             string
+	fTaskProviders         bool
 	fRespectGitignore      bool
 	fSkipRunnerFallback    bool
 	fInsecure              bool
@@ -29,6 +32,8 @@ var (
 	fLogFilePath           string
 	fExtensionHandle       string
 	fStateful              bool
+	// fFlags are flags of the executed command.
+	fFlags *pflag.FlagSet
 )
 
 func Root() *cobra.Command {
@@ -61,6 +66,8 @@ func Root() *cobra.Command {
 
 			fFileMode = cmd.Flags().Changed("chdir") || cmd.Flags().Changed("filename")
 
+			fFlags = cmd.Flags()
+
 			if fFileMode && !cmd.Flags().Changed("allow-unnamed") {
 				fAllowUnnamed = true
 			}
@@ -87,10 +94,13 @@ func Root() *cobra.Command {
 	pflags.StringVar(&fFileName, "filename", "README.md", "Name of the README file")
 	pflags.BoolVar(&fInsecure, "insecure", false, "Explicitly allow insecure operations to prevent misuse")
 
-	pflags.StringVar(&fProject, "project", "", "Root project to find runnable tasks")
+	pflags.StringVar(&fProject, "project", "", "Root project to find runnable tasks; also a git URL (git+https://...#ref:path), a bare git repository, or a tar/zip archive")
 	pflags.BoolVar(&fProjectDirEnv, "direnv", false, "Enable direnv loading for project")
 	pflags.BoolVar(&fRespectGitignore, "git-ignore", true, "Whether to respect .gitignore file(s) in project")
 	pflags.StringArrayVar(&fProjectIgnorePatterns, "ignore-pattern", []string{"node_modules", ".venv"}, "Patterns to ignore in project mode")
+	pflags.BoolVar(&fProjectCache, "cache", true, "Cache tasks found in project files on disk")
+	pflags.BoolVar(&fTaskProviders, "task-providers", false, "Also find read-only tasks in Makefiles, package.json scripts, and Taskfiles")
+	pflags.StringVar(&fWorkspace, "workspace", "", "Path to a runme.yaml with workspace roots to find runnable tasks in; tasks are namespaced by root, e.g. infra:deploy")
 
 	pflags.BoolVar(&fLogEnabled, "log", false, "Enable logging")
 	pflags.StringVar(&fLogFilePath, "log-file", filepath.Join(getTempDir(), "runme.log"), "Log file path")
@@ -109,12 +119,18 @@ func Root() *cobra.Command {
 		}
 	})
 
+	cmd.AddCommand(auditCmd())
+	cmd.AddCommand(ciCmd())
 	cmd.AddCommand(codeServerCmd())
+	cmd.AddCommand(diffCmd())
 	cmd.AddCommand(environmentCmd())
 	cmd.AddCommand(fmtCmd())
 	cmd.AddCommand(listCmd())
+	cmd.AddCommand(searchCmd())
 	cmd.AddCommand(loginCmd())
 	cmd.AddCommand(logoutCmd())
+	cmd.AddCommand(lspCmd())
+	cmd.AddCommand(mergeDriverCmd())
 	cmd.AddCommand(printCmd())
 	cmd.AddCommand(extensionCmd())
 	cmd.AddCommand(runCmd())
@@ -122,6 +138,7 @@ func Root() *cobra.Command {
 	cmd.AddCommand(serverCmd())
 	cmd.AddCommand(shellCmd())
 	cmd.AddCommand(tasksCmd())
+	cmd.AddCommand(testCmd())
 	cmd.AddCommand(tokenCmd())
 	cmd.AddCommand(tuiCmd)
 
@@ -8,7 +8,9 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -19,6 +21,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/stateful/runme/v3/internal/runevents"
+	"github.com/stateful/runme/v3/internal/runner"
 	"github.com/stateful/runme/v3/internal/runner/client"
 	"github.com/stateful/runme/v3/internal/tui"
 	"github.com/stateful/runme/v3/pkg/document"
@@ -45,6 +49,7 @@ func runCmd() *cobra.Command {
 		cmdTags               []string
 		getRunnerOpts         func() ([]client.RunnerOption, error)
 		runIndex              int
+		output                string
 	)
 
 	cmd := cobra.Command{
@@ -57,6 +62,11 @@ func runCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			runWithIndex := fFileMode && runIndex >= 0
 
+			outputFormat, err := runevents.ParseFormat(output)
+			if err != nil {
+				return err
+			}
+
 			if len(cmdCategories) > 0 {
 				cmdTags = append(cmdTags, cmdCategories...)
 			}
@@ -103,25 +113,9 @@ func runCmd() *cobra.Command {
 						}
 
 						// Check if the block matches any of the specified tags
-						if len(cmdTags) > 0 {
-							blockTags := block.Tags()
-							fm := block.Document().Frontmatter()
-							fmTags := resolveFrontmatterTags(fm)
-							match := false
-							if len(fmTags) > 0 && containsTags(fmTags, cmdTags) {
-								if len(blockTags) == 0 {
-									match = true
-								} else {
-									match = containsTags(fmTags, blockTags)
-								}
-							} else if containsTags(blockTags, cmdTags) {
-								match = true
-							}
-
-							if !match {
-								// Skip the task if it doesn't match any specified tags
-								continue
-							}
+						if len(cmdTags) > 0 && !matchesTags(block, cmdTags) {
+							// Skip the task if it doesn't match any specified tags
+							continue
 						}
 
 						// If none of the exclusion conditions met, add the task to runTasks
@@ -174,17 +168,17 @@ func runCmd() *cobra.Command {
 				client.WithStdin(stdin),
 				client.WithStdout(cmd.OutOrStdout()),
 				client.WithStderr(cmd.ErrOrStderr()),
-				client.WithProject(proj),
 			)
 
 			preRunOpts := []client.RunnerOption{
 				client.WrapWithCancelReader(),
 			}
 
-			runner, err := client.New(cmd.Context(), serverAddr, fSkipRunnerFallback, runnerOpts)
+			runners, err := newRootRunners(cmd.Context(), serverAddr, proj, runTasks, runnerOpts)
 			if err != nil {
 				return err
 			}
+			runner := runners[runTasks[0].Root]
 
 			for _, task := range runTasks {
 				doc := task.CodeBlock.Document()
@@ -198,10 +192,16 @@ func runCmd() *cobra.Command {
 				}
 			}
 
+			// Machine-readable output is not interactive.
+			if outputFormat != runevents.FormatText {
+				skipPrompts = true
+			}
+
 			if (skipPromptsExplicitly || isTerminal(os.Stdout.Fd())) && !skipPrompts {
-				err = promptEnvVars(cmd, runner, runTasks...)
-				if err != nil {
-					return err
+				for _, task := range runTasks {
+					if err := promptEnvVars(cmd, runners[task.Root], task); err != nil {
+						return err
+					}
 				}
 
 				if runMany {
@@ -212,6 +212,15 @@ func runCmd() *cobra.Command {
 				}
 			}
 
+			if outputFormat != runevents.FormatText && !dryRun {
+				emitter, err := runevents.New(cmd.OutOrStdout(), outputFormat)
+				if err != nil {
+					return err
+				}
+				defer func() { _ = cleanupRunners(cmd.Context(), runners) }()
+				return runTasksWithEmitter(ctx, runners, runTasks, parallel, emitter)
+			}
+
 			blockColor := color.New(color.Bold, color.FgYellow)
 			playColor := color.New(color.BgHiBlue, color.Bold, color.FgWhite)
 			textColor := color.New()
@@ -221,7 +230,8 @@ func runCmd() *cobra.Command {
 			infoMsgPrefix := playColor.Sprint(" ► ")
 
 			multiRunner := client.MultiRunner{
-				Runner: runner,
+				Runner:      runner,
+				RootRunners: runners,
 				PreRunMsg: func(tasks []project.Task, parallel bool) string {
 					blockNames := make([]string, len(tasks))
 					for i, task := range tasks {
@@ -282,10 +292,13 @@ func runCmd() *cobra.Command {
 				multiRunner.StdoutPrefix = fmt.Sprintf("[%s] ", blockColor.Sprintf("%%s"))
 			}
 
-			defer multiRunner.Cleanup(cmd.Context())
+			defer func() { _ = cleanupRunners(cmd.Context(), runners) }()
 
 			if dryRun {
-				return runner.DryRunTask(ctx, runTasks[0], cmd.ErrOrStderr()) // #nosec G602; runBlocks is checked
+				return errors.Wrap(
+					runner.DryRunTask(ctx, runTasks[0], cmd.ErrOrStderr()), // #nosec G602; runBlocks is checked
+					runTasks[0].Location(),
+				)
 			}
 
 			err = inRawMode(func() error {
@@ -297,7 +310,8 @@ func runCmd() *cobra.Command {
 					return err
 				}
 
-				return runner.RunTask(ctx, runTasks[0]) // #nosec G602; runBlocks comes from the parent scope and is checked
+				task := runTasks[0] // #nosec G602; runBlocks comes from the parent scope and is checked
+				return errors.Wrap(runner.RunTask(ctx, task), task.Location())
 			})
 			if errors.Is(err, io.ErrClosedPipe) {
 				err = nil
@@ -315,6 +329,7 @@ func runCmd() *cobra.Command {
 	cmd.Flags().StringArrayVarP(&cmdCategories, "category", "c", nil, "Run from a specific category.")
 	cmd.Flags().StringArrayVarP(&cmdTags, "tag", "t", nil, "Run from a specific tag.")
 	cmd.Flags().IntVarP(&runIndex, "index", "i", -1, "Index of command to run, 0-based. (Ignored in project mode)")
+	cmd.Flags().StringVar(&output, "output", string(runevents.FormatText), "Output format; one of: text, jsonl, github. jsonl emits events as JSON lines; github emits grouped logs and annotations for GitHub Actions.")
 	_ = cmd.Flags().MarkDeprecated("category", "use --tag instead")
 	cmd.PreRun = func(cmd *cobra.Command, args []string) {
 		skipPromptsExplicitly = cmd.Flags().Changed("skip-prompts")
@@ -325,6 +340,130 @@ func runCmd() *cobra.Command {
 	return &cmd
 }
 
+// newRootRunners creates a runner for each workspace root of tasks
+// so that tasks run with env files of their own roots. Runners are keyed
+// by the root name. Outside of a workspace, there is a single runner of proj
+// keyed by the empty string.
+func newRootRunners(
+	ctx context.Context,
+	serverAddr string,
+	proj *project.Project,
+	tasks []project.Task,
+	opts []client.RunnerOption,
+) (map[string]client.Runner, error) {
+	ws, err := getWorkspace()
+	if err != nil {
+		return nil, err
+	}
+
+	runners := make(map[string]client.Runner)
+	for _, task := range tasks {
+		if _, ok := runners[task.Root]; ok {
+			continue
+		}
+
+		rootProj := proj
+		if ws != nil {
+			if p, ok := ws.Project(task.Root); ok {
+				rootProj = p
+			}
+		}
+
+		runnerOpts := append(opts[:len(opts):len(opts)], client.WithProject(rootProj))
+		r, err := client.New(ctx, serverAddr, fSkipRunnerFallback, runnerOpts)
+		if err != nil {
+			_ = cleanupRunners(ctx, runners)
+			return nil, err
+		}
+		runners[task.Root] = r
+	}
+
+	return runners, nil
+}
+
+func cleanupRunners(ctx context.Context, runners map[string]client.Runner) error {
+	var result error
+	for _, r := range runners {
+		if err := r.Cleanup(ctx); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// runTasksWithEmitter runs tasks reporting their progress and outputs
+// to the emitter instead of writing them to stdout and stderr directly.
+// Changes of the env are not reported for tasks run in parallel because
+// they share the session and changes could not be attributed to a task.
+func runTasksWithEmitter(ctx context.Context, runners map[string]client.Runner, tasks []project.Task, parallel bool, emitter runevents.Emitter) error {
+	emitter.Plan(tasks)
+
+	run := func(task project.Task) error {
+		r := runners[task.Root].Clone()
+		if err := client.ApplyOptions(
+			r,
+			client.WithStdout(emitter.Stdout(task)),
+			client.WithStderr(emitter.Stderr(task)),
+		); err != nil {
+			return err
+		}
+
+		var before []string
+		if !parallel {
+			before, _ = r.GetEnvs(ctx)
+		}
+
+		emitter.TaskStart(task)
+		start := time.Now()
+
+		err := r.RunTask(ctx, task)
+
+		exitCode, runErr := 0, err
+		if exitErr := (*runner.ExitError)(nil); errors.As(err, &exitErr) {
+			exitCode, runErr = int(exitErr.Code), nil
+		}
+
+		if before != nil {
+			if after, envErr := r.GetEnvs(ctx); envErr == nil {
+				emitter.EnvChanged(task, before, after)
+			}
+		}
+
+		emitter.TaskEnd(task, exitCode, time.Since(start), runErr)
+
+		return errors.Wrap(err, task.Location())
+	}
+
+	if !parallel {
+		for _, task := range tasks {
+			if err := run(task); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(tasks))
+	)
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = run(task)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ctxWithSigCancel(ctx context.Context) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -475,8 +614,9 @@ func filterTasksByFileAndTaskName(tasks []project.Task, queryFile, queryName str
 		foundFile = true
 
 		// This is expected that the task name query is
-		// matched exactly.
-		if queryName != task.CodeBlock.Name() {
+		// matched exactly. Tasks from workspace roots can be
+		// also matched by their qualified names like "infra:deploy".
+		if queryName != task.CodeBlock.Name() && queryName != task.QualifiedName() {
 			continue
 		}
 
@@ -490,7 +630,23 @@ func filterTasksByFileAndTaskName(tasks []project.Task, queryFile, queryName str
 		return nil, &errTaskWithNameNotFound{queryName: queryName}
 	}
 
-	return results, nil
+	return preferMarkdownTasks(results), nil
+}
+
+// preferMarkdownTasks drops tasks found by task providers, like Makefile
+// targets, if there are markdown tasks with the same name. Otherwise, adding
+// a provider would make names of existing markdown tasks ambiguous.
+func preferMarkdownTasks(tasks []project.Task) []project.Task {
+	markdown := make([]project.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if !task.Source.ReadOnly() {
+			markdown = append(markdown, task)
+		}
+	}
+	if len(markdown) == 0 {
+		return tasks
+	}
+	return markdown
 }
 
 func lookupTaskWithPrompt(cmd *cobra.Command, query string, tasks []project.Task) (task project.Task, err error) {
@@ -594,6 +750,20 @@ func confirmExecution(cmd *cobra.Command, numTasks int, parallel bool, categorie
 	return nil
 }
 
+// matchesTags returns true if the block or its document is tagged
+// with any of the tags. Tags of the document apply to blocks without tags.
+func matchesTags(block *document.CodeBlock, tags []string) bool {
+	blockTags := block.Tags()
+	fmTags := resolveFrontmatterTags(block.Document().Frontmatter())
+	if len(fmTags) > 0 && containsTags(fmTags, tags) {
+		if len(blockTags) == 0 {
+			return true
+		}
+		return containsTags(fmTags, blockTags)
+	}
+	return containsTags(blockTags, tags)
+}
+
 func resolveFrontmatterTags(fm *document.Frontmatter) []string {
 	if fm == nil {
 		return []string{}
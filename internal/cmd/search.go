@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cli/go-gh/pkg/jsonpretty"
+	"github.com/cli/go-gh/pkg/tableprinter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/term"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+type searchRow struct {
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	Description string   `json:"description"`
+	Score       int      `json:"score"`
+	Matched     []string `json:"matched"`
+}
+
+func searchCmd() *cobra.Command {
+	var (
+		formatJSON bool
+		limit      int
+	)
+
+	cmd := cobra.Command{
+		Use:   "search <query>",
+		Short: "Search tasks by name, description, tags, path, and code",
+		Long: `Search tasks by name, description, tags, path, and code.
+
+Unlike "list", which filters by a regular expression, the query is matched loosely
+against words of the text surrounding tasks and their content, and results are ranked.
+Names and paths are also matched fuzzily, for example, "dplprod" finds "deploy-prod".`,
+		Example: `runme search the block that rotates the TLS certs
+runme search --limit 3 deploy`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tasks, err := getProjectTasks(cmd)
+			if err != nil {
+				return err
+			}
+
+			results := project.SearchTasks(tasks, strings.Join(args, " "))
+			if len(results) == 0 {
+				return errors.New("no tasks match the query")
+			}
+			if limit > 0 && len(results) > limit {
+				results = results[:limit]
+			}
+
+			rows := make([]searchRow, 0, len(results))
+			for _, result := range results {
+				matched := make([]string, 0, len(result.Fields))
+				for _, f := range result.Fields {
+					matched = append(matched, f.String())
+				}
+				rows = append(rows, searchRow{
+					Name:        result.Task.CodeBlock.Name(),
+					File:        project.GetRelativePath(getCwd(), result.Task.DocumentPath),
+					Description: result.Task.CodeBlock.Intro(),
+					Score:       result.Score,
+					Matched:     matched,
+				})
+			}
+
+			if formatJSON {
+				return displaySearchJSON(cmd, rows)
+			}
+
+			return displaySearchTable(cmd, rows)
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	cmd.Flags().BoolVar(&formatJSON, "json", false, "Print results in JSON.")
+	cmd.Flags().IntVar(&limit, "limit", 10, "Maximum number of results. Zero means no limit.")
+
+	return &cmd
+}
+
+func displaySearchJSON(cmd *cobra.Command, rows []searchRow) error {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	return jsonpretty.Format(cmd.OutOrStdout(), bytes.NewReader(data), "  ", false)
+}
+
+func displaySearchTable(cmd *cobra.Command, rows []searchRow) error {
+	term := term.FromIO(cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+
+	// Detect width. For non-TTY, use a default width of 80.
+	width, _, err := term.Size()
+	if err != nil {
+		width = 80
+	}
+	table := tableprinter.New(term.Out(), term.IsTTY(), width)
+
+	table.AddField("NAME")
+	table.AddField("FILE")
+	table.AddField("SCORE")
+	table.AddField("MATCHED")
+	table.AddField("DESCRIPTION")
+	table.EndRow()
+
+	for _, row := range rows {
+		table.AddField(row.Name)
+		table.AddField(row.File)
+		table.AddField(strconv.Itoa(row.Score))
+		table.AddField(strings.Join(row.Matched, ","))
+		table.AddField(row.Description)
+		table.EndRow()
+	}
+
+	return errors.Wrap(table.Render(), "failed to render")
+}
@@ -1,23 +1,33 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/stateful/runme/v3/internal/command"
+	"github.com/stateful/runme/v3/internal/config"
+	"github.com/stateful/runme/v3/internal/daemon"
 	notebookservice "github.com/stateful/runme/v3/internal/notebook"
 	"github.com/stateful/runme/v3/internal/project/projectservice"
 	"github.com/stateful/runme/v3/internal/runner"
 	runnerv2service "github.com/stateful/runme/v3/internal/runnerv2service"
+	"github.com/stateful/runme/v3/internal/server"
 	"github.com/stateful/runme/v3/internal/telemetry"
 	runmetls "github.com/stateful/runme/v3/internal/tls"
 	notebookv1alpha1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/notebook/v1alpha1"
@@ -28,6 +38,9 @@ import (
 	"github.com/stateful/runme/v3/pkg/document/editor/editorservice"
 )
 
+// telemetryShutdownTimeout is how long to wait for pending spans to be exported.
+const telemetryShutdownTimeout = 5 * time.Second
+
 func serverCmd() *cobra.Command {
 	const (
 		defaultAddr = "localhost:7863"
@@ -38,6 +51,21 @@ func serverCmd() *cobra.Command {
 		devMode      bool
 		enableRunner bool
 		tlsDir       string
+		// issuedCertsOnly rejects clients using the server certificate.
+		issuedCertsOnly bool
+		// daemonMode writes a pidfile next to the unix socket
+		// and removes both on SIGINT or SIGTERM.
+		daemonMode  bool
+		idleTimeout time.Duration
+		// remoteSources allows clients to make the server
+		// fetch projects from git URLs and archives.
+		remoteSources bool
+		// Telemetry is disabled unless the metrics address
+		// or the tracing endpoint is set.
+		metricsAddr        string
+		tracingEndpoint    string
+		tracingInsecure    bool
+		tracingSampleRatio float64
 	)
 
 	cmd := cobra.Command{
@@ -68,12 +96,20 @@ The kernel is used to run long running processes like shells and interacting wit
 			var tlsConfig *tls.Config
 
 			if !fInsecure {
-				tlsConfig, err = runmetls.LoadOrGenerateConfigFromDir(tlsDir, logger)
+				var opts []runmetls.ServerOption
+				if issuedCertsOnly || !server.IsLocalAddress(addr) {
+					opts = append(opts, runmetls.WithIssuedClientCertsOnly())
+				}
+				tlsConfig, err = runmetls.LoadOrGenerateConfigFromDir(tlsDir, logger, opts...)
 				if err != nil {
 					return err
 				}
 			}
 
+			if daemonMode && !strings.HasPrefix(addr, "unix://") {
+				return errors.New("daemon mode requires a unix socket address")
+			}
+
 			var lis net.Listener
 			protocol := "tcp"
 
@@ -87,45 +123,126 @@ The kernel is used to run long running processes like shells and interacting wit
 				defer func() { _ = os.Remove(addr) }()
 			}
 
-			if tlsConfig == nil {
-				lis, err = net.Listen(protocol, addr)
-			} else {
-				lis, err = tls.Listen(protocol, addr, tlsConfig)
-			}
-
+			lis, err = net.Listen(protocol, addr)
 			if err != nil {
 				return err
 			}
 
 			_ = telemetry.ReportUnlessNoTracking(logger)
 
+			// Telemetry is set up before services so that they use
+			// the global tracer and meter providers set up by it.
+			telemetryCfg := &config.ConfigServerTelemetry{}
+			if metricsAddr != "" {
+				telemetryCfg.Metrics = &config.ConfigServerTelemetryMetrics{Enabled: true, Address: metricsAddr}
+			}
+			if tracingEndpoint != "" {
+				telemetryCfg.Tracing = &config.ConfigServerTelemetryTracing{
+					Enabled:     true,
+					Endpoint:    tracingEndpoint,
+					Insecure:    &tracingInsecure,
+					SampleRatio: tracingSampleRatio,
+				}
+			}
+			telemetryEnabled := telemetryCfg.Metrics != nil || telemetryCfg.Tracing != nil
+			if telemetryEnabled {
+				tel, err := telemetry.New(telemetryCfg, logger)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					ctx, cancel := context.WithTimeout(context.Background(), telemetryShutdownTimeout)
+					defer cancel()
+					if err := tel.Shutdown(ctx); err != nil {
+						logger.Info("failed to shut down telemetry", zap.Error(err))
+					}
+				}()
+			}
+
 			logger.Info("started listening", zap.String("addr", lis.Addr().String()))
 
 			const maxMsgSize = 100 * 1024 * 1024 // 100 MiB
 
-			server := grpc.NewServer(
+			opts := []grpc.ServerOption{
 				grpc.MaxRecvMsgSize(maxMsgSize),
 				grpc.MaxSendMsgSize(maxMsgSize),
+			}
+			if telemetryEnabled {
+				opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+			}
+			if tlsConfig != nil {
+				opts = append(
+					opts,
+					grpc.Creds(credentials.NewTLS(tlsConfig)),
+					grpc.ChainUnaryInterceptor(server.UnaryClientIdentityInterceptor),
+					grpc.ChainStreamInterceptor(server.StreamClientIdentityInterceptor),
+				)
+			}
+			unaryNamespace, streamNamespace := server.NamespaceInterceptors(tlsConfig != nil)
+			opts = append(
+				opts,
+				grpc.ChainUnaryInterceptor(unaryNamespace),
+				grpc.ChainStreamInterceptor(streamNamespace),
+			)
+
+			var (
+				runnerServicev1 runnerv1.RunnerServiceServer
+				runnerServicev2 runnerv2.RunnerServiceServer
+				// usage contains the runner services which report running programs.
+				usage []runnerv2service.UsageReporter
 			)
-			parserv1.RegisterParserServiceServer(server, editorservice.NewParserServiceServer(logger))
-			projectv1.RegisterProjectServiceServer(server, projectservice.NewProjectServiceServer(logger))
-			notebookv1alpha1.RegisterNotebookServiceServer(server, notebookservice.NewNotebookService(logger))
-			// todo(sebastian): decided to forgo the reporter service for now
-			// reporterv1alpha1.RegisterReporterServiceServer(server, reporterservice.NewReporterServiceServer(logger))
 			if enableRunner {
-				runnerServicev1, err := runner.NewRunnerService(logger)
+				runnerServicev1, err = runner.NewRunnerService(logger)
 				if err != nil {
 					return err
 				}
-				runnerv1.RegisterRunnerServiceServer(server, runnerServicev1)
 
-				runnerServicev2, err := runnerv2service.NewRunnerService(
+				var runnerOpts []runnerv2service.Option
+				if u, ok := runnerServicev1.(runnerv2service.UsageReporter); ok {
+					usage = append(usage, u)
+					runnerOpts = append(runnerOpts, runnerv2service.WithUsageReporter(u))
+				}
+
+				runnerServicev2, err = runnerv2service.NewRunnerService(
 					command.NewFactory(command.WithLogger(logger)),
 					logger,
+					runnerOpts...,
 				)
 				if err != nil {
 					return err
 				}
+				if u, ok := runnerServicev2.(runnerv2service.UsageReporter); ok {
+					usage = append(usage, u)
+				}
+			}
+
+			var idle *server.IdleMonitor
+			if idleTimeout > 0 {
+				// Background processes outlive calls which started them.
+				idle = server.NewIdleMonitor(idleTimeout, server.WithBusyFunc(func() bool {
+					for _, u := range usage {
+						if u.ActiveExecutions() > 0 {
+							return true
+						}
+					}
+					return false
+				}))
+				defer idle.Stop()
+				opts = append(
+					opts,
+					grpc.ChainUnaryInterceptor(idle.Unary),
+					grpc.ChainStreamInterceptor(idle.Stream),
+				)
+			}
+
+			server := grpc.NewServer(opts...)
+			parserv1.RegisterParserServiceServer(server, editorservice.NewParserServiceServer(logger))
+			projectv1.RegisterProjectServiceServer(server, projectservice.NewProjectServiceServer(logger, projectservice.WithRemoteSources(remoteSources)))
+			notebookv1alpha1.RegisterNotebookServiceServer(server, notebookservice.NewNotebookService(logger))
+			// todo(sebastian): decided to forgo the reporter service for now
+			// reporterv1alpha1.RegisterReporterServiceServer(server, reporterservice.NewReporterServiceServer(logger))
+			if enableRunner {
+				runnerv1.RegisterRunnerServiceServer(server, runnerServicev1)
 				runnerv2.RegisterRunnerServiceServer(server, runnerServicev2)
 			}
 
@@ -135,6 +252,34 @@ The kernel is used to run long running processes like shells and interacting wit
 			healthcheck.SetServingStatus("", healthgrpc.HealthCheckResponse_SERVING)
 
 			reflection.Register(server)
+
+			if daemonMode {
+				pidFile := daemon.PIDFileFromAddress("unix://" + addr)
+				if err := daemon.WritePIDFile(pidFile); err != nil {
+					return err
+				}
+				defer func() { _ = os.Remove(pidFile) }()
+
+				signals := make(chan os.Signal, 1)
+				signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+				defer signal.Stop(signals)
+
+				go func() {
+					if _, ok := <-signals; ok {
+						logger.Info("stopping daemon")
+						server.Stop()
+					}
+				}()
+			}
+
+			if idle != nil {
+				go func() {
+					<-idle.Idle()
+					logger.Info("shutting down idle server", zap.Duration("idle_timeout", idleTimeout))
+					server.GracefulStop()
+				}()
+			}
+
 			return server.Serve(lis)
 		},
 	}
@@ -144,8 +289,19 @@ The kernel is used to run long running processes like shells and interacting wit
 	cmd.Flags().StringVarP(&addr, "address", "a", defaultAddr, "Address to create unix (unix:///path/to/socket) or IP socket (localhost:7890)")
 	cmd.Flags().BoolVar(&devMode, "dev", false, "Enable development mode")
 	cmd.Flags().BoolVar(&enableRunner, "runner", true, "Enable runner service (legacy, defaults to true)")
-	cmd.Flags().StringVar(&tlsDir, "tls", defaultTLSDir, "Directory in which to generate TLS certificates & use for all incoming and outgoing messages")
+	cmd.PersistentFlags().StringVar(&tlsDir, "tls", defaultTLSDir, "Directory in which to generate TLS certificates & use for all incoming and outgoing messages")
+	cmd.Flags().BoolVar(&issuedCertsOnly, "issued-client-certs-only", false, "Accept only client certificates issued with \"runme server client-cert issue\" and not the local client certificate or the server certificate; always on for addresses other than loopback and unix sockets")
 	cmd.Flags().StringVar(&configDir, configDirF, GetUserConfigHome(), "Sets the configuration directory.")
+	cmd.Flags().BoolVar(&daemonMode, "daemon", false, "Run as a background daemon writing a pidfile next to the unix socket")
+	cmd.Flags().BoolVar(&remoteSources, "remote-sources", false, "Allow loading projects from git URLs, local bare repositories, and archives fetched by the server")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "Shut down after the duration without calls; zero disables it")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-address", "", "Serve Prometheus metrics at /metrics on the address, for example, localhost:9464; empty disables metrics")
+	cmd.Flags().StringVar(&tracingEndpoint, "tracing-endpoint", "", "Export traces using OTLP over gRPC to the endpoint, for example, localhost:4317; empty disables tracing")
+	cmd.Flags().BoolVar(&tracingInsecure, "tracing-insecure", false, "Disable TLS of the connection to the tracing endpoint")
+	cmd.Flags().Float64Var(&tracingSampleRatio, "tracing-sample-ratio", 1, "Ratio of sampled traces between 0 and 1")
 	_ = cmd.Flags().MarkHidden("runner")
+
+	cmd.AddCommand(serverClientCertCmd(&tlsDir))
+
 	return &cmd
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	runmetls "github.com/stateful/runme/v3/internal/tls"
+)
+
+func serverClientCertCmd(tlsDir *string) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "client-cert",
+		Short: "Manage client certificates accepted by the server",
+		Long: `Manage client certificates issued by the local CA stored in the TLS directory.
+
+The server requires clients to authenticate with a certificate issued by the CA.
+Revoked certificates are rejected by a running server on the next connection.`,
+	}
+
+	cmd.AddCommand(serverClientCertIssueCmd(tlsDir))
+	cmd.AddCommand(serverClientCertRevokeCmd(tlsDir))
+	cmd.AddCommand(serverClientCertListCmd(tlsDir))
+
+	return &cmd
+}
+
+func serverClientCertIssueCmd(tlsDir *string) *cobra.Command {
+	var (
+		outDir string
+		ttl    time.Duration
+	)
+
+	cmd := cobra.Command{
+		Use:   "issue <name>",
+		Short: "Issue a client certificate",
+		Long: `Issue a client certificate named <name>. The certificate and its private key
+are written to the output directory as cert.pem and key.pem which can be passed
+to clients with --tls.
+
+Issuing a certificate for an existing name does not revoke the previous one,
+hence, clients can rotate certificates before they expire.`,
+		Example: `runme server client-cert issue ci --ttl 168h
+runme server client-cert issue laptop --output-dir ~/.runme-client`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca, err := runmetls.LoadOrGenerateCA(*tlsDir, zap.NewNop())
+			if err != nil {
+				return err
+			}
+
+			if outDir == "" {
+				outDir = filepath.Join(*tlsDir, "clients", args[0])
+			}
+
+			issued, err := ca.IssueClientCertificate(args[0], ttl, outDir)
+			if err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Issued client certificate %s (serial %s) valid until %s to %s\n", issued.Name, issued.Serial, issued.NotAfter.Format(time.RFC3339), outDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outDir, "output-dir", "o", "", "Directory to write cert.pem and key.pem to (default <tls>/clients/<name>)")
+	cmd.Flags().DurationVar(&ttl, "ttl", runmetls.DefaultClientCertTTL, "Validity of the certificate")
+
+	return &cmd
+}
+
+func serverClientCertRevokeCmd(tlsDir *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <name|serial>",
+		Short: "Revoke client certificates by name or serial number",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revoked, err := runmetls.RevokeClientCertificate(*tlsDir, args[0])
+			if err != nil {
+				return err
+			}
+			for _, c := range revoked {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Revoked client certificate %s (serial %s)\n", c.Name, c.Serial)
+			}
+			return nil
+		},
+	}
+}
+
+func serverClientCertListCmd(tlsDir *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List issued client certificates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			certs, err := runmetls.ListClientCertificates(*tlsDir)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tSERIAL\tEXPIRES\tSTATUS")
+			for _, c := range certs {
+				status := "valid"
+				switch {
+				case c.Revoked:
+					status = "revoked"
+				case time.Now().After(c.NotAfter):
+					status = "expired"
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.Serial, c.NotAfter.Format(time.RFC3339), status)
+			}
+			return w.Flush()
+		},
+	}
+}
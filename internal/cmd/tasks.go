@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -11,10 +15,13 @@ import (
 
 func tasksCmd() *cobra.Command {
 	cmd := cobra.Command{
-		Use:    "tasks",
-		Short:  "Generates task.json for VS Code editor. Caution, this is experimental.",
-		Hidden: true,
-		Args:   cobra.ExactArgs(1),
+		Use:   "tasks <name>",
+		Short: "Export tasks to other task runners or generate tasks.json for VS Code",
+		Long: `Export tasks to other task runners with "runme tasks export".
+
+Given a task name, generates a task definition for tasks.json of VS Code.
+Caution, this is experimental.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 		generateBlocks:
 			projTasks, err := getProjectTasks(cmd)
@@ -55,5 +62,126 @@ func tasksCmd() *cobra.Command {
 
 	setDefaultFlags(&cmd)
 
+	cmd.AddCommand(tasksExportCmd())
+
+	return &cmd
+}
+
+func tasksExportCmd() *cobra.Command {
+	var (
+		format string
+		output string
+		check  bool
+	)
+
+	cmd := cobra.Command{
+		Use:   "export",
+		Short: "Export tasks to a Makefile, Taskfile, or justfile",
+		Long: `Export tasks to a GNU Makefile, a Taskfile.yml, or a justfile.
+
+Each task runs its code with the block's interpreter in the block's working directory.
+Env files of the project are loaded by the tool running the exported tasks. Additionally,
+a "tag-<tag>" task is exported for each tag which runs all tasks with the tag.
+
+With --check, the exported file is compared with the existing one and the command fails
+if they differ. It is useful in CI to make sure the exported file is up to date.`,
+		Example: `runme tasks export --format make > Makefile
+runme tasks export --format taskfile --output Taskfile.yml
+runme tasks export --format just --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exportFormat, err := tasks.ParseExportFormat(format)
+			if err != nil {
+				return err
+			}
+
+			if check && output == "" {
+				output = exportFormat.FileName()
+			}
+
+			dir := getCwd()
+			path := output
+			if output != "" {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(getCwd(), path)
+				}
+				dir = filepath.Dir(path)
+			}
+
+			projTasks, err := getProjectTasks(cmd)
+			if err != nil {
+				return err
+			}
+
+			exportTasks, err := tasks.NewExportTasks(projTasks, dir)
+			if err != nil {
+				return err
+			}
+
+			envFiles, err := getExportEnvFiles(dir)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			if err := tasks.Export(&buf, exportFormat, exportTasks, tasks.ExportOptions{EnvFiles: envFiles}); err != nil {
+				return errors.Wrapf(err, "failed to export tasks to %s", exportFormat)
+			}
+
+			switch {
+			case check:
+				current, err := os.ReadFile(path)
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return errors.WithStack(err)
+				}
+				if !bytes.Equal(current, buf.Bytes()) {
+					return errors.Errorf("%s is out of date; run \"runme tasks export --format %s --output %s\"", output, exportFormat, output)
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s is up to date\n", output)
+				return nil
+			case output != "":
+				return errors.WithStack(os.WriteFile(path, buf.Bytes(), 0o644))
+			default:
+				_, err := cmd.OutOrStdout().Write(buf.Bytes())
+				return errors.WithStack(err)
+			}
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	cmd.Flags().StringVar(&format, "format", string(tasks.ExportFormatMake), "Format of the exported file; one of: make, taskfile, just.")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Path to the exported file. If empty, the result is written to stdout.")
+	cmd.Flags().BoolVar(&check, "check", false, "Fail if the exported file is out of date instead of writing it.")
+
 	return &cmd
 }
+
+// getExportEnvFiles returns existing env files of the project,
+// relative to dir, to be loaded by exported tasks.
+func getExportEnvFiles(dir string) ([]string, error) {
+	if !fLoadEnv || fWorkspace != "" {
+		return nil, nil
+	}
+
+	proj, err := getProject()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	for _, file := range proj.EnvFilesReadOrder() {
+		path := filepath.Join(proj.Root(), file)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		result = append(result, filepath.ToSlash(rel))
+	}
+
+	return result, nil
+}
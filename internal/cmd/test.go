@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/stateful/runme/v3/internal/expect"
+	"github.com/stateful/runme/v3/internal/runner"
+	"github.com/stateful/runme/v3/internal/runner/client"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+func testCmd() *cobra.Command {
+	var (
+		serverAddr    string
+		getRunnerOpts func() ([]client.RunnerOption, error)
+		tags          []string
+		format        string
+		output        string
+		golden        string
+	)
+
+	cmd := cobra.Command{
+		Use:   "test [task...]",
+		Short: "Run tasks and check their results",
+		Long: `Run tasks and check their exit codes and outputs against expectations.
+
+Expectations are defined with attributes of code blocks:
+
+  expect-exit              expected exit code; defaults to 0
+  expect-stdout            expected stdout
+  expect-stdout-contains   text which stdout contains
+  expect-stdout-match      regular expression matching stdout
+  expect-stderr-contains   text which stderr contains
+
+Alternatively, a code block with the "expect" attribute set to "stdout" or "stderr"
+directly following a task contains its expected output. Such blocks are not tasks.
+
+With --golden, outputs and exit codes are also compared with a session outputs document.
+
+By default, all tasks with expectations are run. Results are reported in TAP or JUnit XML.`,
+		Example: `runme test
+runme test --tag smoke --format junit --output report.xml
+runme test build --golden README-01HJP23P1R57BPGEA17QDJXJE.md`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reportFormat, err := expect.ParseReportFormat(format)
+			if err != nil {
+				return err
+			}
+
+			var goldens map[string]expect.Expectation
+			if golden != "" {
+				data, err := os.ReadFile(golden)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				goldens, err = expect.ParseSessionOutputs(data)
+				if err != nil {
+					return errors.WithMessagef(err, "failed to read %s", golden)
+				}
+			}
+
+			proj, err := getProject()
+			if err != nil {
+				return err
+			}
+
+			projTasks, err := getProjectTasks(cmd)
+			if err != nil {
+				return err
+			}
+
+			var (
+				tasks        []project.Task
+				expectations []expect.Expectation
+			)
+
+			candidates := projTasks
+			if len(args) > 0 || len(tags) > 0 {
+				candidates, err = selectTasks(projTasks, args, tags)
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, task := range candidates {
+				exp, ok, err := expect.FromBlock(task.CodeBlock)
+				if err != nil {
+					return errors.WithMessage(err, task.Location())
+				}
+				if g, hasGolden := goldens[task.CodeBlock.Name()]; hasGolden {
+					exp, ok = expect.Merge(exp, g), true
+				}
+				// Without arguments or tags, only tasks with expectations are run.
+				if !ok && len(args) == 0 && len(tags) == 0 {
+					continue
+				}
+				tasks = append(tasks, task)
+				expectations = append(expectations, exp)
+			}
+
+			if len(tasks) == 0 {
+				return errors.New("no tasks to test")
+			}
+
+			runnerOpts, err := getRunnerOpts()
+			if err != nil {
+				return err
+			}
+
+			runnerOpts = append(
+				runnerOpts,
+				client.WithStdin(bytes.NewBuffer(nil)),
+				client.WithProject(proj),
+			)
+
+			runnerClient, err := client.New(cmd.Context(), serverAddr, fSkipRunnerFallback, runnerOpts)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = runnerClient.Cleanup(cmd.Context()) }()
+
+			ctx, cancel := ctxWithSigCancel(cmd.Context())
+			defer cancel()
+
+			cases := make([]expect.Case, 0, len(tasks))
+			failed := 0
+
+			for i, task := range tasks {
+				var stdout, stderr bytes.Buffer
+
+				r := runnerClient.Clone()
+				if err := client.ApplyOptions(r, client.WithStdout(&stdout), client.WithStderr(&stderr)); err != nil {
+					return err
+				}
+
+				start := time.Now()
+				result := runTestTask(ctx, r, task)
+				result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+				c := expect.Case{
+					Name:     task.CodeBlock.Name(),
+					File:     task.RelDocumentPath,
+					Duration: time.Since(start),
+					Failures: expect.Check(expectations[i], result),
+					Stdout:   result.Stdout,
+					Stderr:   result.Stderr,
+				}
+				if !c.Passed() {
+					failed++
+				}
+				cases = append(cases, c)
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(filepath.Clean(output))
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				defer func() { _ = f.Close() }()
+				w = f
+			}
+
+			if err := expect.WriteReport(w, reportFormat, "runme", cases); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return errors.Errorf("%d of %d tests failed", failed, len(cases))
+			}
+
+			if output != "" {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%d tests passed\n", len(cases))
+			}
+
+			return nil
+		},
+	}
+
+	setDefaultFlags(&cmd)
+
+	cmd.Flags().StringArrayVarP(&tags, "tag", "t", nil, "Test tasks with the tags.")
+	cmd.Flags().StringVar(&format, "format", string(expect.ReportFormatTAP), "Format of the report; one of: tap, junit.")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Path to the report. If empty, the report is written to stdout.")
+	cmd.Flags().StringVar(&golden, "golden", "", "Path to a session outputs document with expected outputs.")
+
+	getRunnerOpts = setRunnerFlags(&cmd, &serverAddr)
+
+	return &cmd
+}
+
+// runTestTask runs the task and returns its exit code. Errors other
+// than a non-zero exit code are returned in the result.
+func runTestTask(ctx context.Context, r client.Runner, task project.Task) expect.Result {
+	err := r.RunTask(ctx, task)
+	if err == nil {
+		return expect.Result{}
+	}
+
+	if exitErr := (*runner.ExitError)(nil); errors.As(err, &exitErr) {
+		return expect.Result{ExitCode: int(exitErr.Code)}
+	}
+
+	return expect.Result{Err: err}
+}
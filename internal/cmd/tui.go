@@ -225,6 +225,9 @@ type tuiModel struct {
 	result          tuiResult
 	allowUnnamed    bool
 	allowUnknown    bool
+	// searching is true while the query is being typed.
+	searching bool
+	query     string
 }
 
 type tuiResult struct {
@@ -251,11 +254,11 @@ func (m *tuiModel) filterCodeBlocks() {
 	hasInitialized := m.tasks != nil
 
 	var oldSelection project.Task
-	if hasInitialized {
+	if len(m.tasks) > 0 {
 		oldSelection = m.tasks[m.cursor]
 	}
 
-	m.tasks, _ = project.FilterTasksByFn(m.unfilteredTasks, func(t project.Task) (bool, error) {
+	tasks, _ := project.FilterTasksByFn(m.unfilteredTasks, func(t project.Task) (bool, error) {
 		if !m.allowUnknown && t.CodeBlock.IsUnknown() {
 			return false, nil
 		}
@@ -267,10 +270,22 @@ func (m *tuiModel) filterCodeBlocks() {
 		return true, nil
 	})
 
+	// Results are ranked, hence, the best match is shown first.
+	m.tasks = make([]project.Task, 0, len(tasks))
+	for _, result := range project.SearchTasks(tasks, m.query) {
+		m.tasks = append(m.tasks, result.Task)
+	}
+
 	if !hasInitialized {
 		return
 	}
 
+	if m.query != "" {
+		m.scroll = 0
+		m.moveCursorTo(0)
+		return
+	}
+
 	foundOldSelection := false
 	for i, task := range m.tasks {
 		if task == oldSelection {
@@ -317,6 +332,10 @@ func (m tuiModel) View() string {
 
 	_, _ = s.WriteString(m.header)
 
+	if m.searching {
+		_, _ = fmt.Fprintf(&s, "Search: %s█\n\n", m.query)
+	}
+
 	for i := m.scroll; i < m.scroll+m.numBlocksShown(); i++ {
 		task := m.tasks[i]
 		block := task.CodeBlock
@@ -336,6 +355,9 @@ func (m tuiModel) View() string {
 			if block.IsUnnamed() {
 				name += " (unnamed)"
 			}
+			if task.Source.ReadOnly() {
+				name += " (" + string(task.Source) + ")"
+			}
 
 			relFilename := project.GetRelativePath(getCwd(), task.DocumentPath)
 			filename := ansi.Color(relFilename, "white+d")
@@ -404,10 +426,22 @@ func (m tuiModel) View() string {
 				"Run [Enter]",
 				"Expand [Space]",
 				fmt.Sprintf("%s Unnamed [u]", unnamedVerb),
+				"Search [/]",
 				"Quit [q]",
 			},
 			tab,
 		)
+		if m.searching {
+			help = strings.Join(
+				[]string{
+					fmt.Sprintf("%d/%d", m.cursor+1, len(m.tasks)),
+					"Choose ↑↓",
+					"Run [Enter]",
+					"Clear [Esc]",
+				},
+				tab,
+			)
+		}
 
 		help = ansi.Color(help, "white+d")
 
@@ -420,6 +454,10 @@ func (m tuiModel) View() string {
 func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	keyMsg, isKeyPress := msg.(tea.KeyMsg)
 
+	if isKeyPress && m.searching {
+		return m.updateSearch(keyMsg)
+	}
+
 	if isKeyPress {
 		switch keyMsg.String() {
 		case "ctrl+c", "q":
@@ -446,7 +484,14 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			command := strings.Join(m.tasks[m.cursor].CodeBlock.Lines(), "\n")
 			_ = clipboard.WriteAll(command)
 
+		case "/":
+			m.searching = true
+
 		case "enter", "l":
+			if len(m.tasks) == 0 {
+				break
+			}
+
 			m.result = tuiResult{
 				task: m.tasks[m.cursor],
 			}
@@ -462,6 +507,59 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateSearch handles keys while the search query is being typed.
+func (m tuiModel) updateSearch(keyMsg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch keyMsg.Type {
+	case tea.KeyCtrlC:
+		m.result = tuiResult{
+			exit: true,
+		}
+
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.searching = false
+		m.query = ""
+		m.filterCodeBlocks()
+
+	case tea.KeyEnter:
+		if len(m.tasks) == 0 {
+			break
+		}
+
+		m.result = tuiResult{
+			task: m.tasks[m.cursor],
+		}
+
+		return m, tea.Quit
+
+	case tea.KeyUp:
+		m.moveCursor(-1)
+
+	case tea.KeyDown:
+		m.moveCursor(1)
+
+	case tea.KeyBackspace:
+		if m.query == "" {
+			m.searching = false
+			break
+		}
+		runes := []rune(m.query)
+		m.query = string(runes[:len(runes)-1])
+		m.filterCodeBlocks()
+
+	case tea.KeySpace:
+		m.query += " "
+		m.filterCodeBlocks()
+
+	case tea.KeyRunes:
+		m.query += string(keyMsg.Runes)
+		m.filterCodeBlocks()
+	}
+
+	return m, nil
+}
+
 func clamp[T constraints.Ordered](x, a, b T) T {
 	return min(b, max(a, x))
 }
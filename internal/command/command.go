@@ -184,7 +184,7 @@ func (c *base) findProgramInPath(name string, args []string) (string, []string,
 }
 
 func (c *base) findProgramInKnownInterpreters(programName string, args []string) (string, []string, error) {
-	interpreters := inferInterpreterFromLanguage(programName)
+	interpreters := InferInterpreterFromLanguage(programName)
 	if len(interpreters) == 0 {
 		return "", nil, errors.Errorf("unsupported language %q", programName)
 	}
@@ -253,7 +253,9 @@ func normalizeProgramName(name string) (_ string, args []string) {
 	return name, args
 }
 
-func inferInterpreterFromLanguage(langID string) []string {
+// InferInterpreterFromLanguage returns a list of candidate interpreters
+// for the language ID in the order of preference.
+func InferInterpreterFromLanguage(langID string) []string {
 	return interpreterByLanguageID[langID]
 }
 
@@ -11,8 +11,10 @@
 package autoconfig
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/dig"
@@ -24,13 +26,18 @@ import (
 	"github.com/stateful/runme/v3/internal/command"
 	"github.com/stateful/runme/v3/internal/config"
 	"github.com/stateful/runme/v3/internal/dockerexec"
+	notebookservice "github.com/stateful/runme/v3/internal/notebook"
 	"github.com/stateful/runme/v3/internal/project/projectservice"
+	"github.com/stateful/runme/v3/internal/runner"
 	"github.com/stateful/runme/v3/internal/runnerv2client"
 	"github.com/stateful/runme/v3/internal/runnerv2service"
 	"github.com/stateful/runme/v3/internal/server"
+	"github.com/stateful/runme/v3/internal/session"
 	runmetls "github.com/stateful/runme/v3/internal/tls"
+	notebookv1alpha1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/notebook/v1alpha1"
 	parserv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/parser/v1"
 	projectv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/project/v1"
+	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 	"github.com/stateful/runme/v3/pkg/document/editor/editorservice"
 	"github.com/stateful/runme/v3/pkg/project"
@@ -64,9 +71,10 @@ func (b *Builder) init() {
 	mustProvide(container.Provide(getDocker))
 	mustProvide(container.Provide(getLogger))
 	mustProvide(container.Provide(getProject))
-	mustProvide(container.Provide(getProjectFilters))
+	mustProvide(container.Provide(NewProjectFilters))
 	mustProvide(container.Provide(getRootConfig))
 	mustProvide(container.Provide(getServer))
+	mustProvide(container.Provide(getWorkspace))
 }
 
 func Decorate(decorator interface{}, opts ...dig.DecorateOption) error {
@@ -191,38 +199,64 @@ func getLogger(c *config.Config) (*zap.Logger, error) {
 }
 
 func getProject(c *config.Config, logger *zap.Logger) (*project.Project, error) {
+	return newProject(&c.Project, c.Tasks, logger)
+}
+
+func newProject(c *config.ConfigProject, tasks []config.ConfigTasksElem, logger *zap.Logger, extraOpts ...project.ProjectOption) (*project.Project, error) {
 	opts := []project.ProjectOption{
 		project.WithLogger(logger),
 	}
 
-	if env := c.Project.Env; env != nil {
+	// Composite tasks run their steps with the current executable
+	// rather than "runme" found in PATH.
+	if exe, err := os.Executable(); err == nil {
+		opts = append(opts, project.WithRunmeCommand(exe))
+	}
+	opts = append(opts, extraOpts...)
+
+	if len(tasks) > 0 {
+		opts = append(opts, project.WithCompositeTasks("runme.yaml", NewCompositeTasks(tasks)...))
+	}
+
+	if env := c.Env; env != nil {
 		opts = append(opts, project.WithEnvFilesReadOrder(env.Sources))
 	}
 
-	if c.Project.Filename != "" {
-		return project.NewFileProject(c.Project.Filename, opts...)
+	if c.Filename != "" {
+		return project.NewFileProject(c.Filename, opts...)
 	}
 
-	projDir := c.Project.Root
+	projDir := c.Root
 	// If no project directory is specified, use the current directory.
 	if projDir == "" {
 		projDir = "."
 	}
 
+	// Remote sources are fetched into a cache first.
+	if project.IsRemoteSource(projDir) {
+		dir, err := project.FetchSource(context.Background(), projDir)
+		if err != nil {
+			return nil, err
+		}
+		projDir = dir
+	}
+
 	opts = append(
 		opts,
-		project.WithIgnoreFilePatterns(c.Project.Ignore...),
-		project.WithRespectGitignore(!c.Project.DisableGitignore),
+		project.WithIgnoreFilePatterns(c.Ignore...),
+		project.WithRespectGitignore(!c.DisableGitignore),
 	)
 
-	if c.Project.FindRepoUpward {
+	if c.FindRepoUpward {
 		opts = append(opts, project.WithFindRepoUpward())
 	}
 
 	return project.NewDirProject(projDir, opts...)
 }
 
-func getProjectFilters(c *config.Config) ([]project.Filter, error) {
+// NewProjectFilters returns filters of tasks defined
+// in the "project.filters" section of the config.
+func NewProjectFilters(c *config.Config) ([]project.Filter, error) {
 	var filters []project.Filter
 
 	for _, filter := range c.Project.Filters {
@@ -308,17 +342,165 @@ func getRootConfig(cfgLoader *config.Loader) (*config.Config, error) {
 		}
 	}
 
+	if cfg.Server != nil && cfg.Server.Audit != nil && cfg.Server.Audit.Enabled && cfg.Server.Audit.Path == nil {
+		userCfgDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get user config directory")
+		}
+
+		path := filepath.Join(userCfgDir, "runme", "audit", "audit.jsonl")
+		cfg.Server.Audit.Path = &path
+	}
+
 	return cfg, nil
 }
 
+func getWorkspace(c *config.Config, logger *zap.Logger) (*project.Workspace, error) {
+	if c.Workspace == nil {
+		return nil, nil
+	}
+	return NewWorkspace(c.Workspace, ".", logger)
+}
+
+// NewWorkspace creates a [project.Workspace] from the workspace configuration.
+// Relative roots are resolved against baseDir.
+//
+// Each root has its own configuration chain: if the root directory contains
+// runme.yaml, its project settings are used, otherwise the default ones.
+// Settings of the workspace root entry take precedence over both.
+func NewWorkspace(c *config.ConfigWorkspace, baseDir string, logger *zap.Logger, opts ...project.ProjectOption) (*project.Workspace, error) {
+	roots := make([]project.WorkspaceRoot, 0, len(c.Roots))
+
+	for _, root := range c.Roots {
+		dir := root.Root
+		if project.IsRemoteSource(dir) {
+			fetched, err := project.FetchSource(context.Background(), dir)
+			if err != nil {
+				return nil, err
+			}
+			dir = fetched
+		} else if !filepath.IsAbs(dir) {
+			dir = filepath.Join(baseDir, dir)
+		}
+
+		cfg, err := getWorkspaceRootConfig(dir)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to load configuration of workspace root %q", root.Name)
+		}
+
+		projCfg := cfg.Project
+		projCfg.Root = dir
+		projCfg.Filename = ""
+
+		if root.Ignore != nil {
+			projCfg.Ignore = root.Ignore
+		}
+		if root.DisableGitignore != nil {
+			projCfg.DisableGitignore = *root.DisableGitignore
+		}
+		if root.FindRepoUpward != nil {
+			projCfg.FindRepoUpward = *root.FindRepoUpward
+		}
+		if root.Env != nil {
+			projCfg.Env = &config.ConfigProjectEnv{
+				Sources:      root.Env.Sources,
+				UseSystemEnv: root.Env.UseSystemEnv,
+			}
+		}
+
+		proj, err := newProject(&projCfg, cfg.Tasks, logger.With(zap.String("root", root.Name)), opts...)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to create project of workspace root %q", root.Name)
+		}
+
+		roots = append(roots, project.WorkspaceRoot{Name: root.Name, Project: proj})
+	}
+
+	return project.NewWorkspace(roots...)
+}
+
+// NewCompositeTasks converts the "tasks" section of the configuration.
+func NewCompositeTasks(c []config.ConfigTasksElem) []project.CompositeTask {
+	result := make([]project.CompositeTask, 0, len(c))
+	for _, task := range c {
+		composite := project.CompositeTask{
+			Name:     task.Name,
+			Steps:    task.Run,
+			Parallel: task.Parallel,
+			Env:      task.Env,
+		}
+		if task.Description != nil {
+			composite.Description = *task.Description
+		}
+		result = append(result, composite)
+	}
+	return result
+}
+
+func getWorkspaceRootConfig(dir string) (*config.Config, error) {
+	items, err := config.NewLoader(nil, os.DirFS(dir)).RootConfigs()
+	switch {
+	case err == nil:
+		return config.ParseYAML(items...)
+	case errors.Is(err, config.ErrRootConfigNotFound):
+		return config.Default(), nil
+	default:
+		return nil, err
+	}
+}
+
 func getServer(cfg *config.Config, cmdFactory command.Factory, logger *zap.Logger) (*server.Server, error) {
 	if cfg.Server == nil {
 		return nil, nil
 	}
 
 	parserService := editorservice.NewParserServiceServer(logger)
-	projectService := projectservice.NewProjectServiceServer(logger)
-	runnerService, err := runnerv2service.NewRunnerService(cmdFactory, logger)
+	projectService := projectservice.NewProjectServiceServer(
+		logger,
+		projectservice.WithRemoteSources(cfg.Server.RemoteSources),
+	)
+	notebookService := notebookservice.NewNotebookService(logger)
+	var (
+		runnerOpts   []runnerv2service.Option
+		runnerOptsv1 []runner.Option
+	)
+	if sessions := cfg.Server.Sessions; sessions != nil {
+		runnerOpts = append(
+			runnerOpts,
+			runnerv2service.WithSessionQuota(sessions.Quota),
+			runnerv2service.WithMaxSessions(sessions.MaxSessions),
+		)
+		runnerOptsv1 = append(runnerOptsv1, runner.WithSessionOptions(
+			session.WithQuota(sessions.Quota),
+			session.WithMaxSessions(sessions.MaxSessions),
+		))
+		for namespace, quota := range sessions.NamespaceQuotas {
+			runnerOpts = append(runnerOpts, runnerv2service.WithNamespaceSessionQuota(namespace, quota))
+			runnerOptsv1 = append(runnerOptsv1, runner.WithSessionOptions(session.WithNamespaceQuota(namespace, quota)))
+		}
+	}
+
+	if limits := cfg.Server.Limits; limits != nil {
+		runnerOpts = append(runnerOpts, runnerv2service.WithExecutionLimits(runnerv2service.ExecutionLimits{
+			MaxExecutions:           intOrZero(limits.MaxExecutions),
+			MaxExecutionsPerClient:  intOrZero(limits.MaxExecutionsPerClient),
+			MaxExecutionsPerSession: intOrZero(limits.MaxExecutionsPerSession),
+			MaxQueued:               limits.MaxQueued,
+			QueueTimeout:            time.Duration(limits.QueueTimeout) * time.Second,
+		}))
+	}
+
+	// Runner v1 is served for clients which have not migrated to v2,
+	// for example, to use MonitorEnvStore.
+	runnerServicev1, err := runner.NewRunnerService(logger, runnerOptsv1...)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := runnerServicev1.(runnerv2service.UsageReporter); ok {
+		runnerOpts = append(runnerOpts, runnerv2service.WithUsageReporter(u))
+	}
+
+	runnerService, err := runnerv2service.NewRunnerService(cmdFactory, logger, runnerOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -329,7 +511,16 @@ func getServer(cfg *config.Config, cmdFactory command.Factory, logger *zap.Logge
 		func(sr grpc.ServiceRegistrar) {
 			parserv1.RegisterParserServiceServer(sr, parserService)
 			projectv1.RegisterProjectServiceServer(sr, projectService)
+			notebookv1alpha1.RegisterNotebookServiceServer(sr, notebookService)
+			runnerv1.RegisterRunnerServiceServer(sr, runnerServicev1)
 			runnerv2.RegisterRunnerServiceServer(sr, runnerService)
 		},
 	)
 }
+
+func intOrZero(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
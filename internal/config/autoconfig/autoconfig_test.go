@@ -11,12 +11,14 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/stateful/runme/v3/internal/config"
 	"github.com/stateful/runme/v3/internal/runnerv2client"
 	"github.com/stateful/runme/v3/internal/server"
+	"github.com/stateful/runme/v3/pkg/project"
 )
 
 func TestInvokeForCommand_Config(t *testing.T) {
@@ -183,3 +185,38 @@ func checkHealth(client healthv1.HealthClient) error {
 
 	return err
 }
+
+func TestNewWorkspace(t *testing.T) {
+	base := t.TempDir()
+
+	for _, dir := range []string{"infra", "app"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(base, dir), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(base, dir, "README.md"), []byte("```sh {\"name\":\"deploy\"}\necho deploy\n```\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(base, dir, "ignored.md"), []byte("```sh {\"name\":\"ignored\"}\necho ignored\n```\n"), 0o600))
+	}
+
+	// The root's own configuration is used...
+	require.NoError(t, os.WriteFile(filepath.Join(base, "infra", "runme.yaml"), []byte("version: v1alpha1\nproject:\n  ignore:\n    - ignored.md\n"), 0o600))
+
+	ws, err := NewWorkspace(
+		&config.ConfigWorkspace{
+			Roots: []config.ConfigWorkspaceRootsElem{
+				{Name: "infra", Root: "infra"},
+				// ...unless overridden by the workspace.
+				{Name: "app", Root: "app", Ignore: []string{"ignored.md"}},
+			},
+		},
+		base,
+		zap.NewNop(),
+	)
+	require.NoError(t, err)
+
+	tasks, err := project.LoadTasks(context.Background(), ws)
+	require.NoError(t, err)
+
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.QualifiedName())
+	}
+	require.Equal(t, []string{"infra:deploy", "app:deploy"}, names)
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"path/filepath"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/pkg/errors"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// PolicyEnv is the environment in which conditions of policy rules are evaluated.
+// It describes the caller and, for calls executing programs, the program.
+//
+// The `expr` tag is used to map the field to the corresponding variable.
+type PolicyEnv struct {
+	// Method is the full gRPC method name, for example,
+	// "/runme.runner.v2.RunnerService/Execute".
+	Method string `expr:"method"`
+	// Service is the full name of the service, for example,
+	// "runme.project.v1.ProjectService".
+	Service string `expr:"service"`
+	// Client is the common name of the client certificate.
+	// It is empty if the server does not use TLS.
+	Client       string `expr:"client"`
+	ClientSerial string `expr:"client_serial"`
+	// UID, GID, and PID identify the process connected to a unix socket.
+	// They are -1 for other connections.
+	UID       int64  `expr:"uid"`
+	GID       int64  `expr:"gid"`
+	PID       int64  `expr:"pid"`
+	SessionID string `expr:"session_id"`
+	// Document is a path of the document containing the program, if known.
+	Document    string `expr:"document"`
+	ProjectRoot string `expr:"project_root"`
+	// RemoteSource is the source of a remote project loaded with
+	// the project service, for example, "git+https://github.com/org/repo".
+	RemoteSource string   `expr:"remote_source"`
+	Program      string   `expr:"program"`
+	Args         []string `expr:"args"`
+	Cwd          string   `expr:"cwd"`
+	Language     string   `expr:"language"`
+	// Source is the script or commands, separated by new lines.
+	Source      string `expr:"source"`
+	Interactive bool   `expr:"interactive"`
+	Background  bool   `expr:"background"`
+	// Docker is true if programs are run in a docker container.
+	Docker    bool   `expr:"docker"`
+	KnownID   string `expr:"known_id"`
+	KnownName string `expr:"known_name"`
+}
+
+// Policy authorizes calls based on rules from the "server.policy" section.
+type Policy struct {
+	defaultEffect string
+	rules         []policyRule
+}
+
+type policyRule struct {
+	name    string
+	effect  string
+	message string
+	program *vm.Program
+}
+
+// PolicyDecision is the result of [Policy.Evaluate].
+type PolicyDecision struct {
+	Allowed bool
+	// Rule is the name of the matching rule. It is empty
+	// if the default effect was applied.
+	Rule    string
+	Message string
+}
+
+// NewPolicy compiles the rules of cfg. It returns nil if cfg is nil.
+func NewPolicy(cfg *ConfigServerPolicy) (*Policy, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	p := &Policy{defaultEffect: string(cfg.Default)}
+	if p.defaultEffect == "" {
+		p.defaultEffect = PolicyEffectAllow
+	}
+
+	for _, r := range cfg.Rules {
+		program, err := expr.Compile(
+			r.Condition,
+			expr.Env(PolicyEnv{}),
+			expr.AsBool(),
+			intersection,
+			basename,
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile condition of policy rule %q", r.Name)
+		}
+
+		var message string
+		if r.Message != nil {
+			message = *r.Message
+		}
+
+		p.rules = append(p.rules, policyRule{
+			name:    r.Name,
+			effect:  string(r.Effect),
+			message: message,
+			program: program,
+		})
+	}
+
+	return p, nil
+}
+
+// Evaluate returns the decision of the first rule which condition is true
+// or the default effect if no rule matches. Errors are returned alongside
+// a decision denying the call.
+func (p *Policy) Evaluate(env PolicyEnv) (PolicyDecision, error) {
+	for _, r := range p.rules {
+		result, err := expr.Run(r.program, env)
+		if err != nil {
+			return PolicyDecision{Rule: r.name}, errors.Wrapf(err, "failed to evaluate policy rule %q", r.name)
+		}
+		if !result.(bool) {
+			continue
+		}
+		return PolicyDecision{
+			Allowed: r.effect == PolicyEffectAllow,
+			Rule:    r.name,
+			Message: r.message,
+		}, nil
+	}
+	return PolicyDecision{Allowed: p.defaultEffect == PolicyEffectAllow}, nil
+}
+
+var basename = expr.Function(
+	"basename",
+	func(params ...any) (any, error) {
+		return filepath.Base(params[0].(string)), nil
+	},
+	new(func(string) string),
+)
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	cfg, err := ParseYAML([]byte(`version: v1alpha1
+server:
+  address: localhost:9999
+  policy:
+    default: deny
+    rules:
+      - name: deny-network-tools
+        effect: deny
+        condition: basename(program) in ["curl", "wget"] || source matches "\\b(curl|wget|nc)\\b"
+        message: network tools are not allowed
+      - name: known-tasks
+        effect: allow
+        condition: known_id != "" && project_root == "/repo"
+`))
+	require.NoError(t, err)
+
+	policy, err := NewPolicy(cfg.Server.Policy)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		env      PolicyEnv
+		expected PolicyDecision
+	}{
+		{
+			name:     "known task",
+			env:      PolicyEnv{KnownID: "01HF7B0KJPF469EG9ZVSTKBNQE", ProjectRoot: "/repo", Program: "bash", Source: "echo hello"},
+			expected: PolicyDecision{Allowed: true, Rule: "known-tasks"},
+		},
+		{
+			name:     "network tool in source",
+			env:      PolicyEnv{KnownID: "01HF7B0KJPF469EG9ZVSTKBNQE", ProjectRoot: "/repo", Program: "bash", Source: "curl example.com"},
+			expected: PolicyDecision{Rule: "deny-network-tools", Message: "network tools are not allowed"},
+		},
+		{
+			name:     "network tool as program",
+			env:      PolicyEnv{Program: "/usr/bin/wget"},
+			expected: PolicyDecision{Rule: "deny-network-tools", Message: "network tools are not allowed"},
+		},
+		{
+			name:     "default",
+			env:      PolicyEnv{Program: "bash", Source: "echo hello"},
+			expected: PolicyDecision{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision, err := policy.Evaluate(tc.env)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, decision)
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy, err := NewPolicy(nil)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	policy, err = NewPolicy(&ConfigServerPolicy{})
+	require.NoError(t, err)
+	decision, err := policy.Evaluate(PolicyEnv{})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	_, err = NewPolicy(&ConfigServerPolicy{
+		Rules: []ConfigServerPolicyRulesElem{{Name: "typo", Effect: PolicyEffectDeny, Condition: "programm == 'curl'"}},
+	})
+	require.ErrorContains(t, err, `failed to compile condition of policy rule "typo"`)
+}
@@ -25,8 +25,15 @@ type Config struct {
 	// Server corresponds to the JSON schema field "server".
 	Server *ConfigServer `json:"server,omitempty" yaml:"server,omitempty"`
 
+	// Aliases and composites of tasks found in markdown files.
+	Tasks []ConfigTasksElem `json:"tasks,omitempty" yaml:"tasks,omitempty"`
+
 	// Version corresponds to the JSON schema field "version".
 	Version string `json:"version" yaml:"version"`
+
+	// Multiple project roots, for example, repositories checked out side by side.
+	// Tasks are namespaced by the root name.
+	Workspace *ConfigWorkspace `json:"workspace,omitempty" yaml:"workspace,omitempty"`
 }
 
 type ConfigClient struct {
@@ -297,11 +304,373 @@ type ConfigServer struct {
 	// Address corresponds to the JSON schema field "address".
 	Address string `json:"address" yaml:"address"`
 
+	// Append-only, hash-chained log of calls executing programs or changing sessions.
+	Audit *ConfigServerAudit `json:"audit,omitempty" yaml:"audit,omitempty"`
+
+	// Limits of concurrently running programs started with Execute. Zero means no
+	// limit. Executions above a limit wait in a queue.
+	Limits *ConfigServerLimits `json:"limits,omitempty" yaml:"limits,omitempty"`
+
 	// MaxMessageSize corresponds to the JSON schema field "max_message_size".
 	MaxMessageSize int `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
 
+	// Access control of connections to a unix socket address based on credentials of
+	// the connecting process. A client is allowed if its user or group is allowed.
+	// Without allowed users and groups, all clients which can open the socket are
+	// allowed.
+	PeerCredentials *ConfigServerPeerCredentials `json:"peer_credentials,omitempty" yaml:"peer_credentials,omitempty"`
+
+	// Authorization policy evaluated for every call to the server, except health
+	// checks. Rules are evaluated in order and the first matching rule decides.
+	Policy *ConfigServerPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+
+	// Allow loading projects from git URLs, local bare repositories, and archives
+	// with the project service. The server fetches any source requested by a client.
+	RemoteSources bool `json:"remote_sources,omitempty" yaml:"remote_sources,omitempty"`
+
+	// Sessions are isolated by namespace. The namespace is the name of the
+	// authenticated client or, when client authentication is off, the value of the
+	// runme-namespace request metadata.
+	Sessions *ConfigServerSessions `json:"sessions,omitempty" yaml:"sessions,omitempty"`
+
+	// OpenTelemetry instrumentation of the server.
+	Telemetry *ConfigServerTelemetry `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
+
 	// Tls corresponds to the JSON schema field "tls".
 	Tls *ConfigServerTls `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Serve the services over the Connect protocol and gRPC-Web for browser clients.
+	// gRPC clients can use the same address.
+	Web *ConfigServerWeb `json:"web,omitempty" yaml:"web,omitempty"`
+}
+
+// Append-only, hash-chained log of calls executing programs or changing sessions.
+type ConfigServerAudit struct {
+	// Enabled corresponds to the JSON schema field "enabled".
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Path corresponds to the JSON schema field "path".
+	Path *string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerAudit) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["enabled"]; raw != nil && !ok {
+		return fmt.Errorf("field enabled in ConfigServerAudit: required")
+	}
+	type Plain ConfigServerAudit
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigServerAudit(plain)
+	return nil
+}
+
+// Limits of concurrently running programs started with Execute. Zero means no
+// limit. Executions above a limit wait in a queue.
+type ConfigServerLimits struct {
+	// Maximum number of running programs in the server.
+	MaxExecutions *int `json:"max_executions,omitempty" yaml:"max_executions,omitempty"`
+
+	// Maximum number of running programs of a single client, identified like the
+	// sessions namespace.
+	MaxExecutionsPerClient *int `json:"max_executions_per_client,omitempty" yaml:"max_executions_per_client,omitempty"`
+
+	// Maximum number of running programs in a single session.
+	MaxExecutionsPerSession *int `json:"max_executions_per_session,omitempty" yaml:"max_executions_per_session,omitempty"`
+
+	// Maximum number of executions waiting in the queue. Executions above it fail
+	// immediately with RESOURCE_EXHAUSTED.
+	MaxQueued int `json:"max_queued,omitempty" yaml:"max_queued,omitempty"`
+
+	// How long, in seconds, an execution waits in the queue before it fails with
+	// RESOURCE_EXHAUSTED.
+	QueueTimeout int `json:"queue_timeout,omitempty" yaml:"queue_timeout,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerLimits) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	type Plain ConfigServerLimits
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["max_queued"]; !ok || v == nil {
+		plain.MaxQueued = 64.0
+	}
+	if v, ok := raw["queue_timeout"]; !ok || v == nil {
+		plain.QueueTimeout = 30.0
+	}
+	*j = ConfigServerLimits(plain)
+	return nil
+}
+
+// Access control of connections to a unix socket address based on credentials of
+// the connecting process. A client is allowed if its user or group is allowed.
+// Without allowed users and groups, all clients which can open the socket are
+// allowed.
+type ConfigServerPeerCredentials struct {
+	// IDs of groups allowed to connect. Only the primary group of the process is
+	// checked.
+	AllowedGids []int `json:"allowed_gids,omitempty" yaml:"allowed_gids,omitempty"`
+
+	// IDs of users allowed to connect.
+	AllowedUids []int `json:"allowed_uids,omitempty" yaml:"allowed_uids,omitempty"`
+}
+
+// Authorization policy evaluated for every call to the server, except health
+// checks. Rules are evaluated in order and the first matching rule decides.
+type ConfigServerPolicy struct {
+	// Effect applied when no rule matches.
+	Default ConfigServerPolicyDefault `json:"default,omitempty" yaml:"default,omitempty"`
+
+	// Rules corresponds to the JSON schema field "rules".
+	Rules []ConfigServerPolicyRulesElem `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+type ConfigServerPolicyDefault string
+
+const ConfigServerPolicyDefaultAllow ConfigServerPolicyDefault = "allow"
+const ConfigServerPolicyDefaultDeny ConfigServerPolicyDefault = "deny"
+
+var enumValues_ConfigServerPolicyDefault = []interface{}{
+	"allow",
+	"deny",
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerPolicyDefault) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var ok bool
+	for _, expected := range enumValues_ConfigServerPolicyDefault {
+		if reflect.DeepEqual(v, expected) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("invalid value (expected one of %#v): %#v", enumValues_ConfigServerPolicyDefault, v)
+	}
+	*j = ConfigServerPolicyDefault(v)
+	return nil
+}
+
+type ConfigServerPolicyRulesElem struct {
+	// Expression evaluated with expr-lang. The rule applies if it evaluates to true.
+	Condition string `json:"condition" yaml:"condition"`
+
+	// Effect corresponds to the JSON schema field "effect".
+	Effect ConfigServerPolicyRulesElemEffect `json:"effect" yaml:"effect"`
+
+	// Message returned to clients denied by the rule.
+	Message *string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	// Name corresponds to the JSON schema field "name".
+	Name string `json:"name" yaml:"name"`
+}
+
+type ConfigServerPolicyRulesElemEffect string
+
+const ConfigServerPolicyRulesElemEffectAllow ConfigServerPolicyRulesElemEffect = "allow"
+const ConfigServerPolicyRulesElemEffectDeny ConfigServerPolicyRulesElemEffect = "deny"
+
+var enumValues_ConfigServerPolicyRulesElemEffect = []interface{}{
+	"allow",
+	"deny",
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerPolicyRulesElemEffect) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var ok bool
+	for _, expected := range enumValues_ConfigServerPolicyRulesElemEffect {
+		if reflect.DeepEqual(v, expected) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("invalid value (expected one of %#v): %#v", enumValues_ConfigServerPolicyRulesElemEffect, v)
+	}
+	*j = ConfigServerPolicyRulesElemEffect(v)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerPolicyRulesElem) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["condition"]; raw != nil && !ok {
+		return fmt.Errorf("field condition in ConfigServerPolicyRulesElem: required")
+	}
+	if _, ok := raw["effect"]; raw != nil && !ok {
+		return fmt.Errorf("field effect in ConfigServerPolicyRulesElem: required")
+	}
+	if _, ok := raw["name"]; raw != nil && !ok {
+		return fmt.Errorf("field name in ConfigServerPolicyRulesElem: required")
+	}
+	type Plain ConfigServerPolicyRulesElem
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigServerPolicyRulesElem(plain)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerPolicy) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	type Plain ConfigServerPolicy
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["default"]; !ok || v == nil {
+		plain.Default = "allow"
+	}
+	*j = ConfigServerPolicy(plain)
+	return nil
+}
+
+// Sessions are isolated by namespace. The namespace is the name of the
+// authenticated client or, when client authentication is off, the value of the
+// runme-namespace request metadata.
+type ConfigServerSessions struct {
+	// Maximum number of sessions in all namespaces. When reached, creating sessions
+	// fails with RESOURCE_EXHAUSTED until sessions are deleted.
+	MaxSessions int `json:"max_sessions,omitempty" yaml:"max_sessions,omitempty"`
+
+	// Quotas of specific namespaces overriding the default quota.
+	NamespaceQuotas ConfigServerSessionsNamespaceQuotas `json:"namespace_quotas,omitempty" yaml:"namespace_quotas,omitempty"`
+
+	// Maximum number of sessions in a namespace. When exceeded, the least recently
+	// used session is evicted.
+	Quota int `json:"quota,omitempty" yaml:"quota,omitempty"`
+}
+
+// Quotas of specific namespaces overriding the default quota.
+type ConfigServerSessionsNamespaceQuotas map[string]int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerSessions) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	type Plain ConfigServerSessions
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["max_sessions"]; !ok || v == nil {
+		plain.MaxSessions = 16384.0
+	}
+	if v, ok := raw["quota"]; !ok || v == nil {
+		plain.Quota = 1024.0
+	}
+	*j = ConfigServerSessions(plain)
+	return nil
+}
+
+// OpenTelemetry instrumentation of the server.
+type ConfigServerTelemetry struct {
+	// Prometheus metrics served at /metrics on a separate address.
+	Metrics *ConfigServerTelemetryMetrics `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+
+	// Export of traces using OTLP over gRPC. Standard OTEL_EXPORTER_OTLP_*
+	// environment variables, like OTEL_EXPORTER_OTLP_HEADERS, are respected.
+	Tracing *ConfigServerTelemetryTracing `json:"tracing,omitempty" yaml:"tracing,omitempty"`
+}
+
+// Prometheus metrics served at /metrics on a separate address.
+type ConfigServerTelemetryMetrics struct {
+	// Address corresponds to the JSON schema field "address".
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+
+	// Enabled corresponds to the JSON schema field "enabled".
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerTelemetryMetrics) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["enabled"]; raw != nil && !ok {
+		return fmt.Errorf("field enabled in ConfigServerTelemetryMetrics: required")
+	}
+	type Plain ConfigServerTelemetryMetrics
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["address"]; !ok || v == nil {
+		plain.Address = "localhost:9464"
+	}
+	*j = ConfigServerTelemetryMetrics(plain)
+	return nil
+}
+
+// Export of traces using OTLP over gRPC. Standard OTEL_EXPORTER_OTLP_* environment
+// variables, like OTEL_EXPORTER_OTLP_HEADERS, are respected.
+type ConfigServerTelemetryTracing struct {
+	// Enabled corresponds to the JSON schema field "enabled".
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Endpoint corresponds to the JSON schema field "endpoint".
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+
+	// Disable TLS of the connection to the endpoint.
+	Insecure *bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+
+	// Ratio of sampled traces between 0 and 1.
+	SampleRatio float64 `json:"sample_ratio,omitempty" yaml:"sample_ratio,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerTelemetryTracing) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["enabled"]; raw != nil && !ok {
+		return fmt.Errorf("field enabled in ConfigServerTelemetryTracing: required")
+	}
+	type Plain ConfigServerTelemetryTracing
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["endpoint"]; !ok || v == nil {
+		plain.Endpoint = "localhost:4317"
+	}
+	if v, ok := raw["sample_ratio"]; !ok || v == nil {
+		plain.SampleRatio = 1.0
+	}
+	*j = ConfigServerTelemetryTracing(plain)
+	return nil
 }
 
 type ConfigServerTls struct {
@@ -311,6 +680,14 @@ type ConfigServerTls struct {
 	// Enabled corresponds to the JSON schema field "enabled".
 	Enabled bool `json:"enabled" yaml:"enabled"`
 
+	// Accept only client certificates issued with "runme server client-cert issue".
+	// Otherwise, anyone who can read the TLS directory can authenticate: a local
+	// client certificate for clients sharing the directory with the server is
+	// accepted, and so is the server certificate (deprecated) for clients which have
+	// not migrated to it. Always on for addresses other than loopback and unix
+	// sockets.
+	IssuedClientCertsOnly bool `json:"issued_client_certs_only,omitempty" yaml:"issued_client_certs_only,omitempty"`
+
 	// KeyFile corresponds to the JSON schema field "key_file".
 	KeyFile *string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
 }
@@ -329,10 +706,107 @@ func (j *ConfigServerTls) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &plain); err != nil {
 		return err
 	}
+	if v, ok := raw["issued_client_certs_only"]; !ok || v == nil {
+		plain.IssuedClientCertsOnly = false
+	}
 	*j = ConfigServerTls(plain)
 	return nil
 }
 
+// Serve the services over the Connect protocol and gRPC-Web for browser clients.
+// gRPC clients can use the same address.
+type ConfigServerWeb struct {
+	// Cors corresponds to the JSON schema field "cors".
+	Cors *ConfigServerWebCors `json:"cors,omitempty" yaml:"cors,omitempty"`
+
+	// Enabled corresponds to the JSON schema field "enabled".
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Serve the parser, project, notebook, and runner v2 services as HTTP/JSON under
+	// /api. Streaming responses use server-sent events. Client-streaming methods,
+	// like Execute, are not served. The OpenAPI document is served at
+	// /api/openapi.json.
+	Gateway *ConfigServerWebGateway `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+}
+
+type ConfigServerWebCors struct {
+	// AllowCredentials corresponds to the JSON schema field "allow_credentials".
+	AllowCredentials *bool `json:"allow_credentials,omitempty" yaml:"allow_credentials,omitempty"`
+
+	// Headers allowed in addition to the ones required by the Connect protocol and
+	// gRPC-Web.
+	AllowedHeaders []string `json:"allowed_headers,omitempty" yaml:"allowed_headers,omitempty"`
+
+	// Origins allowed to make cross-origin requests, for example,
+	// "http://localhost:3000" or "*".
+	AllowedOrigins []string `json:"allowed_origins,omitempty" yaml:"allowed_origins,omitempty"`
+
+	// How long, in seconds, the results of a preflight request can be cached.
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerWebCors) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	type Plain ConfigServerWebCors
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["max_age"]; !ok || v == nil {
+		plain.MaxAge = 7200.0
+	}
+	*j = ConfigServerWebCors(plain)
+	return nil
+}
+
+// Serve the parser, project, notebook, and runner v2 services as HTTP/JSON under
+// /api. Streaming responses use server-sent events. Client-streaming methods, like
+// Execute, are not served. The OpenAPI document is served at /api/openapi.json.
+type ConfigServerWebGateway struct {
+	// Enabled corresponds to the JSON schema field "enabled".
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerWebGateway) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["enabled"]; raw != nil && !ok {
+		return fmt.Errorf("field enabled in ConfigServerWebGateway: required")
+	}
+	type Plain ConfigServerWebGateway
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigServerWebGateway(plain)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigServerWeb) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["enabled"]; raw != nil && !ok {
+		return fmt.Errorf("field enabled in ConfigServerWeb: required")
+	}
+	type Plain ConfigServerWeb
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigServerWeb(plain)
+	return nil
+}
+
 // UnmarshalJSON implements json.Unmarshaler.
 func (j *ConfigServer) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
@@ -350,10 +824,152 @@ func (j *ConfigServer) UnmarshalJSON(b []byte) error {
 	if v, ok := raw["max_message_size"]; !ok || v == nil {
 		plain.MaxMessageSize = 33554432.0
 	}
+	if v, ok := raw["remote_sources"]; !ok || v == nil {
+		plain.RemoteSources = false
+	}
 	*j = ConfigServer(plain)
 	return nil
 }
 
+type ConfigTasksElem struct {
+	// Description corresponds to the JSON schema field "description".
+	Description *string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Environment variables overriding these of the referenced tasks.
+	Env ConfigTasksElemEnv `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Name corresponds to the JSON schema field "name".
+	Name string `json:"name" yaml:"name"`
+
+	// Parallel corresponds to the JSON schema field "parallel".
+	Parallel bool `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+
+	// References to tasks run in order, or concurrently if parallel is set.
+	Run []string `json:"run" yaml:"run"`
+}
+
+// Environment variables overriding these of the referenced tasks.
+type ConfigTasksElemEnv map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigTasksElem) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["name"]; raw != nil && !ok {
+		return fmt.Errorf("field name in ConfigTasksElem: required")
+	}
+	if _, ok := raw["run"]; raw != nil && !ok {
+		return fmt.Errorf("field run in ConfigTasksElem: required")
+	}
+	type Plain ConfigTasksElem
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["parallel"]; !ok || v == nil {
+		plain.Parallel = false
+	}
+	if plain.Run != nil && len(plain.Run) < 1 {
+		return fmt.Errorf("field %s length: must be >= %d", "run", 1)
+	}
+	*j = ConfigTasksElem(plain)
+	return nil
+}
+
+// Multiple project roots, for example, repositories checked out side by side.
+// Tasks are namespaced by the root name.
+type ConfigWorkspace struct {
+	// Roots corresponds to the JSON schema field "roots".
+	Roots []ConfigWorkspaceRootsElem `json:"roots" yaml:"roots"`
+}
+
+type ConfigWorkspaceRootsElem struct {
+	// DisableGitignore corresponds to the JSON schema field "disable_gitignore".
+	DisableGitignore *bool `json:"disable_gitignore,omitempty" yaml:"disable_gitignore,omitempty"`
+
+	// Env corresponds to the JSON schema field "env".
+	Env *ConfigWorkspaceRootsElemEnv `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// FindRepoUpward corresponds to the JSON schema field "find_repo_upward".
+	FindRepoUpward *bool `json:"find_repo_upward,omitempty" yaml:"find_repo_upward,omitempty"`
+
+	// Ignore corresponds to the JSON schema field "ignore".
+	Ignore []string `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+
+	// Name corresponds to the JSON schema field "name".
+	Name string `json:"name" yaml:"name"`
+
+	// Root corresponds to the JSON schema field "root".
+	Root string `json:"root" yaml:"root"`
+}
+
+type ConfigWorkspaceRootsElemEnv struct {
+	// Sources corresponds to the JSON schema field "sources".
+	Sources []string `json:"sources,omitempty" yaml:"sources,omitempty"`
+
+	// UseSystemEnv corresponds to the JSON schema field "use_system_env".
+	UseSystemEnv bool `json:"use_system_env,omitempty" yaml:"use_system_env,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigWorkspaceRootsElemEnv) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	type Plain ConfigWorkspaceRootsElemEnv
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	if v, ok := raw["use_system_env"]; !ok || v == nil {
+		plain.UseSystemEnv = false
+	}
+	*j = ConfigWorkspaceRootsElemEnv(plain)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigWorkspaceRootsElem) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["name"]; raw != nil && !ok {
+		return fmt.Errorf("field name in ConfigWorkspaceRootsElem: required")
+	}
+	if _, ok := raw["root"]; raw != nil && !ok {
+		return fmt.Errorf("field root in ConfigWorkspaceRootsElem: required")
+	}
+	type Plain ConfigWorkspaceRootsElem
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigWorkspaceRootsElem(plain)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ConfigWorkspace) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw["roots"]; raw != nil && !ok {
+		return fmt.Errorf("field roots in ConfigWorkspace: required")
+	}
+	type Plain ConfigWorkspace
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ConfigWorkspace(plain)
+	return nil
+}
+
 // UnmarshalJSON implements json.Unmarshaler.
 func (j *Config) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
@@ -84,6 +84,42 @@ project:
 `,
 			errorSubstring: "failed to validate v1alpha1 config: project.filename: outside of the current working directory",
 		},
+		{
+			name: "tasks",
+			rawConfig: `version: v1alpha1
+project:
+  filename: README.md
+tasks:
+  - name: ci
+    run: [lint, test]
+    parallel: true
+    env:
+      CI: "true"
+`,
+			expectedConfig: &Config{
+				Version: "v1alpha1",
+				Project: ConfigProject{Filename: "README.md"},
+				Tasks: []ConfigTasksElem{
+					{
+						Name:     "ci",
+						Run:      []string{"lint", "test"},
+						Parallel: true,
+						Env:      ConfigTasksElemEnv{"CI": "true"},
+					},
+				},
+			},
+		},
+		{
+			name: "validate tasks run",
+			rawConfig: `version: v1alpha1
+project:
+  filename: README.md
+tasks:
+  - name: ci
+    run: []
+`,
+			errorSubstring: "field run length: must be >= 1",
+		},
 	}
 
 	for _, tc := range testCases {
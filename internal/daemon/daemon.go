@@ -0,0 +1,373 @@
+// Package daemon manages a runme server running in the background.
+// CLI commands connect to it instead of running programs locally
+// so that sessions and background processes outlive single commands.
+//
+// The daemon listens on a unix socket in a per-user runtime directory
+// accessible only by its owner, hence, it does not use TLS. It shuts down
+// after a period without calls and is restarted by a client of a different
+// version.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/stateful/runme/v3/internal/version"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+const (
+	socketName   = "runme.sock"
+	pidFileName  = "runme.pid"
+	logFileName  = "daemon.log"
+	lockFileName = "spawn.lock"
+
+	dirMode = 0o700
+)
+
+const (
+	// DefaultIdleTimeout is how long the daemon runs without calls.
+	DefaultIdleTimeout = 30 * time.Minute
+
+	startTimeout = 10 * time.Second
+	stopTimeout  = 5 * time.Second
+	pollInterval = 50 * time.Millisecond
+)
+
+var (
+	// ErrNotRunning is returned when the daemon does not respond to health checks.
+	ErrNotRunning = errors.New("daemon is not running")
+	// ErrOutdated is returned when the daemon is too old to report its status.
+	ErrOutdated = errors.New("daemon is outdated")
+)
+
+// RuntimeDir returns the directory of the socket, pidfile, and log
+// of the daemon. It is "runme" in $XDG_RUNTIME_DIR or, if it is not set,
+// a per-user directory in the temporary directory.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "runme")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("runme-%d", os.Getuid()))
+}
+
+// PIDFileFromAddress returns the path of the pidfile of a server
+// listening on a unix socket. It returns an empty string for other addresses.
+func PIDFileFromAddress(addr string) string {
+	if !strings.HasPrefix(addr, "unix://") {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(strings.TrimPrefix(addr, "unix://")), pidFileName)
+}
+
+// WritePIDFile writes the PID of the current process.
+func WritePIDFile(path string) error {
+	return errors.WithStack(os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o600))
+}
+
+type Daemon struct {
+	dir         string
+	executable  string
+	idleTimeout time.Duration
+	logger      *zap.Logger
+}
+
+type Option func(*Daemon)
+
+// WithDir overrides [RuntimeDir].
+func WithDir(dir string) Option {
+	return func(d *Daemon) {
+		d.dir = dir
+	}
+}
+
+// WithExecutable sets the runme binary used to start the daemon.
+// It defaults to the current executable.
+func WithExecutable(path string) Option {
+	return func(d *Daemon) {
+		d.executable = path
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(d *Daemon) {
+		d.idleTimeout = timeout
+	}
+}
+
+func WithLogger(logger *zap.Logger) Option {
+	return func(d *Daemon) {
+		d.logger = logger
+	}
+}
+
+func New(opts ...Option) *Daemon {
+	d := &Daemon{
+		dir:         RuntimeDir(),
+		idleTimeout: DefaultIdleTimeout,
+		logger:      zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.logger = d.logger.Named("Daemon")
+	return d
+}
+
+// Address returns the address of the daemon for gRPC clients.
+func (d *Daemon) Address() string {
+	return "unix://" + filepath.Join(d.dir, socketName)
+}
+
+func (d *Daemon) PIDFile() string {
+	return filepath.Join(d.dir, pidFileName)
+}
+
+func (d *Daemon) LogFile() string {
+	return filepath.Join(d.dir, logFileName)
+}
+
+type Status struct {
+	Address string
+	PID     int
+	*runnerv2.GetServerStatusResponse
+}
+
+// Status returns the status of a running daemon, [ErrNotRunning],
+// or [ErrOutdated].
+func (d *Daemon) Status(ctx context.Context) (*Status, error) {
+	conn, err := grpc.NewClient(d.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	resp, err := healthv1.NewHealthClient(conn).Check(ctx, &healthv1.HealthCheckRequest{})
+	if err != nil || resp.Status != healthv1.HealthCheckResponse_SERVING {
+		return nil, ErrNotRunning
+	}
+
+	serverStatus, err := runnerv2.NewRunnerServiceClient(conn).GetServerStatus(ctx, &runnerv2.GetServerStatusRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, ErrOutdated
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get server status")
+	}
+
+	pid, _ := readPIDFile(d.PIDFile())
+
+	return &Status{
+		Address:                 d.Address(),
+		PID:                     pid,
+		GetServerStatusResponse: serverStatus,
+	}, nil
+}
+
+// Ensure starts the daemon unless it is running. A daemon of a different
+// version, including an outdated one, is restarted. It returns the address
+// of the daemon.
+func (d *Daemon) Ensure(ctx context.Context) (string, error) {
+	status, err := d.Status(ctx)
+	switch {
+	case err == nil && status.Version == version.BaseVersionInfo():
+		return d.Address(), nil
+	case err == nil:
+		d.logger.Info(
+			"restarting daemon of a different version",
+			zap.String("version", status.Version),
+			zap.String("expected", version.BaseVersionInfo()),
+		)
+		if err := d.Stop(ctx); err != nil {
+			return "", err
+		}
+	case errors.Is(err, ErrOutdated):
+		d.logger.Info("restarting outdated daemon", zap.String("expected", version.BaseVersionInfo()))
+		// Daemons which do not write the pidfile cannot be stopped, but
+		// starting a new one replaces the socket.
+		if err := d.Stop(ctx); err != nil && !errors.Is(err, ErrNotRunning) {
+			return "", err
+		}
+	case !errors.Is(err, ErrNotRunning):
+		return "", err
+	}
+
+	if err := d.Start(ctx); err != nil {
+		return "", err
+	}
+	return d.Address(), nil
+}
+
+// Start starts the daemon in the background and waits until it is healthy.
+// It does nothing if the daemon is running. If another process is starting
+// the daemon at the same time, it only waits for it.
+func (d *Daemon) Start(ctx context.Context) error {
+	if err := os.MkdirAll(d.dir, dirMode); err != nil {
+		return errors.WithStack(err)
+	}
+	// The directory might have been created by someone else with wider permissions.
+	if err := os.Chmod(d.dir, dirMode); err != nil {
+		return errors.WithStack(err)
+	}
+
+	locked, err := d.lock()
+	if err != nil {
+		return err
+	}
+	if locked {
+		defer d.unlock()
+
+		// Another process might have started the daemon
+		// before the lock was taken.
+		if _, err := d.Status(ctx); err == nil {
+			return nil
+		}
+
+		if err := d.spawn(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	for {
+		if _, err := d.Status(ctx); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("daemon did not start in %s; see %s", startTimeout, d.LogFile())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (d *Daemon) spawn() error {
+	executable := d.executable
+	if executable == "" {
+		var err error
+		executable, err = os.Executable()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	logFile, err := os.OpenFile(d.LogFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(
+		executable,
+		"server",
+		"--address", d.Address(),
+		"--insecure",
+		"--daemon",
+		"--idle-timeout", d.idleTimeout.String(),
+	)
+	cmd.Dir = d.dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	setDetached(cmd)
+
+	d.logger.Info("starting daemon", zap.Strings("args", cmd.Args))
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start daemon")
+	}
+	return errors.WithStack(cmd.Process.Release())
+}
+
+// lock prevents concurrent clients from starting multiple daemons.
+// A lock older than [startTimeout] is considered abandoned.
+func (d *Daemon) lock() (bool, error) {
+	path := filepath.Join(d.dir, lockFileName)
+
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			return true, errors.WithStack(f.Close())
+		}
+		if !os.IsExist(err) {
+			return false, errors.WithStack(err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < startTimeout {
+			return false, nil
+		}
+		_ = os.Remove(path)
+	}
+
+	return false, nil
+}
+
+func (d *Daemon) unlock() {
+	_ = os.Remove(filepath.Join(d.dir, lockFileName))
+}
+
+// Stop stops the daemon and waits until it exits.
+func (d *Daemon) Stop(ctx context.Context) error {
+	pid, err := readPIDFile(d.PIDFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotRunning
+	}
+	if err != nil {
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return errors.Wrap(err, "failed to find process")
+	}
+
+	d.logger.Info("stopping daemon", zap.Int("pid", pid))
+
+	if err := interrupt(process); err != nil {
+		// The process is gone, but it did not clean up.
+		_ = os.Remove(d.PIDFile())
+		return ErrNotRunning
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stopTimeout)
+	defer cancel()
+
+	for {
+		if _, err := d.Status(ctx); errors.Is(err, ErrNotRunning) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			d.logger.Info("daemon did not stop; killing it", zap.Int("pid", pid))
+			return errors.Wrap(process.Kill(), "failed to kill daemon")
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, errors.WithStack(err)
+}
@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRuntimeDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	assert.Equal(t, filepath.Join("/run/user/1000", "runme"), RuntimeDir())
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	assert.Equal(t, os.TempDir(), filepath.Dir(RuntimeDir()))
+}
+
+func TestDaemon_Paths(t *testing.T) {
+	d := New(WithDir("/run/user/1000/runme"))
+
+	assert.Equal(t, "unix:///run/user/1000/runme/runme.sock", d.Address())
+	assert.Equal(t, "/run/user/1000/runme/runme.pid", d.PIDFile())
+	assert.Equal(t, "/run/user/1000/runme/daemon.log", d.LogFile())
+	assert.Equal(t, d.PIDFile(), PIDFileFromAddress(d.Address()))
+	assert.Empty(t, PIDFileFromAddress("localhost:7863"))
+}
+
+func TestDaemon_NotRunning(t *testing.T) {
+	d := New(WithDir(t.TempDir()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := d.Status(ctx)
+	require.ErrorIs(t, err, ErrNotRunning)
+
+	err = d.Stop(ctx)
+	require.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestDaemon_Outdated(t *testing.T) {
+	// Unix socket paths are limited to around 100 characters.
+	dir, err := os.MkdirTemp("", "runme")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	d := New(WithDir(dir))
+
+	lis, err := net.Listen("unix", strings.TrimPrefix(d.Address(), "unix://"))
+	require.NoError(t, err)
+
+	// A server which does not implement GetServerStatus.
+	server := grpc.NewServer()
+	healthv1.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = d.Status(ctx)
+	require.ErrorIs(t, err, ErrOutdated)
+}
+
+func TestDaemon_Lock(t *testing.T) {
+	dir := t.TempDir()
+	d := New(WithDir(dir))
+
+	locked, err := d.lock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	locked, err = d.lock()
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	// An abandoned lock is taken over.
+	past := time.Now().Add(-2 * startTimeout)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, lockFileName), past, past))
+
+	locked, err = d.lock()
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	d.unlock()
+	_, err = os.Stat(filepath.Join(dir, lockFileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
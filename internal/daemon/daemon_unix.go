@@ -0,0 +1,22 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setDetached starts the daemon in a new session so that
+// it is not terminated together with the terminal of the client.
+func setDetached(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+func interrupt(process *os.Process) error {
+	return process.Signal(os.Interrupt)
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+func setDetached(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= createNewProcessGroup | detachedProcess
+}
+
+// interrupt kills the process because Windows does not support
+// sending interrupts to processes without a console.
+func interrupt(process *os.Process) error {
+	return process.Kill()
+}
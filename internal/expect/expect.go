@@ -0,0 +1,214 @@
+// Package expect checks results of tasks against expectations
+// defined in markdown files and reports them in JUnit XML and TAP.
+package expect
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/internal/ansi"
+	"github.com/stateful/runme/v3/pkg/document"
+)
+
+// Attributes of code blocks defining expectations.
+const (
+	AttributeExit           = "expect-exit"
+	AttributeStdout         = "expect-stdout"
+	AttributeStdoutContains = "expect-stdout-contains"
+	AttributeStdoutMatch    = "expect-stdout-match"
+	AttributeStderrContains = "expect-stderr-contains"
+
+	// AttributeSibling marks a code block which contains the expected
+	// output of the preceding code block. Its value is either "stdout"
+	// or "stderr". Such blocks are not tasks.
+	AttributeSibling = "expect"
+)
+
+// Expectation describes the expected result of a task.
+// Nil fields are not checked, except for ExitCode which defaults to zero.
+type Expectation struct {
+	ExitCode       *int
+	Stdout         *string
+	Stderr         *string
+	StdoutContains []string
+	StdoutMatch    []*regexp.Regexp
+	StderrContains []string
+}
+
+// Result is the result of running a task.
+type Result struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	// Err is an error which prevented the task from running.
+	Err error
+}
+
+// FromBlock returns expectations defined in attributes of the block
+// and in a sibling block directly following it. The second return value
+// is false if the block does not define any expectations; in such a case,
+// the block is expected to exit with zero.
+func FromBlock(block *document.CodeBlock) (Expectation, bool, error) {
+	var (
+		exp   Expectation
+		found bool
+	)
+
+	attrs := block.Attributes()
+	if attrs == nil {
+		return exp, false, nil
+	}
+
+	if value, ok := attrs.Items[AttributeExit]; ok {
+		code, err := strconv.Atoi(value)
+		if err != nil {
+			return exp, false, errors.Errorf("invalid %s %q", AttributeExit, value)
+		}
+		exp.ExitCode = &code
+		found = true
+	}
+
+	if value, ok := attrs.Items[AttributeStdout]; ok {
+		exp.Stdout = &value
+		found = true
+	}
+
+	if value, ok := attrs.Items[AttributeStdoutContains]; ok {
+		exp.StdoutContains = append(exp.StdoutContains, value)
+		found = true
+	}
+
+	if value, ok := attrs.Items[AttributeStdoutMatch]; ok {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return exp, false, errors.Wrapf(err, "invalid %s", AttributeStdoutMatch)
+		}
+		exp.StdoutMatch = append(exp.StdoutMatch, re)
+		found = true
+	}
+
+	if value, ok := attrs.Items[AttributeStderrContains]; ok {
+		exp.StderrContains = append(exp.StderrContains, value)
+		found = true
+	}
+
+	sibling, err := findSibling(block)
+	if err != nil {
+		return exp, false, err
+	}
+	if sibling != nil {
+		content := strings.Join(sibling.Lines(), "\n")
+		switch stream := sibling.Attributes().Items[AttributeSibling]; stream {
+		case "stdout":
+			exp.Stdout = &content
+		case "stderr":
+			exp.Stderr = &content
+		default:
+			return exp, false, errors.Errorf("invalid %s %q; expected stdout or stderr", AttributeSibling, stream)
+		}
+		found = true
+	}
+
+	return exp, found, nil
+}
+
+// findSibling returns the code block following the block
+// if it defines the expected output.
+func findSibling(block *document.CodeBlock) (*document.CodeBlock, error) {
+	doc := block.Document()
+	if doc == nil {
+		return nil, nil
+	}
+
+	root, err := doc.Root()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	blocks := document.CollectAllCodeBlocks(root)
+	for i, b := range blocks {
+		if b != block || i+1 == len(blocks) {
+			continue
+		}
+		next := blocks[i+1]
+		if attrs := next.Attributes(); attrs != nil {
+			if _, ok := attrs.Items[AttributeSibling]; ok {
+				return next, nil
+			}
+		}
+		return nil, nil
+	}
+
+	return nil, nil
+}
+
+// Merge returns exp with unset exit code and outputs taken from other.
+func Merge(exp, other Expectation) Expectation {
+	if exp.ExitCode == nil {
+		exp.ExitCode = other.ExitCode
+	}
+	if exp.Stdout == nil {
+		exp.Stdout = other.Stdout
+	}
+	if exp.Stderr == nil {
+		exp.Stderr = other.Stderr
+	}
+	return exp
+}
+
+// Check returns descriptions of unmet expectations.
+func Check(exp Expectation, result Result) []string {
+	if result.Err != nil {
+		return []string{fmt.Sprintf("failed to run: %v", result.Err)}
+	}
+
+	var failures []string
+
+	expectedExitCode := 0
+	if exp.ExitCode != nil {
+		expectedExitCode = *exp.ExitCode
+	}
+	if result.ExitCode != expectedExitCode {
+		failures = append(failures, fmt.Sprintf("exit code: expected %d, got %d", expectedExitCode, result.ExitCode))
+	}
+
+	stdout := normalizeOutput(result.Stdout)
+	stderr := normalizeOutput(result.Stderr)
+
+	if exp.Stdout != nil && stdout != normalizeOutput(*exp.Stdout) {
+		failures = append(failures, fmt.Sprintf("stdout: expected %q, got %q", normalizeOutput(*exp.Stdout), stdout))
+	}
+	if exp.Stderr != nil && stderr != normalizeOutput(*exp.Stderr) {
+		failures = append(failures, fmt.Sprintf("stderr: expected %q, got %q", normalizeOutput(*exp.Stderr), stderr))
+	}
+
+	for _, s := range exp.StdoutContains {
+		if !strings.Contains(stdout, s) {
+			failures = append(failures, fmt.Sprintf("stdout: expected to contain %q", s))
+		}
+	}
+	for _, re := range exp.StdoutMatch {
+		if !re.MatchString(stdout) {
+			failures = append(failures, fmt.Sprintf("stdout: expected to match %q", re.String()))
+		}
+	}
+	for _, s := range exp.StderrContains {
+		if !strings.Contains(stderr, s) {
+			failures = append(failures, fmt.Sprintf("stderr: expected to contain %q", s))
+		}
+	}
+
+	return failures
+}
+
+// normalizeOutput makes outputs comparable regardless of
+// line endings, terminal escape codes, and trailing line breaks.
+func normalizeOutput(s string) string {
+	s = string(ansi.Strip([]byte(s)))
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.TrimRight(s, "\n")
+}
@@ -0,0 +1,169 @@
+package expect
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stateful/runme/v3/pkg/document"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+func parseBlocks(t *testing.T, source string) document.CodeBlocks {
+	t.Helper()
+
+	doc := document.New([]byte(source), identity.NewResolver(identity.DefaultLifecycleIdentity))
+	node, err := doc.Root()
+	require.NoError(t, err)
+	return document.CollectCodeBlocks(node)
+}
+
+func TestFromBlock(t *testing.T) {
+	blocks := parseBlocks(t, "```sh {\"name\":\"attrs\",\"expect-exit\":\"2\",\"expect-stdout-contains\":\"hello\",\"expect-stdout-match\":\"^h\",\"expect-stderr-contains\":\"oops\"}\necho hello\n```\n\n"+
+		"```sh {\"name\":\"sibling\"}\necho one\n```\n\nThe output is:\n\n```text {\"expect\":\"stdout\"}\none\n```\n\n"+
+		"```sh {\"name\":\"none\"}\necho none\n```\n")
+
+	// The sibling block is not a task.
+	require.Len(t, blocks, 3)
+
+	exp, ok, err := FromBlock(blocks[0])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NotNil(t, exp.ExitCode)
+	assert.Equal(t, 2, *exp.ExitCode)
+	assert.Equal(t, []string{"hello"}, exp.StdoutContains)
+	assert.Equal(t, "^h", exp.StdoutMatch[0].String())
+	assert.Equal(t, []string{"oops"}, exp.StderrContains)
+	assert.Nil(t, exp.Stdout)
+
+	exp, ok, err = FromBlock(blocks[1])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NotNil(t, exp.Stdout)
+	assert.Equal(t, "one", *exp.Stdout)
+
+	_, ok, err = FromBlock(blocks[2])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	blocks = parseBlocks(t, "```sh {\"name\":\"bad\",\"expect-exit\":\"x\"}\nexit 1\n```\n")
+	_, _, err = FromBlock(blocks[0])
+	require.ErrorContains(t, err, `invalid expect-exit "x"`)
+}
+
+func TestCheck(t *testing.T) {
+	one := 1
+	stdout := "hello\nworld"
+
+	exp := Expectation{
+		ExitCode:       &one,
+		Stdout:         &stdout,
+		StdoutContains: []string{"world"},
+		StderrContains: []string{"warn"},
+	}
+
+	assert.Empty(t, Check(exp, Result{ExitCode: 1, Stdout: "\x1b[1mhello\x1b[0m\r\nworld\n", Stderr: "warn\n"}))
+
+	assert.Equal(t, []string{
+		"exit code: expected 1, got 0",
+		`stdout: expected "hello\nworld", got "bye"`,
+		`stdout: expected to contain "world"`,
+		`stderr: expected to contain "warn"`,
+	}, Check(exp, Result{Stdout: "bye"}))
+
+	assert.Equal(t, []string{"failed to run: boom"}, Check(exp, Result{Err: fmt.Errorf("boom")}))
+
+	assert.Empty(t, Check(Expectation{}, Result{Stdout: "anything"}))
+}
+
+func TestParseSessionOutputs(t *testing.T) {
+	data := []byte(`---
+runme:
+  id: 01HJP23P1R57BPGEA17QDJXJE
+  version: v3
+  session:
+    id: 01HJP23P1R57BPGEA17QDJXJF
+    updated: 2024-01-02 15:04:05Z
+---
+
+` + "```sh {\"name\":\"hello\"}" + `
+echo hello
+
+# Ran on 2024-01-02 15:04:05Z for 0.1s exited with 0
+hello
+` + "```" + `
+
+` + "```sh {\"name\":\"failing\"}" + `
+exit 2
+
+# Ran on 2024-01-02 15:04:05Z for 0.1s exited with 2
+` + "```" + `
+
+` + "```sh {\"name\":\"not-run\"}" + `
+echo skipped
+` + "```" + `
+`)
+
+	result, err := ParseSessionOutputs(data)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	assert.Equal(t, "hello", *result["hello"].Stdout)
+	assert.Equal(t, 0, *result["hello"].ExitCode)
+	assert.Equal(t, "", *result["failing"].Stdout)
+	assert.Equal(t, 2, *result["failing"].ExitCode)
+
+	_, err = ParseSessionOutputs([]byte("# Not a session\n"))
+	require.ErrorContains(t, err, "not a session outputs document")
+}
+
+var testCases = []Case{
+	{Name: "hello", File: "README.md", Duration: 1500 * time.Millisecond},
+	{Name: "fail", File: "docs/TEST.md", Duration: 250 * time.Millisecond, Failures: []string{"exit code: expected 0, got 1", `stdout: expected to contain "ok"`}, Stdout: "nope\n", Stderr: "a & b\n"},
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteTAP(&buf, testCases))
+	assert.Equal(t, `TAP version 13
+1..2
+ok 1 - hello
+not ok 2 - fail
+  ---
+  file: docs/TEST.md
+  failures:
+    - 'exit code: expected 0, got 1'
+    - 'stdout: expected to contain "ok"'
+  ...
+`, buf.String())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, "runme", testCases))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="runme" tests="2" failures="1" time="1.750">
+    <testcase name="hello" classname="README.md" time="1.500"></testcase>
+    <testcase name="fail" classname="docs/TEST.md" time="0.250">
+      <failure message="exit code: expected 0, got 1">exit code: expected 0, got 1&#xA;stdout: expected to contain &#34;ok&#34;</failure>
+      <system-out>nope&#xA;</system-out>
+      <system-err>a &amp; b&#xA;</system-err>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func TestParseReportFormat(t *testing.T) {
+	f, err := ParseReportFormat("JUnit")
+	require.NoError(t, err)
+	assert.Equal(t, ReportFormatJUnit, f)
+
+	_, err = ParseReportFormat("xunit")
+	require.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package expect
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/pkg/document"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+// ranOnRe matches the summary line which precedes outputs of a cell
+// in session outputs documents, for example,
+// "# Ran on 2024-01-02 15:04:05Z for 1.2s exited with 0".
+var ranOnRe = regexp.MustCompile(`^# Ran on .+ for .+?(?: exited with (-?\d+))?$`)
+
+// ParseSessionOutputs returns expectations of named tasks from
+// a session outputs document which is stored after running cells
+// of a notebook. Cells without outputs are skipped.
+func ParseSessionOutputs(data []byte) (map[string]Expectation, error) {
+	doc := document.New(data, identity.NewResolver(identity.DefaultLifecycleIdentity))
+
+	fmtr, err := doc.FrontmatterWithError()
+	if err != nil {
+		return nil, err
+	}
+	if fmtr == nil || fmtr.Runme.Session.GetID() == "" {
+		return nil, errors.New("not a session outputs document")
+	}
+
+	root, err := doc.Root()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	result := make(map[string]Expectation)
+
+	for _, block := range document.CollectAllCodeBlocks(root) {
+		if block.IsUnnamed() {
+			continue
+		}
+
+		lines := block.Lines()
+		for i, line := range lines {
+			m := ranOnRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			var exp Expectation
+
+			stdout := strings.Join(lines[i+1:], "\n")
+			exp.Stdout = &stdout
+
+			if m[1] != "" {
+				code, _ := strconv.Atoi(m[1])
+				exp.ExitCode = &code
+			}
+
+			result[block.Name()] = exp
+			break
+		}
+	}
+
+	return result, nil
+}
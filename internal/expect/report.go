@@ -0,0 +1,170 @@
+package expect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type ReportFormat string
+
+const (
+	ReportFormatTAP   ReportFormat = "tap"
+	ReportFormatJUnit ReportFormat = "junit"
+)
+
+var ReportFormats = []ReportFormat{ReportFormatTAP, ReportFormatJUnit}
+
+func ParseReportFormat(s string) (ReportFormat, error) {
+	f := ReportFormat(strings.ToLower(s))
+	if !slices.Contains(ReportFormats, f) {
+		return "", errors.Errorf("unsupported report format %q", s)
+	}
+	return f, nil
+}
+
+// Case is a checked task.
+type Case struct {
+	Name     string
+	File     string
+	Duration time.Duration
+	Failures []string
+	Stdout   string
+	Stderr   string
+}
+
+func (c Case) Passed() bool { return len(c.Failures) == 0 }
+
+// WriteReport writes cases in the format to w.
+func WriteReport(w io.Writer, format ReportFormat, suite string, cases []Case) error {
+	switch format {
+	case ReportFormatTAP:
+		return WriteTAP(w, cases)
+	case ReportFormatJUnit:
+		return WriteJUnit(w, suite, cases)
+	default:
+		return errors.Errorf("unsupported report format %q", format)
+	}
+}
+
+// WriteTAP writes cases in the Test Anything Protocol version 13.
+// Failures are described in YAML diagnostic blocks.
+func WriteTAP(w io.Writer, cases []Case) error {
+	var b strings.Builder
+
+	_, _ = fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(cases))
+
+	for i, c := range cases {
+		status := "ok"
+		if !c.Passed() {
+			status = "not ok"
+		}
+		_, _ = fmt.Fprintf(&b, "%s %d - %s\n", status, i+1, c.Name)
+
+		if c.Passed() {
+			continue
+		}
+
+		var diag bytes.Buffer
+		encoder := yaml.NewEncoder(&diag)
+		encoder.SetIndent(2)
+		err := encoder.Encode(struct {
+			File     string   `yaml:"file"`
+			Failures []string `yaml:"failures"`
+		}{c.File, c.Failures})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		_, _ = b.WriteString("  ---\n")
+		for _, line := range strings.Split(strings.TrimRight(diag.String(), "\n"), "\n") {
+			_, _ = b.WriteString("  " + line + "\n")
+		}
+		_, _ = b.WriteString("  ...\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return errors.WithStack(err)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes cases as a JUnit XML test suite. Cases are
+// classified by their files. Outputs are included for failed cases.
+func WriteJUnit(w io.Writer, suite string, cases []Case) error {
+	s := junitTestSuite{Name: suite, Tests: len(cases)}
+
+	var total time.Duration
+
+	for _, c := range cases {
+		total += c.Duration
+
+		tc := junitTestCase{
+			Name:      c.Name,
+			Classname: c.File,
+			Time:      formatSeconds(c.Duration),
+		}
+
+		if !c.Passed() {
+			s.Failures++
+			tc.Failure = &junitFailure{
+				Message: c.Failures[0],
+				Text:    strings.Join(c.Failures, "\n"),
+			}
+			tc.SystemOut = c.Stdout
+			tc.SystemErr = c.Stderr
+		}
+
+		s.Cases = append(s.Cases, tc)
+	}
+
+	s.Time = formatSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{s}}); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return errors.WithStack(err)
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
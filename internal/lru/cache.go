@@ -19,6 +19,7 @@ type Cache[T CacheIdentifier] struct {
 	capacity int
 	mu       sync.RWMutex
 	order    *list.List
+	onEvict  func(T)
 }
 
 func NewCache[T CacheIdentifier](capacity int) *Cache[T] {
@@ -44,9 +45,22 @@ func (sl *Cache[T]) evictUnsafe() {
 	element := sl.order.Back()
 	if element != nil {
 		sl.order.Remove(element)
+		if sl.onEvict != nil {
+			sl.onEvict(element.Value.(*listEntry[T]).entry)
+		}
 	}
 }
 
+// OnEvict sets a function called with entries evicted
+// because the capacity was reached. It is called with
+// the cache locked, hence, it must not use the cache.
+func (sl *Cache[T]) OnEvict(fn func(T)) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.onEvict = fn
+}
+
 func (sl *Cache[T]) Add(entry T) error {
 	sl.mu.Lock()
 	defer sl.mu.Unlock()
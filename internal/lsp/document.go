@@ -0,0 +1,422 @@
+package lsp
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/stateful/runme/v3/internal/command"
+	"github.com/stateful/runme/v3/pkg/document"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+// textDocument is a markdown document opened in the editor.
+type textDocument struct {
+	uri     DocumentURI
+	version int
+	text    []byte
+	// lineStarts contains offsets of the beginning of each line.
+	lineStarts []int
+
+	doc      *document.Document
+	blocks   document.CodeBlocks
+	parseErr error
+}
+
+func newTextDocument(uri DocumentURI, version int, text string) *textDocument {
+	d := &textDocument{
+		uri:     uri,
+		version: version,
+		text:    []byte(text),
+	}
+
+	d.lineStarts = append(d.lineStarts, 0)
+	for i, b := range d.text {
+		if b == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	d.doc = document.New(d.text, identity.NewResolver(identity.UnspecifiedLifecycleIdentity))
+	node, err := d.doc.Root()
+	if err != nil {
+		d.parseErr = err
+		return d
+	}
+	d.blocks = document.CollectCodeBlocks(node)
+
+	return d
+}
+
+// path returns a file system path of the document or
+// an empty string if the URI does not use the file scheme.
+func (d *textDocument) path() string {
+	return uriToPath(d.uri)
+}
+
+func uriToPath(uri DocumentURI) string {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func (d *textDocument) line(n int) []byte {
+	if n < 0 || n >= len(d.lineStarts) {
+		return nil
+	}
+	end := len(d.text)
+	if n+1 < len(d.lineStarts) {
+		end = d.lineStarts[n+1]
+	}
+	return bytes.TrimRight(d.text[d.lineStarts[n]:end], "\r\n")
+}
+
+// offsetToPosition converts a byte offset into an LSP position
+// with the character counted in UTF-16 code units.
+func (d *textDocument) offsetToPosition(offset int) Position {
+	offset = max(0, min(offset, len(d.text)))
+
+	line := 0
+	for line+1 < len(d.lineStarts) && d.lineStarts[line+1] <= offset {
+		line++
+	}
+
+	return Position{
+		Line:      line,
+		Character: utf16Len(d.text[d.lineStarts[line]:offset]),
+	}
+}
+
+// positionToOffset converts an LSP position into a byte offset.
+func (d *textDocument) positionToOffset(pos Position) int {
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	lineStart := d.lineStarts[pos.Line]
+	line := d.line(pos.Line)
+
+	units := 0
+	for i, r := range string(line) {
+		if units >= pos.Character {
+			return lineStart + i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return lineStart + len(line)
+}
+
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *textDocument) toRange(start, end document.Position) Range {
+	return Range{Start: d.offsetToPosition(start.Offset), End: d.offsetToPosition(end.Offset)}
+}
+
+func (d *textDocument) Diagnostics() []Diagnostic {
+	if d.parseErr != nil {
+		return []Diagnostic{
+			{
+				Range:    Range{},
+				Severity: DiagnosticSeverityError,
+				Source:   "runme",
+				Message:  d.parseErr.Error(),
+			},
+		}
+	}
+
+	diagnostics, err := d.doc.Validate()
+	if err != nil {
+		return nil
+	}
+
+	result := make([]Diagnostic, 0, len(diagnostics))
+	for _, diag := range diagnostics {
+		severity := DiagnosticSeverityWarning
+		if diag.Severity == document.DiagnosticSeverityError {
+			severity = DiagnosticSeverityError
+		}
+
+		result = append(result, Diagnostic{
+			Range:    d.toRange(diag.Start, diag.End),
+			Severity: severity,
+			Source:   "runme",
+			Message:  diag.Message,
+		})
+	}
+	return result
+}
+
+// blockRange returns a range of the code block from the opening fence
+// to the closing one. It returns false if the block has no position.
+func (d *textDocument) blockRange(block *document.CodeBlock) (Range, bool) {
+	spans := block.Spans()
+	if spans.Fence.IsZero() && spans.Content.IsZero() {
+		return Range{}, false
+	}
+
+	start, end := spans.Content.Start, spans.Content.End
+	if !spans.Fence.IsZero() {
+		start = spans.Fence.Start
+	}
+	if !spans.ClosingFence.IsZero() {
+		end = spans.ClosingFence.End
+	}
+
+	r := Range{Start: d.offsetToPosition(start.Offset), End: d.offsetToPosition(end.Offset)}
+	r.Start.Character = 0
+	r.End.Character = utf16Len(d.line(r.End.Line))
+
+	return r, true
+}
+
+// headerRange returns a range of the first line of the code block.
+func (d *textDocument) headerRange(block *document.CodeBlock) (Range, bool) {
+	r, ok := d.blockRange(block)
+	if !ok {
+		return Range{}, false
+	}
+	return Range{
+		Start: r.Start,
+		End:   Position{Line: r.Start.Line, Character: utf16Len(d.line(r.Start.Line))},
+	}, true
+}
+
+func (d *textDocument) blockAt(pos Position) *document.CodeBlock {
+	for _, block := range d.blocks {
+		if r, ok := d.blockRange(block); ok && r.Contains(pos) {
+			return block
+		}
+	}
+	return nil
+}
+
+func (d *textDocument) blockByName(name string) *document.CodeBlock {
+	for _, block := range d.blocks {
+		if block.Name() == name {
+			return block
+		}
+	}
+	return nil
+}
+
+func (d *textDocument) Symbols() []DocumentSymbol {
+	var result []DocumentSymbol
+	for _, block := range d.blocks {
+		if block.IsUnnamed() {
+			continue
+		}
+		r, ok := d.blockRange(block)
+		if !ok {
+			continue
+		}
+		header, _ := d.headerRange(block)
+		result = append(result, DocumentSymbol{
+			Name:           block.Name(),
+			Detail:         block.Language(),
+			Kind:           SymbolKindFunction,
+			Range:          r,
+			SelectionRange: header,
+		})
+	}
+	return result
+}
+
+const runCellCommand = "runme.runCell"
+
+func (d *textDocument) CodeLenses() []CodeLens {
+	var result []CodeLens
+	for _, block := range d.blocks {
+		if block.IsUnknown() {
+			continue
+		}
+		header, ok := d.headerRange(block)
+		if !ok {
+			continue
+		}
+		result = append(result, CodeLens{
+			Range: header,
+			Command: &Command{
+				Title:     "▶ Run " + block.Name(),
+				Command:   runCellCommand,
+				Arguments: []any{string(d.uri), block.Name()},
+			},
+		})
+	}
+	return result
+}
+
+var (
+	fenceWithAttributesRe = regexp.MustCompile("^\\s*(```|~~~)[^{]*\\{")
+	attributeValueRe      = regexp.MustCompile(`"?([A-Za-z]+)"?\s*[=:]\s*"?([^\s",}]*)$`)
+	envVarPrefixRe        = regexp.MustCompile(`\$\{?([A-Za-z0-9_]*)$`)
+)
+
+// Completion returns completion items for attribute names and values
+// when the position is within the attributes of a fence, and
+// environment variables when the position is within a code block.
+func (d *textDocument) Completion(pos Position, envKeys []EnvVar) []CompletionItem {
+	line := d.line(pos.Line)
+	offset := d.positionToOffset(pos) - d.lineStarts[pos.Line]
+	before := string(line[:max(0, min(offset, len(line)))])
+
+	if loc := fenceWithAttributesRe.FindStringIndex(before); loc != nil && !strings.Contains(before[loc[1]:], "}") {
+		attrs := before[loc[1]:]
+		if m := attributeValueRe.FindStringSubmatch(attrs); m != nil {
+			return attributeValueCompletion(m[1])
+		}
+		return attributeNameCompletion()
+	}
+
+	if d.blockAt(pos) == nil {
+		return nil
+	}
+
+	m := envVarPrefixRe.FindStringSubmatch(before)
+	if m == nil {
+		return nil
+	}
+
+	var result []CompletionItem
+	for _, env := range envKeys {
+		if !strings.HasPrefix(env.Name, m[1]) {
+			continue
+		}
+		result = append(result, CompletionItem{
+			Label:         env.Name,
+			Kind:          CompletionItemKindVariable,
+			Detail:        env.Detail,
+			Documentation: env.Description,
+		})
+	}
+	return result
+}
+
+func attributeNameCompletion() []CompletionItem {
+	specs := document.KnownAttributes()
+	result := make([]CompletionItem, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Deprecated != "" {
+			continue
+		}
+		result = append(result, CompletionItem{
+			Label:         spec.Name,
+			Kind:          CompletionItemKindProperty,
+			Detail:        spec.Type.String(),
+			Documentation: spec.Description,
+		})
+	}
+	return result
+}
+
+func attributeValueCompletion(name string) []CompletionItem {
+	spec, ok := document.LookupAttribute(name)
+	if !ok {
+		return nil
+	}
+
+	var values []string
+	switch spec.Type {
+	case document.AttributeTypeBool:
+		values = []string{"true", "false"}
+	case document.AttributeTypeEnum:
+		values = spec.Values
+	}
+
+	result := make([]CompletionItem, 0, len(values))
+	for _, v := range values {
+		result = append(result, CompletionItem{
+			Label:  v,
+			Kind:   CompletionItemKindValue,
+			Detail: spec.Name,
+		})
+	}
+	return result
+}
+
+// Hover describes the code block at the position including
+// the program that will be used to execute it.
+func (d *textDocument) Hover(pos Position) *Hover {
+	block := d.blockAt(pos)
+	if block == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "**%s**", block.Name())
+	if lang := block.Language(); lang != "" {
+		_, _ = fmt.Fprintf(&b, " (`%s`)", lang)
+	}
+	_, _ = b.WriteString("\n\n")
+
+	program, resolved := resolveProgram(block)
+	if program == "" {
+		_, _ = b.WriteString("Program: _unknown_\n")
+	} else if resolved != "" {
+		_, _ = fmt.Fprintf(&b, "Program: `%s` → `%s`\n", program, resolved)
+	} else {
+		_, _ = fmt.Fprintf(&b, "Program: `%s` (not found in PATH)\n", program)
+	}
+
+	if cwd := block.Cwd(); cwd != "" {
+		_, _ = fmt.Fprintf(&b, "\nCwd: `%s`\n", cwd)
+	}
+	if block.Interactive() {
+		_, _ = b.WriteString("\nInteractive: `true`\n")
+	}
+	if block.Background() {
+		_, _ = b.WriteString("\nBackground: `true`\n")
+	}
+
+	r, _ := d.blockRange(block)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: b.String()},
+		Range:    &r,
+	}
+}
+
+// resolveProgram returns the program configured for the block and
+// its path found in PATH. If the program is not configured explicitly,
+// the interpreter is inferred from the language.
+func resolveProgram(block *document.CodeBlock) (program, resolved string) {
+	cfg, err := command.NewProgramConfigFromCodeBlock(block)
+	if err == nil && cfg.ProgramName != "" {
+		program = cfg.ProgramName
+	}
+
+	candidates := []string{program}
+	if program == "" {
+		candidates = command.InferInterpreterFromLanguage(block.Language())
+	}
+
+	for _, candidate := range candidates {
+		name, _, _ := strings.Cut(candidate, " ")
+		if path, err := exec.LookPath(name); err == nil {
+			return candidate, path
+		}
+	}
+
+	if len(candidates) > 0 {
+		program = candidates[0]
+	}
+	return program, ""
+}
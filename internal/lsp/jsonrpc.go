@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// JSON-RPC 2.0 error codes used by LSP.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	// codeRequestCancelled is returned for requests
+	// cancelled with the $/cancelRequest notification.
+	codeRequestCancelled = -32800
+)
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool { return m.ID != nil && m.Method != "" }
+
+func (m *message) isNotification() bool { return m.ID == nil && m.Method != "" }
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// conn reads and writes JSON-RPC messages framed
+// with the Content-Length header as required by LSP.
+type conn struct {
+	reader *textproto.Reader
+	buf    *bufio.Reader
+
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	buf := bufio.NewReader(r)
+	return &conn{
+		reader: textproto.NewReader(buf),
+		buf:    buf,
+		writer: w,
+	}
+}
+
+func (c *conn) Read() (*message, error) {
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Content-Length header")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.buf, data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+func (c *conn) Write(msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = c.writer.Write(data)
+	return errors.WithStack(err)
+}
+
+func (c *conn) Reply(id *json.RawMessage, result any, respErr error) error {
+	msg := &message{ID: id}
+
+	if respErr != nil {
+		var rpcErr *responseError
+		if !errors.As(respErr, &rpcErr) {
+			rpcErr = &responseError{Code: codeInternalError, Message: respErr.Error()}
+		}
+		msg.Error = rpcErr
+		return c.Write(msg)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	msg.Result = data
+
+	return c.Write(msg)
+}
+
+func (c *conn) Notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Write(&message{Method: method, Params: data})
+}
@@ -0,0 +1,390 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/stateful/runme/v3/internal/runner"
+	"github.com/stateful/runme/v3/internal/version"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+// EnvVar is a known environment variable offered in completions.
+type EnvVar struct {
+	Name        string
+	Detail      string
+	Description string
+}
+
+// Executor executes a task and writes its output to w.
+// It is used to run cells using the code lens commands.
+type Executor func(ctx context.Context, task project.Task, w io.Writer) error
+
+type Options struct {
+	// EnvVars are environment variables offered in completions.
+	EnvVars []EnvVar
+	// Executor runs cells. If nil, the run command is not available.
+	Executor Executor
+	Logger   *zap.Logger
+}
+
+// Server is a Language Server Protocol server for runme markdown files.
+// It communicates over a single stream, usually stdio.
+//
+// Requests are handled in order except for running cells which
+// happens in the background and can be cancelled with $/cancelRequest.
+type Server struct {
+	conn *conn
+	opts Options
+
+	mu        sync.Mutex
+	documents map[DocumentURI]*textDocument
+	shutdown  bool
+	// running contains cancel functions of requests
+	// handled in the background keyed by the request ID.
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// backgroundRequest is returned by handleRequest for requests
+// which are handled in the background.
+type backgroundRequest func(ctx context.Context) (any, error)
+
+func NewServer(r io.Reader, w io.Writer, opts Options) *Server {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	return &Server{
+		conn:      newConn(r, w),
+		opts:      opts,
+		documents: make(map[DocumentURI]*textDocument),
+		running:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve handles messages until the client sends the exit
+// notification or the stream is closed. Requests handled
+// in the background are cancelled before it returns.
+func (s *Server) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+
+	for {
+		msg, err := s.conn.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rpcErr *responseError
+			if errors.As(err, &rpcErr) {
+				_ = s.conn.Reply(nil, nil, rpcErr)
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		switch {
+		case msg.isRequest():
+			result, err := s.handleRequest(ctx, msg)
+			if run, ok := result.(backgroundRequest); ok && err == nil {
+				s.runInBackground(ctx, msg, run)
+				continue
+			}
+			if err := s.reply(msg, result, err); err != nil {
+				return err
+			}
+		case msg.isNotification():
+			if err := s.handleNotification(msg); err != nil {
+				s.opts.Logger.Info("notification failed", zap.String("method", msg.Method), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *Server) reply(msg *message, result any, err error) error {
+	if err != nil {
+		s.opts.Logger.Info("request failed", zap.String("method", msg.Method), zap.Error(err))
+	}
+	return s.conn.Reply(msg.ID, result, err)
+}
+
+// runInBackground handles the request in a goroutine. The request
+// can be cancelled using its ID until the reply is sent.
+func (s *Server) runInBackground(ctx context.Context, msg *message, run backgroundRequest) {
+	ctx, cancel := context.WithCancel(ctx)
+	id := string(*msg.ID)
+
+	s.mu.Lock()
+	s.running[id] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		result, err := run(ctx)
+		if ctx.Err() != nil {
+			result, err = nil, &responseError{Code: codeRequestCancelled, Message: "request cancelled"}
+		}
+
+		s.mu.Lock()
+		delete(s.running, id)
+		s.mu.Unlock()
+
+		_ = s.reply(msg, result, err)
+	}()
+}
+
+func (s *Server) cancelRequest(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.running[string(id)]; ok {
+		cancel()
+	}
+}
+
+func (s *Server) handleRequest(ctx context.Context, msg *message) (any, error) {
+	s.mu.Lock()
+	isShutdown := s.shutdown
+	s.mu.Unlock()
+
+	if isShutdown && msg.Method != "shutdown" {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.document(params.TextDocument.URI); doc != nil {
+			return emptyIfNil(doc.Symbols()), nil
+		}
+		return []DocumentSymbol{}, nil
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.document(params.TextDocument.URI); doc != nil && s.opts.Executor != nil {
+			return emptyIfNil(doc.CodeLenses()), nil
+		}
+		return []CodeLens{}, nil
+	case "workspace/executeCommand":
+		var params ExecuteCommandParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.executeCommand(params)
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+	}
+}
+
+func (s *Server) handleNotification(msg *message) error {
+	switch msg.Method {
+	case "$/cancelRequest":
+		var params CancelParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return err
+		}
+		s.cancelRequest(params.ID)
+		return nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return err
+		}
+		item := params.TextDocument
+		return s.update(newTextDocument(item.URI, item.Version, item.Text))
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil
+		}
+		// Only full sync is supported, so the last change contains the whole text.
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return s.update(newTextDocument(params.TextDocument.URI, params.TextDocument.Version, text))
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		delete(s.documents, params.TextDocument.URI)
+		s.mu.Unlock()
+		return s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+	return nil
+}
+
+func (s *Server) initialize() InitializeResult {
+	capabilities := ServerCapabilities{
+		TextDocumentSync: TextDocumentSyncKindFull,
+		CompletionProvider: &CompletionOptions{
+			TriggerCharacters: []string{"{", " ", ",", "$", "="},
+		},
+		HoverProvider:          true,
+		DocumentSymbolProvider: true,
+	}
+
+	if s.opts.Executor != nil {
+		capabilities.CodeLensProvider = &CodeLensOptions{}
+		capabilities.ExecuteCommandProvider = &ExecuteCommandOptions{Commands: []string{runCellCommand}}
+	}
+
+	return InitializeResult{
+		Capabilities: capabilities,
+		ServerInfo:   &ServerInfo{Name: "runme", Version: version.BaseVersion()},
+	}
+}
+
+func (s *Server) document(uri DocumentURI) *textDocument {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.documents[uri]
+}
+
+func (s *Server) update(doc *textDocument) error {
+	s.mu.Lock()
+	s.documents[doc.uri] = doc
+	s.mu.Unlock()
+
+	return s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: emptyIfNil(doc.Diagnostics()),
+	})
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) CompletionList {
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return CompletionList{Items: []CompletionItem{}}
+	}
+	return CompletionList{Items: emptyIfNil(doc.Completion(params.Position, s.opts.EnvVars))}
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+	return doc.Hover(params.Position)
+}
+
+// RunCellResult is returned by the run cell command.
+type RunCellResult struct {
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+}
+
+// executeCommand finds the cell to run right away, so that later changes
+// of the document do not affect it, and runs it in the background.
+func (s *Server) executeCommand(params ExecuteCommandParams) (any, error) {
+	if params.Command != runCellCommand || s.opts.Executor == nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command %q", params.Command)}
+	}
+
+	if len(params.Arguments) != 2 {
+		return nil, &responseError{Code: codeInvalidParams, Message: "expected document URI and cell name"}
+	}
+	uri, _ := params.Arguments[0].(string)
+	name, _ := params.Arguments[1].(string)
+
+	doc := s.document(DocumentURI(uri))
+	if doc == nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document %q is not open", uri)}
+	}
+
+	block := doc.blockByName(name)
+	if block == nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("cell %q not found", name)}
+	}
+
+	task := project.Task{
+		CodeBlock:       block,
+		DocumentPath:    doc.path(),
+		RelDocumentPath: doc.path(),
+	}
+
+	return backgroundRequest(func(ctx context.Context) (any, error) {
+		return s.runCell(ctx, task)
+	}), nil
+}
+
+func (s *Server) runCell(ctx context.Context, task project.Task) (any, error) {
+	var output bytes.Buffer
+	err := s.opts.Executor(ctx, task, &output)
+
+	result := RunCellResult{Output: output.String()}
+	if exitErr := (*runner.ExitError)(nil); errors.As(err, &exitErr) {
+		result.ExitCode = int(exitErr.Code)
+	} else if err != nil {
+		return nil, err
+	}
+
+	_ = s.conn.Notify("window/logMessage", LogMessageParams{
+		Type:    MessageTypeLog,
+		Message: fmt.Sprintf("Task %s exited with code %d\n%s", task.CodeBlock.Name(), result.ExitCode, result.Output),
+	})
+
+	return result, nil
+}
+
+func unmarshalParams(msg *message, v any) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// emptyIfNil makes sure that nil slices are encoded as empty JSON arrays.
+func emptyIfNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,290 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stateful/runme/v3/internal/runner"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+const testURI = DocumentURI("file:///tmp/README.md")
+
+var testDocument = strings.Join([]string{
+	"# Test",
+	"",
+	"```sh { name=hello interactve=false background=true }",
+	"echo $OPENAI_",
+	"```",
+	"",
+	"```sh",
+	"ls -la",
+	"```",
+	"",
+}, "\n")
+
+type testClient struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// notifications received while waiting for responses.
+	notifications []*message
+}
+
+func newTestClient(t *testing.T, opts Options) *testClient {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server := NewServer(serverReader, serverWriter, opts)
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(context.Background()) }()
+
+	t.Cleanup(func() {
+		_ = clientWriter.Close()
+		select {
+		case err := <-errc:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Error("server did not stop")
+		}
+	})
+
+	return &testClient{t: t, conn: newConn(clientReader, clientWriter)}
+}
+
+func (c *testClient) call(method string, params any, result any) {
+	c.t.Helper()
+
+	msg := c.response(c.send(method, params))
+	require.Nil(c.t, msg.Error)
+	if result != nil {
+		require.NoError(c.t, json.Unmarshal(msg.Result, result))
+	}
+}
+
+// send writes a request without waiting for the response.
+func (c *testClient) send(method string, params any) json.RawMessage {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(fmt.Sprintf("%d", c.nextID))
+	data, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.Write(&message{ID: &id, Method: method, Params: data}))
+	return id
+}
+
+func (c *testClient) response(id json.RawMessage) *message {
+	c.t.Helper()
+
+	for {
+		msg, err := c.conn.Read()
+		require.NoError(c.t, err)
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		require.Equal(c.t, string(id), string(*msg.ID))
+		return msg
+	}
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.Notify(method, params))
+}
+
+func (c *testClient) waitNotification(method string, result any) {
+	c.t.Helper()
+
+	for i, msg := range c.notifications {
+		if msg.Method == method {
+			c.notifications = append(c.notifications[:i], c.notifications[i+1:]...)
+			require.NoError(c.t, json.Unmarshal(msg.Params, result))
+			return
+		}
+	}
+
+	for {
+		msg, err := c.conn.Read()
+		require.NoError(c.t, err)
+		if msg.Method == method {
+			require.NoError(c.t, json.Unmarshal(msg.Params, result))
+			return
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	var executed project.Task
+
+	client := newTestClient(t, Options{
+		EnvVars: []EnvVar{{Name: "OPENAI_API_KEY"}, {Name: "OPENAI_ORG_ID"}, {Name: "DATABASE_URL"}},
+		Executor: func(_ context.Context, task project.Task, w io.Writer) error {
+			executed = task
+			_, _ = io.WriteString(w, "hello\n")
+			return &runner.ExitError{Code: 3}
+		},
+	})
+
+	var initResult InitializeResult
+	client.call("initialize", InitializeParams{}, &initResult)
+	assert.True(t, initResult.Capabilities.HoverProvider)
+	assert.Equal(t, []string{runCellCommand}, initResult.Capabilities.ExecuteCommandProvider.Commands)
+
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "markdown", Version: 1, Text: testDocument},
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		var params PublishDiagnosticsParams
+		client.waitNotification("textDocument/publishDiagnostics", &params)
+		require.Len(t, params.Diagnostics, 1)
+		assert.Equal(t, DiagnosticSeverityWarning, params.Diagnostics[0].Severity)
+		assert.Equal(t, Range{Start: Position{Line: 2, Character: 19}, End: Position{Line: 2, Character: 29}}, params.Diagnostics[0].Range)
+	})
+
+	t.Run("CompletionAttributeName", func(t *testing.T) {
+		var list CompletionList
+		client.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Position:     Position{Line: 2, Character: 8},
+		}, &list)
+		labels := completionLabels(list)
+		assert.Contains(t, labels, "interactive")
+		assert.Contains(t, labels, "terminalRows")
+		assert.NotContains(t, labels, "category")
+	})
+
+	t.Run("CompletionAttributeValue", func(t *testing.T) {
+		var list CompletionList
+		client.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Position:     Position{Line: 2, Character: 47},
+		}, &list)
+		assert.Equal(t, []string{"true", "false"}, completionLabels(list))
+	})
+
+	t.Run("CompletionEnvVar", func(t *testing.T) {
+		var list CompletionList
+		client.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Position:     Position{Line: 3, Character: 13},
+		}, &list)
+		assert.Equal(t, []string{"OPENAI_API_KEY", "OPENAI_ORG_ID"}, completionLabels(list))
+	})
+
+	t.Run("Hover", func(t *testing.T) {
+		var hover Hover
+		client.call("textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Position:     Position{Line: 3, Character: 1},
+		}, &hover)
+		assert.Contains(t, hover.Contents.Value, "**hello** (`sh`)")
+		assert.Contains(t, hover.Contents.Value, "Background: `true`")
+		assert.Equal(t, Range{Start: Position{Line: 2}, End: Position{Line: 4, Character: 3}}, *hover.Range)
+	})
+
+	t.Run("DocumentSymbol", func(t *testing.T) {
+		var symbols []DocumentSymbol
+		client.call("textDocument/documentSymbol", DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+		}, &symbols)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "hello", symbols[0].Name)
+		assert.Equal(t, 2, symbols[0].SelectionRange.Start.Line)
+	})
+
+	t.Run("CodeLensAndExecute", func(t *testing.T) {
+		var lenses []CodeLens
+		client.call("textDocument/codeLens", CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+		}, &lenses)
+		require.Len(t, lenses, 2)
+		assert.Equal(t, 6, lenses[1].Range.Start.Line)
+
+		var result RunCellResult
+		client.call("workspace/executeCommand", ExecuteCommandParams{
+			Command:   lenses[0].Command.Command,
+			Arguments: lenses[0].Command.Arguments,
+		}, &result)
+		assert.Equal(t, RunCellResult{ExitCode: 3, Output: "hello\n"}, result)
+		assert.Equal(t, "hello", executed.CodeBlock.Name())
+		assert.Equal(t, "/tmp/README.md", executed.DocumentPath)
+	})
+
+	t.Run("DidChange", func(t *testing.T) {
+		client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: "# Empty\n"}},
+		})
+
+		var params PublishDiagnosticsParams
+		client.waitNotification("textDocument/publishDiagnostics", &params)
+		assert.Equal(t, 2, params.Version)
+		assert.Empty(t, params.Diagnostics)
+	})
+
+	client.call("shutdown", nil, nil)
+	client.notify("exit", nil)
+}
+
+func TestServerCancelRequest(t *testing.T) {
+	started := make(chan struct{})
+
+	client := newTestClient(t, Options{
+		Executor: func(ctx context.Context, _ project.Task, _ io.Writer) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	client.call("initialize", InitializeParams{}, nil)
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "markdown", Version: 1, Text: testDocument},
+	})
+	var diagnostics PublishDiagnosticsParams
+	client.waitNotification("textDocument/publishDiagnostics", &diagnostics)
+
+	id := client.send("workspace/executeCommand", ExecuteCommandParams{
+		Command:   runCellCommand,
+		Arguments: []any{string(testURI), "hello"},
+	})
+	<-started
+
+	// Other requests are handled while the cell is running.
+	var hover *Hover
+	client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: 2, Character: 8},
+	}, &hover)
+	require.NotNil(t, hover)
+
+	client.notify("$/cancelRequest", CancelParams{ID: id})
+
+	msg := client.response(id)
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, codeRequestCancelled, msg.Error.Code)
+
+	client.call("shutdown", nil, nil)
+	client.notify("exit", nil)
+}
+
+func completionLabels(list CompletionList) (result []string) {
+	for _, item := range list.Items {
+		result = append(result, item.Label)
+	}
+	return
+}
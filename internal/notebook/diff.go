@@ -0,0 +1,315 @@
+package notebook
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/stateful/runme/v3/pkg/document/editor"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+type CellChangeKind int
+
+const (
+	CellAdded CellChangeKind = iota + 1
+	CellDeleted
+	CellModified
+)
+
+func (k CellChangeKind) String() string {
+	switch k {
+	case CellAdded:
+		return "added"
+	case CellDeleted:
+		return "deleted"
+	case CellModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// AttributeChange is a change of a single cell attribute.
+// Old or New is empty if the attribute was added or removed.
+type AttributeChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// CellChange describes how a cell differs between two notebooks.
+// Cells are matched by their "id" attribute, then by name,
+// and finally by content.
+type CellChange struct {
+	Kind CellChangeKind
+	// OldIndex and NewIndex are indices of the cell in the respective
+	// notebooks. They are -1 if the cell is missing in the notebook.
+	OldIndex int
+	NewIndex int
+	Old      *editor.Cell
+	New      *editor.Cell
+	// ValueChanged is true if the code or the language changed.
+	ValueChanged bool
+	// Attributes contains changes of attributes, excluding internal ones.
+	Attributes []AttributeChange
+	// OutputsChanged is true if text or image outputs changed.
+	OutputsChanged bool
+	// Moved is true if the cell changed its position relative to other cells.
+	Moved bool
+}
+
+// MetadataOnly returns true if the change affects only
+// attributes or outputs, but not the code.
+func (c CellChange) MetadataOnly() bool {
+	return c.Kind == CellModified && !c.ValueChanged && !c.Moved
+}
+
+// DiffOptions configures [Diff].
+type DiffOptions struct {
+	// IgnoreOutputs skips changes of cell outputs.
+	IgnoreOutputs bool
+}
+
+// Diff compares two markdown documents cell by cell.
+// Changes are returned in the order of the cells in b. Deleted cells
+// follow the closest preceding cell in a which kept its position.
+func Diff(a, b []byte, opts DiffOptions) ([]CellChange, error) {
+	oldEntries, err := deserializeEntries(a)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := deserializeEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	oldByKey := make(map[string]int, len(oldEntries))
+	for i, e := range oldEntries {
+		oldByKey[e.key] = i
+	}
+
+	// Matched cells which are not a part of the longest
+	// increasing subsequence of old indices were moved.
+	var matched []int
+	for _, e := range newEntries {
+		if i, ok := oldByKey[e.key]; ok {
+			matched = append(matched, i)
+		}
+	}
+	inOrder := make(map[int]bool)
+	for _, i := range longestIncreasingSubsequence(matched) {
+		inOrder[i] = true
+	}
+
+	newKeys := make(map[string]bool, len(newEntries))
+	for _, e := range newEntries {
+		newKeys[e.key] = true
+	}
+
+	var result []CellChange
+
+	deleted := func(upTo int) {
+		for i, e := range oldEntries[:upTo] {
+			if newKeys[e.key] {
+				continue
+			}
+			newKeys[e.key] = true // report only once
+			result = append(result, CellChange{
+				Kind:     CellDeleted,
+				OldIndex: e.index,
+				NewIndex: -1,
+				Old:      oldEntries[i].cell,
+			})
+		}
+	}
+
+	for _, e := range newEntries {
+		i, ok := oldByKey[e.key]
+		if !ok {
+			result = append(result, CellChange{
+				Kind:     CellAdded,
+				OldIndex: -1,
+				NewIndex: e.index,
+				New:      e.cell,
+			})
+			continue
+		}
+
+		if inOrder[i] {
+			deleted(i)
+		}
+
+		old := oldEntries[i]
+		change := CellChange{
+			Kind:         CellModified,
+			OldIndex:     old.index,
+			NewIndex:     e.index,
+			Old:          old.cell,
+			New:          e.cell,
+			ValueChanged: old.code != e.code || old.cell.LanguageID != e.cell.LanguageID,
+			Attributes:   diffAttributes(old.attributes(), e.attributes()),
+			Moved:        !inOrder[i],
+		}
+		if !opts.IgnoreOutputs {
+			change.OutputsChanged = old.outputs() != e.outputs()
+		}
+
+		if change.ValueChanged || change.OutputsChanged || change.Moved || len(change.Attributes) > 0 {
+			result = append(result, change)
+		}
+	}
+	deleted(len(oldEntries))
+
+	return result, nil
+}
+
+func diffAttributes(a, b map[string]string) (result []AttributeChange) {
+	keys := slices.Collect(maps.Keys(a))
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if a[k] != b[k] {
+			result = append(result, AttributeChange{Name: k, Old: a[k], New: b[k]})
+		}
+	}
+	return result
+}
+
+// cellEntry is a cell along with its outputs. Outputs are split
+// from the code as they are serialized into the markdown by [editor.Serialize].
+type cellEntry struct {
+	// index is the index of the cell in the notebook.
+	index int
+	// key identifies the cell across versions of the notebook.
+	key  string
+	cell *editor.Cell
+	// code is the value of the cell without text outputs.
+	code string
+	// output is the text output, starting with the execution summary.
+	output string
+	// images are markup cells with image outputs following the code cell.
+	images []*editor.Cell
+}
+
+// textOutputSeparator precedes text outputs in the value of a code cell.
+const textOutputSeparator = "\n\n# Ran on "
+
+var imageOutputRe = regexp.MustCompile(`^!\[[^\n]*\]\(data:image/[^;]+;base64,[^)]*\)\s*$`)
+
+// attributes returns attributes of the cell without internal ones.
+func (e *cellEntry) attributes() map[string]string {
+	result := make(map[string]string)
+	if e.cell.Kind != editor.CodeKind {
+		return result
+	}
+	for k, v := range e.cell.Metadata {
+		if k == "index" || strings.HasPrefix(k, editor.InternalAttributePrefix) || strings.HasPrefix(k, editor.PrivateAttributePrefix) {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// outputs returns a string representation of all outputs used for comparison.
+func (e *cellEntry) outputs() string {
+	var b strings.Builder
+	_, _ = b.WriteString(e.output)
+	for _, image := range e.images {
+		_ = b.WriteByte('\n')
+		_, _ = b.WriteString(image.Value)
+	}
+	return b.String()
+}
+
+func deserializeEntries(source []byte) ([]*cellEntry, error) {
+	notebook, err := editor.Deserialize(source, editor.Options{
+		IdentityResolver: identity.NewResolver(identity.UnspecifiedLifecycleIdentity),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toEntries(notebook), nil
+}
+
+func toEntries(notebook *editor.Notebook) (result []*cellEntry) {
+	// The resolver does not generate identities; it only
+	// returns valid identities found in the attributes.
+	resolver := identity.NewResolver(identity.UnspecifiedLifecycleIdentity)
+	occurrences := make(map[string]int)
+
+	var last *cellEntry
+	for i, cell := range notebook.Cells {
+		if cell.Kind == editor.MarkupKind && last != nil && last.cell.Kind == editor.CodeKind && imageOutputRe.MatchString(cell.Value) {
+			last.images = append(last.images, cell)
+			continue
+		}
+
+		e := &cellEntry{index: i, cell: cell, code: cell.Value}
+		if cell.Kind == editor.CodeKind {
+			if idx := strings.Index(cell.Value, textOutputSeparator); idx >= 0 {
+				e.code, e.output = cell.Value[:idx], cell.Value[idx+2:]
+			}
+		}
+
+		key := cellKey(resolver, e)
+		e.key = fmt.Sprintf("%s#%d", key, occurrences[key])
+		occurrences[key]++
+
+		result = append(result, e)
+		last = e
+	}
+	return result
+}
+
+func cellKey(resolver *identity.IdentityResolver, e *cellEntry) string {
+	if e.cell.Kind != editor.CodeKind {
+		return "markup:" + e.code
+	}
+	if id, ok := resolver.GetCellID(e.cell, e.cell.Metadata); ok {
+		return "id:" + id
+	}
+	if name, ok := e.cell.Metadata["name"]; ok && name != "" {
+		return "name:" + name
+	}
+	return "code:" + e.cell.LanguageID + ":" + e.code
+}
+
+// longestIncreasingSubsequence returns elements of values
+// forming the longest strictly increasing subsequence.
+func longestIncreasingSubsequence(values []int) []int {
+	if len(values) == 0 {
+		return nil
+	}
+
+	tails := []int{}
+	prev := make([]int, len(values))
+	for i, v := range values {
+		pos := sort.Search(len(tails), func(j int) bool { return values[tails[j]] >= v })
+		if pos > 0 {
+			prev[i] = tails[pos-1]
+		} else {
+			prev[i] = -1
+		}
+		if pos == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[pos] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = values[k]
+	}
+	return result
+}
@@ -0,0 +1,349 @@
+package notebook
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/pkg/document"
+	"github.com/stateful/runme/v3/pkg/document/editor"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+// MergeConflict is a conflict which could not be resolved automatically.
+type MergeConflict struct {
+	// Cell is the cell in the merged result containing the conflict.
+	Cell   *editor.Cell
+	Reason string
+}
+
+// MergeResult is the result of a three-way merge.
+type MergeResult struct {
+	Source []byte
+	// Conflicts are conflicts of cell values. Conflicting values
+	// are written into the cells with conflict markers.
+	Conflicts []MergeConflict
+	// Resolved is the number of conflicts of attributes, outputs,
+	// or frontmatter which were resolved in favor of ours.
+	Resolved int
+}
+
+// Merge performs a three-way merge of markdown documents. Cells are matched
+// the same way as in [Diff]. The result is based on ours, including its order
+// of cells and formatting, with changes from theirs applied on top.
+//
+// Conflicting changes of metadata, that is attributes, outputs, and
+// frontmatter, are resolved in favor of ours. Conflicting changes of
+// cell values are marked with conflict markers and reported.
+func Merge(base, ours, theirs []byte) (*MergeResult, error) {
+	baseEntries, err := deserializeEntries(base)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse base")
+	}
+	ourEntries, err := deserializeEntries(ours)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse ours")
+	}
+	theirEntries, err := deserializeEntries(theirs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse theirs")
+	}
+
+	m := &merger{
+		base:   entriesByKey(baseEntries),
+		theirs: entriesByKey(theirEntries),
+		result: &MergeResult{},
+	}
+
+	ourKeys := make(map[string]bool, len(ourEntries))
+	for _, e := range ourEntries {
+		ourKeys[e.key] = true
+	}
+
+	for _, e := range ourEntries {
+		b, t := m.base[e.key], m.theirs[e.key]
+		switch {
+		case t == nil && b != nil:
+			// Deleted in theirs.
+			if e.code != b.code || e.cell.LanguageID != b.cell.LanguageID || !maps.Equal(e.attributes(), b.attributes()) {
+				m.conflict(e.cell, "deleted in theirs and modified in ours")
+				m.keep(e)
+			}
+		case t == nil:
+			// Added in ours.
+			m.keep(e)
+		default:
+			m.merge(b, e, t)
+		}
+	}
+
+	for i, t := range theirEntries {
+		if ourKeys[t.key] {
+			continue
+		}
+
+		b := m.base[t.key]
+		if b != nil {
+			// Deleted in ours; restore it only if theirs modified it.
+			if t.code == b.code && t.cell.LanguageID == b.cell.LanguageID && maps.Equal(t.attributes(), b.attributes()) {
+				continue
+			}
+			m.conflict(t.cell, "deleted in ours and modified in theirs")
+		}
+
+		// Insert after the closest preceding cell of theirs which is in the result.
+		pos := 0
+		for j := i - 1; j >= 0; j-- {
+			if idx := m.indexOf(theirEntries[j].key); idx >= 0 {
+				pos = idx + 1
+				break
+			}
+		}
+		m.items = slices.Insert(m.items, pos, &mergeItem{
+			key:    t.key,
+			cell:   t.cell,
+			value:  t.cell.Value,
+			attrs:  t.attributes(),
+			images: t.images,
+		})
+	}
+
+	edits := m.cellEdits(len(ourEntries) + countImages(ourEntries))
+
+	textEdits, err := editor.ApplyEdits(ours, edits, editor.Options{
+		IdentityResolver: identity.NewResolver(identity.UnspecifiedLifecycleIdentity),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if edit, ok := m.frontmatterEdit(base, ours, theirs); ok {
+		textEdits = append([]editor.TextEdit{edit}, textEdits...)
+	}
+
+	m.result.Source = editor.ApplyTextEdits(ours, textEdits)
+	return m.result, nil
+}
+
+// mergeItem is a cell in the merged result. If ours is nil,
+// the cell does not exist in ours and will be inserted.
+type mergeItem struct {
+	key    string
+	ours   *cellEntry
+	cell   *editor.Cell
+	value  string
+	attrs  map[string]string
+	images []*editor.Cell
+}
+
+type merger struct {
+	base   map[string]*cellEntry
+	theirs map[string]*cellEntry
+	items  []*mergeItem
+	result *MergeResult
+}
+
+func entriesByKey(entries []*cellEntry) map[string]*cellEntry {
+	result := make(map[string]*cellEntry, len(entries))
+	for _, e := range entries {
+		result[e.key] = e
+	}
+	return result
+}
+
+func countImages(entries []*cellEntry) (n int) {
+	for _, e := range entries {
+		n += len(e.images)
+	}
+	return n
+}
+
+func (m *merger) indexOf(key string) int {
+	return slices.IndexFunc(m.items, func(item *mergeItem) bool { return item.key == key })
+}
+
+func (m *merger) conflict(cell *editor.Cell, reason string) {
+	m.result.Conflicts = append(m.result.Conflicts, MergeConflict{Cell: cell, Reason: reason})
+}
+
+func (m *merger) keep(e *cellEntry) {
+	m.items = append(m.items, &mergeItem{
+		key:    e.key,
+		ours:   e,
+		cell:   e.cell,
+		value:  e.cell.Value,
+		attrs:  e.attributes(),
+		images: e.images,
+	})
+}
+
+// merge merges a cell which exists in ours and theirs.
+// b is nil if the cell was added on both sides.
+func (m *merger) merge(b, o, t *cellEntry) {
+	var baseCode, baseOutputs string
+	var baseAttrs map[string]string
+	if b != nil {
+		baseCode, baseOutputs, baseAttrs = b.code, b.outputs(), b.attributes()
+	}
+
+	code, ok := merge3(b != nil, baseCode, o.code, t.code)
+	if !ok {
+		code = fmt.Sprintf("<<<<<<< ours\n%s\n=======\n%s\n>>>>>>> theirs", o.code, t.code)
+		m.conflict(o.cell, "value modified in ours and theirs")
+	}
+
+	attrs := make(map[string]string)
+	ourAttrs, theirAttrs := o.attributes(), t.attributes()
+	keys := slices.Collect(maps.Keys(ourAttrs))
+	keys = append(keys, slices.Collect(maps.Keys(theirAttrs))...)
+	keys = append(keys, slices.Collect(maps.Keys(baseAttrs))...)
+	sort.Strings(keys)
+	for _, k := range slices.Compact(keys) {
+		// Presence is encoded as a prefix to distinguish
+		// a missing attribute from an empty one.
+		present := func(attrs map[string]string) string {
+			if v, ok := attrs[k]; ok {
+				return "+" + v
+			}
+			return ""
+		}
+		v, ok := merge3(b != nil, present(baseAttrs), present(ourAttrs), present(theirAttrs))
+		if !ok {
+			v = present(ourAttrs)
+			m.result.Resolved++
+		}
+		if v != "" {
+			attrs[k] = v[1:]
+		}
+	}
+
+	output, images := o.output, o.images
+	if merged, ok := merge3(b != nil, baseOutputs, o.outputs(), t.outputs()); !ok {
+		m.result.Resolved++
+	} else if merged != o.outputs() {
+		output, images = t.output, t.images
+	}
+
+	value := code
+	if output != "" {
+		value += "\n\n" + output
+	}
+
+	m.items = append(m.items, &mergeItem{
+		key:    o.key,
+		ours:   o,
+		cell:   o.cell,
+		value:  value,
+		attrs:  attrs,
+		images: images,
+	})
+}
+
+// merge3 merges a single value. It returns false if
+// both sides changed the value in a different way.
+func merge3(hasBase bool, base, ours, theirs string) (string, bool) {
+	switch {
+	case ours == theirs:
+		return ours, true
+	case hasBase && ours == base:
+		return theirs, true
+	case hasBase && theirs == base:
+		return ours, true
+	default:
+		return ours, false
+	}
+}
+
+// cellEdits returns edits transforming cells of ours into the merged result.
+func (m *merger) cellEdits(n int) []editor.CellEdit {
+	// Find indices of cells of ours which are retained.
+	kept := make(map[int]bool, n)
+	for _, item := range m.items {
+		if item.ours == nil {
+			continue
+		}
+		kept[item.ours.index] = true
+		if sameCells(item.images, item.ours.images) {
+			for i := range item.ours.images {
+				kept[item.ours.index+1+i] = true
+			}
+		}
+	}
+
+	var edits []editor.CellEdit
+	for i := n - 1; i >= 0; i-- {
+		if !kept[i] {
+			edits = append(edits, editor.CellEdit{Kind: editor.DeleteCellEdit, Index: i})
+		}
+	}
+
+	// Retained cells are in the same order as in ours,
+	// hence only new cells need to be inserted.
+	idx := 0
+	for _, item := range m.items {
+		if item.ours == nil {
+			cell := *item.cell
+			cell.Value = item.value
+			edits = append(edits, editor.CellEdit{Kind: editor.InsertCellEdit, Index: idx, Cell: &cell})
+		} else {
+			if item.value != item.cell.Value {
+				edits = append(edits, editor.CellEdit{Kind: editor.UpdateCellValueEdit, Index: idx, Value: item.value})
+			}
+			if item.cell.Kind == editor.CodeKind && !maps.Equal(item.attrs, item.ours.attributes()) {
+				edits = append(edits, editor.CellEdit{Kind: editor.UpdateCellAttributesEdit, Index: idx, Attributes: item.attrs})
+			}
+		}
+		idx++
+
+		if item.ours != nil && sameCells(item.images, item.ours.images) {
+			idx += len(item.images)
+			continue
+		}
+		for _, image := range item.images {
+			edits = append(edits, editor.CellEdit{Kind: editor.InsertCellEdit, Index: idx, Cell: image})
+			idx++
+		}
+	}
+
+	return edits
+}
+
+func sameCells(a, b []*editor.Cell) bool {
+	return slices.EqualFunc(a, b, func(x, y *editor.Cell) bool { return x.Value == y.Value })
+}
+
+// frontmatterEdit returns an edit of the frontmatter of ours
+// if theirs changed it. Conflicts are resolved in favor of ours.
+func (m *merger) frontmatterEdit(base, ours, theirs []byte) (editor.TextEdit, bool) {
+	raw := func(source []byte) string {
+		return string(document.New(source, identity.NewResolver(identity.UnspecifiedLifecycleIdentity)).FrontmatterRaw())
+	}
+
+	baseRaw, ourRaw, theirRaw := raw(base), raw(ours), raw(theirs)
+	merged, ok := merge3(true, baseRaw, ourRaw, theirRaw)
+	if !ok {
+		m.result.Resolved++
+		return editor.TextEdit{}, false
+	}
+	if merged == ourRaw {
+		return editor.TextEdit{}, false
+	}
+
+	doc := document.New(ours, identity.NewResolver(identity.UnspecifiedLifecycleIdentity))
+	span, ok := doc.FrontmatterSpan()
+	switch {
+	case !ok:
+		return editor.TextEdit{NewText: merged + "\n\n"}, true
+	case merged == "":
+		end := span.End.Offset
+		for end < len(ours) && (ours[end] == '\n' || ours[end] == '\r') {
+			end++
+		}
+		return editor.TextEdit{Start: span.Start.Offset, End: end}, true
+	default:
+		return editor.TextEdit{Start: span.Start.Offset, End: span.End.Offset, NewText: merged}, true
+	}
+}
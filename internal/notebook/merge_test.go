@@ -0,0 +1,196 @@
+package notebook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mergeBase = `# Notebook
+
+` + "```sh {\"id\":\"01JJDCG2SPRDWGQ1F4Z6EH69EJ\",\"name\":\"build\"}" + `
+go build
+` + "```" + `
+
+Some text.
+
+` + "```sh {\"id\":\"01JJDCG2SQSGV0DP55X86EJFSZ\",\"name\":\"test\"}" + `
+go test
+` + "```" + `
+`
+
+func TestDiff(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		changes, err := Diff([]byte(mergeBase), []byte(mergeBase), DiffOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("Renamed", func(t *testing.T) {
+		// Cells are matched by id, so renaming is an attribute change.
+		b := strings.Replace(mergeBase, `"name":"build"`, `"name":"compile"`, 1)
+		b = strings.Replace(b, "go test", "go test ./...", 1)
+
+		changes, err := Diff([]byte(mergeBase), []byte(b), DiffOptions{})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+
+		assert.Equal(t, CellModified, changes[0].Kind)
+		assert.True(t, changes[0].MetadataOnly())
+		assert.Equal(t, []AttributeChange{{Name: "name", Old: "build", New: "compile"}}, changes[0].Attributes)
+
+		assert.Equal(t, CellModified, changes[1].Kind)
+		assert.True(t, changes[1].ValueChanged)
+		assert.Equal(t, 3, changes[1].OldIndex)
+	})
+
+	t.Run("AddedDeletedMoved", func(t *testing.T) {
+		b := strings.Join([]string{
+			"# Notebook",
+			"",
+			"```sh {\"id\":\"01JJDCG2SQSGV0DP55X86EJFSZ\",\"name\":\"test\"}",
+			"go test",
+			"```",
+			"",
+			"```sh {\"id\":\"01JJDCG2SPRDWGQ1F4Z6EH69EJ\",\"name\":\"build\"}",
+			"go build",
+			"```",
+			"",
+			"New text.",
+			"",
+		}, "\n")
+
+		changes, err := Diff([]byte(mergeBase), []byte(b), DiffOptions{})
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+
+		assert.Equal(t, CellModified, changes[0].Kind)
+		assert.True(t, changes[0].Moved)
+		assert.Equal(t, 3, changes[0].OldIndex)
+		assert.Equal(t, 1, changes[0].NewIndex)
+
+		assert.Equal(t, CellAdded, changes[1].Kind)
+		assert.Equal(t, "New text.", changes[1].New.Value)
+
+		assert.Equal(t, CellDeleted, changes[2].Kind)
+		assert.Equal(t, "Some text.", changes[2].Old.Value)
+	})
+
+	t.Run("Outputs", func(t *testing.T) {
+		b := strings.Replace(mergeBase, "go build\n", "go build\n\n# Ran on 2024-01-01 10:00:00Z for 1s exited with 0\nok\n", 1)
+
+		changes, err := Diff([]byte(mergeBase), []byte(b), DiffOptions{})
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.True(t, changes[0].OutputsChanged)
+		assert.False(t, changes[0].ValueChanged)
+		assert.True(t, changes[0].MetadataOnly())
+
+		changes, err = Diff([]byte(mergeBase), []byte(b), DiffOptions{IgnoreOutputs: true})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+}
+
+func TestMerge(t *testing.T) {
+	merge := func(t *testing.T, ours, theirs string) *MergeResult {
+		t.Helper()
+		result, err := Merge([]byte(mergeBase), []byte(ours), []byte(theirs))
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("Clean", func(t *testing.T) {
+		ours := strings.Replace(mergeBase, "go build", "go build -v", 1)
+		theirs := strings.Replace(mergeBase, "go test", "go test -race", 1)
+		theirs = strings.Replace(theirs, "Some text.", "Some text.\n\n```sh {\"name\":\"lint\"}\ngolangci-lint run\n```", 1)
+
+		result := merge(t, ours, theirs)
+		assert.Empty(t, result.Conflicts)
+		assert.Equal(t, 0, result.Resolved)
+
+		expected := strings.Replace(theirs, "go build", "go build -v", 1)
+		assert.Equal(t, expected, string(result.Source))
+	})
+
+	t.Run("MetadataConflict", func(t *testing.T) {
+		// Both sides change the same attribute and add outputs,
+		// which is resolved in favor of ours.
+		ours := strings.Replace(mergeBase, `"name":"build"}`, `"interactive":"false","name":"build"}`, 1)
+		ours = strings.Replace(ours, "go test\n", "go test\n\n# Ran on 2024-01-01 10:00:00Z for 1s exited with 0\nPASS\n", 1)
+		theirs := strings.Replace(mergeBase, `"name":"build"}`, `"interactive":"true","name":"build"}`, 1)
+		theirs = strings.Replace(theirs, "go test\n", "go test\n\n# Ran on 2024-01-02 10:00:00Z for 1s exited with 1\nFAIL\n", 1)
+
+		result := merge(t, ours, theirs)
+		assert.Empty(t, result.Conflicts)
+		assert.Equal(t, 2, result.Resolved)
+		assert.Equal(t, ours, string(result.Source))
+	})
+
+	t.Run("OutputsFromTheirs", func(t *testing.T) {
+		theirs := strings.Replace(mergeBase, "go test\n", "go test\n\n# Ran on 2024-01-02 10:00:00Z for 1s exited with 0\nPASS\n", 1)
+
+		result := merge(t, mergeBase, theirs)
+		assert.Empty(t, result.Conflicts)
+		assert.Equal(t, theirs, string(result.Source))
+	})
+
+	t.Run("DeletedAndMoved", func(t *testing.T) {
+		// Ours deletes the text, theirs moves the test cell to the top.
+		ours := strings.Replace(mergeBase, "Some text.\n\n", "", 1)
+		theirs := strings.Join([]string{
+			"# Notebook",
+			"",
+			"```sh {\"id\":\"01JJDCG2SQSGV0DP55X86EJFSZ\",\"name\":\"test\"}",
+			"go test",
+			"```",
+			"",
+			"```sh {\"id\":\"01JJDCG2SPRDWGQ1F4Z6EH69EJ\",\"name\":\"build\"}",
+			"go build",
+			"```",
+			"",
+			"Some text.",
+			"",
+		}, "\n")
+
+		// The order of ours is retained.
+		result := merge(t, ours, theirs)
+		assert.Empty(t, result.Conflicts)
+		assert.Equal(t, ours, string(result.Source))
+	})
+
+	t.Run("ValueConflict", func(t *testing.T) {
+		ours := strings.Replace(mergeBase, "go build", "go build -v", 1)
+		theirs := strings.Replace(mergeBase, "go build", "go build -x", 1)
+
+		result := merge(t, ours, theirs)
+		require.Len(t, result.Conflicts, 1)
+		assert.Equal(t, "value modified in ours and theirs", result.Conflicts[0].Reason)
+		assert.Equal(
+			t,
+			strings.Replace(mergeBase, "go build", "<<<<<<< ours\ngo build -v\n=======\ngo build -x\n>>>>>>> theirs", 1),
+			string(result.Source),
+		)
+	})
+
+	t.Run("DeletedAndModified", func(t *testing.T) {
+		ours := strings.Replace(mergeBase, "```sh {\"id\":\"01JJDCG2SQSGV0DP55X86EJFSZ\",\"name\":\"test\"}\ngo test\n```\n", "", 1)
+		ours = strings.TrimRight(ours, "\n") + "\n"
+		theirs := strings.Replace(mergeBase, "go test", "go test -race", 1)
+
+		result := merge(t, ours, theirs)
+		require.Len(t, result.Conflicts, 1)
+		assert.Equal(t, "deleted in ours and modified in theirs", result.Conflicts[0].Reason)
+		assert.Equal(t, theirs, string(result.Source))
+	})
+
+	t.Run("Frontmatter", func(t *testing.T) {
+		theirs := "---\nshell: bash\n---\n\n" + mergeBase
+
+		result := merge(t, mergeBase, theirs)
+		assert.Empty(t, result.Conflicts)
+		assert.Equal(t, theirs, string(result.Source))
+	})
+}
@@ -809,6 +809,41 @@ func (s *Store) defineEnvSpecs(envSpecs interface{}) error {
 	return err
 }
 
+// SpecKey describes an environment variable defined by an env spec definition.
+type SpecKey struct {
+	Name        string
+	Spec        string
+	Atomic      string
+	Description string
+	Required    bool
+}
+
+// SpecKeys returns environment variables defined by all known
+// env spec definitions, sorted by name.
+func (s *Store) SpecKeys() []SpecKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []SpecKey
+	for _, def := range s.specDefs {
+		for key, atomic := range def.Atomics {
+			result = append(result, SpecKey{
+				Name:        def.Breaker + "_" + key,
+				Spec:        def.Name,
+				Atomic:      atomic.Atomic,
+				Description: atomic.Description,
+				Required:    atomic.Required,
+			})
+		}
+	}
+
+	slices.SortFunc(result, func(a, b SpecKey) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
 func (s *Store) snapshot(insecure, resolve bool) (SetVarItems, error) {
 	var query, vars bytes.Buffer
 	err := s.snapshotQuery(&query, &vars, resolve)
@@ -7,6 +7,8 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -776,3 +778,19 @@ SLACK_CLIENT_SECRET=this-is-my-secret`)),
 		require.EqualValues(t, []string{"SLACK_CLIENT_SECRET", "SLACK_REDIRECT_URL"}, keys)
 	})
 }
+
+func TestStore_SpecKeys(t *testing.T) {
+	store, err := NewStore()
+	require.NoError(t, err)
+
+	keys := store.SpecKeys()
+	require.NotEmpty(t, keys)
+
+	idx := slices.IndexFunc(keys, func(k SpecKey) bool { return k.Name == "OPENAI_API_KEY" })
+	require.NotEqual(t, -1, idx)
+	assert.Equal(t, "OpenAI", keys[idx].Spec)
+	assert.Equal(t, "Secret", keys[idx].Atomic)
+	assert.True(t, keys[idx].Required)
+
+	assert.True(t, slices.IsSortedFunc(keys, func(a, b SpecKey) int { return strings.Compare(a.Name, b.Name) }))
+}
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"sync"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -13,26 +17,54 @@ import (
 	"github.com/stateful/runme/v3/pkg/project"
 )
 
+var tracer = otel.Tracer("github.com/stateful/runme/v3/internal/project/projectservice")
+
 type projectServiceServer struct {
 	projectv1.UnimplementedProjectServiceServer
 
-	logger *zap.Logger
+	logger        *zap.Logger
+	remoteSources bool
+}
+
+type Option func(*projectServiceServer)
+
+// WithRemoteSources allows clients to load projects from remote
+// sources, which makes the server fetch git repositories and archives
+// from arbitrary URLs and open local bare repositories.
+func WithRemoteSources(enabled bool) Option {
+	return func(s *projectServiceServer) {
+		s.remoteSources = enabled
+	}
 }
 
-func NewProjectServiceServer(logger *zap.Logger) projectv1.ProjectServiceServer {
-	return &projectServiceServer{logger: logger}
+func NewProjectServiceServer(logger *zap.Logger, opts ...Option) projectv1.ProjectServiceServer {
+	s := &projectServiceServer{logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *projectServiceServer) Load(req *projectv1.LoadRequest, srv projectv1.ProjectService_LoadServer) error {
-	proj, err := projectFromReq(req)
+func (s *projectServiceServer) Load(req *projectv1.LoadRequest, srv projectv1.ProjectService_LoadServer) (err error) {
+	spanCtx, span := tracer.Start(srv.Context(), "Load", trace.WithAttributes(loadRequestAttributes(req)...))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	proj, err := s.projectFromReq(spanCtx, req)
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithCancel(srv.Context())
+	ctx, cancel := context.WithCancel(spanCtx)
 	eventc := make(chan project.LoadEvent)
 	errc := make(chan error, 1)
 	wg := sync.WaitGroup{}
+	tasks := 0
 
 	wg.Add(1)
 	go func() {
@@ -49,6 +81,10 @@ func (s *projectServiceServer) Load(req *projectv1.LoadRequest, srv projectv1.Pr
 					return
 				}
 
+				if event.Type == project.LoadEventFoundTask {
+					tasks++
+				}
+
 				msg := &projectv1.LoadResponse{
 					Type: projectv1.LoadEventType(event.Type),
 				}
@@ -83,34 +119,233 @@ func (s *projectServiceServer) Load(req *projectv1.LoadRequest, srv projectv1.Pr
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		proj.Load(ctx, eventc, false)
+		// Only names and IDs of tasks are sent so they can be served from the index.
+		proj.LoadWithOptions(ctx, eventc, project.LoadOptions{FromIndex: true})
 	}()
 
 	wg.Wait()
 
+	span.SetAttributes(attribute.Int("runme.project.tasks", tasks))
+
 	return <-errc
 }
 
-func projectFromReq(req *projectv1.LoadRequest) (*project.Project, error) {
-	switch v := req.GetKind().(type) {
-	case *projectv1.LoadRequest_Directory:
+func loadRequestAttributes(req *projectv1.LoadRequest) []attribute.KeyValue {
+	switch {
+	case req.GetWorkspace() != nil:
+		return []attribute.KeyValue{attribute.String("runme.project.kind", "workspace")}
+	case req.GetRemote() != nil:
+		return []attribute.KeyValue{
+			attribute.String("runme.project.kind", "remote"),
+			attribute.String("runme.project.source", req.GetRemote().GetSource()),
+		}
+	case req.GetFile() != nil:
+		return []attribute.KeyValue{
+			attribute.String("runme.project.kind", "file"),
+			attribute.String("runme.project.path", req.GetFile().GetPath()),
+		}
+	default:
+		return []attribute.KeyValue{
+			attribute.String("runme.project.kind", "directory"),
+			attribute.String("runme.project.path", req.GetDirectory().GetPath()),
+		}
+	}
+}
+
+func (s *projectServiceServer) Watch(req *projectv1.WatchRequest, srv projectv1.ProjectService_WatchServer) error {
+	proj, err := projectFromOptions(req.GetDirectory(), req.GetFile())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(srv.Context())
+	defer cancel()
+
+	eventc := make(chan project.WatchEvent)
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- proj.Watch(ctx, eventc)
+	}()
+
+	for event := range eventc {
+		msg := &projectv1.WatchResponse{
+			Type: projectv1.WatchEventType(event.Type),
+		}
+
+		switch event.Type {
+		case project.WatchEventTaskAdded, project.WatchEventTaskChanged, project.WatchEventTaskRemoved:
+			msg.Task = watchEventTaskToProto(event.Task)
+			if event.Previous != nil {
+				msg.PreviousTask = watchEventTaskToProto(*event.Previous)
+			}
+		case project.WatchEventError:
+			msg.ErrorMessage = event.Err.Error()
+		}
+
+		if err := srv.Send(msg); err != nil {
+			cancel()
+			// Drain eventc to let Watch exit.
+			//revive:disable:empty-block
+			for range eventc {
+			}
+			//revive:enable:empty-block
+			<-errc
+			return err
+		}
+	}
+
+	if err := <-errc; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return status.Error(codes.Canceled, ctx.Err().Error())
+}
+
+func (s *projectServiceServer) Search(ctx context.Context, req *projectv1.SearchRequest) (*projectv1.SearchResponse, error) {
+	proj, err := projectFromOptions(req.GetDirectory(), req.GetFile())
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := project.LoadTasks(ctx, proj)
+	if err != nil {
+		return nil, err
+	}
+
+	results := project.SearchTasks(tasks, req.Query)
+	if limit := int(req.Limit); limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	resp := &projectv1.SearchResponse{}
+	for _, result := range results {
+		fields := make([]string, 0, len(result.Fields))
+		for _, f := range result.Fields {
+			fields = append(fields, f.String())
+		}
+
+		resp.Results = append(resp.Results, &projectv1.SearchResult{
+			DocumentPath:    result.Task.DocumentPath,
+			Id:              result.Task.CodeBlock.ID(),
+			Name:            result.Task.CodeBlock.Name(),
+			IsNameGenerated: result.Task.CodeBlock.IsUnnamed(),
+			Score:           int32(result.Score),
+			MatchedFields:   fields,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *projectServiceServer) projectFromReq(ctx context.Context, req *projectv1.LoadRequest) (project.Loader, error) {
+	if ws := req.GetWorkspace(); ws != nil {
+		return workspaceFromOptions(ws)
+	}
+	if remote := req.GetRemote(); remote != nil {
+		if !s.remoteSources {
+			return nil, status.Error(codes.PermissionDenied, "remote project sources are disabled in the server")
+		}
+		return projectFromRemoteOptions(ctx, remote)
+	}
+	return projectFromOptions(req.GetDirectory(), req.GetFile())
+}
+
+func projectFromRemoteOptions(ctx context.Context, options *projectv1.RemoteProjectOptions) (*project.Project, error) {
+	if !project.IsRemoteSource(options.Source) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported project source %q", options.Source)
+	}
+
+	dir, err := project.FetchSource(ctx, options.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return project.NewDirProject(
+		dir,
+		project.WithRespectGitignore(!options.SkipGitignore),
+		project.WithIgnoreFilePatterns(options.IgnoreFilePatterns...),
+	)
+}
+
+func workspaceFromOptions(options *projectv1.WorkspaceProjectOptions) (*project.Workspace, error) {
+	roots := make([]project.WorkspaceRoot, 0, len(options.Roots))
+
+	for _, root := range options.Roots {
+		if root.Directory == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "workspace root %q has no directory", root.Name)
+		}
+
+		proj, err := projectFromOptions(root.Directory, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		roots = append(roots, project.WorkspaceRoot{Name: root.Name, Project: proj})
+	}
+
+	ws, err := project.NewWorkspace(roots...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return ws, nil
+}
+
+func projectFromOptions(directory *projectv1.DirectoryProjectOptions, file *projectv1.FileProjectOptions) (*project.Project, error) {
+	switch {
+	case directory != nil:
 		opts := []project.ProjectOption{
-			project.WithRespectGitignore(!v.Directory.SkipGitignore),
-			project.WithIgnoreFilePatterns(v.Directory.IgnoreFilePatterns...),
+			project.WithRespectGitignore(!directory.SkipGitignore),
+			project.WithIgnoreFilePatterns(directory.IgnoreFilePatterns...),
 		}
 
-		if !v.Directory.SkipRepoLookupUpward {
+		if !directory.SkipRepoLookupUpward {
 			opts = append(opts, project.WithFindRepoUpward())
 		}
 
-		return project.NewDirProject(v.Directory.Path, opts...)
-	case *projectv1.LoadRequest_File:
-		return project.NewFileProject(v.File.Path)
+		if directory.TaskProviders {
+			opts = append(opts, project.WithTaskProviders(project.DefaultTaskProviders()...))
+		}
+
+		// The cache is an optimization; without it, all files are parsed.
+		if dir, err := project.DefaultTaskIndexDir(); err == nil {
+			opts = append(opts, project.WithTaskIndex(dir))
+		}
+
+		return project.NewDirProject(directory.Path, opts...)
+	case file != nil:
+		return project.NewFileProject(file.Path)
 	default:
 		return nil, errors.New("unknown request kind")
 	}
 }
 
+func watchEventTaskToProto(task project.Task) *projectv1.WatchEventTask {
+	return &projectv1.WatchEventTask{
+		DocumentPath:    task.DocumentPath,
+		Id:              task.CodeBlock.ID(),
+		Name:            task.CodeBlock.Name(),
+		IsNameGenerated: task.CodeBlock.IsUnnamed(),
+		Source:          taskSourceToProto(task.Source),
+	}
+}
+
+func taskSourceToProto(source project.TaskSource) projectv1.TaskSource {
+	switch source {
+	case project.TaskSourceMarkdown:
+		return projectv1.TaskSource_TASK_SOURCE_MARKDOWN
+	case project.TaskSourceConfig:
+		return projectv1.TaskSource_TASK_SOURCE_CONFIG
+	case project.TaskSourceMakefile:
+		return projectv1.TaskSource_TASK_SOURCE_MAKEFILE
+	case project.TaskSourcePackageJSON:
+		return projectv1.TaskSource_TASK_SOURCE_PACKAGE_JSON
+	case project.TaskSourceTaskfile:
+		return projectv1.TaskSource_TASK_SOURCE_TASKFILE
+	default:
+		return projectv1.TaskSource_TASK_SOURCE_UNSPECIFIED
+	}
+}
+
 func setDataForLoadResponseFromLoadEvent(resp *projectv1.LoadResponse, event project.LoadEvent) error {
 	switch event.Type {
 	case project.LoadEventStartedWalk:
@@ -162,6 +397,8 @@ func setDataForLoadResponseFromLoadEvent(resp *projectv1.LoadResponse, event pro
 				Id:              data.Task.CodeBlock.ID(),
 				Name:            data.Task.CodeBlock.Name(),
 				IsNameGenerated: data.Task.CodeBlock.IsUnnamed(),
+				Root:            data.Task.Root,
+				Source:          taskSourceToProto(data.Task.Source),
 			},
 		}
 	case project.LoadEventError:
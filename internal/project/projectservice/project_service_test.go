@@ -1,9 +1,12 @@
 package projectservice_test
 
 import (
+	"archive/tar"
 	"context"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -11,6 +14,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/stateful/runme/v3/internal/project/projectservice"
@@ -93,7 +98,7 @@ func collectLoadEventTypes(client projectv1.ProjectService_LoadClient) ([]projec
 	return eventTypes, nil
 }
 
-func testStartProjectServiceServer(t *testing.T) (
+func testStartProjectServiceServer(t *testing.T, opts ...projectservice.Option) (
 	interface{ Dial() (net.Conn, error) },
 	func(),
 ) {
@@ -101,10 +106,230 @@ func testStartProjectServiceServer(t *testing.T) (
 
 	server := grpc.NewServer()
 
-	service := projectservice.NewProjectServiceServer(zaptest.NewLogger(t))
+	service := projectservice.NewProjectServiceServer(zaptest.NewLogger(t), opts...)
 	projectv1.RegisterProjectServiceServer(server, service)
 
 	lis := bufconn.Listen(1024 << 10)
 	go server.Serve(lis)
 	return lis, server.Stop
 }
+
+func TestProjectServiceServer_Watch(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := testStartProjectServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+	dir := t.TempDir()
+	readme := filepath.Join(dir, "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"hello\"}\necho hello\n```\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchClient, err := client.Watch(ctx, &projectv1.WatchRequest{
+		Kind: &projectv1.WatchRequest_Directory{
+			Directory: &projectv1.DirectoryProjectOptions{
+				Path:                 dir,
+				SkipRepoLookupUpward: true,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := watchClient.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, projectv1.WatchEventType_WATCH_EVENT_TYPE_TASK_ADDED, resp.Type)
+	assert.Equal(t, "hello", resp.Task.Name)
+	assert.Equal(t, readme, resp.Task.DocumentPath)
+
+	resp, err = watchClient.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, projectv1.WatchEventType_WATCH_EVENT_TYPE_SYNCED, resp.Type)
+
+	require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"hello\"}\necho changed\n```\n"), 0o600))
+
+	resp, err = watchClient.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, projectv1.WatchEventType_WATCH_EVENT_TYPE_TASK_CHANGED, resp.Type)
+	assert.Equal(t, "hello", resp.Task.Name)
+}
+
+func TestProjectServiceServer_Search(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := testStartProjectServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+	dir := t.TempDir()
+	readme := filepath.Join(dir, "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("Rotate TLS certificates.\n\n```sh {\"name\":\"renew\"}\ncertbot renew\n```\n\n```sh {\"name\":\"build\"}\ngo build\n```\n"), 0o600))
+
+	resp, err := client.Search(context.Background(), &projectv1.SearchRequest{
+		Kind: &projectv1.SearchRequest_File{
+			File: &projectv1.FileProjectOptions{Path: readme},
+		},
+		Query: "rotate certs",
+		Limit: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "renew", resp.Results[0].Name)
+	assert.Equal(t, []string{"intro"}, resp.Results[0].MatchedFields)
+}
+
+func TestProjectServiceServer_LoadWorkspace(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := testStartProjectServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+	var roots []*projectv1.WorkspaceRoot
+	for _, name := range []string{"infra", "app"} {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("```sh {\"name\":\"deploy\"}\necho deploy\n```\n"), 0o600))
+		roots = append(roots, &projectv1.WorkspaceRoot{
+			Name: name,
+			Directory: &projectv1.DirectoryProjectOptions{
+				Path:                 dir,
+				SkipRepoLookupUpward: true,
+			},
+		})
+	}
+
+	loadClient, err := client.Load(context.Background(), &projectv1.LoadRequest{
+		Kind: &projectv1.LoadRequest_Workspace{
+			Workspace: &projectv1.WorkspaceProjectOptions{Roots: roots},
+		},
+	})
+	require.NoError(t, err)
+
+	var taskRoots []string
+	for {
+		resp, err := loadClient.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if task := resp.GetFoundTask(); task != nil {
+			assert.Equal(t, "deploy", task.Name)
+			taskRoots = append(taskRoots, task.Root)
+		}
+	}
+	assert.Equal(t, []string{"infra", "app"}, taskRoots)
+}
+
+func TestProjectServiceServer_LoadTaskProviders(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := testStartProjectServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("```sh {\"name\":\"hello\"}\necho hello\n```\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Makefile"), []byte("build:\n\tgo build\n"), 0o600))
+
+	load := func(t *testing.T, providers bool) map[string]projectv1.TaskSource {
+		t.Helper()
+
+		loadClient, err := client.Load(context.Background(), &projectv1.LoadRequest{
+			Kind: &projectv1.LoadRequest_Directory{
+				Directory: &projectv1.DirectoryProjectOptions{
+					Path:                 dir,
+					SkipRepoLookupUpward: true,
+					TaskProviders:        providers,
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		sources := make(map[string]projectv1.TaskSource)
+		for {
+			resp, err := loadClient.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+
+			if task := resp.GetFoundTask(); task != nil {
+				sources[task.Name] = task.Source
+			}
+		}
+		return sources
+	}
+
+	assert.Equal(t, map[string]projectv1.TaskSource{
+		"hello": projectv1.TaskSource_TASK_SOURCE_MARKDOWN,
+	}, load(t, false))
+
+	assert.Equal(t, map[string]projectv1.TaskSource{
+		"hello": projectv1.TaskSource_TASK_SOURCE_MARKDOWN,
+		"build": projectv1.TaskSource_TASK_SOURCE_MAKEFILE,
+	}, load(t, true))
+}
+
+func TestProjectServiceServer_LoadRemote(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	lis, stop := testStartProjectServiceServer(t, projectservice.WithRemoteSources(true))
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+	archive := filepath.Join(t.TempDir(), "runbooks.tar")
+	{
+		f, err := os.Create(archive)
+		require.NoError(t, err)
+		content := []byte("```sh {\"name\":\"hello\"}\necho hello\n```\n")
+		tw := tar.NewWriter(f)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/README.md", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, f.Close())
+	}
+
+	req := &projectv1.LoadRequest{
+		Kind: &projectv1.LoadRequest_Remote{
+			Remote: &projectv1.RemoteProjectOptions{Source: archive + "#docs"},
+		},
+	}
+
+	loadClient, err := client.Load(context.Background(), req)
+	require.NoError(t, err)
+
+	var names []string
+	for {
+		resp, err := loadClient.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if task := resp.GetFoundTask(); task != nil {
+			names = append(names, task.Name)
+		}
+	}
+	assert.Equal(t, []string{"hello"}, names)
+
+	t.Run("Disabled", func(t *testing.T) {
+		lis, stop := testStartProjectServiceServer(t)
+		t.Cleanup(stop)
+
+		_, client := testutils.NewGRPCClientWithT(t, lis, projectv1.NewProjectServiceClient)
+
+		loadClient, err := client.Load(context.Background(), req)
+		require.NoError(t, err)
+		_, err = loadClient.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
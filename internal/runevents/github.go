@@ -0,0 +1,140 @@
+package runevents
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+// githubEmitter writes workflow commands of GitHub Actions.
+// Output of each task is grouped. Failed tasks are annotated
+// with errors.
+//
+// Groups cannot be nested or interleaved, hence, only one task
+// streams its output at a time. Output of tasks running in parallel
+// to it is buffered and written once they and the streaming task finish.
+type githubEmitter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	streaming string
+	buffers   map[string]*bytes.Buffer
+	// finished contains output of tasks which finished
+	// while another task was streaming.
+	finished []*bytes.Buffer
+	// midLine contains tasks which output does not end with a new line.
+	midLine map[string]bool
+}
+
+func newGitHubEmitter(w io.Writer) *githubEmitter {
+	return &githubEmitter{
+		w:       w,
+		buffers: make(map[string]*bytes.Buffer),
+		midLine: make(map[string]bool),
+	}
+}
+
+func (e *githubEmitter) Plan([]project.Task) {}
+
+func (e *githubEmitter) TaskStart(task project.Task) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := taskID(task)
+
+	if e.streaming == "" {
+		e.streaming = id
+		_, _ = fmt.Fprintf(e.w, "::group::%s\n", escapeData(task.CodeBlock.Name()))
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	_, _ = fmt.Fprintf(buf, "::group::%s\n", escapeData(task.CodeBlock.Name()))
+	e.buffers[id] = buf
+}
+
+func (e *githubEmitter) Stdout(task project.Task) io.Writer { return e.outputWriter(task) }
+
+func (e *githubEmitter) Stderr(task project.Task) io.Writer { return e.outputWriter(task) }
+
+func (e *githubEmitter) outputWriter(task project.Task) io.Writer {
+	id := taskID(task)
+	return writerFunc(func(p []byte) (int, error) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if len(p) > 0 {
+			e.midLine[id] = p[len(p)-1] != '\n'
+		}
+		if buf, ok := e.buffers[id]; ok {
+			return buf.Write(p)
+		}
+		return e.w.Write(p)
+	})
+}
+
+func (e *githubEmitter) EnvChanged(project.Task, []string, []string) {}
+
+func (e *githubEmitter) TaskEnd(task project.Task, exitCode int, _ time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := taskID(task)
+
+	var w io.Writer = e.w
+	buf, buffered := e.buffers[id]
+	if buffered {
+		w = buf
+	}
+
+	if e.midLine[id] {
+		_, _ = io.WriteString(w, "\n")
+	}
+	delete(e.midLine, id)
+	_, _ = io.WriteString(w, "::endgroup::\n")
+
+	name := task.CodeBlock.Name()
+	switch {
+	case err != nil:
+		_, _ = fmt.Fprintf(w, "::error file=%s,title=%s::%s\n", escapeProperty(task.RelDocumentPath), escapeProperty("Task "+name+" failed"), escapeData(err.Error()))
+	case exitCode != 0:
+		_, _ = fmt.Fprintf(w, "::error file=%s,title=%s::%s\n", escapeProperty(task.RelDocumentPath), escapeProperty("Task "+name+" failed"), escapeData(fmt.Sprintf("Task %s exited with code %d", name, exitCode)))
+	}
+
+	if buffered {
+		delete(e.buffers, id)
+		e.finished = append(e.finished, buf)
+	}
+
+	if e.streaming == id {
+		e.streaming = ""
+	}
+
+	if e.streaming == "" {
+		for _, buf := range e.finished {
+			_, _ = e.w.Write(buf.Bytes())
+		}
+		e.finished = nil
+	}
+}
+
+func taskID(task project.Task) string {
+	return task.DocumentPath + "#" + task.CodeBlock.Name()
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
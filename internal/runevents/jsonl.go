@@ -0,0 +1,121 @@
+package runevents
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+type EventType string
+
+const (
+	EventTypePlan      EventType = "plan"
+	EventTypeTaskStart EventType = "task_start"
+	EventTypeStdout    EventType = "stdout"
+	EventTypeStderr    EventType = "stderr"
+	EventTypeEnv       EventType = "env"
+	EventTypeTaskEnd   EventType = "task_end"
+)
+
+// Event is a line of the JSONL output.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Task string    `json:"task,omitempty"`
+	File string    `json:"file,omitempty"`
+	// Tasks are set for [EventTypePlan].
+	Tasks []EventTask `json:"tasks,omitempty"`
+	// Data is a chunk of output. It is encoded in base64.
+	Data []byte `json:"data,omitempty"`
+	// Set and Unset are names of env variables changed by the task.
+	// Values are omitted as they might be secrets.
+	Set   []string `json:"set,omitempty"`
+	Unset []string `json:"unset,omitempty"`
+	// ExitCode, DurationMs, and Error are set for [EventTypeTaskEnd].
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type EventTask struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+type jsonlEmitter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	now     func() time.Time
+}
+
+func newJSONLEmitter(w io.Writer) *jsonlEmitter {
+	return &jsonlEmitter{
+		encoder: json.NewEncoder(w),
+		now:     time.Now,
+	}
+}
+
+func (e *jsonlEmitter) emit(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event.Time = e.now().UTC()
+	// Errors are ignored like when writing to stdout directly.
+	_ = e.encoder.Encode(event)
+}
+
+func (e *jsonlEmitter) Plan(tasks []project.Task) {
+	event := Event{Type: EventTypePlan, Tasks: make([]EventTask, 0, len(tasks))}
+	for _, t := range tasks {
+		event.Tasks = append(event.Tasks, EventTask{Name: t.CodeBlock.Name(), File: t.RelDocumentPath})
+	}
+	e.emit(event)
+}
+
+func (e *jsonlEmitter) TaskStart(task project.Task) {
+	e.emit(taskEvent(EventTypeTaskStart, task))
+}
+
+func (e *jsonlEmitter) Stdout(task project.Task) io.Writer {
+	return e.outputWriter(EventTypeStdout, task)
+}
+
+func (e *jsonlEmitter) Stderr(task project.Task) io.Writer {
+	return e.outputWriter(EventTypeStderr, task)
+}
+
+func (e *jsonlEmitter) outputWriter(typ EventType, task project.Task) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		event := taskEvent(typ, task)
+		// p must not be retained by writers.
+		event.Data = append([]byte(nil), p...)
+		e.emit(event)
+		return len(p), nil
+	})
+}
+
+func (e *jsonlEmitter) EnvChanged(task project.Task, before, after []string) {
+	set, unset := diffEnv(before, after)
+	if len(set) == 0 && len(unset) == 0 {
+		return
+	}
+	event := taskEvent(EventTypeEnv, task)
+	event.Set, event.Unset = set, unset
+	e.emit(event)
+}
+
+func (e *jsonlEmitter) TaskEnd(task project.Task, exitCode int, duration time.Duration, err error) {
+	event := taskEvent(EventTypeTaskEnd, task)
+	durationMs := duration.Milliseconds()
+	event.ExitCode, event.DurationMs = &exitCode, &durationMs
+	if err != nil {
+		event.Error = err.Error()
+	}
+	e.emit(event)
+}
+
+func taskEvent(typ EventType, task project.Task) Event {
+	return Event{Type: typ, Task: task.CodeBlock.Name(), File: task.RelDocumentPath}
+}
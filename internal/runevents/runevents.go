@@ -0,0 +1,104 @@
+// Package runevents reports progress of running tasks
+// in machine-readable formats.
+package runevents
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+type Format string
+
+const (
+	// FormatText is the default, human-readable output.
+	// It is not handled by emitters.
+	FormatText   Format = "text"
+	FormatJSONL  Format = "jsonl"
+	FormatGitHub Format = "github"
+)
+
+var Formats = []Format{FormatText, FormatJSONL, FormatGitHub}
+
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(s))
+	if !slices.Contains(Formats, f) {
+		return "", errors.Errorf("unsupported output %q", s)
+	}
+	return f, nil
+}
+
+// Emitter reports progress of running tasks. It is safe
+// to use from multiple goroutines running tasks in parallel.
+type Emitter interface {
+	// Plan reports tasks which are going to run.
+	Plan(tasks []project.Task)
+	TaskStart(task project.Task)
+	// Stdout returns a writer for stdout of the task.
+	Stdout(task project.Task) io.Writer
+	// Stderr returns a writer for stderr of the task.
+	Stderr(task project.Task) io.Writer
+	// EnvChanged reports a difference in the session env
+	// before and after running the task. It is not called
+	// for tasks run in parallel.
+	EnvChanged(task project.Task, before, after []string)
+	// TaskEnd reports the exit code of the task. err is set
+	// if the task failed to run for other reasons.
+	TaskEnd(task project.Task, exitCode int, duration time.Duration, err error)
+}
+
+// New returns an emitter writing events in the format to w.
+func New(w io.Writer, format Format) (Emitter, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLEmitter(w), nil
+	case FormatGitHub:
+		return newGitHubEmitter(w), nil
+	default:
+		return nil, errors.Errorf("unsupported output %q", format)
+	}
+}
+
+// diffEnv returns names of env variables set or changed in after
+// and names of env variables removed from before.
+func diffEnv(before, after []string) (set, unset []string) {
+	prev := envToMap(before)
+	next := envToMap(after)
+
+	for k, v := range next {
+		if old, ok := prev[k]; !ok || old != v {
+			set = append(set, k)
+		}
+	}
+
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			unset = append(unset, k)
+		}
+	}
+
+	sort.Strings(set)
+	sort.Strings(unset)
+
+	return set, unset
+}
+
+func envToMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		m[k] = v
+	}
+	return m
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,114 @@
+package runevents
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stateful/runme/v3/pkg/document"
+	"github.com/stateful/runme/v3/pkg/document/identity"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+func newTestTasks(t *testing.T) (project.Task, project.Task) {
+	t.Helper()
+
+	doc := document.New(
+		[]byte("```sh {\"name\":\"build\"}\nmake\n```\n\n```sh {\"name\":\"test\"}\nmake test\n```\n"),
+		identity.NewResolver(identity.DefaultLifecycleIdentity),
+	)
+	node, err := doc.Root()
+	require.NoError(t, err)
+	blocks := document.CollectCodeBlocks(node)
+	require.Len(t, blocks, 2)
+
+	newTask := func(block *document.CodeBlock) project.Task {
+		return project.Task{CodeBlock: block, DocumentPath: "/repo/README.md", RelDocumentPath: "README.md"}
+	}
+	return newTask(blocks[0]), newTask(blocks[1])
+}
+
+func TestJSONLEmitter(t *testing.T) {
+	build, _ := newTestTasks(t)
+
+	var buf bytes.Buffer
+	e := newJSONLEmitter(&buf)
+	e.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	e.Plan([]project.Task{build})
+	e.TaskStart(build)
+	_, _ = io.WriteString(e.Stdout(build), "hi\n")
+	_, _ = io.WriteString(e.Stderr(build), "oops")
+	e.EnvChanged(build, []string{"A=1", "B=2", "C=3"}, []string{"A=1", "B=3", "D=4"})
+	e.EnvChanged(build, []string{"A=1"}, []string{"A=1"})
+	e.TaskEnd(build, 2, 1500*time.Millisecond, nil)
+
+	assert.Equal(t, `{"type":"plan","time":"2024-01-02T03:04:05Z","tasks":[{"name":"build","file":"README.md"}]}
+{"type":"task_start","time":"2024-01-02T03:04:05Z","task":"build","file":"README.md"}
+{"type":"stdout","time":"2024-01-02T03:04:05Z","task":"build","file":"README.md","data":"aGkK"}
+{"type":"stderr","time":"2024-01-02T03:04:05Z","task":"build","file":"README.md","data":"b29wcw=="}
+{"type":"env","time":"2024-01-02T03:04:05Z","task":"build","file":"README.md","set":["B","D"],"unset":["C"]}
+{"type":"task_end","time":"2024-01-02T03:04:05Z","task":"build","file":"README.md","exit_code":2,"duration_ms":1500}
+`, buf.String())
+}
+
+func TestGitHubEmitter(t *testing.T) {
+	build, test := newTestTasks(t)
+
+	t.Run("Sequential", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := newGitHubEmitter(&buf)
+
+		e.Plan([]project.Task{build, test})
+		e.TaskStart(build)
+		_, _ = io.WriteString(e.Stdout(build), "building\n")
+		e.TaskEnd(build, 0, time.Second, nil)
+		e.TaskStart(test)
+		_, _ = io.WriteString(e.Stderr(test), "50% failed")
+		e.TaskEnd(test, 1, time.Second, nil)
+
+		assert.Equal(t, `::group::build
+building
+::endgroup::
+::group::test
+50% failed
+::endgroup::
+::error file=README.md,title=Task test failed::Task test exited with code 1
+`, buf.String())
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := newGitHubEmitter(&buf)
+
+		e.TaskStart(build)
+		e.TaskStart(test)
+		_, _ = io.WriteString(e.Stdout(test), "testing\n")
+		_, _ = io.WriteString(e.Stdout(build), "building\n")
+		e.TaskEnd(test, 0, time.Second, errors.New("failed to start: a, b"))
+		e.TaskEnd(build, 0, time.Second, nil)
+
+		assert.Equal(t, `::group::build
+building
+::endgroup::
+::group::test
+testing
+::endgroup::
+::error file=README.md,title=Task test failed::failed to start: a, b
+`, buf.String())
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("JSONL")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSONL, f)
+
+	_, err = ParseFormat("xml")
+	require.ErrorContains(t, err, `unsupported output "xml"`)
+}
@@ -15,6 +15,9 @@ import (
 
 type MultiRunner struct {
 	Runner Runner
+	// RootRunners, if set, are runners of workspace roots keyed by the root
+	// name. Tasks of these roots are run with them instead of Runner.
+	RootRunners map[string]Runner
 
 	StdoutPrefix string
 
@@ -79,7 +82,7 @@ func (m MultiRunner) RunBlocks(ctx context.Context, tasks []project.Task, parall
 	for _, task := range tasks {
 		block := task.CodeBlock
 
-		runnerClient := m.Runner.Clone()
+		runnerClient := m.runnerFor(task).Clone()
 
 		err := ApplyOptions(runnerClient, m.PreRunOpts...)
 		if err != nil {
@@ -133,7 +136,11 @@ func (m MultiRunner) RunBlocks(ctx context.Context, tasks []project.Task, parall
 				))
 			}
 
-			return err
+			if err != nil {
+				return fmt.Errorf("%s: %w", task.Location(), err)
+			}
+
+			return nil
 		}
 
 		if !parallel {
@@ -174,6 +181,13 @@ outer:
 	return nil
 }
 
+func (m MultiRunner) runnerFor(task project.Task) Runner {
+	if r, ok := m.RootRunners[task.Root]; ok {
+		return r
+	}
+	return m.Runner
+}
+
 func (m MultiRunner) Cleanup(ctx context.Context) error {
 	return m.Runner.Cleanup(ctx)
 }
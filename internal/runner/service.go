@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -27,10 +28,10 @@ import (
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	commandpkg "github.com/stateful/runme/v3/internal/command"
-	"github.com/stateful/runme/v3/internal/lru"
 	"github.com/stateful/runme/v3/internal/owl"
 	"github.com/stateful/runme/v3/internal/rbuffer"
 	rcontext "github.com/stateful/runme/v3/internal/runner/context"
+	"github.com/stateful/runme/v3/internal/session"
 	"github.com/stateful/runme/v3/internal/ulid"
 	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
 	"github.com/stateful/runme/v3/pkg/project"
@@ -56,22 +57,62 @@ var OpininatedEnvVarNamingRegexp = regexp.MustCompile(`^[A-Z_][A-Z0-9_]{1}[A-Z0-
 type runnerService struct {
 	runnerv1.UnimplementedRunnerServiceServer
 
-	sessions *lru.Cache[*Session]
+	sessions *session.NamespacedList[*Session]
+	// running is the number of programs which have not finished,
+	// including background ones.
+	running atomic.Int64
+
+	sessionOpts []session.NamespacedListOption
 
 	logger *zap.Logger
 }
 
-func NewRunnerService(logger *zap.Logger) (runnerv1.RunnerServiceServer, error) {
-	return newRunnerService(logger)
+// Option configures the runner service.
+type Option func(*runnerService)
+
+// WithSessionOptions sets options of the list of sessions, like quotas.
+// Sessions are isolated by the namespace of the caller like in the runner v2.
+func WithSessionOptions(opts ...session.NamespacedListOption) Option {
+	return func(r *runnerService) {
+		r.sessionOpts = append(r.sessionOpts, opts...)
+	}
 }
 
-func newRunnerService(logger *zap.Logger) (*runnerService, error) {
-	sessions := NewSessionList()
+func NewRunnerService(logger *zap.Logger, opts ...Option) (runnerv1.RunnerServiceServer, error) {
+	return newRunnerService(logger, opts...)
+}
 
-	return &runnerService{
-		logger:   logger,
-		sessions: sessions,
-	}, nil
+// Sessions returns the number of sessions.
+func (r *runnerService) Sessions() int {
+	return r.sessions.Size()
+}
+
+// ActiveExecutions returns the number of running programs.
+func (r *runnerService) ActiveExecutions() int {
+	return int(r.running.Load())
+}
+
+func newRunnerService(logger *zap.Logger, opts ...Option) (*runnerService, error) {
+	r := &runnerService{logger: logger}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.sessions = session.NewNamespacedList[*Session](r.sessionOpts...)
+	return r, nil
+}
+
+// sessionsFor returns sessions of the caller's namespace.
+// Callers must not see or use sessions of other namespaces.
+func (r *runnerService) sessionsFor(ctx context.Context) *session.Sessions[*Session] {
+	return r.sessions.Namespace(session.NamespaceFromContext(ctx))
+}
+
+// sessionError converts errors of adding sessions to gRPC errors.
+func sessionError(err error) error {
+	if errors.Is(err, session.ErrTooManySessions) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return err
 }
 
 func toRunnerv1Session(sess *Session) (*runnerv1.Session, error) {
@@ -114,9 +155,9 @@ func (r *runnerService) CreateSession(ctx context.Context, req *runnerv1.CreateS
 		return nil, err
 	}
 
-	err = r.sessions.Add(sess)
+	err = r.sessionsFor(ctx).Add(sess)
 	if err != nil {
-		return nil, err
+		return nil, sessionError(err)
 	}
 
 	r.logger.Debug("created session", zap.String("id", sess.ID))
@@ -130,10 +171,10 @@ func (r *runnerService) CreateSession(ctx context.Context, req *runnerv1.CreateS
 	}, nil
 }
 
-func (r *runnerService) GetSession(_ context.Context, req *runnerv1.GetSessionRequest) (*runnerv1.GetSessionResponse, error) {
+func (r *runnerService) GetSession(ctx context.Context, req *runnerv1.GetSessionRequest) (*runnerv1.GetSessionResponse, error) {
 	r.logger.Info("running GetSession in runnerService")
 
-	sess, ok := r.sessions.GetByID(req.Id)
+	sess, ok := r.sessionsFor(ctx).GetByID(req.Id)
 
 	if !ok {
 		return nil, status.Error(codes.NotFound, "session not found")
@@ -148,10 +189,10 @@ func (r *runnerService) GetSession(_ context.Context, req *runnerv1.GetSessionRe
 	}, nil
 }
 
-func (r *runnerService) ListSessions(_ context.Context, req *runnerv1.ListSessionsRequest) (*runnerv1.ListSessionsResponse, error) {
+func (r *runnerService) ListSessions(ctx context.Context, req *runnerv1.ListSessionsRequest) (*runnerv1.ListSessionsResponse, error) {
 	r.logger.Info("running ListSessions in runnerService")
 
-	sessions := r.sessions.List()
+	sessions := r.sessionsFor(ctx).List()
 	runnerSessions := make([]*runnerv1.Session, 0, len(sessions))
 	for _, s := range sessions {
 		runnerSess, err := toRunnerv1Session(s)
@@ -164,10 +205,10 @@ func (r *runnerService) ListSessions(_ context.Context, req *runnerv1.ListSessio
 	return &runnerv1.ListSessionsResponse{Sessions: runnerSessions}, nil
 }
 
-func (r *runnerService) DeleteSession(_ context.Context, req *runnerv1.DeleteSessionRequest) (*runnerv1.DeleteSessionResponse, error) {
+func (r *runnerService) DeleteSession(ctx context.Context, req *runnerv1.DeleteSessionRequest) (*runnerv1.DeleteSessionResponse, error) {
 	r.logger.Info("running DeleteSession in runnerService")
 
-	deleted := r.sessions.DeleteByID(req.Id)
+	deleted := r.sessionsFor(ctx).DeleteByID(req.Id)
 
 	if !deleted {
 		return nil, status.Error(codes.NotFound, "session not found")
@@ -175,8 +216,8 @@ func (r *runnerService) DeleteSession(_ context.Context, req *runnerv1.DeleteSes
 	return &runnerv1.DeleteSessionResponse{}, nil
 }
 
-func (r *runnerService) findSession(id string) *Session {
-	if sess, ok := r.sessions.GetByID(id); ok {
+func (r *runnerService) findSession(ctx context.Context, id string) *Session {
+	if sess, ok := r.sessionsFor(ctx).GetByID(id); ok {
 		return sess
 	}
 
@@ -253,7 +294,7 @@ func (r *runnerService) Execute(srv runnerv1.RunnerService_ExecuteServer) error
 	switch req.SessionStrategy {
 	case runnerv1.SessionStrategy_SESSION_STRATEGY_UNSPECIFIED:
 		if req.SessionId != "" {
-			sess = r.findSession(req.SessionId)
+			sess = r.findSession(ctx, req.SessionId)
 			if sess == nil {
 				return errors.New("session not found")
 			}
@@ -271,9 +312,9 @@ func (r *runnerService) Execute(srv runnerv1.RunnerService_ExecuteServer) error
 			}
 		}
 	case runnerv1.SessionStrategy_SESSION_STRATEGY_MOST_RECENT:
-		sess, err = r.sessions.MostRecentOrCreate(func() (*Session, error) { return createSession(req.Envs) })
+		sess, err = r.sessionsFor(ctx).NewestOrCreate(func() (*Session, error) { return createSession(req.Envs) })
 		if err != nil {
-			return err
+			return sessionError(err)
 		}
 	}
 
@@ -375,6 +416,9 @@ func (r *runnerService) Execute(srv runnerv1.RunnerService_ExecuteServer) error
 		return err
 	}
 
+	r.running.Add(1)
+	defer r.running.Add(-1)
+
 	if err := srv.Send(&runnerv1.ExecuteResponse{
 		Pid: &runnerv1.ProcessPID{
 			Pid: int64(cmd.cmd.Process.Pid),
@@ -693,7 +737,7 @@ func (r *runnerService) ResolveProgram(ctx context.Context, req *runnerv1.Resolv
 	// 	return nil, status.Error(codes.InvalidArgument, "language id is required")
 	// }
 
-	resolver, err := r.getProgramResolverFromReq(req)
+	resolver, err := r.getProgramResolverFromReq(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -756,7 +800,7 @@ type requestWithSession interface {
 	GetSessionStrategy() runnerv1.SessionStrategy
 }
 
-func (r *runnerService) getProgramResolverFromReq(req *runnerv1.ResolveProgramRequest) (*commandpkg.ProgramResolver, error) {
+func (r *runnerService) getProgramResolverFromReq(ctx context.Context, req *runnerv1.ResolveProgramRequest) (*commandpkg.ProgramResolver, error) {
 	// Add explicitly passed env as a source.
 	sources := []commandpkg.ProgramResolverSource{
 		commandpkg.ProgramResolverSourceFunc(req.Env),
@@ -778,15 +822,15 @@ func (r *runnerService) getProgramResolverFromReq(req *runnerv1.ResolveProgramRe
 
 	// Add session env as a source and pass info about sensitive env vars.
 	sensitiveEnvKeys := []string{}
-	session, found := r.getSessionFromRequest(req)
+	sess, found := r.getSessionFromRequest(ctx, req)
 	if found {
-		env, err := session.Envs()
+		env, err := sess.Envs()
 		if err != nil {
 			return nil, err
 		}
 		sources = append(sources, commandpkg.ProgramResolverSourceFunc(env))
 
-		sensitiveEnvKeys, err = session.SensitiveEnvKeys()
+		sensitiveEnvKeys, err = sess.SensitiveEnvKeys()
 		if err != nil {
 			return nil, err
 		}
@@ -815,13 +859,13 @@ func (r *runnerService) getProgramResolverRetentionFromReq(req *runnerv1.Resolve
 	}
 }
 
-func (r *runnerService) getSessionFromRequest(req requestWithSession) (*Session, bool) {
+func (r *runnerService) getSessionFromRequest(ctx context.Context, req requestWithSession) (*Session, bool) {
 	switch req.GetSessionStrategy() {
 	case runnerv1.SessionStrategy_SESSION_STRATEGY_MOST_RECENT:
-		return r.sessions.MostRecent()
+		return r.sessionsFor(ctx).Newest()
 	default:
 		if sessID := req.GetSessionId(); sessID != "" {
-			sess := r.findSession(sessID)
+			sess := r.findSession(ctx, sessID)
 			return sess, sess != nil
 		}
 		return nil, false
@@ -833,7 +877,7 @@ func (r *runnerService) MonitorEnvStore(req *runnerv1.MonitorEnvStoreRequest, sr
 		return status.Error(codes.InvalidArgument, "session is required")
 	}
 
-	sess, ok := r.sessions.GetByID(req.Session.Id)
+	sess, ok := r.sessionsFor(srv.Context()).GetByID(req.Session.Id)
 	if !ok {
 		return status.Error(codes.NotFound, "session not found")
 	}
@@ -24,6 +24,7 @@ import (
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/stateful/runme/v3/internal/session"
 	"github.com/stateful/runme/v3/internal/testutils"
 	"github.com/stateful/runme/v3/internal/ulid"
 	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
@@ -1057,6 +1058,56 @@ func Test_runnerService(t *testing.T) {
 	})
 }
 
+func Test_runnerService_Namespaces(t *testing.T) {
+	runnerService, err := newRunnerService(zap.NewNop(), WithSessionOptions(session.WithQuota(1)))
+	require.NoError(t, err)
+
+	aliceCtx := session.ContextWithNamespace(context.Background(), "alice")
+	bobCtx := session.ContextWithNamespace(context.Background(), "bob")
+
+	resp, err := runnerService.CreateSession(aliceCtx, &runnerv1.CreateSessionRequest{Envs: []string{"SECRET=alice"}})
+	require.NoError(t, err)
+	aliceID := resp.Session.Id
+
+	list, err := runnerService.ListSessions(bobCtx, &runnerv1.ListSessionsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Sessions)
+
+	_, err = runnerService.GetSession(bobCtx, &runnerv1.GetSessionRequest{Id: aliceID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = runnerService.DeleteSession(bobCtx, &runnerv1.DeleteSessionRequest{Id: aliceID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The most recent session of bob does not exist, so the env of alice is not used.
+	resolved, err := runnerService.ResolveProgram(bobCtx, &runnerv1.ResolveProgramRequest{
+		Source:          &runnerv1.ResolveProgramRequest_Script{Script: "export SECRET=default"},
+		SessionStrategy: runnerv1.SessionStrategy_SESSION_STRATEGY_MOST_RECENT,
+		LanguageId:      "bash",
+	})
+	require.NoError(t, err)
+	require.Len(t, resolved.Vars, 1)
+	assert.Equal(t, "default", resolved.Vars[0].OriginalValue)
+	assert.Empty(t, resolved.Vars[0].ResolvedValue)
+
+	resolved, err = runnerService.ResolveProgram(aliceCtx, &runnerv1.ResolveProgramRequest{
+		Source:          &runnerv1.ResolveProgramRequest_Script{Script: "export SECRET=default"},
+		SessionStrategy: runnerv1.SessionStrategy_SESSION_STRATEGY_MOST_RECENT,
+		LanguageId:      "bash",
+	})
+	require.NoError(t, err)
+	require.Len(t, resolved.Vars, 1)
+	assert.Equal(t, "alice", resolved.Vars[0].ResolvedValue)
+
+	// The quota applies to each namespace.
+	_, err = runnerService.CreateSession(bobCtx, &runnerv1.CreateSessionRequest{})
+	require.NoError(t, err)
+	_, err = runnerService.CreateSession(aliceCtx, &runnerv1.CreateSessionRequest{})
+	require.NoError(t, err)
+	_, err = runnerService.GetSession(aliceCtx, &runnerv1.GetSessionRequest{Id: aliceID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 2, runnerService.Sessions())
+}
+
 func Test_varRetentionStrategies(t *testing.T) {
 	lis, stop := testStartRunnerServiceServer(t)
 	t.Cleanup(stop)
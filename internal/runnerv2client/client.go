@@ -2,6 +2,7 @@ package runnerv2client
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"reflect"
 
@@ -36,6 +37,9 @@ func (c *Client) Close() error {
 }
 
 type ExecuteProgramOptions struct {
+	// DocumentPath is a path of the document containing the program.
+	// It is used by the server to evaluate the authorization policy.
+	DocumentPath     string
 	InputData        []byte
 	SessionID        string
 	Stdin            io.ReadCloser
@@ -45,6 +49,16 @@ type ExecuteProgramOptions struct {
 	Winsize          *runnerv2.Winsize
 }
 
+// ExitError is returned by [Client.ExecuteProgram]
+// if the program exits with a non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit with code %d", e.Code)
+}
+
 func (c *Client) ExecuteProgram(
 	ctx context.Context,
 	cfg *runnerv2.ProgramConfig,
@@ -66,6 +80,7 @@ func (c *Client) executeProgram(
 	// Send the initial request.
 	req := &runnerv2.ExecuteRequest{
 		Config:           cfg,
+		DocumentPath:     opts.DocumentPath,
 		InputData:        opts.InputData,
 		SessionId:        opts.SessionID,
 		StoreStdoutInEnv: opts.StoreStdoutInEnv,
@@ -141,7 +156,7 @@ func (c *Client) executeProgram(
 		}
 
 		if code := resp.GetExitCode(); code != nil && code.GetValue() != 0 {
-			return errors.WithMessagef(err, "exit with code %d", code.GetValue())
+			return &ExitError{Code: int(code.GetValue())}
 		}
 	}
 
@@ -72,6 +72,29 @@ func TestClient_ExecuteProgram(t *testing.T) {
 		require.Equal(t, "test-output-with-session-stderr", stderr.String())
 	})
 
+	t.Run("ExitCode", func(t *testing.T) {
+		t.Parallel()
+
+		client := createClient(t, lis)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		cfg := &command.ProgramConfig{
+			ProgramName: "bash",
+			Source: &runnerv2.ProgramConfig_Commands{
+				Commands: &runnerv2.ProgramConfig_CommandList{
+					Items: []string{"exit 3"},
+				},
+			},
+			Mode: runnerv2.CommandMode_COMMAND_MODE_INLINE,
+		}
+		err := client.ExecuteProgram(ctx, cfg, ExecuteProgramOptions{})
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 3, exitErr.Code)
+	})
+
 	t.Run("InputNonInteractive", func(t *testing.T) {
 		t.Parallel()
 
@@ -0,0 +1,253 @@
+package runnerv2service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExecutionLimits limit the number of concurrently running programs.
+// Zero means no limit.
+type ExecutionLimits struct {
+	MaxExecutions           int
+	MaxExecutionsPerClient  int
+	MaxExecutionsPerSession int
+
+	// MaxQueued is the maximum number of executions waiting for
+	// other executions to finish. If zero, executions above a limit
+	// fail right away.
+	MaxQueued int
+
+	// QueueTimeout is how long an execution can wait in the queue.
+	// If zero, it waits until the client cancels the call.
+	QueueTimeout time.Duration
+}
+
+// executionOwner determines which limits apply to an execution.
+type executionOwner struct {
+	namespace string
+	sessionID string
+}
+
+type executionWaiter struct {
+	owner executionOwner
+	// granted is closed when the execution can start.
+	granted chan struct{}
+	// moved is notified when the position in the queue changes.
+	moved chan struct{}
+}
+
+// executionLimiter enforces [ExecutionLimits]. Executions above
+// a limit wait in a FIFO queue. An execution which fits all limits
+// starts right away, even if the queue is not empty, because
+// the queued executions are blocked by limits which do not apply to it.
+type executionLimiter struct {
+	limits ExecutionLimits
+
+	mu sync.Mutex
+	// +checklocks:mu
+	active int
+	// +checklocks:mu
+	activeByClient map[string]int
+	// +checklocks:mu
+	activeBySession map[string]int
+	// +checklocks:mu
+	queue *list.List
+}
+
+func newExecutionLimiter(limits ExecutionLimits) *executionLimiter {
+	return &executionLimiter{
+		limits:          limits,
+		activeByClient:  make(map[string]int),
+		activeBySession: make(map[string]int),
+		queue:           list.New(),
+	}
+}
+
+// acquire waits until the execution fits the limits. While waiting,
+// onQueued is called with the position in the queue every time it changes.
+// The returned function must be called when the execution finishes.
+func (l *executionLimiter) acquire(
+	ctx context.Context,
+	owner executionOwner,
+	onQueued func(position int) error,
+) (release func(), _ error) {
+	l.mu.Lock()
+
+	if l.fitsLocked(owner) {
+		l.takeLocked(owner)
+		l.mu.Unlock()
+		return l.releaseFunc(owner), nil
+	}
+
+	if l.queue.Len() >= l.limits.MaxQueued {
+		l.mu.Unlock()
+		return nil, status.Error(codes.ResourceExhausted, "too many concurrent executions")
+	}
+
+	w := &executionWaiter{
+		owner:   owner,
+		granted: make(chan struct{}),
+		moved:   make(chan struct{}, 1),
+	}
+	element := l.queue.PushBack(w)
+	position := l.queue.Len()
+
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.limits.QueueTimeout > 0 {
+		timer := time.NewTimer(l.limits.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	err := onQueued(position)
+
+	for err == nil {
+		select {
+		case <-w.granted:
+			return l.releaseFunc(owner), nil
+		case <-w.moved:
+			if position = l.position(element); position > 0 {
+				err = onQueued(position)
+			}
+		case <-timeout:
+			err = status.Errorf(codes.ResourceExhausted, "timed out after %s waiting for other executions to finish", l.limits.QueueTimeout)
+		case <-ctx.Done():
+			err = status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if l.leave(element) {
+		return nil, err
+	}
+
+	// The execution was granted concurrently with the error.
+	// Release it right away so that other executions can start.
+	l.releaseFunc(owner)()
+	return nil, err
+}
+
+func (l *executionLimiter) releaseFunc(owner executionOwner) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.active--
+			decrement(l.activeByClient, owner.namespace)
+			decrement(l.activeBySession, owner.sessionID)
+
+			l.dispatchLocked()
+		})
+	}
+}
+
+// leave removes the waiter from the queue. It returns false
+// if the waiter is not in the queue because it has been granted.
+func (l *executionLimiter) leave(element *list.Element) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-element.Value.(*executionWaiter).granted:
+		return false
+	default:
+	}
+
+	l.removeLocked(element)
+	return true
+}
+
+func (l *executionLimiter) position(element *list.Element) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	position := 1
+	for e := l.queue.Front(); e != nil; e = e.Next() {
+		if e == element {
+			return position
+		}
+		position++
+	}
+	return 0
+}
+
+// +checklocks:l.mu
+func (l *executionLimiter) fitsLocked(owner executionOwner) bool {
+	return fits(l.limits.MaxExecutions, l.active) &&
+		fits(l.limits.MaxExecutionsPerClient, l.activeByClient[owner.namespace]) &&
+		fits(l.limits.MaxExecutionsPerSession, l.activeBySession[owner.sessionID])
+}
+
+// +checklocks:l.mu
+func (l *executionLimiter) takeLocked(owner executionOwner) {
+	l.active++
+	l.activeByClient[owner.namespace]++
+	l.activeBySession[owner.sessionID]++
+}
+
+// dispatchLocked starts queued executions which fit the limits.
+//
+// +checklocks:l.mu
+func (l *executionLimiter) dispatchLocked() {
+	for e := l.queue.Front(); e != nil; {
+		next := e.Next()
+		w := e.Value.(*executionWaiter)
+		if l.fitsLocked(w.owner) {
+			l.takeLocked(w.owner)
+			l.removeLocked(e)
+			close(w.granted)
+		}
+		e = next
+	}
+}
+
+// removeLocked removes the element from the queue
+// and notifies waiters which moved forward.
+//
+// +checklocks:l.mu
+func (l *executionLimiter) removeLocked(element *list.Element) {
+	for e := element.Next(); e != nil; e = e.Next() {
+		select {
+		case e.Value.(*executionWaiter).moved <- struct{}{}:
+		default:
+		}
+	}
+	l.queue.Remove(element)
+}
+
+type executionUsage struct {
+	active       int
+	queued       int
+	clientActive int
+}
+
+func (l *executionLimiter) usage(namespace string) executionUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return executionUsage{
+		active:       l.active,
+		queued:       l.queue.Len(),
+		clientActive: l.activeByClient[namespace],
+	}
+}
+
+func fits(limit, active int) bool {
+	return limit <= 0 || active < limit
+}
+
+func decrement(m map[string]int, key string) {
+	if m[key] <= 1 {
+		delete(m, key)
+	} else {
+		m[key]--
+	}
+}
@@ -0,0 +1,114 @@
+package runnerv2service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func noQueue(t *testing.T) func(int) error {
+	return func(position int) error {
+		t.Fatalf("unexpectedly queued at position %d", position)
+		return nil
+	}
+}
+
+func TestExecutionLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queue", func(t *testing.T) {
+		t.Parallel()
+
+		l := newExecutionLimiter(ExecutionLimits{MaxExecutions: 1, MaxQueued: 1})
+		owner := executionOwner{namespace: "alice", sessionID: "s1"}
+
+		release, err := l.acquire(context.Background(), owner, noQueue(t))
+		require.NoError(t, err)
+
+		positions := make(chan int, 1)
+		acquired := make(chan func())
+		go func() {
+			release, err := l.acquire(context.Background(), owner, func(position int) error {
+				positions <- position
+				return nil
+			})
+			assert.NoError(t, err)
+			acquired <- release
+		}()
+		assert.Equal(t, 1, <-positions)
+
+		// The queue is full.
+		_, err = l.acquire(context.Background(), owner, noQueue(t))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		assert.Equal(t, executionUsage{active: 1, queued: 1, clientActive: 1}, l.usage("alice"))
+
+		release()
+		release() // no-op
+		(<-acquired)()
+
+		assert.Equal(t, executionUsage{}, l.usage("alice"))
+	})
+
+	t.Run("PerSession", func(t *testing.T) {
+		t.Parallel()
+
+		l := newExecutionLimiter(ExecutionLimits{MaxExecutionsPerSession: 1, MaxExecutionsPerClient: 2})
+
+		release1, err := l.acquire(context.Background(), executionOwner{namespace: "alice", sessionID: "s1"}, noQueue(t))
+		require.NoError(t, err)
+		defer release1()
+
+		_, err = l.acquire(context.Background(), executionOwner{namespace: "alice", sessionID: "s1"}, noQueue(t))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		release2, err := l.acquire(context.Background(), executionOwner{namespace: "alice", sessionID: "s2"}, noQueue(t))
+		require.NoError(t, err)
+		defer release2()
+
+		// Client limit is reached, but other clients are not affected.
+		_, err = l.acquire(context.Background(), executionOwner{namespace: "alice", sessionID: "s3"}, noQueue(t))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		release3, err := l.acquire(context.Background(), executionOwner{namespace: "bob", sessionID: "s4"}, noQueue(t))
+		require.NoError(t, err)
+		defer release3()
+	})
+
+	t.Run("QueueTimeout", func(t *testing.T) {
+		t.Parallel()
+
+		l := newExecutionLimiter(ExecutionLimits{MaxExecutions: 1, MaxQueued: 1, QueueTimeout: 10 * time.Millisecond})
+
+		release, err := l.acquire(context.Background(), executionOwner{}, noQueue(t))
+		require.NoError(t, err)
+		defer release()
+
+		_, err = l.acquire(context.Background(), executionOwner{}, func(int) error { return nil })
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, 0, l.usage("").queued)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+
+		l := newExecutionLimiter(ExecutionLimits{MaxExecutions: 1, MaxQueued: 2})
+
+		release, err := l.acquire(context.Background(), executionOwner{}, noQueue(t))
+		require.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = l.acquire(ctx, executionOwner{}, func(int) error {
+			cancel()
+			return nil
+		})
+		assert.Equal(t, codes.Canceled, status.Code(err))
+		assert.Equal(t, 0, l.usage("").queued)
+	})
+}
@@ -1,14 +1,15 @@
 package runnerv2service
 
 import (
+	"context"
 	"os"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/stateful/runme/v3/internal/command"
-	"github.com/stateful/runme/v3/internal/lru"
 	"github.com/stateful/runme/v3/internal/session"
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 	"github.com/stateful/runme/v3/pkg/project"
@@ -18,61 +19,161 @@ type runnerService struct {
 	runnerv2.UnimplementedRunnerServiceServer
 
 	cmdFactory command.Factory
-	sessions   *lru.Cache[*session.Session]
+	sessions   *session.NamespacedList[*session.Session]
+	inputs     *executionInputs
+	limiter    *executionLimiter
+	usage      []UsageReporter
+	metrics    *serviceMetrics
 	logger     *zap.Logger
 }
 
-func NewRunnerService(factory command.Factory, logger *zap.Logger) (runnerv2.RunnerServiceServer, error) {
-	sessions := session.NewSessionList()
+type Option func(*options)
+
+type options struct {
+	sessionOpts []session.NamespacedListOption
+	limits      ExecutionLimits
+	usage       []UsageReporter
+}
+
+// WithSessionQuota sets the maximum number of sessions of a single caller.
+func WithSessionQuota(quota int) Option {
+	return func(o *options) {
+		o.sessionOpts = append(o.sessionOpts, session.WithQuota(quota))
+	}
+}
+
+// WithNamespaceSessionQuota overrides the session quota of a specific namespace.
+func WithNamespaceSessionQuota(namespace string, quota int) Option {
+	return func(o *options) {
+		o.sessionOpts = append(o.sessionOpts, session.WithNamespaceQuota(namespace, quota))
+	}
+}
+
+// WithMaxSessions sets the maximum number of sessions of all callers.
+func WithMaxSessions(max int) Option {
+	return func(o *options) {
+		o.sessionOpts = append(o.sessionOpts, session.WithMaxSessions(max))
+	}
+}
+
+// WithExecutionLimits limits the number of concurrently running programs.
+func WithExecutionLimits(limits ExecutionLimits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// UsageReporter reports sessions and running programs of a service.
+// It is implemented by the runner services of both versions.
+type UsageReporter interface {
+	Sessions() int
+	ActiveExecutions() int
+}
+
+// WithUsageReporter includes sessions and executions of another service
+// served by the same server, like the runner v1, in GetServerStatus.
+func WithUsageReporter(u UsageReporter) Option {
+	return func(o *options) {
+		o.usage = append(o.usage, u)
+	}
+}
+
+func NewRunnerService(factory command.Factory, logger *zap.Logger, opts ...Option) (runnerv2.RunnerServiceServer, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	metrics, err := newServiceMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := session.NewNamespacedList[*session.Session](
+		append(
+			o.sessionOpts,
+			session.WithEvictHandler(func(namespace string, sess *session.Session) {
+				logger.Info("evicted session", zap.String("namespace", namespace), zap.String("id", sess.ID))
+				metrics.sessionEvictions.Add(context.Background(), 1)
+			}),
+		)...,
+	)
+
+	if err := metrics.observeSessions(sessions); err != nil {
+		return nil, err
+	}
 
 	r := &runnerService{
 		cmdFactory: factory,
 		sessions:   sessions,
+		inputs:     newExecutionInputs(),
+		limiter:    newExecutionLimiter(o.limits),
+		usage:      o.usage,
+		metrics:    metrics,
 		logger:     logger,
 	}
 
 	return r, nil
 }
 
+// sessionsFor returns sessions of the caller's namespace.
+// Callers must not see or use sessions of other namespaces.
+func (r *runnerService) sessionsFor(ctx context.Context) *session.Sessions[*session.Session] {
+	return r.sessions.Namespace(session.NamespaceFromContext(ctx))
+}
+
+// addSession adds the session to the caller's namespace.
+func (r *runnerService) addSession(ctx context.Context, sess *session.Session) error {
+	err := r.sessionsFor(ctx).Add(sess)
+	if errors.Is(err, session.ErrTooManySessions) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return err
+}
+
 type requestWithSession interface {
 	GetSessionId() string
 	GetSessionStrategy() runnerv2.SessionStrategy
 }
 
-func (r *runnerService) getSessionFromRequest(req requestWithSession) (*session.Session, bool, error) {
+func (r *runnerService) getSessionFromRequest(ctx context.Context, req requestWithSession) (*session.Session, bool, error) {
 	var (
 		session *session.Session
 		found   bool
 	)
 
+	sessions := r.sessionsFor(ctx)
+
 	switch req.GetSessionStrategy() {
 	case runnerv2.SessionStrategy_SESSION_STRATEGY_UNSPECIFIED:
 		if req.GetSessionId() != "" {
-			session, found = r.sessions.GetByID(req.GetSessionId())
+			session, found = sessions.GetByID(req.GetSessionId())
 			if !found {
 				return nil, false, status.Errorf(codes.NotFound, "session %q not found", req.GetSessionId())
 			}
 		}
 	case runnerv2.SessionStrategy_SESSION_STRATEGY_MOST_RECENT:
-		session, found = r.sessions.Newest()
+		session, found = sessions.Newest()
 	}
 
 	return session, found, nil
 }
 
-func (r *runnerService) getOrCreateSessionFromRequest(req requestWithSession, proj *project.Project) (_ *session.Session, exists bool, _ error) {
+func (r *runnerService) getOrCreateSessionFromRequest(ctx context.Context, req requestWithSession, proj *project.Project) (_ *session.Session, exists bool, _ error) {
 	var (
 		sess  *session.Session
 		found bool
 	)
 
+	sessions := r.sessionsFor(ctx)
+
 	// TODO(adamb): this should come from the runme.yaml in the future.
 	seedEnv := os.Environ()
 
 	switch req.GetSessionStrategy() {
 	case runnerv2.SessionStrategy_SESSION_STRATEGY_UNSPECIFIED:
 		if req.GetSessionId() != "" {
-			sess, found = r.sessions.GetByID(req.GetSessionId())
+			sess, found = sessions.GetByID(req.GetSessionId())
 			if !found {
 				return nil, false, status.Errorf(codes.NotFound, "session %q not found", req.GetSessionId())
 			}
@@ -88,7 +189,7 @@ func (r *runnerService) getOrCreateSessionFromRequest(req requestWithSession, pr
 			sess = s
 		}
 	case runnerv2.SessionStrategy_SESSION_STRATEGY_MOST_RECENT:
-		sess, found = r.sessions.Newest()
+		sess, found = sessions.Newest()
 		if !found {
 			s, err := session.New(
 				session.WithOwl(false),
@@ -5,20 +5,28 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	rcontext "github.com/stateful/runme/v3/internal/runner/context"
+	rsession "github.com/stateful/runme/v3/internal/session"
 	"github.com/stateful/runme/v3/internal/ulid"
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 )
 
 func (r *runnerService) Execute(srv runnerv2.RunnerService_ExecuteServer) error {
-	runID := ulid.GenerateID()
+	return r.execute(srv, ulid.GenerateID())
+}
+
+func (r *runnerService) execute(srv runnerv2.RunnerService_ExecuteServer, runID string) (err error) {
 	logger := r.logger.Named("Execute").With(zap.String("id", runID))
 
+	srv = &meteredExecuteServer{RunnerService_ExecuteServer: srv, outputBytes: r.metrics.outputBytes}
+
 	// Get the initial request.
 	req, err := srv.Recv()
 	if err != nil {
@@ -31,10 +39,22 @@ func (r *runnerService) Execute(srv runnerv2.RunnerService_ExecuteServer) error
 	}
 	logger.Info("received initial request", zap.Any("req", req))
 
+	ctx, span := tracer.Start(
+		srv.Context(),
+		"Execute",
+		trace.WithAttributes(
+			attrKnownID.String(req.GetConfig().GetKnownId()),
+			attrKnownName.String(req.GetConfig().GetKnownName()),
+			attrProgram.String(req.GetConfig().GetProgramName()),
+			attrLanguageID.String(req.GetConfig().GetLanguageId()),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
 	execInfo := getExecutionInfoFromExecutionRequest(req)
 	execInfo.RunID = runID
 
-	ctx := rcontext.WithExecutionInfo(srv.Context(), execInfo)
+	ctx = rcontext.WithExecutionInfo(ctx, execInfo)
 
 	// Load the project.
 	// TODO(adamb): this should come from the runme.yaml in the future.
@@ -44,16 +64,35 @@ func (r *runnerService) Execute(srv runnerv2.RunnerService_ExecuteServer) error
 	}
 
 	// Manage the session.
-	session, existed, err := r.getOrCreateSessionFromRequest(req, proj)
+	session, existed, err := r.getOrCreateSessionFromRequest(ctx, req, proj)
 	if err != nil {
 		return err
 	}
 	if !existed {
-		err := r.sessions.Add(session)
+		err := r.addSession(ctx, session)
 		if err != nil {
 			return err
 		}
 	}
+	span.SetAttributes(attrSessionID.String(session.ID))
+	release, err := r.limiter.acquire(
+		ctx,
+		executionOwner{namespace: rsession.NamespaceFromContext(ctx), sessionID: session.ID},
+		func(position int) error {
+			logger.Info("execution queued", zap.Int("position", position))
+			return srv.Send(&runnerv2.ExecuteResponse{
+				ExecutionId: runID,
+				Queue:       &runnerv2.ExecuteQueue{Position: uint32(position)},
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Env is set only once the execution is allowed to start
+	// so that rejected executions do not modify the session.
 	if err := session.SetEnv(ctx, req.Config.Env...); err != nil {
 		return err
 	}
@@ -73,8 +112,13 @@ func (r *runnerService) Execute(srv runnerv2.RunnerService_ExecuteServer) error
 	if err := exec.Cmd.Start(ctx); err != nil {
 		return err
 	}
+
+	r.metrics.activeExecutions.Add(ctx, 1)
+	defer r.metrics.activeExecutions.Add(ctx, -1)
+
 	if err := srv.Send(&runnerv2.ExecuteResponse{
-		Pid: &wrapperspb.UInt32Value{Value: uint32(exec.Cmd.Pid())},
+		Pid:         &wrapperspb.UInt32Value{Value: uint32(exec.Cmd.Pid())},
+		ExecutionId: runID,
 	}); err != nil {
 		return err
 	}
@@ -126,6 +170,9 @@ func (r *runnerService) Execute(srv runnerv2.RunnerService_ExecuteServer) error
 	exitCode, waitErr := exec.Wait(ctx, srv)
 	logger.Info("command finished", zap.Int("exitCode", exitCode), zap.Error(waitErr))
 
+	span.SetAttributes(attrExitCode.Int(exitCode))
+	r.metrics.executions.Add(ctx, 1, metric.WithAttributes(attrExitCode.Int(exitCode)))
+
 	var finalExitCode *wrapperspb.UInt32Value
 	if exitCode > -1 {
 		finalExitCode = wrapperspb.UInt32(uint32(exitCode))
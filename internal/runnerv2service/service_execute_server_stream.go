@@ -0,0 +1,146 @@
+package runnerv2service
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/stateful/runme/v3/internal/server"
+	"github.com/stateful/runme/v3/internal/ulid"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func (r *runnerService) ExecuteServerStream(req *runnerv2.ExecuteRequest, srv runnerv2.RunnerService_ExecuteServerStreamServer) error {
+	runID := ulid.GenerateID()
+
+	input := r.inputs.Add(runID, inputOwner(srv.Context()))
+	defer r.inputs.Remove(runID)
+
+	return r.execute(
+		&executeServerStream{
+			RunnerService_ExecuteServerStreamServer: srv,
+			initialReq:                              req,
+			input:                                   input,
+		},
+		runID,
+	)
+}
+
+func (r *runnerService) SendInput(ctx context.Context, req *runnerv2.SendInputRequest) (*runnerv2.SendInputResponse, error) {
+	// Without the identity of the caller, it is impossible
+	// to tell whether it started the execution.
+	owner := inputOwner(ctx)
+	if owner == "" {
+		return nil, status.Error(codes.Unauthenticated, "sending input requires an authenticated client")
+	}
+
+	input, ok := r.inputs.Get(req.GetExecutionId())
+	// Executions of other clients are reported as not found
+	// in order to not reveal their existence.
+	if !ok || input.owner != owner {
+		return nil, status.Errorf(codes.NotFound, "execution %q not found", req.GetExecutionId())
+	}
+
+	inputReq := &runnerv2.ExecuteRequest{
+		InputData: req.GetInputData(),
+		Stop:      req.GetStop(),
+		Winsize:   req.GetWinsize(),
+	}
+
+	select {
+	case input.requests <- inputReq:
+		return &runnerv2.SendInputResponse{}, nil
+	case <-input.done:
+		return nil, status.Errorf(codes.NotFound, "execution %q not found", req.GetExecutionId())
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// executeServerStream adapts a server-streaming call to the bidirectional
+// stream expected by [runnerService.execute]. Requests following the initial
+// one come from SendInput.
+type executeServerStream struct {
+	runnerv2.RunnerService_ExecuteServerStreamServer
+
+	initialReq *runnerv2.ExecuteRequest
+	input      *executionInput
+}
+
+func (s *executeServerStream) Recv() (*runnerv2.ExecuteRequest, error) {
+	if req := s.initialReq; req != nil {
+		s.initialReq = nil
+		return req, nil
+	}
+
+	select {
+	case req := <-s.input.requests:
+		return req, nil
+	case <-s.Context().Done():
+		return nil, status.FromContextError(s.Context().Err()).Err()
+	}
+}
+
+type executionInput struct {
+	// owner is the client which started the execution; see [inputOwner].
+	owner    string
+	requests chan *runnerv2.ExecuteRequest
+	done     chan struct{}
+}
+
+// executionInputs routes requests sent with SendInput
+// to executions started with ExecuteServerStream.
+type executionInputs struct {
+	mu     sync.Mutex
+	inputs map[string]*executionInput
+}
+
+func newExecutionInputs() *executionInputs {
+	return &executionInputs{inputs: make(map[string]*executionInput)}
+}
+
+func (e *executionInputs) Add(id, owner string) *executionInput {
+	input := &executionInput{
+		owner:    owner,
+		requests: make(chan *runnerv2.ExecuteRequest),
+		done:     make(chan struct{}),
+	}
+
+	e.mu.Lock()
+	e.inputs[id] = input
+	e.mu.Unlock()
+
+	return input
+}
+
+func (e *executionInputs) Get(id string) (*executionInput, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	input, ok := e.inputs[id]
+	return input, ok
+}
+
+func (e *executionInputs) Remove(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if input, ok := e.inputs[id]; ok {
+		close(input.done)
+		delete(e.inputs, id)
+	}
+}
+
+// inputOwner identifies the client which can send input to an execution.
+// It is the serial number of the client certificate, the user of the process
+// connected to a unix socket, or an empty string if the client is not authenticated.
+func inputOwner(ctx context.Context) string {
+	if id, ok := server.ClientIdentityFromContext(ctx); ok {
+		return id.Serial
+	}
+	if creds, ok := server.PeerCredentialsFromContext(ctx); ok {
+		return "uid:" + strconv.FormatUint(uint64(creds.UID), 10)
+	}
+	return ""
+}
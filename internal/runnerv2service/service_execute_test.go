@@ -16,10 +16,14 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/stateful/runme/v3/internal/command"
 	"github.com/stateful/runme/v3/internal/command/testdata"
+	runmeserver "github.com/stateful/runme/v3/internal/server"
 	"github.com/stateful/runme/v3/internal/testutils"
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 )
@@ -844,6 +848,121 @@ func TestRunnerServiceServerExecute_WithInput(t *testing.T) {
 	})
 }
 
+func TestRunnerServiceServerExecuteServerStream(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := startRunnerServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	ctx := withTestClient(context.Background(), "a")
+
+	t.Run("WithInput", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := client.ExecuteServerStream(
+			ctx,
+			&runnerv2.ExecuteRequest{
+				Config: &runnerv2.ProgramConfig{
+					ProgramName: "bash",
+					Source: &runnerv2.ProgramConfig_Commands{
+						Commands: &runnerv2.ProgramConfig_CommandList{
+							Items: []string{
+								"read name",
+								"echo \"hello $name\"",
+							},
+						},
+					},
+					Interactive: true,
+				},
+			},
+		)
+		require.NoError(t, err)
+
+		// Assert first response which contains PID and the execution ID.
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Greater(t, resp.Pid.Value, uint32(1))
+		require.NotEmpty(t, resp.ExecutionId)
+
+		resultC := make(chan executeResult)
+		go getExecuteResult(stream, resultC)
+
+		// Other clients cannot send input.
+		_, err = client.SendInput(withTestClient(context.Background(), "b"), &runnerv2.SendInputRequest{
+			ExecutionId: resp.ExecutionId,
+			InputData:   []byte("eve\n"),
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.SendInput(context.Background(), &runnerv2.SendInputRequest{
+			ExecutionId: resp.ExecutionId,
+			InputData:   []byte("eve\n"),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.SendInput(ctx, &runnerv2.SendInputRequest{
+			ExecutionId: resp.ExecutionId,
+			InputData:   []byte("frank\n"),
+		})
+		require.NoError(t, err)
+
+		result := <-resultC
+		assert.NoError(t, result.Err)
+		assert.EqualValues(t, 0, result.ExitCode)
+		assert.Contains(t, string(result.Stdout), "hello frank")
+
+		// The execution is finished.
+		_, err = client.SendInput(ctx, &runnerv2.SendInputRequest{
+			ExecutionId: resp.ExecutionId,
+			InputData:   []byte("frank\n"),
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("WithStop", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := client.ExecuteServerStream(
+			ctx,
+			&runnerv2.ExecuteRequest{
+				Config: &runnerv2.ProgramConfig{
+					ProgramName: "sleep",
+					Arguments:   []string{"30"},
+					Mode:        runnerv2.CommandMode_COMMAND_MODE_INLINE,
+				},
+			},
+		)
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+
+		resultC := make(chan executeResult)
+		go getExecuteResult(stream, resultC)
+
+		_, err = client.SendInput(ctx, &runnerv2.SendInputRequest{
+			ExecutionId: resp.ExecutionId,
+			Stop:        runnerv2.ExecuteStop_EXECUTE_STOP_INTERRUPT,
+		})
+		require.NoError(t, err)
+
+		result := <-resultC
+		require.NotNil(t, result.Err)
+		assert.Contains(t, result.Err.Error(), "signal: interrupt")
+		assert.Equal(t, 130, result.ExitCode)
+	})
+
+	t.Run("UnknownExecution", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := client.SendInput(ctx, &runnerv2.SendInputRequest{
+			ExecutionId: "unknown",
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
 func TestRunnerServiceServerExecute_WithSession(t *testing.T) {
 	t.Parallel()
 
@@ -1174,18 +1293,20 @@ func Test_VarRetentionStrategies(t *testing.T) {
 }
 
 // Duplicated in testutils/runnerservice/runner_service.go for other packages.
-func startRunnerServiceServer(t *testing.T) (_ *bufconn.Listener, stop func()) {
+func startRunnerServiceServer(t *testing.T, opts ...Option) (_ *bufconn.Listener, stop func()) {
 	t.Helper()
 
 	logger := zaptest.NewLogger(t)
 	factory := command.NewFactory(command.WithLogger(logger))
 
-	runnerService, err := NewRunnerService(factory, logger)
+	runnerService, err := NewRunnerService(factory, logger, opts...)
 	require.NoError(t, err)
 
 	server := grpc.NewServer(
 		grpc.MaxRecvMsgSize(msgBufferSize*2),
 		grpc.MaxSendMsgSize(msgBufferSize*2),
+		grpc.ChainUnaryInterceptor(testClientIdentityUnary),
+		grpc.ChainStreamInterceptor(testClientIdentityStream),
 	)
 	runnerv2.RegisterRunnerServiceServer(server, runnerService)
 
@@ -1195,6 +1316,36 @@ func startRunnerServiceServer(t *testing.T) (_ *bufconn.Listener, stop func()) {
 	return lis, server.Stop
 }
 
+// testClientSerialKey is the metadata key with which test clients
+// authenticate instead of client certificates.
+const testClientSerialKey = "test-client-serial"
+
+func withTestClient(ctx context.Context, serial string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, testClientSerialKey, serial)
+}
+
+func withTestClientIdentity(ctx context.Context) context.Context {
+	if values := metadata.ValueFromIncomingContext(ctx, testClientSerialKey); len(values) > 0 {
+		return runmeserver.ContextWithClientIdentity(ctx, runmeserver.ClientIdentity{Name: values[0], Serial: values[0]})
+	}
+	return ctx
+}
+
+func testClientIdentityUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withTestClientIdentity(ctx), req)
+}
+
+func testClientIdentityStream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStreamWithContext{ServerStream: ss, ctx: withTestClientIdentity(ss.Context())})
+}
+
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStreamWithContext) Context() context.Context { return s.ctx }
+
 type executeResult struct {
 	Err      error
 	ExitCode int
@@ -1204,7 +1355,9 @@ type executeResult struct {
 }
 
 func getExecuteResult(
-	stream runnerv2.RunnerService_ExecuteClient,
+	stream interface {
+		Recv() (*runnerv2.ExecuteResponse, error)
+	},
 	resultc chan<- executeResult,
 ) {
 	result := executeResult{
@@ -1237,3 +1390,14 @@ func getExecuteResult(
 
 	resultc <- result
 }
+
+func TestInputOwner(t *testing.T) {
+	assert.Equal(t, "", inputOwner(context.Background()))
+
+	ctx := runmeserver.ContextWithPeerCredentials(context.Background(), runmeserver.PeerCredentials{UID: 1000, GID: 100, PID: 42})
+	assert.Equal(t, "uid:1000", inputOwner(ctx))
+
+	// The client certificate takes precedence.
+	ctx = runmeserver.ContextWithClientIdentity(ctx, runmeserver.ClientIdentity{Serial: "ff"})
+	assert.Equal(t, "ff", inputOwner(ctx))
+}
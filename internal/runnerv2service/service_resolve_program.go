@@ -5,6 +5,7 @@ import (
 	"context"
 	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -13,15 +14,25 @@ import (
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 )
 
-func (r *runnerService) ResolveProgram(ctx context.Context, req *runnerv2.ResolveProgramRequest) (*runnerv2.ResolveProgramResponse, error) {
+func (r *runnerService) ResolveProgram(ctx context.Context, req *runnerv2.ResolveProgramRequest) (_ *runnerv2.ResolveProgramResponse, err error) {
 	r.logger.Info("running ResolveProgram in runnerService")
 
+	_, span := tracer.Start(
+		ctx,
+		"ResolveProgram",
+		trace.WithAttributes(
+			attrLanguageID.String(req.GetLanguageId()),
+			attrSessionID.String(req.GetSessionId()),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
 	// todo(sebastian): reenable once extension includes it in request
 	// if req.GetLanguageId() == "" {
 	// 	return nil, status.Error(codes.InvalidArgument, "language id is required")
 	// }
 
-	resolver, err := r.getProgramResolverFromReq(req)
+	resolver, err := r.getProgramResolverFromReq(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +90,7 @@ func (r *runnerService) ResolveProgram(ctx context.Context, req *runnerv2.Resolv
 	return response, nil
 }
 
-func (r *runnerService) getProgramResolverFromReq(req *runnerv2.ResolveProgramRequest) (*command.ProgramResolver, error) {
+func (r *runnerService) getProgramResolverFromReq(ctx context.Context, req *runnerv2.ResolveProgramRequest) (*command.ProgramResolver, error) {
 	// Add explicitly passed env as a source.
 	sources := []command.ProgramResolverSource{
 		command.ProgramResolverSourceFunc(req.Env),
@@ -102,7 +113,7 @@ func (r *runnerService) getProgramResolverFromReq(req *runnerv2.ResolveProgramRe
 	// todo(sebastian): bring back sensitive keys for owl store
 	// Add session env as a source and pass info about sensitive env vars.
 	sensitiveEnvKeys := []string{}
-	session, found, _ := r.getSessionFromRequest(req)
+	session, found, _ := r.getSessionFromRequest(ctx, req)
 	if found {
 		env := session.GetAllEnv()
 		sources = append(sources, command.ProgramResolverSourceFunc(env))
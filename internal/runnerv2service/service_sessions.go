@@ -46,7 +46,7 @@ func (r *runnerService) CreateSession(ctx context.Context, req *runnerv2.CreateS
 		return nil, err
 	}
 
-	err = r.sessions.Add(sess)
+	err = r.addSession(ctx, sess)
 	if err != nil {
 		return nil, err
 	}
@@ -58,10 +58,10 @@ func (r *runnerService) CreateSession(ctx context.Context, req *runnerv2.CreateS
 	}, nil
 }
 
-func (r *runnerService) GetSession(_ context.Context, req *runnerv2.GetSessionRequest) (*runnerv2.GetSessionResponse, error) {
+func (r *runnerService) GetSession(ctx context.Context, req *runnerv2.GetSessionRequest) (*runnerv2.GetSessionResponse, error) {
 	r.logger.Info("running GetSession in runnerService")
 
-	sess, ok := r.sessions.GetByID(req.Id)
+	sess, ok := r.sessionsFor(ctx).GetByID(req.Id)
 	if !ok {
 		return nil, status.Error(codes.NotFound, "session not found")
 	}
@@ -71,10 +71,10 @@ func (r *runnerService) GetSession(_ context.Context, req *runnerv2.GetSessionRe
 	}, nil
 }
 
-func (r *runnerService) ListSessions(_ context.Context, req *runnerv2.ListSessionsRequest) (*runnerv2.ListSessionsResponse, error) {
+func (r *runnerService) ListSessions(ctx context.Context, req *runnerv2.ListSessionsRequest) (*runnerv2.ListSessionsResponse, error) {
 	r.logger.Info("running ListSessions in runnerService")
 
-	sessions := r.sessions.List()
+	sessions := r.sessionsFor(ctx).List()
 
 	runnerSessions := make([]*runnerv2.Session, 0, len(sessions))
 	for _, s := range sessions {
@@ -87,7 +87,7 @@ func (r *runnerService) ListSessions(_ context.Context, req *runnerv2.ListSessio
 func (r *runnerService) UpdateSession(ctx context.Context, req *runnerv2.UpdateSessionRequest) (*runnerv2.UpdateSessionResponse, error) {
 	r.logger.Info("running UpdateSession in runnerService")
 
-	sess, ok := r.sessions.GetByID(req.Id)
+	sess, ok := r.sessionsFor(ctx).GetByID(req.Id)
 	if !ok {
 		return nil, status.Error(codes.NotFound, "session not found")
 	}
@@ -99,10 +99,10 @@ func (r *runnerService) UpdateSession(ctx context.Context, req *runnerv2.UpdateS
 	return &runnerv2.UpdateSessionResponse{Session: convertSessionToProtoSession(sess)}, nil
 }
 
-func (r *runnerService) DeleteSession(_ context.Context, req *runnerv2.DeleteSessionRequest) (*runnerv2.DeleteSessionResponse, error) {
+func (r *runnerService) DeleteSession(ctx context.Context, req *runnerv2.DeleteSessionRequest) (*runnerv2.DeleteSessionResponse, error) {
 	r.logger.Info("running DeleteSession in runnerService")
 
-	deleted := r.sessions.DeleteByID(req.Id)
+	deleted := r.sessionsFor(ctx).DeleteByID(req.Id)
 
 	if !deleted {
 		return nil, status.Error(codes.NotFound, "session not found")
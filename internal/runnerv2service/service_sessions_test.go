@@ -8,7 +8,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/stateful/runme/v3/internal/command"
+	"github.com/stateful/runme/v3/internal/server"
 	"github.com/stateful/runme/v3/internal/testutils"
 	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
 	"github.com/stateful/runme/v3/pkg/project/teststub"
@@ -169,3 +177,104 @@ func TestRunnerService_Sessions_ExecuteWithStrategyMostRecent(t *testing.T) {
 	_, err = client.DeleteSession(context.Background(), &runnerv2.DeleteSessionRequest{Id: sessResp.GetSession().GetId()})
 	require.NoError(t, err)
 }
+
+func TestRunnerService_Sessions_Namespaces(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := startNamespacedRunnerServiceServer(t, WithSessionQuota(2))
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	aliceCtx := metadata.AppendToOutgoingContext(context.Background(), server.NamespaceMetadataKey, "alice")
+	bobCtx := metadata.AppendToOutgoingContext(context.Background(), server.NamespaceMetadataKey, "bob")
+
+	sessResp, err := client.CreateSession(aliceCtx, &runnerv2.CreateSessionRequest{Env: []string{"TEST1=alice"}})
+	require.NoError(t, err)
+	aliceSessionID := sessResp.GetSession().GetId()
+
+	listResp, err := client.ListSessions(bobCtx, &runnerv2.ListSessionsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, listResp.Sessions)
+
+	_, err = client.GetSession(bobCtx, &runnerv2.GetSessionRequest{Id: aliceSessionID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteSession(bobCtx, &runnerv2.DeleteSessionRequest{Id: aliceSessionID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The most recent session of bob is a new one, without the env of alice.
+	stream, err := client.Execute(bobCtx)
+	require.NoError(t, err)
+
+	execResult := make(chan executeResult)
+	go getExecuteResult(stream, execResult)
+
+	err = stream.Send(&runnerv2.ExecuteRequest{
+		Config: &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source: &runnerv2.ProgramConfig_Commands{
+				Commands: &runnerv2.ProgramConfig_CommandList{
+					Items: []string{`echo "TEST1=$TEST1"`},
+				},
+			},
+		},
+		SessionStrategy: runnerv2.SessionStrategy_SESSION_STRATEGY_MOST_RECENT,
+	})
+	require.NoError(t, err)
+
+	result := <-execResult
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "TEST1=\n", string(result.Stdout))
+
+	listResp, err = client.ListSessions(aliceCtx, &runnerv2.ListSessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, listResp.Sessions, 1)
+	assert.Equal(t, aliceSessionID, listResp.Sessions[0].Id)
+}
+
+func TestRunnerService_Sessions_MaxSessions(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := startNamespacedRunnerServiceServer(t, WithMaxSessions(1))
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	aliceCtx := metadata.AppendToOutgoingContext(context.Background(), server.NamespaceMetadataKey, "alice")
+	bobCtx := metadata.AppendToOutgoingContext(context.Background(), server.NamespaceMetadataKey, "bob")
+
+	sessResp, err := client.CreateSession(aliceCtx, &runnerv2.CreateSessionRequest{})
+	require.NoError(t, err)
+
+	_, err = client.CreateSession(bobCtx, &runnerv2.CreateSessionRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = client.DeleteSession(aliceCtx, &runnerv2.DeleteSessionRequest{Id: sessResp.GetSession().GetId()})
+	require.NoError(t, err)
+
+	_, err = client.CreateSession(bobCtx, &runnerv2.CreateSessionRequest{})
+	require.NoError(t, err)
+}
+
+func startNamespacedRunnerServiceServer(t *testing.T, opts ...Option) (_ *bufconn.Listener, stop func()) {
+	t.Helper()
+
+	logger := zaptest.NewLogger(t)
+	factory := command.NewFactory(command.WithLogger(logger))
+
+	runnerService, err := NewRunnerService(factory, logger, opts...)
+	require.NoError(t, err)
+
+	unary, stream := server.NamespaceInterceptors(false)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary),
+		grpc.ChainStreamInterceptor(stream),
+	)
+	runnerv2.RegisterRunnerServiceServer(grpcServer, runnerService)
+
+	lis := bufconn.Listen(1 << 20) // 1 MB
+	go grpcServer.Serve(lis)
+
+	return lis, grpcServer.Stop
+}
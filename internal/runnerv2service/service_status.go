@@ -0,0 +1,48 @@
+package runnerv2service
+
+import (
+	"context"
+
+	"github.com/stateful/runme/v3/internal/session"
+	"github.com/stateful/runme/v3/internal/version"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func (r *runnerService) GetServerStatus(ctx context.Context, _ *runnerv2.GetServerStatusRequest) (*runnerv2.GetServerStatusResponse, error) {
+	r.logger.Info("running GetServerStatus in runnerService")
+
+	limits := r.limiter.limits
+	usage := r.limiter.usage(session.NamespaceFromContext(ctx))
+
+	sessions := r.sessions.Size()
+	for _, u := range r.usage {
+		sessions += u.Sessions()
+		usage.active += u.ActiveExecutions()
+	}
+
+	return &runnerv2.GetServerStatusResponse{
+		Limits: &runnerv2.ExecutionLimits{
+			MaxExecutions:           uint32(limits.MaxExecutions),
+			MaxExecutionsPerClient:  uint32(limits.MaxExecutionsPerClient),
+			MaxExecutionsPerSession: uint32(limits.MaxExecutionsPerSession),
+			MaxQueued:               uint32(limits.MaxQueued),
+			QueueTimeoutSeconds:     uint32(limits.QueueTimeout.Seconds()),
+		},
+		ActiveExecutions:       uint32(usage.active),
+		QueuedExecutions:       uint32(usage.queued),
+		ClientActiveExecutions: uint32(usage.clientActive),
+		ClientSessions:         uint32(r.sessionsFor(ctx).Size()),
+		Version:                version.BaseVersionInfo(),
+		Sessions:               uint32(sessions),
+	}, nil
+}
+
+// Sessions returns the number of sessions in all namespaces.
+func (r *runnerService) Sessions() int {
+	return r.sessions.Size()
+}
+
+// ActiveExecutions returns the number of running programs.
+func (r *runnerService) ActiveExecutions() int {
+	return r.limiter.usage("").active
+}
@@ -0,0 +1,127 @@
+//go:build !windows
+
+package runnerv2service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/stateful/runme/v3/internal/testutils"
+	"github.com/stateful/runme/v3/internal/version"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func TestRunnerServiceServerExecute_Limits(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := startRunnerServiceServer(
+		t,
+		WithExecutionLimits(ExecutionLimits{MaxExecutions: 1, MaxQueued: 1, QueueTimeout: time.Minute}),
+	)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	commandsConfig := func(commands ...string) *runnerv2.ProgramConfig {
+		return &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source: &runnerv2.ProgramConfig_Commands{
+				Commands: &runnerv2.ProgramConfig_CommandList{Items: commands},
+			},
+			Mode: runnerv2.CommandMode_COMMAND_MODE_INLINE,
+		}
+	}
+
+	// Occupy the only slot.
+	running, err := client.Execute(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, running.Send(&runnerv2.ExecuteRequest{Config: commandsConfig("sleep 30")}))
+	resp, err := running.Recv()
+	require.NoError(t, err)
+	require.NotNil(t, resp.Pid)
+
+	// The next execution waits in the queue.
+	queued, err := client.Execute(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, queued.Send(&runnerv2.ExecuteRequest{Config: commandsConfig("echo -n queued")}))
+	resp, err = queued.Recv()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, resp.GetQueue().GetPosition())
+	assert.Nil(t, resp.Pid)
+
+	// The queue is full.
+	sessResp, err := client.CreateSession(context.Background(), &runnerv2.CreateSessionRequest{})
+	require.NoError(t, err)
+	rejectedConfig := commandsConfig("true")
+	rejectedConfig.Env = []string{"REJECTED=1"}
+	rejected, err := client.ExecuteServerStream(context.Background(), &runnerv2.ExecuteRequest{
+		Config:    rejectedConfig,
+		SessionId: sessResp.GetSession().GetId(),
+	})
+	require.NoError(t, err)
+	_, err = rejected.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// The rejected execution did not modify the session.
+	getResp, err := client.GetSession(context.Background(), &runnerv2.GetSessionRequest{Id: sessResp.GetSession().GetId()})
+	require.NoError(t, err)
+	assert.NotContains(t, getResp.GetSession().GetEnv(), "REJECTED=1")
+
+	statusResp, err := client.GetServerStatus(context.Background(), &runnerv2.GetServerStatusRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, statusResp.GetLimits().GetMaxExecutions())
+	assert.EqualValues(t, 60, statusResp.GetLimits().GetQueueTimeoutSeconds())
+	assert.EqualValues(t, 1, statusResp.ActiveExecutions)
+	assert.EqualValues(t, 1, statusResp.QueuedExecutions)
+	assert.EqualValues(t, 1, statusResp.ClientActiveExecutions)
+	assert.Equal(t, version.BaseVersionInfo(), statusResp.Version)
+
+	queuedResult := make(chan executeResult)
+	go getExecuteResult(queued, queuedResult)
+
+	// Stopping the running program lets the queued one start.
+	runningResult := make(chan executeResult)
+	go getExecuteResult(running, runningResult)
+	require.NoError(t, running.Send(&runnerv2.ExecuteRequest{Stop: runnerv2.ExecuteStop_EXECUTE_STOP_INTERRUPT}))
+	<-runningResult
+
+	result := <-queuedResult
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "queued", string(result.Stdout))
+
+	statusResp, err = client.GetServerStatus(context.Background(), &runnerv2.GetServerStatusRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, statusResp.ActiveExecutions)
+	assert.EqualValues(t, 0, statusResp.QueuedExecutions)
+}
+
+type fakeUsageReporter struct {
+	sessions, activeExecutions int
+}
+
+func (u fakeUsageReporter) Sessions() int { return u.sessions }
+
+func (u fakeUsageReporter) ActiveExecutions() int { return u.activeExecutions }
+
+func TestRunnerServiceServerGetServerStatus_UsageReporter(t *testing.T) {
+	t.Parallel()
+
+	lis, stop := startRunnerServiceServer(t, WithUsageReporter(fakeUsageReporter{sessions: 2, activeExecutions: 3}))
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	_, err := client.CreateSession(context.Background(), &runnerv2.CreateSessionRequest{})
+	require.NoError(t, err)
+
+	statusResp, err := client.GetServerStatus(context.Background(), &runnerv2.GetServerStatusRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, statusResp.Sessions)
+	assert.EqualValues(t, 3, statusResp.ActiveExecutions)
+}
@@ -0,0 +1,126 @@
+package runnerv2service
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stateful/runme/v3/internal/session"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+const instrumentationName = "github.com/stateful/runme/v3/internal/runnerv2service"
+
+// tracer and the meter in [newServiceMetrics] come from the global providers.
+// They are no-op unless telemetry is enabled in the server config.
+var tracer = otel.Tracer(instrumentationName)
+
+const (
+	attrKnownID    = attribute.Key("runme.cell.known_id")
+	attrKnownName  = attribute.Key("runme.cell.known_name")
+	attrSessionID  = attribute.Key("runme.session.id")
+	attrProgram    = attribute.Key("runme.program.name")
+	attrLanguageID = attribute.Key("runme.program.language_id")
+	attrExitCode   = attribute.Key("runme.exit_code")
+	attrStream     = attribute.Key("runme.stream")
+)
+
+var (
+	stdoutAttrs = metric.WithAttributes(attrStream.String("stdout"))
+	stderrAttrs = metric.WithAttributes(attrStream.String("stderr"))
+)
+
+type serviceMetrics struct {
+	activeExecutions metric.Int64UpDownCounter
+	executions       metric.Int64Counter
+	outputBytes      metric.Int64Counter
+	sessionEvictions metric.Int64Counter
+}
+
+func newServiceMetrics() (*serviceMetrics, error) {
+	meter := otel.Meter(instrumentationName)
+
+	var (
+		m   serviceMetrics
+		err error
+	)
+
+	m.activeExecutions, err = meter.Int64UpDownCounter(
+		"runme.executions.active",
+		metric.WithDescription("Number of running programs."),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	m.executions, err = meter.Int64Counter(
+		"runme.executions",
+		metric.WithDescription("Number of finished programs by exit code."),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	m.outputBytes, err = meter.Int64Counter(
+		"runme.execution.output",
+		metric.WithUnit("By"),
+		metric.WithDescription("Bytes of stdout and stderr streamed to clients."),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	m.sessionEvictions, err = meter.Int64Counter(
+		"runme.sessions.evicted",
+		metric.WithDescription("Number of sessions evicted because the session limit was reached."),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &m, nil
+}
+
+// observeSessions reports the number of sessions in all namespaces.
+func (m *serviceMetrics) observeSessions(sessions *session.NamespacedList[*session.Session]) error {
+	_, err := otel.Meter(instrumentationName).Int64ObservableGauge(
+		"runme.sessions",
+		metric.WithDescription("Number of sessions."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(sessions.Size()))
+			return nil
+		}),
+	)
+	return errors.WithStack(err)
+}
+
+// meteredExecuteServer counts bytes of the output sent to the client.
+type meteredExecuteServer struct {
+	runnerv2.RunnerService_ExecuteServer
+	outputBytes metric.Int64Counter
+}
+
+func (s *meteredExecuteServer) Send(resp *runnerv2.ExecuteResponse) error {
+	ctx := s.Context()
+	if n := len(resp.StdoutData); n > 0 {
+		s.outputBytes.Add(ctx, int64(n), stdoutAttrs)
+	}
+	if n := len(resp.StderrData); n > 0 {
+		s.outputBytes.Add(ctx, int64(n), stderrAttrs)
+	}
+	return s.RunnerService_ExecuteServer.Send(resp)
+}
+
+// endSpan records err, if not nil, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+
+package runnerv2service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/stateful/runme/v3/internal/testutils"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+// TestRunnerServiceMetrics is not parallel because it replaces the global meter provider.
+func TestRunnerServiceMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prevMeterProvider := otel.GetMeterProvider()
+	otel.SetMeterProvider(meterProvider)
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prevMeterProvider)
+		_ = meterProvider.Shutdown(context.Background())
+	})
+
+	lis, stop := startRunnerServiceServer(t)
+	t.Cleanup(stop)
+
+	_, client := testutils.NewGRPCClientWithT(t, lis, runnerv2.NewRunnerServiceClient)
+
+	stream, err := client.Execute(context.Background())
+	require.NoError(t, err)
+
+	execResult := make(chan executeResult)
+	go getExecuteResult(stream, execResult)
+
+	err = stream.Send(&runnerv2.ExecuteRequest{
+		Config: &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source: &runnerv2.ProgramConfig_Commands{
+				Commands: &runnerv2.ProgramConfig_CommandList{
+					Items: []string{"echo -n hello", "exit 3"},
+				},
+			},
+			Mode: runnerv2.CommandMode_COMMAND_MODE_INLINE,
+		},
+	})
+	require.NoError(t, err)
+
+	result := <-execResult
+	// A non-zero exit code is also reported as an error.
+	require.EqualError(t, result.Err, "rpc error: code = Unknown desc = exit status 3")
+	assert.Equal(t, 3, result.ExitCode)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	executions := findSum(t, rm, "runme.executions")
+	require.Len(t, executions.DataPoints, 1)
+	assert.EqualValues(t, 1, executions.DataPoints[0].Value)
+	exitCode, ok := executions.DataPoints[0].Attributes.Value(attrExitCode)
+	require.True(t, ok)
+	assert.EqualValues(t, 3, exitCode.AsInt64())
+
+	active := findSum(t, rm, "runme.executions.active")
+	require.Len(t, active.DataPoints, 1)
+	assert.EqualValues(t, 0, active.DataPoints[0].Value)
+
+	output := findSum(t, rm, "runme.execution.output")
+	var stdoutBytes int64
+	for _, dp := range output.DataPoints {
+		if stream, _ := dp.Attributes.Value(attrStream); stream.AsString() == "stdout" {
+			stdoutBytes = dp.Value
+		}
+	}
+	assert.EqualValues(t, len("hello"), stdoutBytes)
+}
+
+func findSum(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Sum[int64] {
+	t.Helper()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				sum, ok := m.Data.(metricdata.Sum[int64])
+				require.True(t, ok, "metric %s is not an int64 sum", name)
+				return sum
+			}
+		}
+	}
+
+	t.Fatalf("metric %s not found", name)
+	return metricdata.Sum[int64]{}
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/stateful/runme/v3/internal/audit"
+	"github.com/stateful/runme/v3/internal/ulid"
+	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+// runnerServicePrefix matches methods of all versions of the runner service.
+const runnerServicePrefix = "/runme.runner."
+
+// auditedMethods maps names of methods of the runner service to types of audit records.
+var auditedMethods = map[string]audit.Type{
+	"Execute":             audit.TypeExecute,
+	"ExecuteServerStream": audit.TypeExecute,
+	"ResolveProgram":      audit.TypeResolveProgram,
+	"CreateSession":       audit.TypeSessionCreate,
+	"UpdateSession":       audit.TypeSessionUpdate,
+	"DeleteSession":       audit.TypeSessionDelete,
+}
+
+// auditInterceptor writes an audit record before and after each call
+// executing a program or changing a session. If the first record cannot
+// be written, the call is rejected.
+type auditInterceptor struct {
+	log    *audit.Log
+	logger *zap.Logger
+}
+
+func auditType(method string) (audit.Type, bool) {
+	if !strings.HasPrefix(method, runnerServicePrefix) {
+		return "", false
+	}
+	typ, ok := auditedMethods[path.Base(method)]
+	return typ, ok
+}
+
+func (i *auditInterceptor) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	typ, ok := auditType(info.FullMethod)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	rec := newAuditRecord(ctx, typ, info.FullMethod, req)
+	rec.CallID = ulid.GenerateID()
+	if err := i.start(rec); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	if r, ok := resp.(*runnerv2.CreateSessionResponse); ok {
+		rec.SessionID = r.GetSession().GetId()
+	}
+	i.finish(rec, err, nil, time.Since(start))
+
+	return resp, err
+}
+
+func (i *auditInterceptor) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	typ, ok := auditType(info.FullMethod)
+	if !ok {
+		return handler(srv, ss)
+	}
+
+	start := time.Now()
+	stream := &auditServerStream{
+		ServerStream: ss,
+		interceptor:  i,
+		rec:          newAuditRecord(ss.Context(), typ, info.FullMethod, nil),
+	}
+	stream.rec.CallID = ulid.GenerateID()
+	err := handler(srv, stream)
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	// Calls which ended before the initial request was received
+	// or without the started record are not recorded at all.
+	if stream.started {
+		i.finish(stream.rec, err, stream.exitCode, time.Since(start))
+	}
+
+	return err
+}
+
+// start writes the record of a call which is about to be handled.
+func (i *auditInterceptor) start(rec audit.Record) error {
+	rec.Phase = audit.PhaseStarted
+	// Without a record, the call must not happen.
+	if err := i.log.Append(rec); err != nil {
+		i.logger.Error("failed to write audit record", zap.Error(err), zap.String("method", rec.Method))
+		return status.Error(codes.Unavailable, "failed to write audit record")
+	}
+	return nil
+}
+
+// finish writes the record with the outcome of a call.
+func (i *auditInterceptor) finish(rec audit.Record, err error, exitCode *int, duration time.Duration) {
+	rec.Phase = audit.PhaseFinished
+	rec.Outcome = &audit.Outcome{
+		Code:       status.Code(err).String(),
+		ExitCode:   exitCode,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		rec.Outcome.Error = err.Error()
+	}
+
+	// The call already happened; failing it would not undo it.
+	if err := i.log.Append(rec); err != nil {
+		i.logger.Error("failed to write audit record", zap.Error(err), zap.String("method", rec.Method))
+	}
+}
+
+// auditServerStream writes the started record when the initial request
+// is received, which, in case of Execute, contains the program config.
+// It also captures the exit code of Execute.
+type auditServerStream struct {
+	grpc.ServerStream
+	interceptor *auditInterceptor
+
+	mu  sync.Mutex
+	rec audit.Record
+	// received is true after the initial request is received.
+	received bool
+	// started is true if the started record was written.
+	started  bool
+	exitCode *int
+}
+
+func (s *auditServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.received {
+		return nil
+	}
+	s.received = true
+
+	callID := s.rec.CallID
+	s.rec = newAuditRecord(s.Context(), s.rec.Type, s.rec.Method, m)
+	s.rec.CallID = callID
+	if err := s.interceptor.start(s.rec); err != nil {
+		return err
+	}
+	s.started = true
+	return nil
+}
+
+// executeResponse is implemented by ExecuteResponse
+// of both versions of the runner service.
+type executeResponse interface {
+	GetExitCode() *wrapperspb.UInt32Value
+}
+
+func (s *auditServerStream) SendMsg(m any) error {
+	if r, ok := m.(executeResponse); ok && r.GetExitCode() != nil {
+		code := int(r.GetExitCode().GetValue())
+		s.mu.Lock()
+		s.exitCode = &code
+		s.mu.Unlock()
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func newAuditRecord(ctx context.Context, typ audit.Type, method string, req any) audit.Record {
+	rec := audit.Record{Type: typ, Method: method}
+
+	if id, ok := ClientIdentityFromContext(ctx); ok {
+		rec.Actor.Client = id.Name
+		rec.Actor.ClientSerial = id.Serial
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		rec.Actor.Addr = p.Addr.String()
+	}
+	if creds, ok := PeerCredentialsFromContext(ctx); ok {
+		rec.Actor.Process = &audit.Process{UID: creds.UID, GID: creds.GID, PID: creds.PID}
+	}
+
+	switch r := req.(type) {
+	case *runnerv2.ExecuteRequest:
+		cfg := r.GetConfig()
+		rec.SessionID = r.GetSessionId()
+		rec.Project = r.GetProject().GetRoot()
+		rec.Document = r.GetDocumentPath()
+		rec.CellID = cfg.GetKnownId()
+		rec.CellName = cfg.GetKnownName()
+		rec.Program = &audit.Program{
+			Name:         cfg.GetProgramName(),
+			Args:         cfg.GetArguments(),
+			Cwd:          cfg.GetDirectory(),
+			Language:     cfg.GetLanguageId(),
+			Mode:         cfg.GetMode().String(),
+			Interactive:  cfg.GetInteractive(),
+			Background:   cfg.GetBackground(),
+			ScriptSHA256: audit.HashScript(programSource(cfg.GetScript(), cfg.GetCommands().GetItems())),
+		}
+		rec.EnvSet = envNames(cfg.GetEnv())
+	case *runnerv2.ResolveProgramRequest:
+		rec.SessionID = r.GetSessionId()
+		rec.Project = r.GetProject().GetRoot()
+		rec.Program = &audit.Program{
+			Language:     r.GetLanguageId(),
+			Mode:         r.GetMode().String(),
+			ScriptSHA256: audit.HashScript(programSource(r.GetScript(), r.GetCommands().GetLines())),
+		}
+	case *runnerv2.CreateSessionRequest:
+		rec.Project = r.GetProject().GetRoot()
+		rec.EnvSet = envNames(r.GetEnv())
+	case *runnerv2.UpdateSessionRequest:
+		rec.SessionID = r.GetId()
+		rec.Project = r.GetProject().GetRoot()
+		rec.EnvSet = envNames(r.GetEnv())
+	case *runnerv2.DeleteSessionRequest:
+		rec.SessionID = r.GetId()
+	case *runnerv1.ExecuteRequest:
+		rec.SessionID = r.GetSessionId()
+		rec.Project = r.GetProject().GetRoot()
+		rec.CellID = r.GetKnownId()
+		rec.CellName = r.GetKnownName()
+		rec.Program = &audit.Program{
+			Name:         r.GetProgramName(),
+			Args:         r.GetArguments(),
+			Cwd:          r.GetDirectory(),
+			Language:     r.GetLanguageId(),
+			Mode:         r.GetCommandMode().String(),
+			Interactive:  r.GetTty(),
+			Background:   r.GetBackground(),
+			ScriptSHA256: audit.HashScript(programSource(r.GetScript(), r.GetCommands())),
+		}
+		rec.EnvSet = envNames(r.GetEnvs())
+	case *runnerv1.ResolveProgramRequest:
+		rec.SessionID = r.GetSessionId()
+		rec.Project = r.GetProject().GetRoot()
+		rec.Program = &audit.Program{
+			Language:     r.GetLanguageId(),
+			Mode:         r.GetMode().String(),
+			ScriptSHA256: audit.HashScript(programSource(r.GetScript(), r.GetCommands().GetLines())),
+		}
+	case *runnerv1.CreateSessionRequest:
+		rec.Project = r.GetProject().GetRoot()
+		rec.EnvSet = envNames(r.GetEnvs())
+	case *runnerv1.DeleteSessionRequest:
+		rec.SessionID = r.GetId()
+	}
+
+	return rec
+}
+
+// programSource returns the script or commands separated by new lines.
+func programSource(script string, commands []string) string {
+	if script != "" {
+		return script
+	}
+	return strings.Join(commands, "\n")
+}
+
+// envNames returns names of env variables in the KEY=VALUE format.
+func envNames(env []string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/stateful/runme/v3/internal/audit"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func readAuditRecords(t *testing.T, path string) []audit.Record {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []audit.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line struct {
+			Record audit.Record `json:"record"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		records = append(records, line.Record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path)
+	require.NoError(t, err)
+
+	auditor := &auditInterceptor{log: log, logger: zap.NewNop()}
+	policy, _ := newTestPolicyInterceptor(t)
+
+	ctx := ContextWithClientIdentity(context.Background(), ClientIdentity{Name: "ci", Serial: "ff"})
+	ctx = ContextWithPeerCredentials(ctx, PeerCredentials{UID: 1000, GID: 100, PID: 42})
+
+	// CreateSession
+	_, err = auditor.Unary(
+		ctx,
+		&runnerv2.CreateSessionRequest{Env: []string{"TOKEN=secret"}, Project: &runnerv2.Project{Root: "/repo"}},
+		&grpc.UnaryServerInfo{FullMethod: "/runme.runner.v2.RunnerService/CreateSession"},
+		func(context.Context, any) (any, error) {
+			return &runnerv2.CreateSessionResponse{Session: &runnerv2.Session{Id: "sess-1"}}, nil
+		},
+	)
+	require.NoError(t, err)
+
+	// Execute, also chained with the policy which denies the second call.
+	execute := func(req *runnerv2.ExecuteRequest) error {
+		ss := &fakeServerStream{ctx: ctx, req: req}
+		info := &grpc.StreamServerInfo{FullMethod: "/runme.runner.v2.RunnerService/Execute"}
+		return auditor.Stream(nil, ss, info, func(srv any, ss grpc.ServerStream) error {
+			return policy.Stream(srv, ss, info, func(_ any, stream grpc.ServerStream) error {
+				if err := stream.RecvMsg(&runnerv2.ExecuteRequest{}); err != nil {
+					return err
+				}
+				return stream.SendMsg(&runnerv2.ExecuteResponse{ExitCode: wrapperspb.UInt32(3)})
+			})
+		})
+	}
+
+	require.NoError(t, execute(&runnerv2.ExecuteRequest{
+		SessionId:    "sess-1",
+		DocumentPath: "/repo/README.md",
+		Config: &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source:      &runnerv2.ProgramConfig_Script{Script: "echo hello"},
+			Env:         []string{"A=1"},
+			KnownId:     "01HF7B0KJPF469EG9ZVSTKBNQE",
+			KnownName:   "hello",
+		},
+	}))
+	require.Error(t, execute(&runnerv2.ExecuteRequest{Config: &runnerv2.ProgramConfig{ProgramName: "bash"}}))
+
+	// Ended before receiving the request; not recorded.
+	err = auditor.Stream(
+		nil,
+		&fakeServerStream{ctx: ctx},
+		&grpc.StreamServerInfo{FullMethod: "/runme.runner.v2.RunnerService/Execute"},
+		func(any, grpc.ServerStream) error { return status.Error(codes.Canceled, "canceled") },
+	)
+	require.Error(t, err)
+
+	// Not audited.
+	_, err = auditor.Unary(ctx, &runnerv2.ListSessionsRequest{}, &grpc.UnaryServerInfo{FullMethod: "/runme.runner.v2.RunnerService/ListSessions"}, func(context.Context, any) (any, error) { return nil, nil })
+	require.NoError(t, err)
+
+	require.NoError(t, log.Close())
+
+	records := readAuditRecords(t, path)
+	require.Len(t, records, 6)
+
+	// Each call is recorded when it starts and when it finishes.
+	for i := 0; i < len(records); i += 2 {
+		started, finished := records[i], records[i+1]
+		assert.Equal(t, audit.PhaseStarted, started.Phase)
+		assert.Nil(t, started.Outcome)
+		assert.Equal(t, audit.PhaseFinished, finished.Phase)
+		require.NotNil(t, finished.Outcome)
+		assert.NotEmpty(t, started.CallID)
+		assert.Equal(t, started.CallID, finished.CallID)
+	}
+	assert.NotEqual(t, records[0].CallID, records[2].CallID)
+
+	assert.Equal(t, audit.TypeSessionCreate, records[0].Type)
+	assert.Equal(t, []string{"TOKEN"}, records[0].EnvSet)
+	assert.Equal(t, audit.Actor{
+		Client:       "ci",
+		ClientSerial: "ff",
+		Process:      &audit.Process{UID: 1000, GID: 100, PID: 42},
+	}, records[0].Actor)
+	assert.Equal(t, "sess-1", records[1].SessionID)
+	assert.Equal(t, "OK", records[1].Outcome.Code)
+
+	for _, rec := range records[2:4] {
+		assert.Equal(t, audit.TypeExecute, rec.Type)
+		assert.Equal(t, "/repo/README.md", rec.Document)
+		assert.Equal(t, "01HF7B0KJPF469EG9ZVSTKBNQE", rec.CellID)
+		assert.Equal(t, audit.HashScript("echo hello"), rec.Program.ScriptSHA256)
+		assert.Equal(t, []string{"A"}, rec.EnvSet)
+	}
+	require.NotNil(t, records[3].Outcome.ExitCode)
+	assert.Equal(t, 3, *records[3].Outcome.ExitCode)
+
+	assert.Equal(t, "PermissionDenied", records[5].Outcome.Code)
+	assert.Contains(t, records[5].Outcome.Error, `denied by policy rule "known-tasks-only"`)
+	assert.Nil(t, records[5].Outcome.ExitCode)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	n, err := audit.Verify(f)
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+}
+
+func TestAuditInterceptor_AppendFailure(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	auditor := &auditInterceptor{log: log, logger: zap.NewNop()}
+
+	called := false
+	_, err = auditor.Unary(
+		context.Background(),
+		&runnerv2.CreateSessionRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/runme.runner.v2.RunnerService/CreateSession"},
+		func(context.Context, any) (any, error) {
+			called = true
+			return &runnerv2.CreateSessionResponse{}, nil
+		},
+	)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.False(t, called)
+
+	ss := &fakeServerStream{ctx: context.Background(), req: &runnerv2.ExecuteRequest{}}
+	info := &grpc.StreamServerInfo{FullMethod: "/runme.runner.v2.RunnerService/Execute"}
+	err = auditor.Stream(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(&runnerv2.ExecuteRequest{}); err != nil {
+			return err
+		}
+		called = true
+		return nil
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.False(t, called)
+}
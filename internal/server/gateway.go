@@ -0,0 +1,368 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/pkg/errors"
+	"github.com/rs/cors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/stateful/runme/v3/internal/config"
+)
+
+// gatewayPrefix is the path prefix of the HTTP/JSON gateway.
+const gatewayPrefix = "/api"
+
+// gatewayServices are services served by the gateway
+// and the paths of their methods relative to [gatewayPrefix].
+var gatewayServices = map[string]string{
+	"runme.parser.v1.ParserService":           "/parser/v1",
+	"runme.project.v1.ProjectService":         "/project/v1",
+	"runme.notebook.v1alpha1.NotebookService": "/notebook/v1alpha1",
+	"runme.runner.v2.RunnerService":           "/runner/v2",
+}
+
+// gatewayMethod is a method served by the gateway.
+type gatewayMethod struct {
+	path string
+	desc protoreflect.MethodDescriptor
+	// Only one of unary and stream is set.
+	unary  *grpc.MethodDesc
+	stream *grpc.StreamDesc
+	impl   any
+}
+
+// newGatewayHandler returns a handler serving methods of [gatewayServices]
+// as HTTP/JSON endpoints. Each method is called with POST and a JSON-encoded
+// request message. Unary methods respond with a JSON-encoded message.
+// Server-streaming methods respond with server-sent events, one message per event.
+// Client-streaming methods are not served; for example, instead of Execute,
+// clients use ExecuteServerStream and send input with SendInput.
+//
+// Browsers send cross-origin POST requests with some content types without
+// a CORS preflight. Hence, only requests with the JSON content type from
+// the same origin or an origin allowed by corsCfg are served.
+func newGatewayHandler(
+	services []recordedService,
+	maxMessageSize int,
+	corsCfg *config.ConfigServerWebCors,
+	interceptors interceptors,
+) (http.Handler, error) {
+	var methods []gatewayMethod
+
+	for _, svc := range services {
+		path, ok := gatewayServices[svc.desc.ServiceName]
+		if !ok {
+			continue
+		}
+
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(svc.desc.ServiceName))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find descriptor of service %s", svc.desc.ServiceName)
+		}
+		serviceDesc, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, errors.Errorf("%s is not a service", svc.desc.ServiceName)
+		}
+
+		for i := range svc.desc.Methods {
+			m := &svc.desc.Methods[i]
+			methods = append(methods, gatewayMethod{
+				path:  gatewayPrefix + path + "/" + m.MethodName,
+				desc:  serviceDesc.Methods().ByName(protoreflect.Name(m.MethodName)),
+				unary: m,
+				impl:  svc.impl,
+			})
+		}
+		for i := range svc.desc.Streams {
+			s := &svc.desc.Streams[i]
+			if s.ClientStreams {
+				continue
+			}
+			methods = append(methods, gatewayMethod{
+				path:   gatewayPrefix + path + "/" + s.StreamName,
+				desc:   serviceDesc.Methods().ByName(protoreflect.Name(s.StreamName)),
+				stream: s,
+				impl:   svc.impl,
+			})
+		}
+	}
+
+	openAPI, err := json.Marshal(newOpenAPIDocument(methods))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	unaryInterceptor := chainUnaryInterceptors(interceptors.unary)
+	streamInterceptor := chainStreamInterceptors(interceptors.stream)
+	originAllowed := newOriginChecker(corsCfg)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+gatewayPrefix+"/openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPI)
+	})
+
+	for _, m := range methods {
+		if m.desc == nil {
+			return nil, errors.Errorf("failed to find descriptor of method %s", m.path)
+		}
+
+		inputType, err := protoregistry.GlobalTypes.FindMessageByName(m.desc.Input().FullName())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find type of %s", m.desc.Input().FullName())
+		}
+
+		mux.HandleFunc("POST "+m.path, func(w http.ResponseWriter, r *http.Request) {
+			if !originAllowed(r) {
+				writeGatewayError(w, status.Errorf(codes.PermissionDenied, "origin %s is not allowed", r.Header.Get("Origin")))
+				return
+			}
+			if !isJSONRequest(r) {
+				writeGatewayErrorWithStatus(
+					w,
+					http.StatusUnsupportedMediaType,
+					status.Errorf(codes.InvalidArgument, "content type must be application/json"),
+				)
+				return
+			}
+
+			req := inputType.New().Interface()
+			if err := readGatewayRequest(w, r, maxMessageSize, req); err != nil {
+				writeGatewayError(w, err)
+				return
+			}
+
+			ctx := withRequestMetadata(r.Context(), r.Header)
+
+			if m.unary != nil {
+				dec := func(in any) error {
+					proto.Merge(in.(proto.Message), req)
+					return nil
+				}
+				resp, err := m.unary.Handler(m.impl, ctx, dec, unaryInterceptor)
+				if err != nil {
+					writeGatewayError(w, err)
+					return
+				}
+				writeGatewayResponse(w, resp.(proto.Message))
+				return
+			}
+
+			info := &grpc.StreamServerInfo{
+				FullMethod:     "/" + string(m.desc.Parent().FullName()) + "/" + string(m.desc.Name()),
+				IsServerStream: true,
+			}
+			stream := &gatewayServerStream{ctx: ctx, w: w, req: req}
+			err := streamInterceptor(m.impl, stream, info, m.stream.Handler)
+			stream.finish(err)
+		})
+	}
+
+	return mux, nil
+}
+
+// newOriginChecker returns a function reporting whether a request comes
+// from the same origin or from an origin allowed by cfg. Requests without
+// the Origin header are sent by clients other than browsers and are allowed.
+func newOriginChecker(cfg *config.ConfigServerWebCors) func(*http.Request) bool {
+	var c *cors.Cors
+	if cfg != nil && len(cfg.AllowedOrigins) > 0 {
+		c = cors.New(cors.Options{AllowedOrigins: cfg.AllowedOrigins})
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		if origin == scheme+"://"+r.Host {
+			return true
+		}
+
+		return c != nil && c.OriginAllowed(r)
+	}
+}
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func readGatewayRequest(w http.ResponseWriter, r *http.Request, maxMessageSize int, req proto.Message) error {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxMessageSize)))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to read request: %v", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+	}
+	return nil
+}
+
+func writeGatewayResponse(w http.ResponseWriter, resp proto.Message) {
+	data, err := protojson.Marshal(resp)
+	if err != nil {
+		writeGatewayError(w, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// gatewayError is the body of error responses and events.
+type gatewayError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newGatewayError(err error) ([]byte, int) {
+	st := status.Convert(err)
+	data, _ := json.Marshal(gatewayError{
+		Code:    connect.Code(st.Code()).String(),
+		Message: st.Message(),
+	})
+	return data, gatewayHTTPStatus(st.Code())
+}
+
+func writeGatewayError(w http.ResponseWriter, err error) {
+	writeGatewayErrorWithStatus(w, gatewayHTTPStatus(status.Code(err)), err)
+}
+
+// writeGatewayErrorWithStatus is like [writeGatewayError],
+// but overrides the HTTP status derived from the gRPC code.
+func writeGatewayErrorWithStatus(w http.ResponseWriter, httpStatus int, err error) {
+	data, _ := newGatewayError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	_, _ = w.Write(data)
+}
+
+// gatewayHTTPStatus maps gRPC codes to HTTP statuses like the Connect protocol.
+func gatewayHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// gatewayServerStream adapts an HTTP request to [grpc.ServerStream]
+// of a server-streaming method. Messages are sent as server-sent events.
+type gatewayServerStream struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	req      proto.Message
+	received bool
+	// started is set once the response status has been written.
+	started bool
+}
+
+var _ grpc.ServerStream = (*gatewayServerStream)(nil)
+
+func (s *gatewayServerStream) SetHeader(md metadata.MD) error {
+	if s.started {
+		return errors.New("headers already sent")
+	}
+	copyMetadata(s.w.Header(), md)
+	return nil
+}
+
+func (s *gatewayServerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+// SetTrailer does nothing because trailers are not used by the gateway.
+func (s *gatewayServerStream) SetTrailer(metadata.MD) {}
+
+func (s *gatewayServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *gatewayServerStream) SendMsg(m any) error {
+	data, err := protojson.Marshal(m.(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode response: %v", err)
+	}
+	return s.writeEvent("message", data)
+}
+
+func (s *gatewayServerStream) RecvMsg(m any) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func (s *gatewayServerStream) writeEvent(event string, data []byte) error {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(http.NewResponseController(s.w).Flush())
+}
+
+// finish reports the result of the call. The event stream ends with
+// an "end" event on success or an "error" event. Errors before the first
+// message are sent as error responses.
+func (s *gatewayServerStream) finish(err error) {
+	switch {
+	case err == nil:
+		_ = s.writeEvent("end", []byte("{}"))
+	case !s.started:
+		writeGatewayError(s.w, err)
+	default:
+		data, _ := newGatewayError(err)
+		_ = s.writeEvent("error", data)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postGateway(t *testing.T, addr, path, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post("http://"+addr+path, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+type event struct {
+	name string
+	data string
+}
+
+// readEvents reads server-sent events until the end of the stream.
+func readEvents(t *testing.T, r io.Reader) []event {
+	t.Helper()
+
+	var (
+		events  []event
+		current event
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = event{}
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestGateway(t *testing.T) {
+	addr := startTestWebServer(t)
+
+	t.Run("ServerStream", func(t *testing.T) {
+		resp := postGateway(t, addr, "/api/runner/v2/ExecuteServerStream", `{"config": {"arguments": ["echo", "hello"]}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		events := readEvents(t, resp.Body)
+		require.Len(t, events, 4)
+		for i, expected := range []string{`{"executionId":"exec-1"}`, `{"stdoutData":"ZWNobyBoZWxsbw=="}`, `{"exitCode":0}`} {
+			assert.Equal(t, "message", events[i].name)
+			assert.JSONEq(t, expected, events[i].data)
+		}
+		assert.Equal(t, "end", events[3].name)
+	})
+
+	t.Run("DeniedByPolicy", func(t *testing.T) {
+		resp := postGateway(t, addr, "/api/runner/v2/CreateSession", "")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		var body gatewayError
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "permission_denied", body.Code)
+		assert.Contains(t, body.Message, "sessions are disabled")
+	})
+
+	t.Run("Unimplemented", func(t *testing.T) {
+		resp := postGateway(t, addr, "/api/runner/v2/GetSession", `{"id": "1"}`)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		resp := postGateway(t, addr, "/api/runner/v2/GetSession", `{"unknown": true}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var body gatewayError
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "invalid_argument", body.Code)
+	})
+
+	t.Run("UnsupportedContentType", func(t *testing.T) {
+		// Browsers send such requests cross-origin without a preflight.
+		resp, err := http.Post("http://"+addr+"/api/runner/v2/ExecuteServerStream", "text/plain", strings.NewReader(`{"config": {"arguments": ["echo", "hello"]}}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+
+	t.Run("Origin", func(t *testing.T) {
+		for origin, expected := range map[string]int{
+			"http://" + addr:        http.StatusOK,
+			"http://localhost:3000": http.StatusOK,
+			"http://example.com":    http.StatusForbidden,
+		} {
+			req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/api/runner/v2/ExecuteServerStream", strings.NewReader(`{"config": {"arguments": ["echo", "hello"]}}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Origin", origin)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			assert.Equal(t, expected, resp.StatusCode, origin)
+		}
+	})
+
+	t.Run("OpenAPI", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/api/openapi.json")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var doc struct {
+			OpenAPI    string                                `json:"openapi"`
+			Paths      map[string]map[string]json.RawMessage `json:"paths"`
+			Components struct {
+				Schemas map[string]openAPISchema `json:"schemas"`
+			} `json:"components"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+		assert.Equal(t, "3.0.3", doc.OpenAPI)
+		assert.NotContains(t, doc.Paths, "/api/runner/v2/Execute")
+		assert.Contains(t, string(doc.Paths["/api/runner/v2/ExecuteServerStream"]["post"]), "text/event-stream")
+
+		request := doc.Components.Schemas["runme.runner.v2.ExecuteRequest"]
+		assert.Equal(t, "#/components/schemas/runme.runner.v2.ProgramConfig", request.Properties["config"].Ref)
+		assert.Equal(t, "integer", doc.Components.Schemas["google.protobuf.UInt32Value"].Type)
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIdentity identifies a client authenticated with a client certificate.
+type ClientIdentity struct {
+	// Name is the common name of the client certificate.
+	Name string
+	// Serial is the hex-encoded serial number of the client certificate.
+	Serial   string
+	NotAfter time.Time
+}
+
+type clientIdentityKey struct{}
+
+// ContextWithClientIdentity returns a copy of ctx with the client identity.
+func ContextWithClientIdentity(ctx context.Context, id ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, id)
+}
+
+// ClientIdentityFromContext returns the identity of the client making the request.
+// It is available only when the server uses mutual TLS.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return id, ok
+}
+
+func withPeerClientIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+	leaf := info.State.VerifiedChains[0][0]
+	return ContextWithClientIdentity(ctx, ClientIdentity{
+		Name:     leaf.Subject.CommonName,
+		Serial:   leaf.SerialNumber.Text(16),
+		NotAfter: leaf.NotAfter,
+	})
+}
+
+// UnaryClientIdentityInterceptor attaches [ClientIdentity] to the context of unary calls.
+func UnaryClientIdentityInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withPeerClientIdentity(ctx), req)
+}
+
+// StreamClientIdentityInterceptor attaches [ClientIdentity] to the context of streaming calls.
+func StreamClientIdentityInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStreamWithContext{ServerStream: ss, ctx: withPeerClientIdentity(ss.Context())})
+}
+
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStreamWithContext) Context() context.Context { return s.ctx }
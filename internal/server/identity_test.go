@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestUnaryClientIdentityInterceptor(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "ci"},
+		SerialNumber: big.NewInt(255),
+		NotAfter:     notAfter,
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}},
+		},
+	})
+
+	handler := func(ctx context.Context, _ any) (any, error) {
+		id, ok := ClientIdentityFromContext(ctx)
+		require.True(t, ok)
+		return id, nil
+	}
+
+	id, err := UnaryClientIdentityInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, ClientIdentity{Name: "ci", Serial: "ff", NotAfter: notAfter}, id)
+
+	_, err = UnaryClientIdentityInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+		_, ok := ClientIdentityFromContext(ctx)
+		assert.False(t, ok)
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
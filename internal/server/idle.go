@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// IdleMonitor reports when a server has had no calls in progress
+// for a period of time. It is used to shut down a daemon
+// which is no longer used.
+type IdleMonitor struct {
+	timeout time.Duration
+	busy    func() bool
+	idle    chan struct{}
+
+	mu sync.Mutex
+	// +checklocks:mu
+	active int
+	// +checklocks:mu
+	timer *time.Timer
+	// +checklocks:mu
+	closed bool
+}
+
+type IdleMonitorOption func(*IdleMonitor)
+
+// WithBusyFunc sets a function reporting whether the server is busy
+// without calls in progress, for example, running background processes.
+// The server does not become idle while it is busy.
+func WithBusyFunc(busy func() bool) IdleMonitorOption {
+	return func(m *IdleMonitor) {
+		m.busy = busy
+	}
+}
+
+// NewIdleMonitor returns a monitor which reports idleness
+// after timeout without calls. Calls must pass through
+// its interceptors.
+func NewIdleMonitor(timeout time.Duration, opts ...IdleMonitorOption) *IdleMonitor {
+	m := &IdleMonitor{
+		timeout: timeout,
+		idle:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.mu.Lock()
+	m.timer = time.AfterFunc(timeout, m.fire)
+	m.mu.Unlock()
+
+	return m
+}
+
+// Idle is closed when the server becomes idle.
+func (m *IdleMonitor) Idle() <-chan struct{} {
+	return m.idle
+}
+
+// Stop stops the monitor. Idle is never closed after Stop.
+func (m *IdleMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	m.timer.Stop()
+}
+
+func (m *IdleMonitor) fire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed || m.active > 0 {
+		return
+	}
+	if m.busy != nil && m.busy() {
+		m.timer.Reset(m.timeout)
+		return
+	}
+	m.closed = true
+	close(m.idle)
+}
+
+func (m *IdleMonitor) begin() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.active++
+	m.timer.Stop()
+}
+
+func (m *IdleMonitor) end() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.active--
+	if m.active == 0 && !m.closed {
+		m.timer.Reset(m.timeout)
+	}
+}
+
+func (m *IdleMonitor) Unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	m.begin()
+	defer m.end()
+	return handler(ctx, req)
+}
+
+func (m *IdleMonitor) Stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	m.begin()
+	defer m.end()
+	return handler(srv, ss)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestIdleMonitor(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	t.Run("Idle", func(t *testing.T) {
+		m := NewIdleMonitor(timeout)
+
+		select {
+		case <-m.Idle():
+		case <-time.After(time.Second):
+			t.Fatal("monitor did not report idleness")
+		}
+	})
+
+	t.Run("ActiveCall", func(t *testing.T) {
+		m := NewIdleMonitor(timeout)
+
+		_, err := m.Unary(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+			select {
+			case <-m.Idle():
+				t.Error("monitor reported idleness during a call")
+			case <-time.After(3 * timeout):
+			}
+			return nil, nil
+		})
+		require.NoError(t, err)
+
+		select {
+		case <-m.Idle():
+		case <-time.After(time.Second):
+			t.Fatal("monitor did not report idleness after the call")
+		}
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		var busy atomic.Bool
+		busy.Store(true)
+
+		m := NewIdleMonitor(timeout, WithBusyFunc(busy.Load))
+
+		select {
+		case <-m.Idle():
+			t.Fatal("monitor reported idleness while busy")
+		case <-time.After(3 * timeout):
+		}
+
+		busy.Store(false)
+
+		select {
+		case <-m.Idle():
+		case <-time.After(time.Second):
+			t.Fatal("monitor did not report idleness after it was busy")
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		m := NewIdleMonitor(timeout)
+		m.Stop()
+
+		select {
+		case <-m.Idle():
+			t.Fatal("stopped monitor reported idleness")
+		case <-time.After(3 * timeout):
+		}
+	})
+}
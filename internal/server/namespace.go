@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/stateful/runme/v3/internal/session"
+)
+
+// NamespaceMetadataKey is the metadata key with which clients choose
+// the namespace of their sessions when client authentication is off.
+const NamespaceMetadataKey = "runme-namespace"
+
+// namespaceInterceptor attaches the namespace of the caller to the context.
+// If clients are authenticated, the namespace is the name of the client
+// and cannot be chosen by the client. Otherwise, the namespace comes from
+// [NamespaceMetadataKey] and isolates well-behaved clients only.
+type namespaceInterceptor struct {
+	authenticated bool
+}
+
+// NamespaceInterceptors return interceptors attaching the namespace of the caller
+// to the context. Set authenticated if the server requires client certificates.
+// They must be chained after the client identity interceptors.
+func NamespaceInterceptors(authenticated bool) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	i := &namespaceInterceptor{authenticated: authenticated}
+	return i.Unary, i.Stream
+}
+
+func (i *namespaceInterceptor) namespace(ctx context.Context) string {
+	if i.authenticated {
+		if id, ok := ClientIdentityFromContext(ctx); ok {
+			return id.Name
+		}
+		return session.DefaultNamespace
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, NamespaceMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+
+	return session.DefaultNamespace
+}
+
+func (i *namespaceInterceptor) Unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(session.ContextWithNamespace(ctx, i.namespace(ctx)), req)
+}
+
+func (i *namespaceInterceptor) Stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+	return handler(srv, &serverStreamWithContext{ServerStream: ss, ctx: session.ContextWithNamespace(ctx, i.namespace(ctx))})
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/stateful/runme/v3/internal/session"
+)
+
+func TestNamespaceInterceptors(t *testing.T) {
+	withMetadata := metadata.NewIncomingContext(
+		context.Background(),
+		metadata.Pairs(NamespaceMetadataKey, "alice"),
+	)
+
+	namespace := func(t *testing.T, ctx context.Context, authenticated bool) string {
+		t.Helper()
+
+		unary, _ := NamespaceInterceptors(authenticated)
+		result, err := unary(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			return session.NamespaceFromContext(ctx), nil
+		})
+		require.NoError(t, err)
+		return result.(string)
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		assert.Equal(t, "alice", namespace(t, withMetadata, false))
+		assert.Equal(t, session.DefaultNamespace, namespace(t, context.Background(), false))
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		ctx := ContextWithClientIdentity(withMetadata, ClientIdentity{Name: "ci"})
+		// Authenticated clients can't choose the namespace.
+		assert.Equal(t, "ci", namespace(t, ctx, true))
+		assert.Equal(t, session.DefaultNamespace, namespace(t, withMetadata, true))
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/stateful/runme/v3/internal/version"
+)
+
+const openAPIRefPrefix = "#/components/schemas/"
+
+// openAPISchema is a subset of the OpenAPI 3.0 schema object.
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+}
+
+// newOpenAPIDocument returns an OpenAPI 3.0 document describing the gateway methods.
+// Schemas are derived from the message descriptors and follow the JSON mapping
+// of protocol buffers, in which, for example, 64-bit integers are strings.
+func newOpenAPIDocument(methods []gatewayMethod) map[string]any {
+	schemas := make(map[string]*openAPISchema)
+	paths := make(map[string]any, len(methods))
+
+	for _, m := range methods {
+		addOpenAPIMessage(schemas, m.desc.Input())
+		addOpenAPIMessage(schemas, m.desc.Output())
+
+		responseContent := map[string]any{
+			"application/json": map[string]any{"schema": openAPIRef(m.desc.Output())},
+		}
+		description := "Response message."
+		if m.desc.IsStreamingServer() {
+			responseContent = map[string]any{
+				"text/event-stream": map[string]any{"schema": openAPIRef(m.desc.Output())},
+			}
+			description = `Server-sent events. Each "message" event contains a response message. ` +
+				`The stream ends with an "end" event or an "error" event containing an error.`
+		}
+
+		service := m.desc.Parent().(protoreflect.ServiceDescriptor)
+
+		paths[m.path] = map[string]any{
+			"post": map[string]any{
+				"operationId": string(service.Name()) + "_" + string(m.desc.Name()),
+				"tags":        []string{string(service.FullName())},
+				"requestBody": map[string]any{
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": openAPIRef(m.desc.Input())},
+					},
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": description,
+						"content":     responseContent,
+					},
+					"default": map[string]any{
+						"description": "Error.",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": &openAPISchema{Ref: openAPIRefPrefix + "Error"}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	schemas["Error"] = &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"code":    {Type: "string", Description: `gRPC status code in snake case, for example, "not_found".`},
+			"message": {Type: "string"},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Runme API",
+			"version": version.BaseVersion(),
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func openAPIRef(desc protoreflect.MessageDescriptor) *openAPISchema {
+	return &openAPISchema{Ref: openAPIRefPrefix + string(desc.FullName())}
+}
+
+// addOpenAPIMessage adds schemas of the message and messages it references.
+func addOpenAPIMessage(schemas map[string]*openAPISchema, desc protoreflect.MessageDescriptor) {
+	name := string(desc.FullName())
+	if _, ok := schemas[name]; ok {
+		return
+	}
+
+	if schema, ok := openAPIWellKnownSchema(desc); ok {
+		schemas[name] = schema
+		return
+	}
+
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	// Set before adding fields to handle recursive messages.
+	schemas[name] = schema
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		schema.Properties[field.JSONName()] = openAPIFieldSchema(schemas, field)
+	}
+}
+
+func openAPIFieldSchema(schemas map[string]*openAPISchema, field protoreflect.FieldDescriptor) *openAPISchema {
+	if field.IsMap() {
+		return &openAPISchema{
+			Type:                 "object",
+			AdditionalProperties: openAPIValueSchema(schemas, field.MapValue()),
+		}
+	}
+	if field.IsList() {
+		return &openAPISchema{
+			Type:  "array",
+			Items: openAPIValueSchema(schemas, field),
+		}
+	}
+	return openAPIValueSchema(schemas, field)
+}
+
+func openAPIValueSchema(schemas map[string]*openAPISchema, field protoreflect.FieldDescriptor) *openAPISchema {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return &openAPISchema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &openAPISchema{Type: "integer", Format: "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &openAPISchema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &openAPISchema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case protoreflect.StringKind:
+		return &openAPISchema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &openAPISchema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		schema := &openAPISchema{Type: "string", Enum: make([]string, 0, values.Len())}
+		for i := 0; i < values.Len(); i++ {
+			schema.Enum = append(schema.Enum, string(values.Get(i).Name()))
+		}
+		return schema
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		addOpenAPIMessage(schemas, field.Message())
+		return openAPIRef(field.Message())
+	default:
+		return &openAPISchema{}
+	}
+}
+
+// openAPIWellKnownSchema returns schemas of well-known types
+// which have special JSON representations.
+func openAPIWellKnownSchema(desc protoreflect.MessageDescriptor) (*openAPISchema, bool) {
+	name := string(desc.FullName())
+	if !strings.HasPrefix(name, "google.protobuf.") {
+		return nil, false
+	}
+
+	switch strings.TrimPrefix(name, "google.protobuf.") {
+	case "BoolValue":
+		return &openAPISchema{Type: "boolean", Nullable: true}, true
+	case "Int32Value":
+		return &openAPISchema{Type: "integer", Format: "int32", Nullable: true}, true
+	case "UInt32Value":
+		return &openAPISchema{Type: "integer", Format: "uint32", Nullable: true}, true
+	case "Int64Value":
+		return &openAPISchema{Type: "string", Format: "int64", Nullable: true}, true
+	case "UInt64Value":
+		return &openAPISchema{Type: "string", Format: "uint64", Nullable: true}, true
+	case "FloatValue", "DoubleValue":
+		return &openAPISchema{Type: "number", Nullable: true}, true
+	case "StringValue":
+		return &openAPISchema{Type: "string", Nullable: true}, true
+	case "BytesValue":
+		return &openAPISchema{Type: "string", Format: "byte", Nullable: true}, true
+	case "Timestamp":
+		return &openAPISchema{Type: "string", Format: "date-time"}, true
+	case "Duration":
+		return &openAPISchema{Type: "string", Description: `Duration in seconds with the "s" suffix, for example, "1.5s".`}, true
+	case "FieldMask":
+		return &openAPISchema{Type: "string", Description: "Comma-separated field paths."}, true
+	case "Struct", "Empty", "Any":
+		return &openAPISchema{Type: "object"}, true
+	case "Value":
+		return &openAPISchema{}, true
+	case "ListValue":
+		return &openAPISchema{Type: "array", Items: &openAPISchema{}}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"net"
+	"slices"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	"github.com/stateful/runme/v3/internal/config"
+)
+
+// PeerCredentials are credentials of a process connected to a unix socket.
+type PeerCredentials struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+// PeerAddr is the address of a client connected to a unix socket.
+// It is available as [peer.Peer.Addr] of calls.
+type PeerAddr struct {
+	net.Addr
+	Credentials PeerCredentials
+}
+
+func (a *PeerAddr) Network() string { return "unix" }
+
+func (a *PeerAddr) String() string {
+	if a.Addr == nil {
+		return ""
+	}
+	return a.Addr.String()
+}
+
+type peerCredentialsKey struct{}
+
+// ContextWithPeerCredentials returns a copy of ctx with the peer credentials.
+func ContextWithPeerCredentials(ctx context.Context, creds PeerCredentials) context.Context {
+	return context.WithValue(ctx, peerCredentialsKey{}, creds)
+}
+
+// PeerCredentialsFromContext returns credentials of the client making the request.
+// They are available only when the server listens on a unix socket.
+func PeerCredentialsFromContext(ctx context.Context) (PeerCredentials, bool) {
+	creds, ok := ctx.Value(peerCredentialsKey{}).(PeerCredentials)
+	return creds, ok
+}
+
+func withPeerCredentials(ctx context.Context) context.Context {
+	if _, ok := PeerCredentialsFromContext(ctx); ok {
+		return ctx
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	addr, ok := p.Addr.(*PeerAddr)
+	if !ok {
+		return ctx
+	}
+	return ContextWithPeerCredentials(ctx, addr.Credentials)
+}
+
+// UnaryPeerCredentialsInterceptor attaches [PeerCredentials] to the context of unary calls.
+func UnaryPeerCredentialsInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withPeerCredentials(ctx), req)
+}
+
+// StreamPeerCredentialsInterceptor attaches [PeerCredentials] to the context of streaming calls.
+func StreamPeerCredentialsInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStreamWithContext{ServerStream: ss, ctx: withPeerCredentials(ss.Context())})
+}
+
+// peerCredentialsListener reads credentials of processes connecting
+// to a unix socket and closes connections of processes which are not allowed.
+type peerCredentialsListener struct {
+	net.Listener
+	allowedUIDs []uint32
+	allowedGIDs []uint32
+	logger      *zap.Logger
+}
+
+func newPeerCredentialsListener(lis net.Listener, cfg *config.ConfigServerPeerCredentials, logger *zap.Logger) (net.Listener, error) {
+	restricted := peerCredentialsRestricted(cfg)
+
+	if !peerCredentialsSupported {
+		if restricted {
+			return nil, errors.New("peer credentials are not supported on this platform")
+		}
+		return lis, nil
+	}
+
+	l := &peerCredentialsListener{
+		Listener: lis,
+		logger:   logger.Named("PeerCredentials"),
+	}
+	if restricted {
+		for _, uid := range cfg.AllowedUids {
+			l.allowedUIDs = append(l.allowedUIDs, uint32(uid))
+		}
+		for _, gid := range cfg.AllowedGids {
+			l.allowedGIDs = append(l.allowedGIDs, uint32(gid))
+		}
+	}
+	return l, nil
+}
+
+// peerCredentialsRestricted returns true if only some users or groups are allowed.
+func peerCredentialsRestricted(cfg *config.ConfigServerPeerCredentials) bool {
+	return cfg != nil && (len(cfg.AllowedUids) > 0 || len(cfg.AllowedGids) > 0)
+}
+
+func (l *peerCredentialsListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		creds, err := readPeerCredentials(conn)
+		if err != nil {
+			l.logger.Warn("connection rejected; failed to read peer credentials", zap.Error(err))
+			_ = conn.Close()
+			continue
+		}
+
+		if !l.allowed(creds) {
+			l.logger.Warn(
+				"connection rejected",
+				zap.Uint32("uid", creds.UID),
+				zap.Uint32("gid", creds.GID),
+				zap.Int32("pid", creds.PID),
+			)
+			_ = conn.Close()
+			continue
+		}
+
+		return &peerCredentialsConn{
+			Conn: conn,
+			addr: &PeerAddr{Addr: conn.RemoteAddr(), Credentials: creds},
+		}, nil
+	}
+}
+
+func (l *peerCredentialsListener) allowed(creds PeerCredentials) bool {
+	if len(l.allowedUIDs) == 0 && len(l.allowedGIDs) == 0 {
+		return true
+	}
+	return slices.Contains(l.allowedUIDs, creds.UID) || slices.Contains(l.allowedGIDs, creds.GID)
+}
+
+type peerCredentialsConn struct {
+	net.Conn
+	addr *PeerAddr
+}
+
+func (c *peerCredentialsConn) RemoteAddr() net.Addr { return c.addr }
+
+// readPeerCredentials reads credentials of the process connected to a unix socket.
+func readPeerCredentials(conn net.Conn) (PeerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return PeerCredentials{}, errors.Errorf("unexpected connection type %T", conn)
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, errors.WithStack(err)
+	}
+
+	var (
+		creds   PeerCredentials
+		credErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		creds, credErr = getsockoptPeerCredentials(int(fd))
+	})
+	if err != nil {
+		return PeerCredentials{}, errors.WithStack(err)
+	}
+	return creds, errors.WithStack(credErr)
+}
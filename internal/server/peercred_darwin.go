@@ -0,0 +1,23 @@
+package server
+
+import "golang.org/x/sys/unix"
+
+const peerCredentialsSupported = true
+
+func getsockoptPeerCredentials(fd int) (PeerCredentials, error) {
+	xucred, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	pid, err := unix.GetsockoptInt(fd, unix.SOL_LOCAL, unix.LOCAL_PEERPID)
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	creds := PeerCredentials{UID: xucred.Uid, PID: int32(pid)}
+	// The first group is the effective group.
+	if xucred.Ngroups > 0 {
+		creds.GID = xucred.Groups[0]
+	}
+	return creds, nil
+}
@@ -0,0 +1,13 @@
+package server
+
+import "golang.org/x/sys/unix"
+
+const peerCredentialsSupported = true
+
+func getsockoptPeerCredentials(fd int) (PeerCredentials, error) {
+	ucred, err := unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	return PeerCredentials{UID: ucred.Uid, GID: ucred.Gid, PID: ucred.Pid}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package server
+
+import "github.com/pkg/errors"
+
+const peerCredentialsSupported = false
+
+func getsockoptPeerCredentials(int) (PeerCredentials, error) {
+	return PeerCredentials{}, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,128 @@
+//go:build linux || darwin
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/stateful/runme/v3/internal/config"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+// peerRunnerService reports peer credentials of the caller as the version.
+type peerRunnerService struct {
+	runnerv2.UnimplementedRunnerServiceServer
+}
+
+func (peerRunnerService) GetServerStatus(ctx context.Context, _ *runnerv2.GetServerStatusRequest) (*runnerv2.GetServerStatusResponse, error) {
+	creds, ok := PeerCredentialsFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "no peer credentials")
+	}
+	return &runnerv2.GetServerStatusResponse{
+		Version: fmt.Sprintf("%d:%d:%d", creds.UID, creds.GID, creds.PID),
+	}, nil
+}
+
+func startPeerCredentialsServer(t *testing.T, peerCredentials string) runnerv2.RunnerServiceClient {
+	t.Helper()
+
+	// Unix socket paths are limited to about 100 characters.
+	dir, err := os.MkdirTemp("", "runme-peercred")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	addr := "unix://" + filepath.Join(dir, "runme.sock")
+
+	cfg, err := config.ParseYAML([]byte(fmt.Sprintf(`version: v1alpha1
+server:
+  address: %s
+%s`, addr, peerCredentials)))
+	require.NoError(t, err)
+
+	s, err := New(cfg, zap.NewNop(), func(sr grpc.ServiceRegistrar) {
+		runnerv2.RegisterRunnerServiceServer(sr, peerRunnerService{})
+	})
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve() }()
+	t.Cleanup(func() {
+		s.Shutdown()
+		assert.NoError(t, <-errc)
+	})
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return runnerv2.NewRunnerServiceClient(conn)
+}
+
+func TestServer_PeerCredentials(t *testing.T) {
+	self := fmt.Sprintf("%d:%d:%d", os.Getuid(), os.Getgid(), os.Getpid())
+
+	getStatus := func(t *testing.T, client runnerv2.RunnerServiceClient) (*runnerv2.GetServerStatusResponse, error) {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return client.GetServerStatus(ctx, &runnerv2.GetServerStatusRequest{}, grpc.WaitForReady(true))
+	}
+
+	t.Run("Unrestricted", func(t *testing.T) {
+		client := startPeerCredentialsServer(t, "")
+
+		resp, err := getStatus(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, self, resp.Version)
+	})
+
+	t.Run("AllowedUID", func(t *testing.T) {
+		client := startPeerCredentialsServer(t, fmt.Sprintf("  peer_credentials:\n    allowed_uids: [%d]\n", os.Getuid()))
+
+		resp, err := getStatus(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, self, resp.Version)
+	})
+
+	t.Run("AllowedGID", func(t *testing.T) {
+		client := startPeerCredentialsServer(t, fmt.Sprintf("  peer_credentials:\n    allowed_uids: [%d]\n    allowed_gids: [%d]\n", os.Getuid()+1, os.Getgid()))
+
+		resp, err := getStatus(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, self, resp.Version)
+	})
+
+	t.Run("Web", func(t *testing.T) {
+		// gRPC calls are served over HTTP.
+		client := startPeerCredentialsServer(t, "  web:\n    enabled: true\n")
+
+		resp, err := getStatus(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, self, resp.Version)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		client := startPeerCredentialsServer(t, fmt.Sprintf("  peer_credentials:\n    allowed_uids: [%d]\n", os.Getuid()+1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.GetServerStatus(ctx, &runnerv2.GetServerStatusRequest{})
+		require.Error(t, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
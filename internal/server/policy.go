@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/stateful/runme/v3/internal/config"
+	projectv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/project/v1"
+	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+// healthServicePrefix matches methods of the health service which
+// are not subject to the authorization policy, so that clients can
+// wait for the server to be ready even if the policy denies by default.
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// policyInterceptor authorizes calls to all services except health.
+type policyInterceptor struct {
+	policy *config.Policy
+	docker bool
+	logger *zap.Logger
+}
+
+func newPolicyInterceptor(cfg *config.Config, logger *zap.Logger) (*policyInterceptor, error) {
+	if cfg.Server == nil || cfg.Server.Policy == nil {
+		return nil, nil
+	}
+
+	policy, err := config.NewPolicy(cfg.Server.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	docker := cfg.Runtime != nil && cfg.Runtime.Docker != nil && cfg.Runtime.Docker.Enabled
+
+	return &policyInterceptor{
+		policy: policy,
+		docker: docker,
+		logger: logger.Named("Policy"),
+	}, nil
+}
+
+func (i *policyInterceptor) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(ctx, req)
+	}
+	if err := i.authorize(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *policyInterceptor) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(srv, ss)
+	}
+	return handler(srv, &policyServerStream{ServerStream: ss, interceptor: i, method: info.FullMethod})
+}
+
+// authorize evaluates the policy for the call. It returns
+// a PermissionDenied error if the call is not allowed.
+func (i *policyInterceptor) authorize(ctx context.Context, method string, req any) error {
+	env := newPolicyEnv(ctx, method, req)
+	env.Docker = i.docker
+
+	decision, err := i.policy.Evaluate(env)
+
+	fields := []zap.Field{
+		zap.String("method", env.Method),
+		zap.String("service", env.Service),
+		zap.String("client", env.Client),
+		zap.String("clientSerial", env.ClientSerial),
+		zap.String("sessionID", env.SessionID),
+		zap.String("document", env.Document),
+		zap.String("remoteSource", env.RemoteSource),
+		zap.String("program", env.Program),
+		zap.Strings("args", env.Args),
+		zap.String("knownID", env.KnownID),
+		zap.String("rule", decision.Rule),
+	}
+
+	switch {
+	case err != nil:
+		// Fail closed; a broken rule should not allow anything.
+		i.logger.Error("call denied; failed to evaluate policy", append(fields, zap.Error(err))...)
+		return status.Errorf(codes.PermissionDenied, "failed to evaluate policy: %v", err)
+	case decision.Allowed:
+		return nil
+	case decision.Rule == "":
+		i.logger.Warn("call denied by default policy", fields...)
+		return status.Error(codes.PermissionDenied, "denied by default policy")
+	case decision.Message != "":
+		i.logger.Warn("call denied by policy rule", fields...)
+		return status.Errorf(codes.PermissionDenied, "denied by policy rule %q: %s", decision.Rule, decision.Message)
+	default:
+		i.logger.Warn("call denied by policy rule", fields...)
+		return status.Errorf(codes.PermissionDenied, "denied by policy rule %q", decision.Rule)
+	}
+}
+
+// policyServerStream authorizes a streaming call on the first received message,
+// which, in case of Execute, contains the program config.
+type policyServerStream struct {
+	grpc.ServerStream
+	interceptor *policyInterceptor
+	method      string
+
+	once sync.Once
+	err  error
+}
+
+func (s *policyServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.once.Do(func() {
+		s.err = s.interceptor.authorize(s.Context(), s.method, m)
+	})
+	return s.err
+}
+
+func newPolicyEnv(ctx context.Context, method string, req any) config.PolicyEnv {
+	env := config.PolicyEnv{Method: method, UID: -1, GID: -1, PID: -1}
+	env.Service, _, _ = strings.Cut(strings.TrimPrefix(method, "/"), "/")
+
+	if id, ok := ClientIdentityFromContext(ctx); ok {
+		env.Client = id.Name
+		env.ClientSerial = id.Serial
+	}
+	if creds, ok := PeerCredentialsFromContext(ctx); ok {
+		env.UID = int64(creds.UID)
+		env.GID = int64(creds.GID)
+		env.PID = int64(creds.PID)
+	}
+
+	if r, ok := req.(interface{ GetSessionId() string }); ok {
+		env.SessionID = r.GetSessionId()
+	}
+
+	switch r := req.(type) {
+	case *projectv1.LoadRequest:
+		env.ProjectRoot = projectPath(r)
+		env.RemoteSource = r.GetRemote().GetSource()
+	case *projectv1.WatchRequest:
+		env.ProjectRoot = projectPath(r)
+	case *runnerv2.CreateSessionRequest:
+		env.ProjectRoot = r.GetProject().GetRoot()
+	case *runnerv2.ExecuteRequest:
+		env.ProjectRoot = r.GetProject().GetRoot()
+		env.Document = r.GetDocumentPath()
+
+		cfg := r.GetConfig()
+		env.Program = cfg.GetProgramName()
+		env.Args = cfg.GetArguments()
+		env.Cwd = cfg.GetDirectory()
+		env.Language = cfg.GetLanguageId()
+		env.Interactive = cfg.GetInteractive()
+		env.Background = cfg.GetBackground()
+		env.KnownID = cfg.GetKnownId()
+		env.KnownName = cfg.GetKnownName()
+
+		env.Source = programSource(cfg.GetScript(), cfg.GetCommands().GetItems())
+	case *runnerv1.CreateSessionRequest:
+		env.ProjectRoot = r.GetProject().GetRoot()
+	case *runnerv1.ExecuteRequest:
+		env.ProjectRoot = r.GetProject().GetRoot()
+		env.Program = r.GetProgramName()
+		env.Args = r.GetArguments()
+		env.Cwd = r.GetDirectory()
+		env.Language = r.GetLanguageId()
+		env.Interactive = r.GetTty()
+		env.Background = r.GetBackground()
+		env.KnownID = r.GetKnownId()
+		env.KnownName = r.GetKnownName()
+
+		env.Source = programSource(r.GetScript(), r.GetCommands())
+	}
+
+	return env
+}
+
+// projectPath returns the path of a directory or file project.
+func projectPath(req interface {
+	GetDirectory() *projectv1.DirectoryProjectOptions
+	GetFile() *projectv1.FileProjectOptions
+},
+) string {
+	if dir := req.GetDirectory(); dir != nil {
+		return dir.GetPath()
+	}
+	return req.GetFile().GetPath()
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stateful/runme/v3/internal/config"
+	parserv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/parser/v1"
+	projectv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/project/v1"
+	runnerv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v1"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+func newTestPolicyInterceptor(t *testing.T) (*policyInterceptor, *observer.ObservedLogs) {
+	t.Helper()
+
+	cfg, err := config.ParseYAML([]byte(`version: v1alpha1
+server:
+  address: localhost:9999
+  policy:
+    rules:
+      - name: deny-network-tools
+        effect: deny
+        condition: source matches "\\bcurl\\b"
+        message: network tools are not allowed
+      - name: known-tasks-only
+        effect: deny
+        condition: method endsWith "/Execute" && known_id == ""
+`))
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor, err := newPolicyInterceptor(cfg, zap.New(core))
+	require.NoError(t, err)
+	require.NotNil(t, interceptor)
+
+	return interceptor, logs
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	req proto.Message
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func (s *fakeServerStream) SendMsg(any) error { return nil }
+
+func (s *fakeServerStream) RecvMsg(m any) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestPolicyInterceptorStream(t *testing.T) {
+	interceptor, logs := newTestPolicyInterceptor(t)
+
+	execute := func(req *runnerv2.ExecuteRequest) error {
+		ctx := ContextWithClientIdentity(context.Background(), ClientIdentity{Name: "ci"})
+		ss := &fakeServerStream{ctx: ctx, req: req}
+		info := &grpc.StreamServerInfo{FullMethod: "/runme.runner.v2.RunnerService/Execute"}
+		return interceptor.Stream(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+			return stream.RecvMsg(&runnerv2.ExecuteRequest{})
+		})
+	}
+
+	err := execute(&runnerv2.ExecuteRequest{
+		Config: &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source:      &runnerv2.ProgramConfig_Script{Script: "echo hello"},
+			KnownId:     "01HF7B0KJPF469EG9ZVSTKBNQE",
+		},
+	})
+	require.NoError(t, err)
+
+	err = execute(&runnerv2.ExecuteRequest{
+		Config: &runnerv2.ProgramConfig{
+			ProgramName: "bash",
+			Source:      &runnerv2.ProgramConfig_Commands{Commands: &runnerv2.ProgramConfig_CommandList{Items: []string{"curl example.com"}}},
+			KnownId:     "01HF7B0KJPF469EG9ZVSTKBNQE",
+		},
+		DocumentPath: "/repo/README.md",
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.EqualError(t, err, `rpc error: code = PermissionDenied desc = denied by policy rule "deny-network-tools": network tools are not allowed`)
+
+	err = execute(&runnerv2.ExecuteRequest{Config: &runnerv2.ProgramConfig{ProgramName: "bash"}})
+	assert.EqualError(t, err, `rpc error: code = PermissionDenied desc = denied by policy rule "known-tasks-only"`)
+
+	entries := logs.FilterMessage("call denied by policy rule").All()
+	require.Len(t, entries, 2)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "ci", fields["client"])
+	assert.Equal(t, "/repo/README.md", fields["document"])
+	assert.Equal(t, "deny-network-tools", fields["rule"])
+}
+
+func TestPolicyInterceptorStream_RunnerV1(t *testing.T) {
+	interceptor, _ := newTestPolicyInterceptor(t)
+
+	ss := &fakeServerStream{
+		ctx: context.Background(),
+		req: &runnerv1.ExecuteRequest{
+			ProgramName: "bash",
+			Commands:    []string{"curl example.com"},
+			KnownId:     "01HF7B0KJPF469EG9ZVSTKBNQE",
+		},
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/runme.runner.v1.RunnerService/Execute"}
+	err := interceptor.Stream(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+		return stream.RecvMsg(&runnerv1.ExecuteRequest{})
+	})
+	assert.EqualError(t, err, `rpc error: code = PermissionDenied desc = denied by policy rule "deny-network-tools": network tools are not allowed`)
+}
+
+func TestPolicyInterceptorUnary(t *testing.T) {
+	interceptor, _ := newTestPolicyInterceptor(t)
+
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	resp, err := interceptor.Unary(
+		context.Background(),
+		&runnerv2.CreateSessionRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/runme.runner.v2.RunnerService/CreateSession"},
+		handler,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestPolicyInterceptor_OtherServices(t *testing.T) {
+	cfg, err := config.ParseYAML([]byte(`version: v1alpha1
+server:
+  address: localhost:9999
+  policy:
+    default: deny
+    rules:
+      - name: no-remote-projects
+        effect: deny
+        condition: service == "runme.project.v1.ProjectService" && remote_source != ""
+      - name: projects-in-repo
+        effect: allow
+        condition: method endsWith "/Load" && project_root startsWith "/repo"
+`))
+	require.NoError(t, err)
+
+	interceptor, err := newPolicyInterceptor(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	load := func(req *projectv1.LoadRequest) error {
+		ss := &fakeServerStream{ctx: context.Background(), req: req}
+		info := &grpc.StreamServerInfo{FullMethod: "/runme.project.v1.ProjectService/Load"}
+		return interceptor.Stream(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+			return stream.RecvMsg(&projectv1.LoadRequest{})
+		})
+	}
+
+	err = load(&projectv1.LoadRequest{
+		Kind: &projectv1.LoadRequest_Directory{Directory: &projectv1.DirectoryProjectOptions{Path: "/repo"}},
+	})
+	require.NoError(t, err)
+
+	err = load(&projectv1.LoadRequest{
+		Kind: &projectv1.LoadRequest_Remote{Remote: &projectv1.RemoteProjectOptions{Source: "git+https://example.com/repo.git"}},
+	})
+	assert.EqualError(t, err, `rpc error: code = PermissionDenied desc = denied by policy rule "no-remote-projects"`)
+
+	_, err = interceptor.Unary(
+		context.Background(),
+		&parserv1.DeserializeRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/runme.parser.v1.ParserService/Deserialize"},
+		func(context.Context, any) (any, error) { return "ok", nil },
+	)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = denied by default policy")
+
+	// Health checks are always allowed.
+	resp, err := interceptor.Unary(
+		context.Background(),
+		&grpc_health_v1.HealthCheckRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(context.Context, any) (any, error) { return "ok", nil },
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestNewPolicyEnv_PeerCredentials(t *testing.T) {
+	method := "/runme.runner.v2.RunnerService/CreateSession"
+
+	env := newPolicyEnv(context.Background(), method, &runnerv2.CreateSessionRequest{})
+	assert.Equal(t, int64(-1), env.UID)
+	assert.Equal(t, int64(-1), env.GID)
+	assert.Equal(t, int64(-1), env.PID)
+
+	ctx := ContextWithPeerCredentials(context.Background(), PeerCredentials{UID: 0, GID: 20, PID: 1234})
+	env = newPolicyEnv(ctx, method, &runnerv2.CreateSessionRequest{})
+	assert.Equal(t, int64(0), env.UID)
+	assert.Equal(t, int64(20), env.GID)
+	assert.Equal(t, int64(1234), env.PID)
+}
+
+func TestNewPolicyInterceptorWithoutPolicy(t *testing.T) {
+	interceptor, err := newPolicyInterceptor(config.Default(), zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, interceptor)
+}
@@ -1,28 +1,49 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/stateful/runme/v3/internal/audit"
 	"github.com/stateful/runme/v3/internal/config"
+	"github.com/stateful/runme/v3/internal/telemetry"
 	runmetls "github.com/stateful/runme/v3/internal/tls"
 )
 
+// webShutdownTimeout is how long to wait for web requests to finish
+// on shutdown. Streams, like MonitorEnvStore, might never finish.
+const webShutdownTimeout = 5 * time.Second
+
+// telemetryShutdownTimeout is how long to wait for pending spans to be exported.
+const telemetryShutdownTimeout = 5 * time.Second
+
 type Server struct {
-	cfg    *config.Config
-	gs     *grpc.Server
-	lis    net.Listener
-	logger *zap.Logger
+	cfg       *config.Config
+	gs        *grpc.Server
+	lis       net.Listener
+	tlsCfg    *tls.Config
+	auditLog  *audit.Log
+	telemetry *telemetry.Telemetry
+	logger    *zap.Logger
+
+	// web, if not nil, serves gRPC, Connect, and gRPC-Web requests.
+	web *http.Server
 }
 
 type ServiceRegistrar func(grpc.ServiceRegistrar)
@@ -31,32 +52,75 @@ func New(
 	cfg *config.Config,
 	logger *zap.Logger,
 	registrar ServiceRegistrar,
-) (*Server, error) {
+) (_ *Server, err error) {
 	logger = logger.Named("Server")
 
+	// Peer credentials are not available for TCP connections. Ignoring
+	// the restriction would let anyone in.
+	if peerCredentialsRestricted(cfg.Server.PeerCredentials) && !strings.HasPrefix(cfg.Server.Address, "unix://") {
+		return nil, errors.New("peer credentials require a unix socket address")
+	}
+
 	tlsCfg, err := createTLSConfig(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	grpcServer := createGRPCServer(cfg, tlsCfg)
+	auditLog, err := createAuditLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && auditLog != nil {
+			_ = auditLog.Close()
+		}
+	}()
+
+	tel, err := createTelemetry(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && tel != nil {
+			_ = tel.Shutdown(context.Background())
+		}
+	}()
+
+	interceptors, err := createInterceptors(cfg, tlsCfg, auditLog, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	grpcServer := createGRPCServer(cfg, tlsCfg, interceptors)
+	services := &serviceRecorder{Server: grpcServer}
 
 	// Register runme services.
-	registrar(grpcServer)
+	registrar(services)
 
 	// Register health service.
 	healthcheck := health.NewServer()
-	grpc_health_v1.RegisterHealthServer(grpcServer, healthcheck)
+	grpc_health_v1.RegisterHealthServer(services, healthcheck)
 	// Setting SERVING for the whole system.
 	healthcheck.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
 
 	// Register reflection service.
-	reflection.Register(grpcServer)
+	reflection.Register(services)
 
 	s := Server{
-		cfg:    cfg,
-		gs:     grpcServer,
-		logger: logger,
+		cfg:       cfg,
+		gs:        grpcServer,
+		tlsCfg:    tlsCfg,
+		auditLog:  auditLog,
+		telemetry: tel,
+		logger:    logger,
+	}
+
+	if web := cfg.Server.Web; web != nil && web.Enabled {
+		handler, err := newWebHandler(web, cfg.Server.MaxMessageSize, grpcServer, services.services, interceptors)
+		if err != nil {
+			return nil, err
+		}
+		s.web = createWebServer(handler, tlsCfg)
 	}
 
 	return &s, nil
@@ -74,13 +138,59 @@ func (s *Server) Serve() (err error) {
 	if err != nil {
 		return err
 	}
+	if strings.HasPrefix(s.cfg.Server.Address, "unix://") {
+		s.lis, err = newPeerCredentialsListener(s.lis, s.cfg.Server.PeerCredentials, s.logger)
+		if err != nil {
+			return err
+		}
+	}
+	if s.web != nil {
+		return s.serveWeb()
+	}
 	s.logger.Info("starting gRPC server", zap.String("address", s.Addr()))
 	return s.gs.Serve(s.lis)
 }
 
+func (s *Server) serveWeb() (err error) {
+	s.logger.Info("starting gRPC server with Connect and gRPC-Web", zap.String("address", s.Addr()))
+	if s.tlsCfg != nil {
+		err = s.web.ServeTLS(s.lis, "", "")
+	} else {
+		err = s.web.Serve(s.lis)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return errors.WithStack(err)
+}
+
 func (s *Server) Shutdown() {
 	s.logger.Info("stopping gRPC server")
+
+	if s.web != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), webShutdownTimeout)
+		defer cancel()
+		if err := s.web.Shutdown(ctx); err != nil {
+			s.logger.Info("failed to gracefully stop web server", zap.Error(err))
+			_ = s.web.Close()
+		}
+	}
+
 	s.gs.GracefulStop()
+
+	if s.auditLog != nil {
+		if err := s.auditLog.Close(); err != nil {
+			s.logger.Info("failed to close audit log", zap.Error(err))
+		}
+	}
+
+	if s.telemetry != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryShutdownTimeout)
+		defer cancel()
+		if err := s.telemetry.Shutdown(ctx); err != nil {
+			s.logger.Info("failed to shut down telemetry", zap.Error(err))
+		}
+	}
 }
 
 func createListener(addr string) (net.Listener, error) {
@@ -98,22 +208,122 @@ func createListener(addr string) (net.Listener, error) {
 	return lis, errors.WithStack(err)
 }
 
+// IsLocalAddress returns true for unix socket and loopback addresses. Servers
+// listening on other addresses accept only issued client certificates, as
+// the TLS directory of the server is not meant to be shared with remote clients.
+func IsLocalAddress(addr string) bool {
+	if strings.HasPrefix(addr, "unix://") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func createTLSConfig(cfg *config.Config, logger *zap.Logger) (*tls.Config, error) {
 	if tls := cfg.Server.Tls; tls != nil && tls.Enabled {
+		var opts []runmetls.ServerOption
+		if tls.IssuedClientCertsOnly || !IsLocalAddress(cfg.Server.Address) {
+			opts = append(opts, runmetls.WithIssuedClientCertsOnly())
+		}
 		// TODO(adamb): redesign runmetls API.
-		return runmetls.LoadOrGenerateConfig(
+		tlsCfg, err := runmetls.LoadOrGenerateConfig(
 			*tls.CertFile, // guaranteed in [getRootConfig]
 			*tls.KeyFile,  // guaranteed in [getRootConfig]
 			logger,
+			opts...,
 		)
+		if err != nil {
+			return nil, err
+		}
+		// Browsers might not negotiate HTTP/2.
+		if web := cfg.Server.Web; web != nil && web.Enabled {
+			tlsCfg.NextProtos = append(tlsCfg.NextProtos, "http/1.1")
+		}
+		return tlsCfg, nil
 	}
 	return nil, nil
 }
 
-func createGRPCServer(cfg *config.Config, tlsCfg *tls.Config) *grpc.Server {
+func createAuditLog(cfg *config.Config) (*audit.Log, error) {
+	if a := cfg.Server.Audit; a != nil && a.Enabled {
+		if a.Path == nil {
+			return nil, errors.New("audit log path is required")
+		}
+		return audit.Open(*a.Path)
+	}
+	return nil, nil
+}
+
+// interceptors are used by the gRPC server and the connect handlers.
+type interceptors struct {
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+}
+
+func createInterceptors(cfg *config.Config, tlsCfg *tls.Config, auditLog *audit.Log, logger *zap.Logger) (interceptors, error) {
+	var result interceptors
+
+	// Interceptors run in order of chaining. The client identity
+	// must be known before the policy is evaluated and the call is audited.
+	// Audit wraps the policy to record denied calls as well.
+	if strings.HasPrefix(cfg.Server.Address, "unix://") {
+		result.unary = append(result.unary, UnaryPeerCredentialsInterceptor)
+		result.stream = append(result.stream, StreamPeerCredentialsInterceptor)
+	}
+	if tlsCfg != nil {
+		result.unary = append(result.unary, UnaryClientIdentityInterceptor)
+		result.stream = append(result.stream, StreamClientIdentityInterceptor)
+	}
+
+	// Sessions are scoped by the namespace which depends on the client identity.
+	unaryNamespace, streamNamespace := NamespaceInterceptors(tlsCfg != nil)
+	result.unary = append(result.unary, unaryNamespace)
+	result.stream = append(result.stream, streamNamespace)
+
+	if auditLog != nil {
+		auditor := &auditInterceptor{log: auditLog, logger: logger.Named("Audit")}
+		result.unary = append(result.unary, auditor.Unary)
+		result.stream = append(result.stream, auditor.Stream)
+	}
+
+	policy, err := newPolicyInterceptor(cfg, logger)
+	if err != nil {
+		return result, err
+	}
+	if policy != nil {
+		result.unary = append(result.unary, policy.Unary)
+		result.stream = append(result.stream, policy.Stream)
+	}
+
+	return result, nil
+}
+
+func createTelemetry(cfg *config.Config, logger *zap.Logger) (*telemetry.Telemetry, error) {
+	if cfg.Server.Telemetry == nil {
+		return nil, nil
+	}
+	return telemetry.New(cfg.Server.Telemetry, logger)
+}
+
+func createGRPCServer(cfg *config.Config, tlsCfg *tls.Config, interceptors interceptors) *grpc.Server {
 	opts := []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(cfg.Server.MaxMessageSize),
 		grpc.MaxSendMsgSize(cfg.Server.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(interceptors.unary...),
+		grpc.ChainStreamInterceptor(interceptors.stream...),
+	}
+
+	// Spans and metrics of all calls. Spans of the services,
+	// like Execute, are children of the call spans.
+	if cfg.Server.Telemetry != nil {
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
 	}
 
 	if tlsCfg != nil {
@@ -122,3 +332,25 @@ func createGRPCServer(cfg *config.Config, tlsCfg *tls.Config) *grpc.Server {
 
 	return grpc.NewServer(opts...)
 }
+
+// createWebServer returns an HTTP server for the handler returned by [newWebHandler].
+// Without TLS, HTTP/2 is used without encryption (h2c) as gRPC requires HTTP/2.
+func createWebServer(handler http.Handler, tlsCfg *tls.Config) *http.Server {
+	if tlsCfg == nil {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	return &http.Server{
+		Handler:           handler,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 10 * time.Second,
+		// The address of the connection carries peer credentials
+		// which are not available in [http.Request.RemoteAddr].
+		// gRPC calls served over HTTP get them from the request context.
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if addr, ok := c.RemoteAddr().(*PeerAddr); ok {
+				ctx = ContextWithPeerCredentials(ctx, addr.Credentials)
+			}
+			return context.WithValue(ctx, remoteAddrKey{}, c.RemoteAddr())
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLocalAddress(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"unix:///tmp/runme.sock": true,
+		"localhost:7998":         true,
+		"127.0.0.1:7998":         true,
+		"[::1]:7998":             true,
+		"0.0.0.0:7998":           false,
+		":7998":                  false,
+		"192.168.1.10:7998":      false,
+		"example.com:7998":       false,
+		"localhost":              false,
+	} {
+		assert.Equal(t, expected, IsLocalAddress(addr), addr)
+	}
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	connectcors "connectrpc.com/cors"
+	"github.com/pkg/errors"
+	"github.com/rs/cors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/stateful/runme/v3/internal/config"
+)
+
+// serviceRecorder registers services in the gRPC server and records them
+// in order to serve them over the Connect protocol and gRPC-Web as well.
+type serviceRecorder struct {
+	*grpc.Server
+	services []recordedService
+}
+
+type recordedService struct {
+	desc *grpc.ServiceDesc
+	impl any
+}
+
+func (r *serviceRecorder) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	r.Server.RegisterService(desc, impl)
+	r.services = append(r.services, recordedService{desc: desc, impl: impl})
+}
+
+// message wraps a generated message. It allows to use the generic
+// connect handlers with messages which types are known only at runtime.
+// The codecs use the embedded message through its ProtoReflect method.
+type message struct {
+	proto.Message
+}
+
+// newWebHandler returns a handler serving gRPC requests with the gRPC server.
+// Other requests, using the Connect protocol or gRPC-Web, are served by connect
+// handlers which call the same services through the same interceptors.
+func newWebHandler(
+	cfg *config.ConfigServerWeb,
+	maxMessageSize int,
+	grpcServer *grpc.Server,
+	services []recordedService,
+	interceptors interceptors,
+) (http.Handler, error) {
+	opts := []connect.HandlerOption{
+		connect.WithReadMaxBytes(maxMessageSize),
+		connect.WithSendMaxBytes(maxMessageSize),
+	}
+
+	mux := http.NewServeMux()
+	for _, svc := range services {
+		if err := registerConnectService(mux, svc, interceptors, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	if gateway := cfg.Gateway; gateway != nil && gateway.Enabled {
+		handler, err := newGatewayHandler(services, maxMessageSize, cfg.Cors, interceptors)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(gatewayPrefix+"/", handler)
+	}
+
+	connectHandler := withCORS(cfg.Cors, withPeer(mux))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPCRequest(r) {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		connectHandler.ServeHTTP(w, r)
+	}), nil
+}
+
+func isGRPCRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return r.ProtoMajor == 2 &&
+		(contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+"))
+}
+
+func registerConnectService(mux *http.ServeMux, svc recordedService, interceptors interceptors, opts ...connect.HandlerOption) error {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(svc.desc.ServiceName))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find descriptor of service %s", svc.desc.ServiceName)
+	}
+	serviceDesc, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return errors.Errorf("%s is not a service", svc.desc.ServiceName)
+	}
+
+	unaryInterceptor := chainUnaryInterceptors(interceptors.unary)
+	streamInterceptor := chainStreamInterceptors(interceptors.stream)
+
+	for _, m := range svc.desc.Methods {
+		procedure := "/" + svc.desc.ServiceName + "/" + m.MethodName
+		handlerOpts, err := methodHandlerOptions(serviceDesc, m.MethodName, opts...)
+		if err != nil {
+			return err
+		}
+
+		handler := m.Handler
+		mux.Handle(procedure, connect.NewUnaryHandler(
+			procedure,
+			func(ctx context.Context, req *connect.Request[message]) (*connect.Response[message], error) {
+				dec := func(in any) error {
+					proto.Merge(in.(proto.Message), req.Msg.Message)
+					return nil
+				}
+				resp, err := handler(svc.impl, withRequestMetadata(ctx, req.Header()), dec, unaryInterceptor)
+				if err != nil {
+					return nil, connectError(err)
+				}
+				return connect.NewResponse(&message{Message: resp.(proto.Message)}), nil
+			},
+			handlerOpts...,
+		))
+	}
+
+	for _, s := range svc.desc.Streams {
+		procedure := "/" + svc.desc.ServiceName + "/" + s.StreamName
+		handlerOpts, err := methodHandlerOptions(serviceDesc, s.StreamName, opts...)
+		if err != nil {
+			return err
+		}
+
+		info := &grpc.StreamServerInfo{
+			FullMethod:     procedure,
+			IsClientStream: s.ClientStreams,
+			IsServerStream: s.ServerStreams,
+		}
+		handleStream := func(ctx context.Context, conn connect.StreamingHandlerConn, req proto.Message) error {
+			stream := &connectServerStream{
+				ctx:  withRequestMetadata(ctx, conn.RequestHeader()),
+				conn: conn,
+				req:  req,
+			}
+			return connectError(streamInterceptor(svc.impl, stream, info, s.Handler))
+		}
+
+		var h http.Handler
+		if s.ClientStreams {
+			// Client-streaming calls are handled as bidirectional ones.
+			// Both require HTTP/2 and are not supported by browsers.
+			h = connect.NewBidiStreamHandler(
+				procedure,
+				func(ctx context.Context, stream *connect.BidiStream[message, message]) error {
+					return handleStream(ctx, stream.Conn(), nil)
+				},
+				handlerOpts...,
+			)
+		} else {
+			h = connect.NewServerStreamHandler(
+				procedure,
+				func(ctx context.Context, req *connect.Request[message], stream *connect.ServerStream[message]) error {
+					return handleStream(ctx, stream.Conn(), req.Msg.Message)
+				},
+				handlerOpts...,
+			)
+		}
+		mux.Handle(procedure, h)
+	}
+
+	return nil
+}
+
+// methodHandlerOptions returns opts extended by options which set the schema of the method
+// and initialize requests with the generated message type of its input.
+func methodHandlerOptions(serviceDesc protoreflect.ServiceDescriptor, name string, opts ...connect.HandlerOption) ([]connect.HandlerOption, error) {
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(name))
+	if methodDesc == nil {
+		return nil, errors.Errorf("failed to find descriptor of method %s/%s", serviceDesc.FullName(), name)
+	}
+
+	inputType, err := protoregistry.GlobalTypes.FindMessageByName(methodDesc.Input().FullName())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find type of %s", methodDesc.Input().FullName())
+	}
+
+	return append(
+		[]connect.HandlerOption{
+			connect.WithSchema(methodDesc),
+			connect.WithRequestInitializer(func(_ connect.Spec, m any) error {
+				m.(*message).Message = inputType.New().Interface()
+				return nil
+			}),
+		},
+		opts...,
+	), nil
+}
+
+// connectServerStream adapts a connect stream to [grpc.ServerStream]
+// expected by the handlers of the generated services.
+type connectServerStream struct {
+	ctx  context.Context
+	conn connect.StreamingHandlerConn
+	// req is the request of a server-streaming call.
+	// It is received by connect before the call is handled.
+	req      proto.Message
+	received bool
+}
+
+var _ grpc.ServerStream = (*connectServerStream)(nil)
+
+func (s *connectServerStream) SetHeader(md metadata.MD) error {
+	copyMetadata(s.conn.ResponseHeader(), md)
+	return nil
+}
+
+func (s *connectServerStream) SendHeader(md metadata.MD) error {
+	// Headers are sent by connect with the first message.
+	return s.SetHeader(md)
+}
+
+func (s *connectServerStream) SetTrailer(md metadata.MD) {
+	copyMetadata(s.conn.ResponseTrailer(), md)
+}
+
+func (s *connectServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *connectServerStream) SendMsg(m any) error {
+	return s.conn.Send(&message{Message: m.(proto.Message)})
+}
+
+func (s *connectServerStream) RecvMsg(m any) error {
+	if s.req != nil {
+		if s.received {
+			return io.EOF
+		}
+		s.received = true
+		proto.Merge(m.(proto.Message), s.req)
+		return nil
+	}
+
+	err := s.conn.Receive(&message{Message: m.(proto.Message)})
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	return err
+}
+
+// connectError converts a gRPC status error to a connect error.
+// The codes of both are the same.
+func connectError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return err
+	}
+	st := status.Convert(err)
+	return connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+}
+
+func copyMetadata(header http.Header, md metadata.MD) {
+	for k, values := range md {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+}
+
+// withRequestMetadata makes request headers available
+// with [metadata.FromIncomingContext] like in gRPC.
+func withRequestMetadata(ctx context.Context, header http.Header) context.Context {
+	md := make(metadata.MD, len(header))
+	for k, values := range header {
+		md.Append(k, values...)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// withPeer adds the peer to the request context like the gRPC server does.
+// It allows the interceptors to identify the client.
+func withPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &peer.Peer{Addr: strAddr(r.RemoteAddr)}
+		if addr, ok := r.Context().Value(remoteAddrKey{}).(*PeerAddr); ok {
+			p.Addr = addr
+		}
+		if r.TLS != nil {
+			p.AuthInfo = credentials.TLSInfo{
+				State:          *r.TLS,
+				CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(peer.NewContext(r.Context(), p)))
+	})
+}
+
+// withCORS allows cross-origin requests from the configured origins.
+// Without allowed origins, only same-origin requests are allowed.
+func withCORS(cfg *config.ConfigServerWebCors, next http.Handler) http.Handler {
+	if cfg == nil || len(cfg.AllowedOrigins) == 0 {
+		return next
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   connectcors.AllowedMethods(),
+		AllowedHeaders:   append(connectcors.AllowedHeaders(), cfg.AllowedHeaders...),
+		ExposedHeaders:   connectcors.ExposedHeaders(),
+		AllowCredentials: cfg.AllowCredentials != nil && *cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}).Handler(next)
+}
+
+// remoteAddrKey is the context key of the address of the connection.
+type remoteAddrKey struct{}
+
+type strAddr string
+
+func (a strAddr) Network() string { return "tcp" }
+
+func (a strAddr) String() string { return string(a) }
+
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(srv any, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/stateful/runme/v3/internal/config"
+	runnerv2 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/runner/v2"
+)
+
+type fakeRunnerService struct {
+	runnerv2.UnimplementedRunnerServiceServer
+}
+
+func (fakeRunnerService) ExecuteServerStream(req *runnerv2.ExecuteRequest, srv runnerv2.RunnerService_ExecuteServerStreamServer) error {
+	responses := []*runnerv2.ExecuteResponse{
+		{ExecutionId: "exec-1"},
+		{StdoutData: []byte(strings.Join(req.GetConfig().GetArguments(), " "))},
+		{ExitCode: wrapperspb.UInt32(0)},
+	}
+	for _, resp := range responses {
+		if err := srv.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startTestWebServer(t *testing.T) string {
+	t.Helper()
+
+	cfg, err := config.ParseYAML([]byte(`version: v1alpha1
+server:
+  address: localhost:0
+  web:
+    enabled: true
+    gateway:
+      enabled: true
+    cors:
+      allowed_origins:
+        - http://localhost:3000
+  policy:
+    rules:
+      - name: no-sessions
+        effect: deny
+        condition: method endsWith "/CreateSession"
+        message: sessions are disabled
+`))
+	require.NoError(t, err)
+
+	s, err := New(cfg, zap.NewNop(), func(sr grpc.ServiceRegistrar) {
+		runnerv2.RegisterRunnerServiceServer(sr, fakeRunnerService{})
+	})
+	require.NoError(t, err)
+	require.NotNil(t, s.web)
+
+	s.lis, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.serveWeb() }()
+	t.Cleanup(func() {
+		s.Shutdown()
+		assert.NoError(t, <-errc)
+	})
+
+	return s.Addr()
+}
+
+func TestWebServer(t *testing.T) {
+	addr := startTestWebServer(t)
+	baseURL := "http://" + addr
+
+	t.Run("GRPC", func(t *testing.T) {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+	})
+
+	t.Run("ConnectJSON", func(t *testing.T) {
+		resp, err := http.Post(baseURL+"/grpc.health.v1.Health/Check", "application/json", strings.NewReader("{}"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"status":"SERVING"}`, string(body))
+	})
+
+	t.Run("PolicyDenied", func(t *testing.T) {
+		resp, err := http.Post(baseURL+"/runme.runner.v2.RunnerService/CreateSession", "application/json", strings.NewReader("{}"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.JSONEq(t, `{"code":"permission_denied","message":"denied by policy rule \"no-sessions\": sessions are disabled"}`, string(body))
+	})
+
+	t.Run("ServerStream", func(t *testing.T) {
+		protocols := map[string][]connect.ClientOption{
+			"Connect": nil,
+			"GRPCWeb": {connect.WithGRPCWeb()},
+		}
+
+		for name, opts := range protocols {
+			t.Run(name, func(t *testing.T) {
+				client := connect.NewClient[runnerv2.ExecuteRequest, runnerv2.ExecuteResponse](
+					http.DefaultClient,
+					baseURL+"/runme.runner.v2.RunnerService/ExecuteServerStream",
+					opts...,
+				)
+
+				stream, err := client.CallServerStream(
+					context.Background(),
+					connect.NewRequest(&runnerv2.ExecuteRequest{
+						Config: &runnerv2.ProgramConfig{ProgramName: "echo", Arguments: []string{"hello", "world"}},
+					}),
+				)
+				require.NoError(t, err)
+				defer stream.Close()
+
+				var responses []*runnerv2.ExecuteResponse
+				for stream.Receive() {
+					responses = append(responses, stream.Msg())
+				}
+				require.NoError(t, stream.Err())
+				require.Len(t, responses, 3)
+				assert.Equal(t, "exec-1", responses[0].ExecutionId)
+				assert.Equal(t, "hello world", string(responses[1].StdoutData))
+				assert.EqualValues(t, 0, responses[2].ExitCode.GetValue())
+			})
+		}
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		preflight := func(origin string) *http.Response {
+			req, err := http.NewRequest(http.MethodOptions, baseURL+"/grpc.health.v1.Health/Check", nil)
+			require.NoError(t, err)
+			req.Header.Set("Origin", origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			// Browsers send lowercase and sorted header names.
+			req.Header.Set("Access-Control-Request-Headers", "connect-protocol-version,content-type")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			return resp
+		}
+
+		resp := preflight("http://localhost:3000")
+		assert.Equal(t, "http://localhost:3000", resp.Header.Get("Access-Control-Allow-Origin"))
+
+		resp = preflight("http://example.com")
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+}
@@ -0,0 +1,300 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/internal/lru"
+)
+
+// DefaultNamespace is used by callers which are not
+// authenticated and do not provide a namespace.
+const DefaultNamespace = ""
+
+type namespaceKey struct{}
+
+// ContextWithNamespace returns a copy of ctx with the namespace of the caller.
+func ContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace of the caller
+// or [DefaultNamespace] if it is not set.
+func NamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey{}).(string)
+	return namespace
+}
+
+// MaxSessions is the default maximum number of sessions in all namespaces.
+// Without it, callers could create any number of namespaces, each
+// with sessions up to the quota.
+const MaxSessions = 16 * SessionListCapacity
+
+// ErrTooManySessions is returned by [Sessions.Add] when the total number
+// of sessions in all namespaces reached the limit set with [WithMaxSessions].
+var ErrTooManySessions = errors.New("too many sessions")
+
+// NamespacedList keeps sessions of each namespace in a separate list
+// so that callers can't see or use sessions of other namespaces.
+// Each list has its own capacity, called quota. A namespace exists
+// only as long as it has sessions. It is generic to hold sessions
+// of both versions of the runner service.
+type NamespacedList[T lru.CacheIdentifier] struct {
+	mu    sync.Mutex
+	lists map[string]*lru.Cache[T]
+	namespacedListOptions
+}
+
+type namespacedListOptions struct {
+	quota       int
+	quotas      map[string]int
+	maxSessions int
+	onEvict     func(string, any)
+}
+
+type NamespacedListOption func(*namespacedListOptions)
+
+// WithQuota sets the maximum number of sessions in a namespace.
+// It defaults to [SessionListCapacity].
+func WithQuota(quota int) NamespacedListOption {
+	return func(o *namespacedListOptions) {
+		if quota > 0 {
+			o.quota = quota
+		}
+	}
+}
+
+// WithNamespaceQuota overrides the quota of a specific namespace.
+func WithNamespaceQuota(namespace string, quota int) NamespacedListOption {
+	return func(o *namespacedListOptions) {
+		if quota > 0 {
+			o.quotas[namespace] = quota
+		}
+	}
+}
+
+// WithMaxSessions sets the maximum number of sessions in all namespaces.
+// It defaults to [MaxSessions]. Unlike the quota, sessions are not evicted
+// when it is reached, as namespaces must not affect each other;
+// new sessions are rejected instead.
+func WithMaxSessions(max int) NamespacedListOption {
+	return func(o *namespacedListOptions) {
+		if max > 0 {
+			o.maxSessions = max
+		}
+	}
+}
+
+// WithEvictHandler sets a function called with sessions
+// evicted because the quota of their namespace was exceeded.
+func WithEvictHandler[T lru.CacheIdentifier](fn func(namespace string, sess T)) NamespacedListOption {
+	return func(o *namespacedListOptions) {
+		o.onEvict = func(namespace string, sess any) { fn(namespace, sess.(T)) }
+	}
+}
+
+func NewNamespacedList[T lru.CacheIdentifier](opts ...NamespacedListOption) *NamespacedList[T] {
+	l := &NamespacedList[T]{
+		lists: make(map[string]*lru.Cache[T]),
+		namespacedListOptions: namespacedListOptions{
+			quota:       SessionListCapacity,
+			quotas:      make(map[string]int),
+			maxSessions: MaxSessions,
+		},
+	}
+	for _, opt := range opts {
+		opt(&l.namespacedListOptions)
+	}
+	return l
+}
+
+// Namespace returns the sessions of the namespace.
+func (l *NamespacedList[T]) Namespace(namespace string) *Sessions[T] {
+	return &Sessions[T]{parent: l, namespace: namespace}
+}
+
+// Quota returns the maximum number of sessions in the namespace.
+func (l *NamespacedList[T]) Quota(namespace string) int {
+	if quota, ok := l.quotas[namespace]; ok {
+		return quota
+	}
+	return l.quota
+}
+
+// Namespaces returns sorted names of namespaces which have sessions.
+func (l *NamespacedList[T]) Namespaces() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]string, 0, len(l.lists))
+	for namespace := range l.lists {
+		result = append(result, namespace)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Size returns the number of sessions in all namespaces.
+func (l *NamespacedList[T]) Size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.sizeUnsafe()
+}
+
+func (l *NamespacedList[T]) sizeUnsafe() int {
+	size := 0
+	for _, list := range l.lists {
+		size += list.Size()
+	}
+	return size
+}
+
+func (l *NamespacedList[T]) list(namespace string) (*lru.Cache[T], bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, ok := l.lists[namespace]
+	return list, ok
+}
+
+// listForAddUnsafe returns the list of the namespace, which is new if the
+// namespace does not exist, or [ErrTooManySessions] if a session cannot be
+// added. New lists are stored by the caller after the session is added.
+func (l *NamespacedList[T]) listForAddUnsafe(namespace string) (_ *lru.Cache[T], created bool, _ error) {
+	list, ok := l.lists[namespace]
+	if !ok {
+		list = lru.NewCache[T](l.Quota(namespace))
+		if l.onEvict != nil {
+			list.OnEvict(func(sess T) { l.onEvict(namespace, sess) })
+		}
+	}
+
+	// Adding to a full list evicts a session of the same namespace,
+	// hence, it does not change the total number of sessions.
+	if l.sizeUnsafe() >= l.maxSessions && list.Size() < l.Quota(namespace) {
+		return nil, false, ErrTooManySessions
+	}
+
+	return list, !ok, nil
+}
+
+func (l *NamespacedList[T]) add(namespace string, sess T) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, created, err := l.listForAddUnsafe(namespace)
+	if err != nil {
+		return err
+	}
+	if err := list.Add(sess); err != nil {
+		return err
+	}
+	if created {
+		l.lists[namespace] = list
+	}
+	return nil
+}
+
+func (l *NamespacedList[T]) newestOrCreate(namespace string, generate func() (T, error)) (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if list, ok := l.lists[namespace]; ok {
+		if sess, ok := list.Newest(); ok {
+			return sess, nil
+		}
+	}
+
+	var zero T
+
+	list, created, err := l.listForAddUnsafe(namespace)
+	if err != nil {
+		return zero, err
+	}
+	sess, err := list.CreateAndAdd(generate)
+	if err != nil {
+		return zero, err
+	}
+	if created {
+		l.lists[namespace] = list
+	}
+	return sess, nil
+}
+
+func (l *NamespacedList[T]) deleteByID(namespace, id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, ok := l.lists[namespace]
+	if !ok {
+		return false
+	}
+	deleted := list.DeleteByID(id)
+	if list.Size() == 0 {
+		delete(l.lists, namespace)
+	}
+	return deleted
+}
+
+// Sessions are sessions of a single namespace of [NamespacedList].
+type Sessions[T lru.CacheIdentifier] struct {
+	parent    *NamespacedList[T]
+	namespace string
+}
+
+// Add adds the session to the namespace. If the quota of the namespace
+// is exceeded, the least recently used session is evicted.
+// It returns [ErrTooManySessions] if the limit of all sessions is reached.
+func (s *Sessions[T]) Add(sess T) error {
+	return s.parent.add(s.namespace, sess)
+}
+
+// NewestOrCreate returns the most recently used session or
+// adds a session created with generate if there is none.
+func (s *Sessions[T]) NewestOrCreate(generate func() (T, error)) (T, error) {
+	return s.parent.newestOrCreate(s.namespace, generate)
+}
+
+func (s *Sessions[T]) GetByID(id string) (T, bool) {
+	list, ok := s.parent.list(s.namespace)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return list.GetByID(id)
+}
+
+func (s *Sessions[T]) Newest() (T, bool) {
+	list, ok := s.parent.list(s.namespace)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return list.Newest()
+}
+
+// List returns sessions from the least to the most recently used.
+func (s *Sessions[T]) List() []T {
+	list, ok := s.parent.list(s.namespace)
+	if !ok {
+		return nil
+	}
+	return list.List()
+}
+
+// DeleteByID deletes the session. The namespace is removed with its last session.
+func (s *Sessions[T]) DeleteByID(id string) bool {
+	return s.parent.deleteByID(s.namespace, id)
+}
+
+func (s *Sessions[T]) Size() int {
+	list, ok := s.parent.list(s.namespace)
+	if !ok {
+		return 0
+	}
+	return list.Size()
+}
@@ -0,0 +1,131 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceFromContext(t *testing.T) {
+	assert.Equal(t, DefaultNamespace, NamespaceFromContext(context.Background()))
+	assert.Equal(t, "ci", NamespaceFromContext(ContextWithNamespace(context.Background(), "ci")))
+}
+
+func TestNamespacedList(t *testing.T) {
+	var evicted []string
+
+	list := NewNamespacedList[*Session](
+		WithQuota(2),
+		WithNamespaceQuota("ci", 1),
+		WithEvictHandler(func(namespace string, sess *Session) {
+			evicted = append(evicted, namespace+"/"+sess.ID)
+		}),
+	)
+
+	assert.Equal(t, 2, list.Quota("alice"))
+	assert.Equal(t, 1, list.Quota("ci"))
+
+	alice1, err := New()
+	require.NoError(t, err)
+	alice2, err := New()
+	require.NoError(t, err)
+	require.NoError(t, list.Namespace("alice").Add(alice1))
+	require.NoError(t, list.Namespace("alice").Add(alice2))
+
+	ci1, err := New()
+	require.NoError(t, err)
+	ci2, err := New()
+	require.NoError(t, err)
+	require.NoError(t, list.Namespace("ci").Add(ci1))
+	require.NoError(t, list.Namespace("ci").Add(ci2))
+
+	// Sessions of other namespaces are neither visible nor evicted.
+	_, ok := list.Namespace("ci").GetByID(alice1.ID)
+	assert.False(t, ok)
+	newest, ok := list.Namespace("alice").Newest()
+	require.True(t, ok)
+	assert.Equal(t, alice2.ID, newest.ID)
+	assert.Len(t, list.Namespace("alice").List(), 2)
+
+	assert.Equal(t, []string{"ci/" + ci1.ID}, evicted)
+	assert.Equal(t, []string{"alice", "ci"}, list.Namespaces())
+	assert.Equal(t, 3, list.Size())
+}
+
+func TestNamespacedList_EmptyNamespaces(t *testing.T) {
+	list := NewNamespacedList[*Session]()
+
+	// Reads do not create namespaces.
+	_, ok := list.Namespace("alice").Newest()
+	assert.False(t, ok)
+	assert.Empty(t, list.Namespace("alice").List())
+	assert.Empty(t, list.Namespaces())
+
+	sess, err := New()
+	require.NoError(t, err)
+	require.NoError(t, list.Namespace("alice").Add(sess))
+	assert.Equal(t, []string{"alice"}, list.Namespaces())
+
+	assert.False(t, list.Namespace("alice").DeleteByID("unknown"))
+	assert.Equal(t, []string{"alice"}, list.Namespaces())
+
+	assert.True(t, list.Namespace("alice").DeleteByID(sess.ID))
+	assert.Empty(t, list.Namespaces())
+	assert.Equal(t, 0, list.Size())
+}
+
+func TestNamespacedList_MaxSessions(t *testing.T) {
+	list := NewNamespacedList[*Session](WithQuota(2), WithMaxSessions(3))
+
+	add := func(namespace string) (*Session, error) {
+		sess, err := New()
+		require.NoError(t, err)
+		return sess, list.Namespace(namespace).Add(sess)
+	}
+
+	_, err := add("alice")
+	require.NoError(t, err)
+	_, err = add("alice")
+	require.NoError(t, err)
+	bob, err := add("bob")
+	require.NoError(t, err)
+
+	_, err = add("bob")
+	assert.ErrorIs(t, err, ErrTooManySessions)
+	_, err = add("eve")
+	assert.ErrorIs(t, err, ErrTooManySessions)
+	assert.Equal(t, []string{"alice", "bob"}, list.Namespaces())
+
+	// A full namespace evicts its own sessions.
+	_, err = add("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, list.Size())
+
+	require.True(t, list.Namespace("bob").DeleteByID(bob.ID))
+	_, err = add("eve")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "eve"}, list.Namespaces())
+}
+
+func TestNamespacedList_NewestOrCreate(t *testing.T) {
+	list := NewNamespacedList[*Session]()
+
+	// A failed creation does not leave an empty namespace.
+	_, err := list.Namespace("alice").NewestOrCreate(func() (*Session, error) {
+		return nil, errors.New("failed")
+	})
+	require.Error(t, err)
+	assert.Empty(t, list.Namespaces())
+
+	create := func() (*Session, error) { return New() }
+
+	created, err := list.Namespace("alice").NewestOrCreate(create)
+	require.NoError(t, err)
+	newest, err := list.Namespace("alice").NewestOrCreate(create)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, newest.ID)
+	assert.Equal(t, 1, list.Size())
+}
@@ -0,0 +1,17 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+var safe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote returns s quoted for POSIX shells. Strings consisting only
+// of characters without a special meaning are returned as they are.
+func Quote(s string) string {
+	if safe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,16 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "./bin/runme", Quote("./bin/runme"))
+	assert.Equal(t, "--env-order=a,b", Quote("--env-order=a,b"))
+	assert.Equal(t, "''", Quote(""))
+	assert.Equal(t, "'hello world'", Quote("hello world"))
+	assert.Equal(t, `'it'\''s'`, Quote("it's"))
+	assert.Equal(t, "'$HOME'", Quote("$HOME"))
+}
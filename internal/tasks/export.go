@@ -0,0 +1,411 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stateful/runme/v3/internal/shell"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatMake     ExportFormat = "make"
+	ExportFormatTaskfile ExportFormat = "taskfile"
+	ExportFormatJust     ExportFormat = "just"
+)
+
+var ExportFormats = []ExportFormat{ExportFormatMake, ExportFormatTaskfile, ExportFormatJust}
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	f := ExportFormat(strings.ToLower(s))
+	if !slices.Contains(ExportFormats, f) {
+		return "", errors.Errorf("unsupported format %q", s)
+	}
+	return f, nil
+}
+
+// FileName returns the conventional name of the file in the format.
+func (f ExportFormat) FileName() string {
+	switch f {
+	case ExportFormatMake:
+		return "Makefile"
+	case ExportFormatTaskfile:
+		return "Taskfile.yml"
+	case ExportFormatJust:
+		return "justfile"
+	default:
+		return ""
+	}
+}
+
+// ExportTask is a task in a form independent of the export format.
+type ExportTask struct {
+	Name        string
+	Description string
+	// Dir is a working directory relative to the exported file.
+	Dir string
+	// Interpreter reads the script from stdin, for example, "bash" or "python3".
+	Interpreter string
+	Lines       []string
+	Tags        []string
+}
+
+type ExportOptions struct {
+	// EnvFiles are loaded by the tool running the exported tasks.
+	// Paths are relative to the exported file.
+	EnvFiles []string
+}
+
+const exportHeader = "Code generated by runme tasks export. DO NOT EDIT."
+
+// heredocDelimiter ends the script passed to the interpreter.
+const heredocDelimiter = "RUNME_EOF"
+
+// NewExportTasks converts project tasks into [ExportTask] with
+// working directories relative to dir, typically the directory
+// of the exported file. Tasks found in Makefiles, package.json files,
+// and Taskfiles are skipped.
+func NewExportTasks(tasks []project.Task, dir string) ([]ExportTask, error) {
+	result := make([]ExportTask, 0, len(tasks))
+
+	for _, task := range tasks {
+		switch task.Source {
+		case project.TaskSourceMakefile, project.TaskSourcePackageJSON, project.TaskSourceTaskfile:
+			// These tasks are defined for a task runner already.
+			continue
+		}
+
+		block := task.CodeBlock
+
+		rel, err := filepath.Rel(dir, Dir(task))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		result = append(result, ExportTask{
+			Name:        task.QualifiedName(),
+			Description: block.Intro(),
+			Dir:         filepath.ToSlash(rel),
+			Interpreter: Interpreter(task),
+			Lines:       block.Lines(),
+			Tags:        block.Tags(),
+		})
+	}
+
+	return result, nil
+}
+
+// Export writes tasks in the format to w. Additionally to the tasks,
+// a task for each tag is written which runs all tasks with the tag.
+func Export(w io.Writer, format ExportFormat, tasks []ExportTask, opts ExportOptions) error {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+
+	switch format {
+	case ExportFormatMake:
+		err = exportMake(&buf, tasks, opts)
+	case ExportFormatTaskfile:
+		err = exportTaskfile(&buf, tasks, opts)
+	case ExportFormatJust:
+		err = exportJust(&buf, tasks, opts)
+	default:
+		err = errors.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return errors.WithStack(err)
+}
+
+func exportMake(w *bytes.Buffer, tasks []ExportTask, opts ExportOptions) error {
+	names, err := exportNames(tasks, makeName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# %s\n\n", exportHeader)
+
+	// Each recipe is a single script passed to the shell.
+	_, _ = w.WriteString(".ONESHELL:\n")
+
+	if len(opts.EnvFiles) > 0 {
+		_, _ = w.WriteString("\n")
+		for _, file := range opts.EnvFiles {
+			fmt.Fprintf(w, "-include %s\n", file)
+		}
+		_, _ = w.WriteString("export\n")
+	}
+
+	tags := exportTags(tasks, names, makeName)
+
+	phony := slices.Clone(names)
+	for _, tag := range tags {
+		phony = append(phony, tag.name)
+	}
+	fmt.Fprintf(w, "\n.PHONY: %s\n", strings.Join(phony, " "))
+
+	for i, task := range tasks {
+		// With .ONESHELL and a POSIX shell, make strips leading whitespace
+		// and "@", "-", and "+" from recipe lines except the first one.
+		// Hence, the script is defined as a variable and passed to
+		// the interpreter through the env.
+		variable := makeScriptVariable + "_" + names[i]
+		fmt.Fprintf(w, "\ndefine %s\n", variable)
+		for _, line := range task.Lines {
+			fmt.Fprintf(w, "%s\n", makeEscape(line))
+		}
+		_, _ = w.WriteString("endef\n")
+		if len(opts.EnvFiles) > 0 {
+			// Scripts are exported only to their own recipes.
+			fmt.Fprintf(w, "unexport %s\n", variable)
+		}
+
+		_, _ = w.WriteString("\n")
+		writeComments(w, "# ", task.Description)
+		fmt.Fprintf(w, "%s: export %s = $(%s)\n", names[i], makeScriptVariable, variable)
+		fmt.Fprintf(w, "%s:\n", names[i])
+		// With .ONESHELL, "@" of the first line silences the whole recipe.
+		fmt.Fprintf(w, "\t@cd %s\n", strings.ReplaceAll(shell.Quote(task.Dir), "$", "$$"))
+		fmt.Fprintf(w, "\tprintf '%%s\\n' \"$$%s\" | %s\n", makeScriptVariable, strings.ReplaceAll(task.Interpreter, "$", "$$"))
+	}
+
+	for _, tag := range tags {
+		fmt.Fprintf(w, "\n# Runs tasks tagged %q.\n%s: %s\n", tag.tag, tag.name, strings.Join(tag.tasks, " "))
+	}
+
+	return nil
+}
+
+type taskfile struct {
+	Version string                  `yaml:"version"`
+	Dotenv  []string                `yaml:"dotenv,omitempty"`
+	Tasks   map[string]taskfileTask `yaml:"tasks"`
+}
+
+type taskfileTask struct {
+	Desc string `yaml:"desc,omitempty"`
+	Dir  string `yaml:"dir,omitempty"`
+	Cmds []any  `yaml:"cmds"`
+}
+
+func exportTaskfile(w *bytes.Buffer, tasks []ExportTask, opts ExportOptions) error {
+	names, err := exportNames(tasks, taskfileName)
+	if err != nil {
+		return err
+	}
+
+	tf := taskfile{
+		Version: "3",
+		Dotenv:  opts.EnvFiles,
+		Tasks:   make(map[string]taskfileTask, len(tasks)),
+	}
+
+	for i, task := range tasks {
+		script := strings.Join(exportScript(task)[1:], "\n")
+		tf.Tasks[names[i]] = taskfileTask{
+			Desc: firstLine(task.Description),
+			Dir:  task.Dir,
+			// Taskfile renders commands as Go templates.
+			Cmds: []any{strings.ReplaceAll(script, "{{", `{{"{{"}}`)},
+		}
+	}
+
+	for _, tag := range exportTags(tasks, names, taskfileName) {
+		cmds := make([]any, 0, len(tag.tasks))
+		for _, name := range tag.tasks {
+			cmds = append(cmds, map[string]string{"task": name})
+		}
+		tf.Tasks[tag.name] = taskfileTask{
+			Desc: fmt.Sprintf("Runs tasks tagged %q.", tag.tag),
+			Cmds: cmds,
+		}
+	}
+
+	fmt.Fprintf(w, "# %s\n\n", exportHeader)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(tf); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(encoder.Close())
+}
+
+func exportJust(w *bytes.Buffer, tasks []ExportTask, opts ExportOptions) error {
+	names, err := exportNames(tasks, justName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# %s\n", exportHeader)
+
+	switch len(opts.EnvFiles) {
+	case 0:
+	case 1:
+		fmt.Fprintf(w, "\nset dotenv-path := %s\n", justString(opts.EnvFiles[0]))
+	default:
+		return errors.New("just supports only one env file")
+	}
+
+	for i, task := range tasks {
+		_, _ = w.WriteString("\n")
+		writeComments(w, "# ", task.Description)
+		fmt.Fprintf(w, "%s:\n", names[i])
+		// Recipes with a shebang run as a single script.
+		_, _ = w.WriteString("    #!/usr/bin/env sh\n")
+		for _, line := range exportScript(task) {
+			if line == "" {
+				_, _ = w.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(line, "{{", "{{{{"))
+		}
+	}
+
+	for _, tag := range exportTags(tasks, names, justName) {
+		fmt.Fprintf(w, "\n# Runs tasks tagged %q.\n%s: %s\n", tag.tag, tag.name, strings.Join(tag.tasks, " "))
+	}
+
+	return nil
+}
+
+// exportScript returns a shell script which changes the directory
+// and passes the task's lines to the interpreter.
+func exportScript(task ExportTask) []string {
+	delimiter := heredocDelimiter
+	for slices.Contains(task.Lines, delimiter) {
+		delimiter += "_"
+	}
+
+	script := []string{
+		"cd " + shell.Quote(task.Dir),
+		fmt.Sprintf("%s <<'%s'", task.Interpreter, delimiter),
+	}
+	script = append(script, task.Lines...)
+	return append(script, delimiter)
+}
+
+type exportTag struct {
+	tag   string
+	name  string
+	tasks []string
+}
+
+// exportTags groups tasks by tags. Tags are sorted.
+func exportTags(tasks []ExportTask, names []string, sanitize func(string) string) []exportTag {
+	byTag := make(map[string][]string)
+	for i, task := range tasks {
+		for _, tag := range task.Tags {
+			byTag[tag] = append(byTag[tag], names[i])
+		}
+	}
+
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	result := make([]exportTag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, exportTag{
+			tag:   tag,
+			name:  sanitize("tag-" + tag),
+			tasks: byTag[tag],
+		})
+	}
+	return result
+}
+
+// exportNames sanitizes names of tasks making sure they are unique.
+func exportNames(tasks []ExportTask, sanitize func(string) string) ([]string, error) {
+	names := make([]string, 0, len(tasks))
+	seen := make(map[string]string, len(tasks))
+
+	for _, task := range tasks {
+		name := sanitize(task.Name)
+		if other, ok := seen[name]; ok {
+			return nil, errors.Errorf("tasks %q and %q have the same name %q in the exported file", other, task.Name, name)
+		}
+		seen[name] = task.Name
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+var (
+	makeInvalidChars     = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	taskfileInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.:-]+`)
+	justInvalidChars     = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// makeScriptVariable is the env variable containing
+// the script of a task exported to a Makefile.
+const makeScriptVariable = "RUNME_SCRIPT"
+
+var makeDirective = regexp.MustCompile(`^\s*(define|endef)\b`)
+
+// makeEscape escapes a line of a script defined with "define".
+// "$()" expands to an empty string and prevents make from treating
+// the line as a directive or joining it with the next line.
+func makeEscape(line string) string {
+	line = strings.ReplaceAll(line, "$", "$$")
+	if makeDirective.MatchString(line) {
+		line = "$()" + line
+	}
+	if strings.HasSuffix(line, `\`) {
+		line += "$()"
+	}
+	return line
+}
+
+func makeName(name string) string {
+	return makeInvalidChars.ReplaceAllString(name, "-")
+}
+
+// taskfileName keeps ":" which Taskfile uses for namespaces.
+func taskfileName(name string) string {
+	return taskfileInvalidChars.ReplaceAllString(name, "-")
+}
+
+func justName(name string) string {
+	name = justInvalidChars.ReplaceAllString(name, "-")
+	if name != "" && (name[0] == '-' || (name[0] >= '0' && name[0] <= '9')) {
+		name = "_" + name
+	}
+	return name
+}
+
+func writeComments(w *bytes.Buffer, prefix, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		_, _ = w.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func justString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,194 @@
+package tasks
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testExportTasks = []ExportTask{
+	{
+		Name:        "build",
+		Description: "Build the app.",
+		Dir:         ".",
+		Interpreter: "bash",
+		Lines:       []string{`echo "building $VERSION {{x}}"`},
+		Tags:        []string{"ci"},
+	},
+	{
+		Name:        "infra:test",
+		Dir:         "infra dir",
+		Interpreter: "python3",
+		Lines:       []string{"print('RUNME_EOF')", "RUNME_EOF"},
+		Tags:        []string{"ci"},
+	},
+}
+
+func TestExport(t *testing.T) {
+	opts := ExportOptions{EnvFiles: []string{".env"}}
+
+	t.Run("Make", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, ExportFormatMake, testExportTasks, opts))
+		assert.Equal(t, `# Code generated by runme tasks export. DO NOT EDIT.
+
+.ONESHELL:
+
+-include .env
+export
+
+.PHONY: build infra-test tag-ci
+
+define RUNME_SCRIPT_build
+echo "building $$VERSION {{x}}"
+endef
+unexport RUNME_SCRIPT_build
+
+# Build the app.
+build: export RUNME_SCRIPT = $(RUNME_SCRIPT_build)
+build:
+	@cd .
+	printf '%s\n' "$$RUNME_SCRIPT" | bash
+
+define RUNME_SCRIPT_infra-test
+print('RUNME_EOF')
+RUNME_EOF
+endef
+unexport RUNME_SCRIPT_infra-test
+
+infra-test: export RUNME_SCRIPT = $(RUNME_SCRIPT_infra-test)
+infra-test:
+	@cd 'infra dir'
+	printf '%s\n' "$$RUNME_SCRIPT" | python3
+
+# Runs tasks tagged "ci".
+tag-ci: build infra-test
+`, buf.String())
+	})
+
+	t.Run("MakeRecipePrefixes", func(t *testing.T) {
+		tasks := []ExportTask{{
+			Name:        "config",
+			Dir:         ".",
+			Interpreter: "sh",
+			Lines: []string{
+				"cat <<'EOF'",
+				"list:",
+				"  - item",
+				"+x",
+				"@silent",
+				"endef",
+				"  define x",
+				"EOF",
+				`echo 'a\`,
+				`b'`,
+			},
+		}}
+
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, ExportFormatMake, tasks, ExportOptions{}))
+		assert.Equal(t, `# Code generated by runme tasks export. DO NOT EDIT.
+
+.ONESHELL:
+
+.PHONY: config
+
+define RUNME_SCRIPT_config
+cat <<'EOF'
+list:
+  - item
++x
+@silent
+$()endef
+$()  define x
+EOF
+echo 'a\$()
+b'
+endef
+
+config: export RUNME_SCRIPT = $(RUNME_SCRIPT_config)
+config:
+	@cd .
+	printf '%s\n' "$$RUNME_SCRIPT" | sh
+`, buf.String())
+	})
+
+	t.Run("Taskfile", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, ExportFormatTaskfile, testExportTasks, opts))
+		assert.Equal(t, `# Code generated by runme tasks export. DO NOT EDIT.
+
+version: "3"
+dotenv:
+  - .env
+tasks:
+  build:
+    desc: Build the app.
+    dir: .
+    cmds:
+      - |-
+        bash <<'RUNME_EOF'
+        echo "building $VERSION {{"{{"}}x}}"
+        RUNME_EOF
+  infra:test:
+    dir: infra dir
+    cmds:
+      - |-
+        python3 <<'RUNME_EOF_'
+        print('RUNME_EOF')
+        RUNME_EOF
+        RUNME_EOF_
+  tag-ci:
+    desc: Runs tasks tagged "ci".
+    cmds:
+      - task: build
+      - task: infra:test
+`, buf.String())
+	})
+
+	t.Run("Just", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, ExportFormatJust, testExportTasks, opts))
+		assert.Equal(t, `# Code generated by runme tasks export. DO NOT EDIT.
+
+set dotenv-path := ".env"
+
+# Build the app.
+build:
+    #!/usr/bin/env sh
+    cd .
+    bash <<'RUNME_EOF'
+    echo "building $VERSION {{{{x}}"
+    RUNME_EOF
+
+infra-test:
+    #!/usr/bin/env sh
+    cd 'infra dir'
+    python3 <<'RUNME_EOF_'
+    print('RUNME_EOF')
+    RUNME_EOF
+    RUNME_EOF_
+
+# Runs tasks tagged "ci".
+tag-ci: build infra-test
+`, buf.String())
+	})
+
+	t.Run("DuplicateNames", func(t *testing.T) {
+		tasks := []ExportTask{{Name: "a:b"}, {Name: "a-b"}}
+		err := Export(&bytes.Buffer{}, ExportFormatMake, tasks, ExportOptions{})
+		require.ErrorContains(t, err, `tasks "a:b" and "a-b" have the same name "a-b"`)
+	})
+}
+
+func TestParseExportFormat(t *testing.T) {
+	f, err := ParseExportFormat("Taskfile")
+	require.NoError(t, err)
+	assert.Equal(t, ExportFormatTaskfile, f)
+	assert.Equal(t, "Taskfile.yml", f.FileName())
+
+	_, err = ParseExportFormat("gradle")
+	require.Error(t, err)
+}
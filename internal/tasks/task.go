@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"path/filepath"
+
+	"github.com/stateful/runme/v3/internal/command"
+	"github.com/stateful/runme/v3/pkg/project"
+)
+
+// Interpreter returns the program running the task. If the block
+// does not set it, it is inferred from the language like runme does.
+func Interpreter(task project.Task) string {
+	block := task.CodeBlock
+
+	if interpreter := block.Interpreter(); interpreter != "" {
+		return interpreter
+	}
+
+	candidates := command.InferInterpreterFromLanguage(block.Language())
+	if len(candidates) == 0 {
+		// Runme runs blocks without a known language as shell scripts.
+		candidates = command.InferInterpreterFromLanguage("sh")
+	}
+	return candidates[0]
+}
+
+// Dir returns the working directory of the task. It is the directory
+// of the document changed by the cwd of the frontmatter and the block.
+func Dir(task project.Task) string {
+	block := task.CodeBlock
+
+	cwd := filepath.Dir(task.DocumentPath)
+	if fm := block.Document().Frontmatter(); fm != nil && fm.Cwd != "" {
+		cwd = resolveDir(cwd, fm.Cwd)
+	}
+	if blockCwd := block.Cwd(); blockCwd != "" {
+		cwd = resolveDir(cwd, blockCwd)
+	}
+	return cwd
+}
+
+func resolveDir(base, dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(base, dir)
+}
@@ -0,0 +1,184 @@
+// Package telemetry sets up OpenTelemetry tracing and metrics of the server.
+//
+// Instrumented packages use the global tracer and meter providers,
+// which are no-op unless telemetry is enabled with [New].
+package telemetry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/stateful/runme/v3/internal/config"
+	"github.com/stateful/runme/v3/internal/version"
+)
+
+const serviceName = "runme"
+
+type Telemetry struct {
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+	metricsServer  *http.Server
+	metricsLis     net.Listener
+	logger         *zap.Logger
+}
+
+// New sets up the global tracer and meter providers according to cfg.
+// If metrics are enabled, they are served right away.
+func New(cfg *config.ConfigServerTelemetry, logger *zap.Logger) (_ *Telemetry, err error) {
+	t := &Telemetry{logger: logger.Named("Telemetry")}
+
+	defer func() {
+		if err != nil {
+			_ = t.Shutdown(context.Background())
+		}
+	}()
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version.BaseVersion()),
+		),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if tracing := cfg.Tracing; tracing != nil && tracing.Enabled {
+		if err := t.setupTracing(tracing, res); err != nil {
+			return nil, err
+		}
+	}
+
+	if metrics := cfg.Metrics; metrics != nil && metrics.Enabled {
+		if err := t.setupMetrics(metrics, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Telemetry) setupTracing(cfg *config.ConfigServerTelemetryTracing, res *resource.Resource) error {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure != nil && *cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	// The exporter connects lazily; it does not fail if the collector is not running.
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create trace exporter")
+	}
+
+	t.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(t.tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	t.logger.Info("exporting traces", zap.String("endpoint", cfg.Endpoint))
+
+	return nil
+}
+
+func (t *Telemetry) setupMetrics(cfg *config.ConfigServerTelemetryMetrics, res *resource.Resource) error {
+	registry := promclient.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+	if err != nil {
+		return errors.Wrap(err, "failed to create metrics exporter")
+	}
+
+	t.meterProvider = sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(t.meterProvider)
+
+	lis, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for metrics")
+	}
+	t.metricsLis = lis
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	t.metricsServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	t.logger.Info("serving metrics", zap.String("address", lis.Addr().String()))
+
+	go func() {
+		if err := t.metricsServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.logger.Error("failed to serve metrics", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// MetricsAddr returns the address of the metrics server
+// or an empty string if metrics are disabled.
+func (t *Telemetry) MetricsAddr() string {
+	if t.metricsLis == nil {
+		return ""
+	}
+	return t.metricsLis.Addr().String()
+}
+
+// Shutdown stops the metrics server and flushes pending spans.
+// The global providers set up by [New] are reset to no-op ones.
+func (t *Telemetry) Shutdown(ctx context.Context) (err error) {
+	if t.meterProvider != nil && otel.GetMeterProvider() == t.meterProvider {
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	}
+	if t.tracerProvider != nil && otel.GetTracerProvider() == t.tracerProvider {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}
+
+	if t.metricsServer != nil {
+		err = multierr.Append(err, errors.Wrap(t.metricsServer.Shutdown(ctx), "failed to stop metrics server"))
+	}
+	if t.meterProvider != nil {
+		err = multierr.Append(err, errors.Wrap(t.meterProvider.Shutdown(ctx), "failed to shut down meter provider"))
+	}
+	if t.tracerProvider != nil {
+		err = multierr.Append(err, errors.Wrap(t.tracerProvider.Shutdown(ctx), "failed to shut down tracer provider"))
+	}
+	return err
+}
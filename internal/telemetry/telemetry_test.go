@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+
+	"github.com/stateful/runme/v3/internal/config"
+)
+
+func TestTelemetryMetrics(t *testing.T) {
+	tel, err := New(
+		&config.ConfigServerTelemetry{
+			Metrics: &config.ConfigServerTelemetryMetrics{Enabled: true, Address: "127.0.0.1:0"},
+		},
+		zap.NewNop(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, tel.Shutdown(context.Background())) })
+
+	counter, err := otel.Meter("test").Int64Counter("runme.test.calls")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	resp, err := http.Get("http://" + tel.MetricsAddr() + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "runme_test_calls_total{")
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+func TestTelemetryShutdown(t *testing.T) {
+	tel, err := New(
+		&config.ConfigServerTelemetry{
+			Metrics: &config.ConfigServerTelemetryMetrics{Enabled: true, Address: "127.0.0.1:0"},
+			Tracing: &config.ConfigServerTelemetryTracing{Enabled: true, Endpoint: "127.0.0.1:0", SampleRatio: 1},
+		},
+		zap.NewNop(),
+	)
+	require.NoError(t, err)
+	assert.Same(t, tel.meterProvider, otel.GetMeterProvider())
+	assert.Same(t, tel.tracerProvider, otel.GetTracerProvider())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = tel.Shutdown(ctx)
+
+	assert.IsType(t, metricnoop.MeterProvider{}, otel.GetMeterProvider())
+	assert.IsType(t, tracenoop.TracerProvider{}, otel.GetTracerProvider())
+}
+
+func TestTelemetryDisabled(t *testing.T) {
+	tel, err := New(&config.ConfigServerTelemetry{}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Empty(t, tel.MetricsAddr())
+	assert.NoError(t, tel.Shutdown(context.Background()))
+}
@@ -0,0 +1,447 @@
+package tls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	caCertPEMFile = "ca.pem"
+	caKeyPEMFile  = "ca-key.pem"
+	// clientsFile keeps track of issued client certificates
+	// and whether they were revoked.
+	clientsFile = "clients.json"
+	// localClientCertPEMFile and localClientKeyPEMFile store the certificate
+	// of local clients which share the directory with the server.
+	localClientCertPEMFile = "local-client-cert.pem"
+	localClientKeyPEMFile  = "local-client-key.pem"
+
+	caValidity     = 365 * 24 * time.Hour
+	serverValidity = 30 * 24 * time.Hour
+	// DefaultClientCertTTL is the validity of client certificates
+	// issued by [CA.IssueClientCertificate] if not specified.
+	DefaultClientCertTTL = 30 * 24 * time.Hour
+	// rotateBefore is how long before the expiry certificates are rotated.
+	rotateBefore = 7 * 24 * time.Hour
+)
+
+// CA is a local certificate authority issuing server and client certificates.
+// It is stored next to the server certificate.
+type CA struct {
+	dir  string
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// LoadOrGenerateCA loads the CA from dir, or generates a new one
+// if it does not exist or is about to expire. Certificates issued
+// by the previous CA are no longer trusted after the CA is regenerated.
+func LoadOrGenerateCA(dir string, logger *zap.Logger) (*CA, error) {
+	ca, err := loadCA(dir)
+	switch {
+	case err == nil && nowFn().Add(rotateBefore).Before(ca.cert.NotAfter):
+		return ca, nil
+	case err == nil:
+		logger.Warn("CA certificate will expire soon; generating new CA", zap.Time("notAfter", ca.cert.NotAfter))
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Info("CA not found; generating new CA", zap.String("dir", dir))
+	default:
+		return nil, err
+	}
+	return generateCA(dir)
+}
+
+func loadCA(dir string) (*CA, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, caCertPEMFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, caKeyPEMFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load CA")
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported CA private key")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA")
+	}
+
+	return &CA{dir: dir, cert: cert, key: key}, nil
+}
+
+func generateCA(dir string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               newSubject("runme CA"),
+		NotBefore:             nowFn().Add(-time.Minute),
+		NotAfter:              nowFn().Add(caValidity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileWithDir(filepath.Join(dir, caKeyPEMFile), keyPEM, tlsFileMode); err != nil {
+		return nil, errors.Wrap(err, "failed to write CA private key")
+	}
+	if err := writeFileWithDir(filepath.Join(dir, caCertPEMFile), encodeCertificates(der), tlsFileMode); err != nil {
+		return nil, errors.Wrap(err, "failed to write CA")
+	}
+
+	return &CA{dir: dir, cert: cert, key: key}, nil
+}
+
+// Issued reports whether cert was issued by the CA.
+func (ca *CA) Issued(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(ca.cert) == nil
+}
+
+// Pool returns a cert pool containing the CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue creates a certificate signed by the CA. The returned certificate
+// contains the chain, i.e. the leaf followed by the CA. The chain is stored
+// in a single file so that it can be used both as a key pair and as
+// a root of trust, which is how clients use the server certificate.
+func (ca *CA) issue(template *x509.Certificate) (certPEM, keyPEM []byte, _ *x509.Certificate, _ error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+
+	template.SerialNumber, err = newSerialNumber()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template.NotBefore = nowFn().Add(-time.Minute)
+	// A certificate cannot outlive the CA.
+	if template.NotAfter.After(ca.cert.NotAfter) {
+		template.NotAfter = ca.cert.NotAfter
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.BasicConstraintsValid = true
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+
+	keyPEM, err = encodePrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return encodeCertificates(der, ca.cert.Raw), keyPEM, cert, nil
+}
+
+// issueServerCertificate issues a certificate for the server and writes it
+// to certFile and keyFile. Local clients use [CA.issueLocalClientCertificate].
+// With legacyClientAuth, the certificate can be used for client authentication
+// as well, for clients which have not migrated to the local client certificate.
+func (ca *CA) issueServerCertificate(certFile, keyFile string, legacyClientAuth bool) (tls.Certificate, error) {
+	extKeyUsage := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	if legacyClientAuth {
+		extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+
+	certPEM, keyPEM, _, err := ca.issue(&x509.Certificate{
+		Subject:     newSubject("runme"),
+		NotAfter:    nowFn().Add(serverValidity),
+		ExtKeyUsage: extKeyUsage,
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:    []string{"localhost"},
+	})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return writeKeyPair(certFile, keyFile, certPEM, keyPEM)
+}
+
+// issueLocalClientCertificate issues a client certificate for local clients,
+// which pass the server certificate to [LoadClientConfig], and writes it
+// to the CA directory. It is not listed by [ListClientCertificates].
+func (ca *CA) issueLocalClientCertificate() (tls.Certificate, error) {
+	certPEM, keyPEM, _, err := ca.issue(&x509.Certificate{
+		Subject:     newSubject("runme local client"),
+		NotAfter:    nowFn().Add(serverValidity),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return writeKeyPair(
+		filepath.Join(ca.dir, localClientCertPEMFile),
+		filepath.Join(ca.dir, localClientKeyPEMFile),
+		certPEM,
+		keyPEM,
+	)
+}
+
+func writeKeyPair(certFile, keyFile string, certPEM, keyPEM []byte) (tls.Certificate, error) {
+	if err := writeFileWithDir(keyFile, keyPEM, tlsFileMode); err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "failed to write private key")
+	}
+	if err := writeFileWithDir(certFile, certPEM, tlsFileMode); err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "failed to write certificate")
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, errors.WithStack(err)
+}
+
+// ClientCert describes a client certificate issued by the CA.
+type ClientCert struct {
+	Name     string    `json:"name"`
+	Serial   string    `json:"serial"`
+	NotAfter time.Time `json:"not_after"`
+	Revoked  bool      `json:"revoked,omitempty"`
+}
+
+// IssueClientCertificate issues a client certificate named name which is valid for ttl.
+// The certificate and private key are written to outDir as cert.pem and key.pem,
+// respectively, so that outDir can be passed to clients as the TLS directory.
+//
+// Issuing a certificate for an existing name does not revoke the previous
+// certificate. It allows clients to rotate certificates before they expire.
+func (ca *CA) IssueClientCertificate(name string, ttl time.Duration, outDir string) (ClientCert, error) {
+	if strings.TrimSpace(name) == "" {
+		return ClientCert{}, errors.New("client name is required")
+	}
+	if ttl <= 0 {
+		ttl = DefaultClientCertTTL
+	}
+
+	certPEM, keyPEM, cert, err := ca.issue(&x509.Certificate{
+		Subject:     newSubject(name),
+		NotAfter:    nowFn().Add(ttl),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return ClientCert{}, err
+	}
+
+	if err := os.MkdirAll(outDir, tlsDirMode); err != nil {
+		return ClientCert{}, errors.Wrap(err, "failed to create output dir")
+	}
+	if err := writeFileWithDir(filepath.Join(outDir, keyPEMFile), keyPEM, tlsFileMode); err != nil {
+		return ClientCert{}, errors.Wrap(err, "failed to write private key")
+	}
+	if err := writeFileWithDir(filepath.Join(outDir, certPEMFile), certPEM, tlsFileMode); err != nil {
+		return ClientCert{}, errors.Wrap(err, "failed to write certificate")
+	}
+
+	issued := ClientCert{
+		Name:     name,
+		Serial:   formatSerial(cert.SerialNumber),
+		NotAfter: cert.NotAfter,
+	}
+
+	err = updateClientCerts(ca.dir, func(certs []ClientCert) ([]ClientCert, error) {
+		return append(certs, issued), nil
+	})
+	return issued, err
+}
+
+// ListClientCertificates returns client certificates issued by the CA stored in dir.
+func ListClientCertificates(dir string) ([]ClientCert, error) {
+	return readClientCerts(dir)
+}
+
+// RevokeClientCertificate revokes client certificates issued by the CA
+// stored in dir, which name or serial number matches nameOrSerial.
+// The running server picks up the change on the next connection.
+func RevokeClientCertificate(dir, nameOrSerial string) (revoked []ClientCert, _ error) {
+	err := updateClientCerts(dir, func(certs []ClientCert) ([]ClientCert, error) {
+		for i, c := range certs {
+			if c.Revoked || (c.Name != nameOrSerial && !strings.EqualFold(c.Serial, nameOrSerial)) {
+				continue
+			}
+			certs[i].Revoked = true
+			revoked = append(revoked, certs[i])
+		}
+		if len(revoked) == 0 {
+			return nil, errors.Errorf("no valid client certificate matches %q", nameOrSerial)
+		}
+		return certs, nil
+	})
+	return revoked, err
+}
+
+var clientCertsMu sync.Mutex
+
+func readClientCerts(dir string) ([]ClientCert, error) {
+	data, err := os.ReadFile(filepath.Join(dir, clientsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var certs []ClientCert
+	if err := json.Unmarshal(data, &certs); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", clientsFile)
+	}
+	return certs, nil
+}
+
+func updateClientCerts(dir string, fn func([]ClientCert) ([]ClientCert, error)) error {
+	clientCertsMu.Lock()
+	defer clientCertsMu.Unlock()
+
+	certs, err := readClientCerts(dir)
+	if err != nil {
+		return err
+	}
+	certs, err = fn(certs)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(certs, func(i, j int) bool { return certs[i].Name < certs[j].Name })
+
+	data, err := json.MarshalIndent(certs, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileWithDir(filepath.Join(dir, clientsFile), data, tlsFileMode)
+}
+
+// clientCertList caches serial numbers of client certificates issued
+// with [CA.IssueClientCertificate]. It is reloaded when the file changes.
+type clientCertList struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	// issued maps serial numbers to whether the certificate was revoked.
+	issued map[string]bool
+}
+
+func newClientCertList(dir string) *clientCertList {
+	return &clientCertList{path: filepath.Join(dir, clientsFile)}
+}
+
+// Lookup reports whether cert was issued with [CA.IssueClientCertificate]
+// and whether it was revoked.
+func (l *clientCertList) Lookup(cert *x509.Certificate) (issued, revoked bool, _ error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, errors.WithStack(err)
+	}
+
+	if l.issued == nil || !info.ModTime().Equal(l.modTime) {
+		certs, err := readClientCerts(filepath.Dir(l.path))
+		if err != nil {
+			return false, false, err
+		}
+		l.issued = make(map[string]bool, len(certs))
+		for _, c := range certs {
+			l.issued[c.Serial] = c.Revoked
+		}
+		l.modTime = info.ModTime()
+	}
+
+	revoked, issued = l.issued[formatSerial(cert.SerialNumber)]
+	return issued, revoked, nil
+}
+
+func newSubject(commonName string) pkix.Name {
+	return pkix.Name{
+		CommonName:   commonName,
+		Organization: []string{"Stateful, Inc."},
+		Country:      []string{"US"},
+		Province:     []string{"California"},
+		Locality:     []string{"Berkeley"},
+	}
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial, errors.WithStack(err)
+}
+
+func formatSerial(serial *big.Int) string {
+	return serial.Text(16)
+}
+
+func encodeCertificates(ders ...[]byte) []byte {
+	buf := new(bytes.Buffer)
+	for _, der := range ders {
+		_ = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	return buf.Bytes()
+}
+
+func encodePrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
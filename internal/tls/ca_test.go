@@ -0,0 +1,171 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// handshake connects to a server using serverConfig with a client using clientConfig.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	t.Helper()
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = conn.Write([]byte("ok"))
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// In TLS 1.3, the client learns that the server
+	// rejected its certificate only after reading.
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadAll(conn)
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+
+	serverConfig, err := LoadOrGenerateConfigFromDir(dir, logger)
+	require.NoError(t, err)
+
+	ca, err := LoadOrGenerateCA(dir, logger)
+	require.NoError(t, err)
+
+	clientDir := filepath.Join(dir, "clients", "ci")
+	issued, err := ca.IssueClientCertificate("ci", time.Hour, clientDir)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", issued.Name)
+
+	clientConfig, err := LoadClientConfigFromDir(clientDir)
+	require.NoError(t, err)
+
+	t.Run("IssuedClientCert", func(t *testing.T) {
+		require.NoError(t, handshake(t, serverConfig, clientConfig))
+	})
+
+	t.Run("LocalClientCert", func(t *testing.T) {
+		localConfig, err := LoadClientConfigFromDir(dir)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(localConfig.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, leaf.ExtKeyUsage)
+		require.NoError(t, handshake(t, serverConfig, localConfig))
+
+		strictConfig, err := LoadOrGenerateConfigFromDir(dir, logger, WithIssuedClientCertsOnly())
+		require.NoError(t, err)
+		require.Error(t, handshake(t, strictConfig, localConfig))
+		require.NoError(t, handshake(t, strictConfig, clientConfig))
+	})
+
+	t.Run("ServerCertAsClientCert", func(t *testing.T) {
+		dir := t.TempDir()
+
+		// Legacy clients, like editor extensions, authenticate with the server
+		// certificate. It is deprecated, but accepted by default.
+		serverConfig, err := LoadOrGenerateConfigFromDir(dir, logger)
+		require.NoError(t, err)
+		legacyConfig, err := LoadClientConfigFromDir(dir)
+		require.NoError(t, err)
+		legacyConfig.Certificates = serverConfig.Certificates
+		require.NoError(t, handshake(t, serverConfig, legacyConfig))
+
+		// Without the local client certificate, Go clients fall back to the server certificate.
+		require.NoError(t, os.Remove(filepath.Join(dir, localClientCertPEMFile)))
+		fallbackConfig, err := LoadClientConfigFromDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, serverConfig.Certificates[0].Certificate[0], fallbackConfig.Certificates[0].Certificate[0])
+
+		// With issued client certificates only, the server certificate is reissued
+		// without client authentication and rejected as a client certificate.
+		strictConfig, err := LoadOrGenerateConfigFromDir(dir, logger, WithIssuedClientCertsOnly())
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(strictConfig.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, leaf.ExtKeyUsage)
+
+		require.Error(t, handshake(t, strictConfig, legacyConfig))
+		legacyConfig.Certificates = strictConfig.Certificates
+		require.Error(t, handshake(t, strictConfig, legacyConfig))
+	})
+
+	t.Run("UnknownCA", func(t *testing.T) {
+		otherDir := t.TempDir()
+		_, err := LoadOrGenerateConfigFromDir(otherDir, logger)
+		require.NoError(t, err)
+		otherConfig, err := LoadClientConfigFromDir(otherDir)
+		require.NoError(t, err)
+		otherConfig.RootCAs = clientConfig.RootCAs
+
+		require.Error(t, handshake(t, serverConfig, otherConfig))
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		revoked, err := RevokeClientCertificate(dir, issued.Serial)
+		require.NoError(t, err)
+		require.Len(t, revoked, 1)
+
+		require.Error(t, handshake(t, serverConfig, clientConfig))
+
+		_, err = RevokeClientCertificate(dir, "ci")
+		require.ErrorContains(t, err, `no valid client certificate matches "ci"`)
+
+		certs, err := ListClientCertificates(dir)
+		require.NoError(t, err)
+		require.Len(t, certs, 1)
+		assert.True(t, certs[0].Revoked)
+	})
+}
+
+func TestServerCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+
+	serverConfig, err := LoadOrGenerateConfigFromDir(dir, logger)
+	require.NoError(t, err)
+
+	config, err := serverConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	t.Cleanup(func() { nowFn = time.Now })
+	nowFn = func() time.Time {
+		return time.Now().AddDate(0, 0, 24)
+	}
+
+	config, err = serverConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.NotEqual(t, serverConfig.Certificates[0].Certificate[0], config.Certificates[0].Certificate[0])
+
+	// The rotated certificate is stored for clients.
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, certPEMFile), filepath.Join(dir, keyPEMFile))
+	require.NoError(t, err)
+	assert.Equal(t, config.Certificates[0].Certificate[0], cert.Certificate[0])
+
+	_, err = LoadClientConfigFromDir(dir)
+	require.NoError(t, err)
+}
@@ -1,18 +1,13 @@
 package tls
 
 import (
-	"bytes"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
 	"io/fs"
-	"math/big"
-	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -28,11 +23,28 @@ const (
 
 var nowFn = time.Now
 
+// LoadClientConfig loads the TLS configuration of a client. certFile and keyFile
+// are either a client certificate issued with [CA.IssueClientCertificate]
+// or the server certificate. Instead of the server certificate, the local
+// client certificate stored next to it is used.
 func LoadClientConfig(certFile, keyFile string) (*tls.Config, error) {
 	cert, certPool, err := loadCertificateAndCertPool(certFile, keyFile)
 	if err != nil {
 		return nil, err
 	}
+
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
+		dir := filepath.Dir(certFile)
+		localCert, err := tls.LoadX509KeyPair(filepath.Join(dir, localClientCertPEMFile), filepath.Join(dir, localClientKeyPEMFile))
+		switch {
+		case err == nil:
+			cert = localCert
+		// Servers of older versions do not issue the local client certificate.
+		case !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth):
+			return nil, errors.Wrap(err, "failed to load local client certificate")
+		}
+	}
+
 	return &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		RootCAs:      certPool,
@@ -84,131 +96,237 @@ func loadCertificateAndCertPool(certFile, keyFile string) (cert tls.Certificate,
 	return cert, pool, nil
 }
 
+// ServerOption configures the TLS configuration returned by [LoadOrGenerateConfig].
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	issuedClientCertsOnly bool
+}
+
+// WithIssuedClientCertsOnly rejects clients authenticating with
+// the local client certificate or the server certificate. Only certificates
+// issued with [CA.IssueClientCertificate] are accepted.
+//
+// Without it, any process which can read the TLS directory can authenticate.
+func WithIssuedClientCertsOnly() ServerOption {
+	return func(o *serverOptions) { o.issuedClientCertsOnly = true }
+}
+
 // LoadOrGenerateConfig loads the TLS configuration from the given files,
 // or generates a new one if the files do not exist.
-func LoadOrGenerateConfig(certFile, keyFile string, logger *zap.Logger) (*tls.Config, error) {
-	config, err := LoadServerConfig(certFile, keyFile)
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+//
+// The server certificate is issued by a local CA stored in the same directory
+// as certFile. It is rotated before it expires. Clients are required
+// to present a client certificate issued by the CA which has not been revoked.
+//
+// Unless [WithIssuedClientCertsOnly] is used, a local client certificate
+// is issued for clients which share the directory with the server.
+// Moreover, the server certificate can still authenticate clients which
+// have not migrated to the local client certificate. It is deprecated and
+// logged as a warning; it will be rejected in a future version.
+func LoadOrGenerateConfig(certFile, keyFile string, logger *zap.Logger, opts ...ServerOption) (*tls.Config, error) {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dir := filepath.Dir(certFile)
+
+	ca, err := LoadOrGenerateCA(dir, logger)
+	if err != nil {
 		return nil, err
 	}
 
-	if config != nil {
-		ttl, err := validateTLSConfig(config)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+
+	legacyClientAuth := !options.issuedClientCertsOnly
+
+	if err == nil {
+		var ttl time.Duration
+		ttl, err = validateServerCertificate(cert, ca, legacyClientAuth)
 		if err == nil {
 			logger.Info("certificate is valid", zap.Duration("ttl", ttl), zap.String("certFile", certFile), zap.String("keyFile", keyFile))
-			return config, nil
+		} else {
+			logger.Warn("failed to validate certificate; generating new certificate", zap.Error(err))
 		}
-		logger.Warn("failed to validate TLS config; generating new cartificate", zap.Error(err))
 	} else {
 		logger.Info("certificate not found; generating new certificate")
 	}
 
-	return generateCertificate(certFile, keyFile)
-}
-
-// Deprecated: use LoadOrGenerateConfig.
-func LoadOrGenerateConfigFromDir(dir string, logger *zap.Logger) (*tls.Config, error) {
-	info, err := os.Stat(dir)
 	if err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return nil, errors.WithStack(err)
-		}
-		if err := os.MkdirAll(dir, tlsDirMode); err != nil {
-			return nil, errors.Wrap(err, "failed to create dir")
-		}
-	} else {
-		if !info.IsDir() {
-			return nil, errors.New("provided path is not a directory")
-		}
-
-		if err := os.Chmod(dir, tlsDirMode); err != nil {
-			return nil, errors.Wrap(err, "failed to change the directory mod")
+		cert, err = ca.issueServerCertificate(certFile, keyFile, legacyClientAuth)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return LoadOrGenerateConfig(filepath.Join(dir, certPEMFile), filepath.Join(dir, keyPEMFile), logger)
-}
+	rotator := &certRotator{
+		ca:               ca,
+		cert:             cert,
+		certFile:         certFile,
+		keyFile:          keyFile,
+		legacyClientAuth: legacyClientAuth,
+		logger:           logger,
+	}
 
-func generateCertificate(certFile, keyFile string) (*tls.Config, error) {
-	privKey, err := rsa.GenerateKey(rand.Reader, 4096)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	if !options.issuedClientCertsOnly {
+		if err := rotator.loadOrIssueLocalClientCertificate(); err != nil {
+			return nil, err
+		}
 	}
 
-	ca := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName:   "runme",
-			Organization: []string{"Stateful, Inc."},
-			Country:      []string{"US"},
-			Province:     []string{"California"},
-			Locality:     []string{"Berkeley"},
-		},
-		NotBefore:             nowFn(),
-		NotAfter:              nowFn().AddDate(0, 0, 30),
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		BasicConstraintsValid: true,
-		SignatureAlgorithm:    x509.SHA256WithRSA,
-		IPAddresses: []net.IP{
-			net.IPv4(127, 0, 0, 1),
-		},
-		DNSNames: []string{
-			"localhost",
+	clientCerts := newClientCertList(dir)
+	// Serial numbers of server certificates used by clients, to warn once per certificate.
+	var legacyClients sync.Map
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.Pool(),
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+		VerifyPeerCertificate: func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+				return errors.New("client certificate not verified")
+			}
+			leaf := verifiedChains[0][0]
+
+			if slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
+				if options.issuedClientCertsOnly {
+					return errors.New("server certificate cannot be used by clients; issue a client certificate")
+				}
+				if _, warned := legacyClients.LoadOrStore(leaf.SerialNumber.String(), true); !warned {
+					logger.Warn(
+						"client authenticated with the server certificate; this is deprecated, use the local client certificate instead",
+						zap.String("serial", formatSerial(leaf.SerialNumber)),
+					)
+				}
+				return nil
+			}
+
+			issued, revoked, err := clientCerts.Lookup(leaf)
+			if err != nil {
+				return err
+			}
+			if options.issuedClientCertsOnly && !issued {
+				return errors.Errorf("client certificate %s was not issued for a client", formatSerial(leaf.SerialNumber))
+			}
+			if revoked {
+				return errors.Errorf("client certificate %s was revoked", formatSerial(leaf.SerialNumber))
+			}
+			return nil
 		},
 	}
 
-	certificateBytes, err := x509.CreateCertificate(rand.Reader, ca, ca, &privKey.PublicKey, privKey)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	// GetConfigForClient is called for every connection which allows
+	// to rotate the server certificate without restarting the server.
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, rotated, err := rotator.Current()
+		if err != nil || !rotated {
+			return nil, err
+		}
+		c := config.Clone()
+		c.Certificates = []tls.Certificate{cert}
+		c.GetConfigForClient = nil
+		return c, nil
 	}
 
-	caPEM := new(bytes.Buffer)
-	if err := pem.Encode(caPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certificateBytes,
-	}); err != nil {
-		return nil, errors.WithStack(err)
-	}
+	return config, nil
+}
 
-	if err := writeFileWithDir(certFile, caPEM.Bytes(), tlsFileMode); err != nil {
-		return nil, errors.Wrap(err, "failed to write CA")
-	}
+// certRotator issues a new server certificate before the current one expires.
+type certRotator struct {
+	ca       *CA
+	certFile string
+	keyFile  string
+	// legacyClientAuth is true if the server certificate
+	// can be used for client authentication.
+	legacyClientAuth bool
+	logger           *zap.Logger
+
+	mu      sync.Mutex
+	cert    tls.Certificate
+	rotated bool
+	// localClientCert is empty if local clients are not allowed.
+	localClientCert tls.Certificate
+}
 
-	privKeyPEM := new(bytes.Buffer)
-	if err := pem.Encode(privKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privKey),
-	}); err != nil {
-		return nil, errors.WithStack(err)
+// Current returns the current certificate and whether it differs
+// from the one the rotator was created with.
+func (r *certRotator) Current() (tls.Certificate, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := validateServerCertificate(r.cert, r.ca, r.legacyClientAuth); err != nil {
+		r.logger.Info("rotating certificate", zap.Error(err))
+
+		cert, err := r.ca.issueServerCertificate(r.certFile, r.keyFile, r.legacyClientAuth)
+		if err != nil {
+			return r.cert, r.rotated, err
+		}
+		r.cert, r.rotated = cert, true
 	}
 
-	if err := writeFileWithDir(keyFile, privKeyPEM.Bytes(), tlsFileMode); err != nil {
-		return nil, errors.Wrap(err, "failed to write private key")
+	if len(r.localClientCert.Certificate) > 0 {
+		if _, err := validateCertificate(r.localClientCert, r.ca); err != nil {
+			r.logger.Info("rotating local client certificate", zap.Error(err))
+
+			cert, err := r.ca.issueLocalClientCertificate()
+			if err != nil {
+				return r.cert, r.rotated, err
+			}
+			r.localClientCert = cert
+		}
 	}
 
-	certPool := x509.NewCertPool()
+	return r.cert, r.rotated, nil
+}
 
-	// TODO: can probably use `AddCert` here
-	if !certPool.AppendCertsFromPEM(caPEM.Bytes()) {
-		return nil, errors.New("failed to add certificate to certificate pool")
+func (r *certRotator) loadOrIssueLocalClientCertificate() error {
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(r.ca.dir, localClientCertPEMFile),
+		filepath.Join(r.ca.dir, localClientKeyPEMFile),
+	)
+	if err == nil {
+		_, err = validateCertificate(cert, r.ca)
 	}
+	if err != nil {
+		r.logger.Info("issuing local client certificate", zap.Error(err))
 
-	tlsCA := tls.Certificate{
-		Certificate: [][]byte{certificateBytes},
-		PrivateKey:  privKey,
-		Leaf:        ca,
+		cert, err = r.ca.issueLocalClientCertificate()
+		if err != nil {
+			return err
+		}
 	}
 
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{tlsCA},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    certPool,
-		MinVersion:   tls.VersionTLS12,
+	r.localClientCert = cert
+	return nil
+}
+
+// Deprecated: use LoadOrGenerateConfig.
+func LoadOrGenerateConfigFromDir(dir string, logger *zap.Logger, opts ...ServerOption) (*tls.Config, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.WithStack(err)
+		}
+		if err := os.MkdirAll(dir, tlsDirMode); err != nil {
+			return nil, errors.Wrap(err, "failed to create dir")
+		}
+	} else {
+		if !info.IsDir() {
+			return nil, errors.New("provided path is not a directory")
+		}
+
+		if err := os.Chmod(dir, tlsDirMode); err != nil {
+			return nil, errors.Wrap(err, "failed to change the directory mod")
+		}
 	}
 
-	return tlsConfig, nil
+	return LoadOrGenerateConfig(filepath.Join(dir, certPEMFile), filepath.Join(dir, keyPEMFile), logger, opts...)
 }
 
 func writeFileWithDir(nameWithPath string, data []byte, perm fs.FileMode) error {
@@ -222,19 +340,44 @@ func writeFileWithDir(nameWithPath string, data []byte, perm fs.FileMode) error
 	return errors.Wrap(err, "failed to write file")
 }
 
-func validateTLSConfig(config *tls.Config) (ttl time.Duration, _ error) {
-	if len(config.Certificates) < 1 || len(config.Certificates[0].Certificate) < 1 {
+// validateServerCertificate validates cert like [validateCertificate].
+// Additionally, it rejects server certificates which can authenticate
+// clients unless legacyClientAuth is true, and the other way around.
+func validateServerCertificate(cert tls.Certificate, ca *CA, legacyClientAuth bool) (time.Duration, error) {
+	ttl, err := validateCertificate(cert, ca)
+	if err != nil {
+		return ttl, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return ttl, errors.Wrap(err, "failed to parse certificate")
+	}
+	switch clientAuth := slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth); {
+	case clientAuth && !legacyClientAuth:
+		return ttl, errors.New("certificate can be used for client authentication")
+	case !clientAuth && legacyClientAuth:
+		return ttl, errors.New("certificate cannot be used for client authentication by legacy clients")
+	}
+	return ttl, nil
+}
+
+func validateCertificate(cert tls.Certificate, ca *CA) (ttl time.Duration, _ error) {
+	if len(cert.Certificate) < 1 {
 		return ttl, errors.New("invalid TLS certificate")
 	}
 
-	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
 	if err != nil {
 		return ttl, errors.Wrap(err, "failed to parse certificate")
 	}
 
-	if nowFn().AddDate(0, 0, 7).After(cert.NotAfter) {
+	if !ca.Issued(leaf) {
+		return ttl, errors.New("certificate was not issued by the CA")
+	}
+
+	if nowFn().Add(rotateBefore).After(leaf.NotAfter) {
 		return ttl, errors.New("certificate will expire soon")
 	}
 
-	return cert.NotAfter.Sub(nowFn()), nil
+	return leaf.NotAfter.Sub(nowFn()), nil
 }
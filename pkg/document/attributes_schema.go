@@ -0,0 +1,197 @@
+package document
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AttributeType describes the type of value a known cell attribute accepts.
+type AttributeType int
+
+const (
+	AttributeTypeString AttributeType = iota + 1
+	AttributeTypeBool
+	AttributeTypeInt
+	AttributeTypeEnum
+)
+
+func (t AttributeType) String() string {
+	switch t {
+	case AttributeTypeString:
+		return "string"
+	case AttributeTypeBool:
+		return "bool"
+	case AttributeTypeInt:
+		return "int"
+	case AttributeTypeEnum:
+		return "enum"
+	default:
+		return "unknown"
+	}
+}
+
+// AttributeSpec describes a known cell attribute.
+// More: https://docs.runme.dev/configuration/cell-level
+type AttributeSpec struct {
+	Name        string
+	Type        AttributeType
+	Description string
+	// Values lists accepted values for [AttributeTypeEnum].
+	Values []string
+	// Deprecated, if not empty, contains a hint what to use instead.
+	Deprecated string
+}
+
+// Validate checks if the value is valid for the attribute.
+func (s AttributeSpec) Validate(value string) error {
+	switch s.Type {
+	case AttributeTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid value %q for %q: expected a boolean", value, s.Name)
+		}
+	case AttributeTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid value %q for %q: expected an integer", value, s.Name)
+		}
+	case AttributeTypeEnum:
+		if !slices.Contains(s.Values, strings.ToLower(value)) {
+			return fmt.Errorf("invalid value %q for %q: expected one of %s", value, s.Name, strings.Join(s.Values, ", "))
+		}
+	}
+	return nil
+}
+
+var knownAttributes = []AttributeSpec{
+	{Name: "background", Type: AttributeTypeBool, Description: "Run the cell as a background process."},
+	{Name: "category", Type: AttributeTypeString, Description: "Comma-separated list of categories.", Deprecated: "use \"tag\" instead"},
+	{Name: "closeTerminalOnSuccess", Type: AttributeTypeBool, Description: "Close the terminal when the cell exits successfully."},
+	{Name: "cwd", Type: AttributeTypeString, Description: "Working directory of the cell relative to the document."},
+	{Name: "excludeFromRunAll", Type: AttributeTypeBool, Description: "Skip the cell when running all cells."},
+	{Name: "id", Type: AttributeTypeString, Description: "Lifecycle identity of the cell."},
+	{Name: "ignore", Type: AttributeTypeBool, Description: "Treat the code block as markdown."},
+	{Name: "interactive", Type: AttributeTypeBool, Description: "Run the cell in an interactive terminal."},
+	{Name: "interpreter", Type: AttributeTypeString, Description: "Program or shebang used to execute the cell."},
+	{Name: "mimeType", Type: AttributeTypeString, Description: "MIME type used to render the output."},
+	{Name: "name", Type: AttributeTypeString, Description: "Name of the cell used to reference it as a task."},
+	{Name: "openTerminalOnError", Type: AttributeTypeBool, Description: "Open the terminal when the cell fails."},
+	{
+		Name:        "promptEnv",
+		Type:        AttributeTypeEnum,
+		Description: "Controls whether to prompt for environment variables.",
+		Values:      []string{"auto", "always", "never", "true", "false", "yes", "no", "1", "0"},
+	},
+	{Name: "tag", Type: AttributeTypeString, Description: "Comma-separated list of tags."},
+	{Name: "terminalRows", Type: AttributeTypeInt, Description: "Number of rows of the terminal."},
+	{Name: "transform", Type: AttributeTypeBool, Description: "Set to false to treat the code block as markdown."},
+}
+
+// KnownAttributes returns specs of all known cell attributes sorted by name.
+func KnownAttributes() []AttributeSpec {
+	return slices.Clone(knownAttributes)
+}
+
+// LookupAttribute returns a spec of a known cell attribute.
+func LookupAttribute(name string) (AttributeSpec, bool) {
+	idx := slices.IndexFunc(knownAttributes, func(s AttributeSpec) bool { return s.Name == name })
+	if idx == -1 {
+		return AttributeSpec{}, false
+	}
+	return knownAttributes[idx], true
+}
+
+// isPrivateAttribute returns true for attributes that are managed
+// by clients and should not be validated.
+func isPrivateAttribute(name string) bool {
+	return name == "index" || strings.HasPrefix(name, "_") || strings.HasPrefix(name, "runme.dev/")
+}
+
+// AttributeProblem describes a single problem found in [Attributes].
+type AttributeProblem struct {
+	Key      string
+	Severity DiagnosticSeverity
+	Message  string
+}
+
+// ValidateAttributes validates attributes against the known schema.
+// Unknown attributes are reported as warnings, invalid values as errors.
+func ValidateAttributes(attr *Attributes) (result []AttributeProblem) {
+	if attr == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attr.Items))
+	for k := range attr.Items {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if isPrivateAttribute(key) {
+			continue
+		}
+
+		spec, ok := LookupAttribute(key)
+		if !ok {
+			msg := fmt.Sprintf("unknown attribute %q", key)
+			if suggestion := suggestAttribute(key); suggestion != "" {
+				msg += fmt.Sprintf("; did you mean %q?", suggestion)
+			}
+			result = append(result, AttributeProblem{Key: key, Severity: DiagnosticSeverityWarning, Message: msg})
+			continue
+		}
+
+		if spec.Deprecated != "" {
+			result = append(result, AttributeProblem{
+				Key:      key,
+				Severity: DiagnosticSeverityWarning,
+				Message:  fmt.Sprintf("attribute %q is deprecated; %s", key, spec.Deprecated),
+			})
+		}
+
+		if err := spec.Validate(attr.Items[key]); err != nil {
+			result = append(result, AttributeProblem{Key: key, Severity: DiagnosticSeverityError, Message: err.Error()})
+		}
+	}
+
+	return result
+}
+
+// suggestAttribute returns the closest known attribute name
+// or an empty string if none is close enough.
+func suggestAttribute(name string) string {
+	best, bestDist := "", -1
+	for _, spec := range knownAttributes {
+		dist := levenshtein(strings.ToLower(name), strings.ToLower(spec.Name))
+		if bestDist == -1 || dist < bestDist {
+			best, bestDist = spec.Name, dist
+		}
+	}
+	// Allow roughly one typo per four characters.
+	if bestDist > max(1, len(best)/4) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
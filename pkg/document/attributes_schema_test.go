@@ -0,0 +1,125 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		items    map[string]string
+		expected []AttributeProblem
+	}{
+		{
+			name:  "Valid",
+			items: map[string]string{"name": "echo", "interactive": "false", "terminalRows": "10", "promptEnv": "never"},
+		},
+		{
+			name:  "PrivateIgnored",
+			items: map[string]string{"runme.dev/id": "123", "_private": "x", "index": "1"},
+		},
+		{
+			name:  "Typo",
+			items: map[string]string{"interactve": "false"},
+			expected: []AttributeProblem{
+				{Key: "interactve", Severity: DiagnosticSeverityWarning, Message: `unknown attribute "interactve"; did you mean "interactive"?`},
+			},
+		},
+		{
+			name:  "Unknown",
+			items: map[string]string{"foo": "bar"},
+			expected: []AttributeProblem{
+				{Key: "foo", Severity: DiagnosticSeverityWarning, Message: `unknown attribute "foo"`},
+			},
+		},
+		{
+			name:  "InvalidValues",
+			items: map[string]string{"background": "maybe", "terminalRows": "many", "promptEnv": "sometimes"},
+			expected: []AttributeProblem{
+				{Key: "background", Severity: DiagnosticSeverityError, Message: `invalid value "maybe" for "background": expected a boolean`},
+				{Key: "promptEnv", Severity: DiagnosticSeverityError, Message: `invalid value "sometimes" for "promptEnv": expected one of auto, always, never, true, false, yes, no, 1, 0`},
+				{Key: "terminalRows", Severity: DiagnosticSeverityError, Message: `invalid value "many" for "terminalRows": expected an integer`},
+			},
+		},
+		{
+			name:  "Deprecated",
+			items: map[string]string{"category": "a,b"},
+			expected: []AttributeProblem{
+				{Key: "category", Severity: DiagnosticSeverityWarning, Message: `attribute "category" is deprecated; use "tag" instead`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := ValidateAttributes(NewAttributes(tc.items))
+			assert.Equal(t, tc.expected, problems)
+		})
+	}
+}
+
+func TestDocument_Validate(t *testing.T) {
+	data := []byte(`---
+shell: bash
+---
+
+# Examples
+
+` + "```" + `sh { name=echo interactve=false }
+echo "Hello"
+` + "```" + `
+
+1. Item
+
+   ` + "```" + `sh {"background":"maybe","name":"nested"}
+   sleep 1
+   ` + "```" + `
+`)
+
+	doc := New(data, allIdentityResolver)
+	diagnostics, err := doc.Validate()
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+
+	assert.Equal(t, DiagnosticSeverityWarning, diagnostics[0].Severity)
+	assert.Equal(t, "interactve", diagnostics[0].Attribute)
+	assert.Equal(t, "echo", diagnostics[0].CellName)
+	assert.Equal(t, Position{Line: 7, Column: 19, Offset: 51}, diagnostics[0].Start)
+	assert.Equal(t, Position{Line: 7, Column: 29, Offset: 61}, diagnostics[0].End)
+	assert.Equal(t, "interactve", string(data[diagnostics[0].Start.Offset:diagnostics[0].End.Offset]))
+
+	assert.Equal(t, DiagnosticSeverityError, diagnostics[1].Severity)
+	assert.Equal(t, "background", diagnostics[1].Attribute)
+	assert.Equal(t, "nested", diagnostics[1].CellName)
+	assert.Equal(t, 13, diagnostics[1].Start.Line)
+	assert.Equal(t, 12, diagnostics[1].Start.Column)
+	assert.Equal(t, "13:12: error: invalid value \"maybe\" for \"background\": expected a boolean", diagnostics[1].String())
+}
+
+func TestDocument_ValidateFrontmatter(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		data := []byte("---\nshell: [bash\n---\n\n# Hello\n")
+		diagnostics, err := New(data, allIdentityResolver).Validate()
+		require.NoError(t, err)
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, DiagnosticSeverityError, diagnostics[0].Severity)
+		assert.Equal(t, Position{Line: 1, Column: 1, Offset: 0}, diagnostics[0].Start)
+		assert.Equal(t, Position{Line: 3, Column: 4, Offset: 20}, diagnostics[0].End)
+	})
+
+	t.Run("InvalidValues", func(t *testing.T) {
+		data := []byte("---\ncategory: a\nterminalRows: many\n---\n\n# Hello\n")
+		diagnostics, err := New(data, allIdentityResolver).Validate()
+		require.NoError(t, err)
+		require.Len(t, diagnostics, 2)
+		assert.Equal(t, "category", diagnostics[0].Attribute)
+		assert.Equal(t, DiagnosticSeverityWarning, diagnostics[0].Severity)
+		assert.Equal(t, Position{Line: 2, Column: 1, Offset: 4}, diagnostics[0].Start)
+		assert.Equal(t, "terminalRows", diagnostics[1].Attribute)
+		assert.Equal(t, DiagnosticSeverityError, diagnostics[1].Severity)
+		assert.Equal(t, Position{Line: 3, Column: 1, Offset: 16}, diagnostics[1].Start)
+	})
+}
@@ -148,6 +148,11 @@ func (b *CodeBlock) Ignored() (ignored bool) {
 		ignored = true
 	}
 
+	// expected outputs of sibling blocks are not runnable
+	if _, ok := b.Attributes().Items["expect"]; ok {
+		ignored = true
+	}
+
 	return
 }
 
@@ -317,6 +322,16 @@ func (b *CodeBlock) TextRange() (textRange TextRange) {
 	return
 }
 
+// info returns the info string of the fenced code block
+// and its offset relative to the document's content.
+func (b *CodeBlock) info() (int, []byte) {
+	if b.inner.Info == nil || b.document == nil {
+		return 0, nil
+	}
+	segment := b.inner.Info.Segment
+	return segment.Start, segment.Value(b.document.content)
+}
+
 func (b *CodeBlock) Unwrap() ast.Node {
 	return b.inner
 }
@@ -50,6 +50,18 @@ func TestBlock_Ignored(t *testing.T) {
 		}
 		assert.True(t, block.Ignored())
 	})
+
+	t.Run("ExpectAttribute", func(t *testing.T) {
+		block := &CodeBlock{
+			attributes: NewAttributesWithFormat(
+				map[string]string{
+					"expect": "stdout",
+				},
+				"json",
+			),
+		}
+		assert.True(t, block.Ignored())
+	})
 }
 
 func TestBlock_Mermaid(t *testing.T) {
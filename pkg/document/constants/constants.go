@@ -1,3 +1,12 @@
 package constants
 
 const FinalLineBreaksKey = "finalLineBreaks"
+
+// BlockStartKey is ast.Node's attribute storing a start offset of
+// the block. OpeningFenceKey and ClosingFenceKey store text.Segment
+// of fences of fenced code blocks.
+const (
+	BlockStartKey   = "runme.dev/blockStart"
+	OpeningFenceKey = "runme.dev/openingFence"
+	ClosingFenceKey = "runme.dev/closingFence"
+)
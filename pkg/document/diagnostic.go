@@ -0,0 +1,165 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+type DiagnosticSeverity int
+
+const (
+	DiagnosticSeverityError DiagnosticSeverity = iota + 1
+	DiagnosticSeverityWarning
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case DiagnosticSeverityError:
+		return "error"
+	case DiagnosticSeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Position is a location in the source. Line and Column are 1-based,
+// Column is counted in bytes.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Diagnostic is a problem found in a document.
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	Message  string
+	// Attribute is a name of the cell attribute the diagnostic refers to, if any.
+	Attribute string
+	// CellName is a name of the cell the diagnostic refers to, if any.
+	CellName string
+	Start    Position
+	End      Position
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Start, d.Severity, d.Message)
+}
+
+// Validate parses the document and validates the frontmatter
+// and attributes of all code blocks.
+func (d *Document) Validate() ([]Diagnostic, error) {
+	node, err := d.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	result := d.validateFrontmatter()
+
+	for _, block := range CollectCodeBlocks(node) {
+		problems := ValidateAttributes(block.Attributes())
+		if len(problems) == 0 {
+			continue
+		}
+
+		infoStart, info := block.info()
+
+		for _, problem := range problems {
+			start, end := infoStart, infoStart+len(info)
+			if idx, length := findAttributeKey(info, problem.Key); idx >= 0 {
+				start, end = infoStart+idx, infoStart+idx+length
+			}
+
+			// Offsets in blocks are relative to the content, but the positions
+			// must be relative to the source which includes frontmatter.
+			result = append(result, Diagnostic{
+				Severity:  problem.Severity,
+				Message:   problem.Message,
+				Attribute: problem.Key,
+				CellName:  block.Name(),
+				Start:     positionAt(d.source, start+d.contentOffset),
+				End:       positionAt(d.source, end+d.contentOffset),
+			})
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b Diagnostic) int {
+		return a.Start.Offset - b.Start.Offset
+	})
+
+	return result, nil
+}
+
+func (d *Document) validateFrontmatter() (result []Diagnostic) {
+	raw := d.FrontmatterRaw()
+	span, ok := d.FrontmatterSpan()
+	if !ok {
+		return nil
+	}
+
+	rawStart, rawEnd := span.Start.Offset, span.End.Offset
+
+	frontmatter, err := d.FrontmatterWithError()
+	if err != nil {
+		return append(result, Diagnostic{
+			Severity: DiagnosticSeverityError,
+			Message:  err.Error(),
+			Start:    positionAt(d.source, rawStart),
+			End:      positionAt(d.source, rawEnd),
+		})
+	}
+
+	keyDiagnostic := func(severity DiagnosticSeverity, key, msg string) Diagnostic {
+		start, end := rawStart, rawEnd
+		if idx, length := findAttributeKey(raw, key); idx >= 0 {
+			start, end = rawStart+idx, rawStart+idx+length
+		}
+		return Diagnostic{
+			Severity:  severity,
+			Message:   msg,
+			Attribute: key,
+			Start:     positionAt(d.source, start),
+			End:       positionAt(d.source, end),
+		}
+	}
+
+	if frontmatter.Category != "" {
+		result = append(result, keyDiagnostic(DiagnosticSeverityWarning, "category", `frontmatter "category" is deprecated; use "tag" instead`))
+	}
+
+	if rows := frontmatter.TerminalRows; rows != "" {
+		if _, err := strconv.Atoi(rows); err != nil {
+			result = append(result, keyDiagnostic(DiagnosticSeverityError, "terminalRows", fmt.Sprintf("invalid value %q for \"terminalRows\": expected an integer", rows)))
+		}
+	}
+
+	return result
+}
+
+// findAttributeKey finds a position of the attribute key in the info string.
+// It returns -1 if the key is not found.
+func findAttributeKey(info []byte, key string) (int, int) {
+	re := regexp.MustCompile(`(^|[\s{,])"?(` + regexp.QuoteMeta(key) + `)"?\s*[=:\s}]`)
+	loc := re.FindSubmatchIndex(info)
+	if loc == nil {
+		return -1, 0
+	}
+	return loc[4], loc[5] - loc[4]
+}
+
+// positionAt converts an offset in the source to [Position].
+func positionAt(source []byte, offset int) Position {
+	offset = max(0, min(offset, len(source)))
+	before := source[:offset]
+	line := bytes.Count(before, []byte{'\n'}) + 1
+	column := offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return Position{Line: line, Column: column, Offset: offset}
+}
@@ -6,7 +6,6 @@ import (
 	"sync"
 
 	"github.com/pkg/errors"
-	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
@@ -31,6 +30,7 @@ type Document struct {
 	rootASTNode             ast.Node
 	rootNode                *Node
 	frontmatterRaw          []byte
+	frontmatterOffset       int
 	content                 []byte // raw data after frontmatter
 	contentOffset           int
 	trailingLineBreaksCount int
@@ -48,12 +48,13 @@ func New(source []byte, identityResolver identityResolver) *Document {
 			namesCounter: map[string]int{},
 			cache:        map[interface{}]string{},
 		},
-		parser:               goldmark.DefaultParser(),
+		parser:               newParser(),
 		renderer:             defaultRenderer,
 		onceParse:            sync.Once{},
 		onceSplitSource:      sync.Once{},
 		onceParseFrontmatter: sync.Once{},
 		contentOffset:        -1,
+		frontmatterOffset:    -1,
 	}
 }
 
@@ -109,6 +110,7 @@ func (d *Document) splitAndParse() error {
 }
 
 func (d *Document) FrontmatterRaw() []byte {
+	d.splitSource()
 	return d.frontmatterRaw
 }
 
@@ -125,6 +127,7 @@ func (d *Document) splitSource() {
 			switch item.Type() {
 			case parsedItemFrontmatter:
 				d.frontmatterRaw = item.Value(d.source)
+				d.frontmatterOffset = item.start
 			case parsedItemContent:
 				d.content = item.Value(d.source)
 				d.contentOffset = item.start
@@ -37,6 +37,50 @@ type TextRange struct {
 	End   int `json:"end"`
 }
 
+// Position is a location in the markdown source.
+// Line and Column are 1-based, Column is counted in bytes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Span is a range in the markdown source. End is exclusive.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// CellSpans are locations of the code cell parts in the markdown source,
+// including frontmatter. Missing parts, like fences of unfenced code blocks, are nil.
+type CellSpans struct {
+	Fence        *Span `json:"fence,omitempty"`
+	Info         *Span `json:"info,omitempty"`
+	Attributes   *Span `json:"attributes,omitempty"`
+	Content      *Span `json:"content,omitempty"`
+	ClosingFence *Span `json:"closingFence,omitempty"`
+}
+
+func newCellSpans(spans document.CodeBlockSpans) *CellSpans {
+	convert := func(s document.Span) *Span {
+		if s.IsZero() {
+			return nil
+		}
+		return &Span{
+			Start: Position(s.Start),
+			End:   Position(s.End),
+		}
+	}
+
+	return &CellSpans{
+		Fence:        convert(spans.Fence),
+		Info:         convert(spans.Info),
+		Attributes:   convert(spans.Attributes),
+		Content:      convert(spans.Content),
+		ClosingFence: convert(spans.ClosingFence),
+	}
+}
+
 // Cell resembles NotebookCellData from VS Code.
 // https://github.com/microsoft/vscode/blob/085c409898bbc89c83409f6a394e73130b932add/src/vscode-dts/vscode.d.ts#L13715
 type Cell struct {
@@ -46,7 +90,11 @@ type Cell struct {
 	Metadata         map[string]string     `json:"metadata,omitempty"`
 	Outputs          []*CellOutput         `json:"outputs,omitempty"`
 	TextRange        *TextRange            `json:"textRange,omitempty"`
+	Spans            *CellSpans            `json:"spans,omitempty"`
 	ExecutionSummary *CellExecutionSummary `json:"executionSummary,omitempty"`
+
+	// block is the block the cell was deserialized from.
+	block document.Block
 }
 
 type CellExecutionSummary struct {
@@ -129,6 +177,7 @@ func toCellsRec(
 					*cells = append(*cells, &Cell{
 						Kind:  MarkupKind,
 						Value: fmtValue(block.Value()),
+						block: block,
 					})
 				} else {
 					for _, listItemNode := range child.Children() {
@@ -141,6 +190,7 @@ func toCellsRec(
 							*cells = append(*cells, &Cell{
 								Kind:  MarkupKind,
 								Value: fmtValue(listItemNode.Item().Value()),
+								block: listItemNode.Item(),
 							})
 						}
 					}
@@ -156,6 +206,7 @@ func toCellsRec(
 					*cells = append(*cells, &Cell{
 						Kind:  MarkupKind,
 						Value: fmtValue(block.Value()),
+						block: block,
 					})
 				}
 			}
@@ -169,6 +220,7 @@ func toCellsRec(
 				*cells = append(*cells, &Cell{
 					Kind:  MarkupKind,
 					Value: fmtValue(block.Value()),
+					block: block,
 				})
 				break
 			}
@@ -204,6 +256,8 @@ func toCellsRec(
 					Start: textRange.Start + doc.ContentOffset(),
 					End:   textRange.End + doc.ContentOffset(),
 				},
+				Spans: newCellSpans(block.Spans()),
+				block: block,
 			})
 
 		case *document.MarkdownBlock:
@@ -249,6 +303,7 @@ func toCellsRec(
 				Kind:     MarkupKind,
 				Value:    fmtValue(value),
 				Metadata: metadata,
+				block:    block,
 			})
 		}
 	}
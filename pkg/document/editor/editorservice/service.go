@@ -5,7 +5,13 @@ import (
 	"encoding/base64"
 	"strings"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	parserv1 "github.com/stateful/runme/v3/pkg/api/gen/proto/go/runme/parser/v1"
 	"github.com/stateful/runme/v3/pkg/document"
@@ -13,6 +19,8 @@ import (
 	"github.com/stateful/runme/v3/pkg/document/identity"
 )
 
+var tracer = otel.Tracer("github.com/stateful/runme/v3/pkg/document/editor/editorservice")
+
 type parserServiceServer struct {
 	parserv1.UnimplementedParserServiceServer
 
@@ -23,16 +31,23 @@ func NewParserServiceServer(logger *zap.Logger) parserv1.ParserServiceServer {
 	return &parserServiceServer{logger: logger}
 }
 
-func (s *parserServiceServer) Deserialize(_ context.Context, req *parserv1.DeserializeRequest) (*parserv1.DeserializeResponse, error) {
+func (s *parserServiceServer) Deserialize(ctx context.Context, req *parserv1.DeserializeRequest) (*parserv1.DeserializeResponse, error) {
 	s.logger.Info("Deserialize", zap.ByteString("source", req.Source[:min(len(req.Source), 64)]))
 
+	_, span := tracer.Start(ctx, "Deserialize", trace.WithAttributes(attribute.Int("runme.document.size", len(req.Source))))
+	defer span.End()
+
 	identityResolver := identity.NewResolver(fromProtoDeserializeReqOptionsToLifecycleIdentity(req.Options))
 	notebook, err := editor.Deserialize(req.Source, editor.Options{LoggerInstance: s.logger, IdentityResolver: identityResolver, Reset: false})
 	if err != nil {
 		s.logger.Info("failed to call Deserialize", zap.Error(err))
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("runme.document.cells", len(notebook.Cells)))
+
 	cells := make([]*parserv1.Cell, 0, len(notebook.Cells))
 	for _, cell := range notebook.Cells {
 		var tr *parserv1.TextRange
@@ -50,6 +65,7 @@ func (s *parserServiceServer) Deserialize(_ context.Context, req *parserv1.Deser
 			LanguageId: cell.LanguageID,
 			Metadata:   cell.Metadata,
 			TextRange:  tr,
+			Spans:      toProtoCellSpans(cell.Spans),
 		})
 	}
 
@@ -151,6 +167,110 @@ func (s *parserServiceServer) Serialize(_ context.Context, req *parserv1.Seriali
 	return &parserv1.SerializeResponse{Result: data}, nil
 }
 
+func (s *parserServiceServer) Validate(_ context.Context, req *parserv1.ValidateRequest) (*parserv1.ValidateResponse, error) {
+	s.logger.Info("Validate", zap.ByteString("source", req.Source[:min(len(req.Source), 64)]))
+
+	doc := document.New(req.Source, identity.NewResolver(identity.UnspecifiedLifecycleIdentity))
+	diagnostics, err := doc.Validate()
+	if err != nil {
+		s.logger.Info("failed to call Validate", zap.Error(err))
+		return nil, err
+	}
+
+	result := make([]*parserv1.Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		result = append(result, &parserv1.Diagnostic{
+			Severity:  parserv1.DiagnosticSeverity(d.Severity),
+			Message:   d.Message,
+			Attribute: d.Attribute,
+			CellName:  d.CellName,
+			Start:     toProtoPosition(d.Start),
+			End:       toProtoPosition(d.End),
+		})
+	}
+
+	return &parserv1.ValidateResponse{Diagnostics: result}, nil
+}
+
+func (s *parserServiceServer) ApplyEdits(_ context.Context, req *parserv1.ApplyEditsRequest) (*parserv1.ApplyEditsResponse, error) {
+	s.logger.Info("ApplyEdits", zap.Int("edits", len(req.Edits)))
+
+	edits := make([]editor.CellEdit, 0, len(req.Edits))
+	for _, edit := range req.Edits {
+		var cell *editor.Cell
+		if c := edit.Cell; c != nil {
+			cell = &editor.Cell{
+				Kind:       editor.CellKind(c.Kind),
+				Value:      c.Value,
+				LanguageID: c.LanguageId,
+				Metadata:   c.Metadata,
+			}
+		}
+
+		edits = append(edits, editor.CellEdit{
+			Kind:       editor.CellEditKind(edit.Kind),
+			Index:      int(edit.Index),
+			ToIndex:    int(edit.ToIndex),
+			Cell:       cell,
+			Value:      edit.Value,
+			Attributes: edit.Attributes,
+		})
+	}
+
+	identityResolver := identity.NewResolver(fromProtoDeserializeReqOptionsToLifecycleIdentity(req.Options))
+	textEdits, err := editor.ApplyEdits(req.Source, edits, editor.Options{LoggerInstance: s.logger, IdentityResolver: identityResolver})
+	if err != nil {
+		s.logger.Info("failed to call ApplyEdits", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result := make([]*parserv1.TextEdit, 0, len(textEdits))
+	for _, edit := range textEdits {
+		result = append(result, &parserv1.TextEdit{
+			Start:   uint32(edit.Start),
+			End:     uint32(edit.End),
+			NewText: edit.NewText,
+		})
+	}
+
+	return &parserv1.ApplyEditsResponse{
+		Edits:  result,
+		Result: editor.ApplyTextEdits(req.Source, textEdits),
+	}, nil
+}
+
+func toProtoCellSpans(spans *editor.CellSpans) *parserv1.CellSpans {
+	if spans == nil {
+		return nil
+	}
+
+	convert := func(s *editor.Span) *parserv1.SourceSpan {
+		if s == nil {
+			return nil
+		}
+		return &parserv1.SourceSpan{
+			Start: toProtoPosition(document.Position(s.Start)),
+			End:   toProtoPosition(document.Position(s.End)),
+		}
+	}
+
+	return &parserv1.CellSpans{
+		Fence:        convert(spans.Fence),
+		Info:         convert(spans.Info),
+		Attributes:   convert(spans.Attributes),
+		Content:      convert(spans.Content),
+		ClosingFence: convert(spans.ClosingFence),
+	}
+}
+
+func toProtoPosition(p document.Position) *parserv1.Position {
+	return &parserv1.Position{
+		Line:   uint32(p.Line),
+		Column: uint32(p.Column),
+		Offset: uint32(p.Offset),
+	}
+}
+
 func (*parserServiceServer) serializeCellExecutionSummary(cell *parserv1.Cell, options *parserv1.SerializeRequestOptions) *editor.CellExecutionSummary {
 	if options == nil || options.Outputs == nil || !options.Outputs.GetSummary() {
 		return nil
@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -459,6 +461,73 @@ func Test_parserServiceServer_Outputs(t *testing.T) {
 	})
 }
 
+func Test_parserServiceServer_Validate(t *testing.T) {
+	content := strings.Join([]string{
+		"# Validate",
+		"",
+		"```sh { name=foo interactve=false terminalRows=ten }",
+		`echo "Foo"`,
+		"```",
+	}, "\n")
+
+	resp, err := client.Validate(context.Background(), &parserv1.ValidateRequest{Source: []byte(content)})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Diagnostics, 2)
+
+	assert.Equal(t, parserv1.DiagnosticSeverity_DIAGNOSTIC_SEVERITY_WARNING, resp.Diagnostics[0].Severity)
+	assert.Equal(t, "interactve", resp.Diagnostics[0].Attribute)
+	assert.Equal(t, "foo", resp.Diagnostics[0].CellName)
+	assert.Equal(t, uint32(3), resp.Diagnostics[0].Start.Line)
+	assert.Equal(t, uint32(18), resp.Diagnostics[0].Start.Column)
+
+	assert.Equal(t, parserv1.DiagnosticSeverity_DIAGNOSTIC_SEVERITY_ERROR, resp.Diagnostics[1].Severity)
+	assert.Equal(t, "terminalRows", resp.Diagnostics[1].Attribute)
+}
+
+func Test_parserServiceServer_Spans(t *testing.T) {
+	resp, err := deserialize(client, documentWithFrontmatter, parserv1.RunmeIdentity_RUNME_IDENTITY_UNSPECIFIED)
+	assert.NoError(t, err)
+
+	cell := resp.Notebook.Cells[1]
+	assert.Equal(t, parserv1.CellKind_CELL_KIND_CODE, cell.Kind)
+
+	spans := cell.Spans
+	assert.Equal(t, &parserv1.Position{Line: 9, Column: 1, Offset: 58}, spans.Fence.Start)
+	assert.Equal(t, &parserv1.Position{Line: 9, Column: 26, Offset: 83}, spans.Fence.End)
+	assert.Equal(t, uint32(7), spans.Attributes.Start.Column)
+	assert.Equal(t, &parserv1.Position{Line: 10, Column: 1, Offset: 84}, spans.Content.Start)
+	assert.Equal(t, cell.TextRange.Start, spans.Content.Start.Offset)
+	assert.Equal(t, uint32(11), spans.ClosingFence.Start.Line)
+}
+
+func Test_parserServiceServer_ApplyEdits(t *testing.T) {
+	resp, err := client.ApplyEdits(context.Background(), &parserv1.ApplyEditsRequest{
+		Source: []byte(documentWithFrontmatter),
+		Edits: []*parserv1.CellEdit{
+			{Kind: parserv1.CellEditKind_CELL_EDIT_KIND_UPDATE_VALUE, Index: 1, Value: `echo "Updated"`},
+			{Kind: parserv1.CellEditKind_CELL_EDIT_KIND_DELETE, Index: 3},
+			{
+				Kind:  parserv1.CellEditKind_CELL_EDIT_KIND_INSERT,
+				Index: 0,
+				Cell:  &parserv1.Cell{Kind: parserv1.CellKind_CELL_KIND_MARKUP, Value: "Intro"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Edits, 3)
+
+	expected := strings.Replace(documentWithFrontmatter, `echo "Foo"`, `echo "Updated"`, 1)
+	expected = strings.Replace(expected, "```sh { \"name\": \"bar\" }\necho \"Bar\"\n```\n", "", 1)
+	expected = strings.Replace(expected, "# H1", "Intro\n\n# H1", 1)
+	assert.Equal(t, expected, string(resp.Result))
+
+	_, err = client.ApplyEdits(context.Background(), &parserv1.ApplyEditsRequest{
+		Source: []byte(documentWithFrontmatter),
+		Edits:  []*parserv1.CellEdit{{Kind: parserv1.CellEditKind_CELL_EDIT_KIND_DELETE, Index: 100}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func deserialize(client parserv1.ParserServiceClient, content string, idt parserv1.RunmeIdentity) (*parserv1.DeserializeResponse, error) {
 	return client.Deserialize(
 		context.Background(),
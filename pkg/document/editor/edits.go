@@ -0,0 +1,531 @@
+package editor
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/pkg/document"
+)
+
+type CellEditKind int
+
+const (
+	// InsertCellEdit inserts Cell at Index.
+	InsertCellEdit CellEditKind = iota + 1
+	// UpdateCellValueEdit replaces the value of the cell at Index with Value.
+	UpdateCellValueEdit
+	// UpdateCellAttributesEdit replaces attributes of the code cell at Index with Attributes.
+	UpdateCellAttributesEdit
+	// DeleteCellEdit deletes the cell at Index.
+	DeleteCellEdit
+	// MoveCellEdit moves the cell at Index to ToIndex.
+	// ToIndex is the position after the cell is removed.
+	MoveCellEdit
+)
+
+// CellEdit is an operation on cells of a notebook. Index refers to the cells
+// as they are after applying all preceding edits. Initially, cells are the same
+// as returned by [Deserialize].
+type CellEdit struct {
+	Kind       CellEditKind
+	Index      int
+	ToIndex    int
+	Cell       *Cell
+	Value      string
+	Attributes map[string]string
+}
+
+// TextEdit replaces the source between Start and End offsets with NewText.
+// Offsets refer to the original source.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// editItem is a cell in the notebook being edited. It is either a cell
+// from the original source along with edits within its range, or a new cell.
+type editItem struct {
+	orig  int // index of the original cell or -1 for new cells
+	cell  *Cell
+	value *TextEdit
+	attrs *TextEdit
+}
+
+// ApplyEdits applies cell edits to the source and returns text edits
+// against the source. Regions of the source not affected by the cell edits
+// are left byte-identical, unlike using [Serialize] which re-renders
+// the whole document.
+func ApplyEdits(source []byte, edits []CellEdit, opts Options) ([]TextEdit, error) {
+	doc := document.New(source, opts.IdentityResolver)
+	node, err := doc.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &sourceEditor{source: source}
+
+	cells := toCells(doc, node, doc.Content())
+	for i, cell := range cells {
+		start := doc.BlockStart(cell.block.Unwrap())
+		if start < 0 {
+			return nil, errors.Errorf("failed to find position of cell %d", i)
+		}
+		e.starts = append(e.starts, start)
+		e.items = append(e.items, &editItem{orig: i, cell: cell})
+	}
+	for i := range e.starts {
+		next := len(source)
+		if i+1 < len(e.starts) {
+			next = e.starts[i+1]
+		}
+		e.ends = append(e.ends, len(bytes.TrimRight(source[:next], " \t\r\n")))
+	}
+
+	for idx, edit := range edits {
+		if err := e.apply(edit); err != nil {
+			return nil, errors.Wrapf(err, "failed to apply edit %d", idx)
+		}
+	}
+
+	return e.textEdits()
+}
+
+type sourceEditor struct {
+	source []byte
+	// starts and ends are ranges of the original cells in the source.
+	starts []int
+	ends   []int
+	items  []*editItem
+}
+
+func (e *sourceEditor) item(idx int) (*editItem, error) {
+	if idx < 0 || idx >= len(e.items) {
+		return nil, errors.Errorf("cell index %d out of range", idx)
+	}
+	return e.items[idx], nil
+}
+
+func (e *sourceEditor) apply(edit CellEdit) error {
+	switch edit.Kind {
+	case InsertCellEdit:
+		if edit.Cell == nil {
+			return errors.New("missing cell to insert")
+		}
+		if edit.Index < 0 || edit.Index > len(e.items) {
+			return errors.Errorf("cell index %d out of range", edit.Index)
+		}
+		cell := *edit.Cell
+		e.items = slices.Insert(e.items, edit.Index, &editItem{orig: -1, cell: &cell})
+
+	case UpdateCellValueEdit:
+		item, err := e.item(edit.Index)
+		if err != nil {
+			return err
+		}
+		if item.orig < 0 {
+			item.cell.Value = edit.Value
+			return nil
+		}
+		textEdit, err := e.valueEdit(item, edit.Value)
+		if err != nil {
+			return err
+		}
+		item.value = textEdit
+
+	case UpdateCellAttributesEdit:
+		item, err := e.item(edit.Index)
+		if err != nil {
+			return err
+		}
+		if item.cell.Kind != CodeKind {
+			return errors.Errorf("cell %d is not a code cell", edit.Index)
+		}
+		if item.orig < 0 {
+			metadata := make(map[string]string)
+			for k, v := range item.cell.Metadata {
+				if strings.HasPrefix(k, InternalAttributePrefix) {
+					metadata[k] = v
+				}
+			}
+			for k, v := range edit.Attributes {
+				metadata[k] = v
+			}
+			item.cell.Metadata = metadata
+			return nil
+		}
+		textEdit, err := e.attributesEdit(item, edit.Attributes)
+		if err != nil {
+			return err
+		}
+		item.attrs = textEdit
+
+	case DeleteCellEdit:
+		if _, err := e.item(edit.Index); err != nil {
+			return err
+		}
+		e.items = slices.Delete(e.items, edit.Index, edit.Index+1)
+
+	case MoveCellEdit:
+		item, err := e.item(edit.Index)
+		if err != nil {
+			return err
+		}
+		e.items = slices.Delete(e.items, edit.Index, edit.Index+1)
+		if edit.ToIndex < 0 || edit.ToIndex > len(e.items) {
+			return errors.Errorf("cell index %d out of range", edit.ToIndex)
+		}
+		e.items = slices.Insert(e.items, edit.ToIndex, item)
+
+	default:
+		return errors.Errorf("unknown edit kind %d", edit.Kind)
+	}
+
+	return nil
+}
+
+// linePrefix returns the text between the beginning of the line
+// and the offset, for example, indentation of a list item.
+func (e *sourceEditor) linePrefix(offset int) string {
+	lineStart := bytes.LastIndexByte(e.source[:offset], '\n') + 1
+	return string(e.source[lineStart:offset])
+}
+
+func (e *sourceEditor) valueEdit(item *editItem, value string) (*TextEdit, error) {
+	start, end := e.starts[item.orig], e.ends[item.orig]
+
+	block, ok := item.cell.block.(*document.CodeBlock)
+	if !ok || item.cell.Kind != CodeKind {
+		// Markup cells are replaced as a whole.
+		return &TextEdit{Start: start, End: end, NewText: strings.TrimRight(value, "\n")}, nil
+	}
+
+	spans := block.Spans()
+	content, closing := spans.Content, spans.ClosingFence
+	lines := strings.Split(strings.TrimRight(value, "\n"), "\n")
+
+	switch {
+	case value == "" && content.Start != content.End && !closing.IsZero():
+		// Remove content lines entirely.
+		lineStart := content.Start.Offset - len(e.linePrefix(content.Start.Offset))
+		closingLineStart := closing.Start.Offset - len(e.linePrefix(closing.Start.Offset))
+		return &TextEdit{Start: lineStart, End: closingLineStart}, nil
+	case content.Start == content.End && !closing.IsZero():
+		if value == "" {
+			return nil, nil
+		}
+		// Empty code block; insert new lines before the closing fence.
+		prefix := e.linePrefix(closing.Start.Offset)
+		closingLineStart := closing.Start.Offset - len(prefix)
+		return &TextEdit{
+			Start:   closingLineStart,
+			End:     closingLineStart,
+			NewText: indentLines(lines, prefix, true) + "\n",
+		}, nil
+	default:
+		return &TextEdit{
+			Start:   content.Start.Offset,
+			End:     content.End.Offset,
+			NewText: indentLines(lines, e.linePrefix(content.Start.Offset), false),
+		}, nil
+	}
+}
+
+// indentLines joins lines prefixing them with the prefix, for example,
+// indentation of a list item or a blockquote marker. Empty lines get
+// the prefix without trailing whitespace. The first line is not
+// prefixed unless indentFirst is true.
+func indentLines(lines []string, prefix string, indentFirst bool) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			_ = b.WriteByte('\n')
+		}
+		if i > 0 || indentFirst {
+			if line == "" {
+				_, _ = b.WriteString(strings.TrimRight(prefix, " \t"))
+			} else {
+				_, _ = b.WriteString(prefix)
+			}
+		}
+		_, _ = b.WriteString(line)
+	}
+	return b.String()
+}
+
+func (e *sourceEditor) attributesEdit(item *editItem, attributes map[string]string) (*TextEdit, error) {
+	block := item.cell.block.(*document.CodeBlock)
+	if !block.FencedEncoding() {
+		return nil, errors.New("cannot set attributes of an unfenced code block")
+	}
+
+	metadata := make(map[string]string, len(attributes)+1)
+	for k, v := range attributes {
+		metadata[k] = v
+	}
+	metadata[PrefixAttributeName(InternalAttributePrefix, "format")] = block.Attributes().Format
+
+	var buf bytes.Buffer
+	if err := serializeFencedCodeAttributes(&buf, &Cell{Metadata: metadata}); err != nil {
+		return nil, err
+	}
+
+	spans := block.Spans()
+
+	if attrs := spans.Attributes; !attrs.IsZero() {
+		// Replace attributes including the preceding whitespace
+		// because the serialized attributes start with a space.
+		start := attrs.Start.Offset
+		for start > spans.Fence.Start.Offset && (e.source[start-1] == ' ' || e.source[start-1] == '\t') {
+			start--
+		}
+		return &TextEdit{Start: start, End: attrs.End.Offset, NewText: buf.String()}, nil
+	}
+
+	end := spans.Fence.End.Offset
+	if !spans.Info.IsZero() {
+		end = spans.Info.End.Offset
+	}
+	return &TextEdit{Start: end, End: end, NewText: buf.String()}, nil
+}
+
+// text returns the text of the item. For original cells,
+// it is the original source with edits applied.
+func (e *sourceEditor) text(item *editItem) (string, error) {
+	if item.orig < 0 {
+		value, err := serializeCells([]*Cell{item.cell}, false)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(value), "\n"), nil
+	}
+
+	start, end := e.starts[item.orig], e.ends[item.orig]
+
+	var b strings.Builder
+	pos := start
+	for _, edit := range item.textEdits() {
+		_, _ = b.Write(e.source[pos:edit.Start])
+		_, _ = b.WriteString(edit.NewText)
+		pos = edit.End
+	}
+	_, _ = b.Write(e.source[pos:end])
+	return b.String(), nil
+}
+
+func (item *editItem) textEdits() (result []TextEdit) {
+	for _, edit := range []*TextEdit{item.value, item.attrs} {
+		if edit != nil {
+			result = append(result, *edit)
+		}
+	}
+	slices.SortFunc(result, func(a, b TextEdit) int { return a.Start - b.Start })
+	return result
+}
+
+func (e *sourceEditor) separator(orig int) string {
+	return string(e.source[e.ends[orig]:e.starts[orig+1]])
+}
+
+func (e *sourceEditor) textEdits() ([]TextEdit, error) {
+	n := len(e.starts)
+
+	if n == 0 {
+		return e.textEditsEmpty()
+	}
+
+	anchors := e.anchors()
+
+	var result []TextEdit
+
+	// Original cells which kept their relative order are anchors.
+	// Only their inner edits are emitted. Regions between anchors
+	// are replaced if cells were inserted, deleted, or moved.
+	prev, itemIdx := -1, 0
+	for _, next := range append(anchors, n) {
+		var texts []string
+		for ; itemIdx < len(e.items) && (next == n || e.items[itemIdx].orig != next); itemIdx++ {
+			text, err := e.text(e.items[itemIdx])
+			if err != nil {
+				return nil, err
+			}
+			texts = append(texts, text)
+		}
+		itemIdx++
+
+		if len(texts) > 0 || next-prev > 1 {
+			result = append(result, e.gapEdit(prev, next, texts))
+		}
+
+		if next < n {
+			for _, edit := range e.items[itemIdx-1].textEdits() {
+				result = append(result, minimizeTextEdit(e.source, edit))
+			}
+		}
+		prev = next
+	}
+
+	result = slices.DeleteFunc(result, func(edit TextEdit) bool {
+		return edit.Start == edit.End && edit.NewText == ""
+	})
+	slices.SortStableFunc(result, func(a, b TextEdit) int { return a.Start - b.Start })
+
+	return result, nil
+}
+
+// gapEdit replaces the region between the prev and next original cells
+// with the texts. prev is -1 for the beginning and next is the number of
+// original cells for the end of the document.
+func (e *sourceEditor) gapEdit(prev, next int, texts []string) TextEdit {
+	n := len(e.starts)
+	joined := strings.Join(texts, "\n\n")
+
+	var start, end int
+	var newText string
+
+	switch {
+	case prev < 0 && next == n:
+		start, end = e.starts[0], e.ends[n-1]
+		newText = joined
+	case prev < 0:
+		start, end = e.starts[0], e.starts[next]
+		if len(texts) > 0 {
+			sep := "\n\n"
+			if next > 0 {
+				sep = e.separator(next - 1)
+			}
+			newText = joined + sep
+		}
+	case next == n:
+		start, end = e.ends[prev], e.ends[n-1]
+		if len(texts) > 0 {
+			sep := "\n\n"
+			if prev < n-1 {
+				sep = e.separator(prev)
+			}
+			newText = sep + joined
+		}
+	default:
+		start, end = e.ends[prev], e.starts[next]
+		newText = e.separator(prev)
+		if len(texts) > 0 {
+			newText += joined + e.separator(next-1)
+		}
+	}
+
+	return minimizeTextEdit(e.source, TextEdit{Start: start, End: end, NewText: newText})
+}
+
+func (e *sourceEditor) textEditsEmpty() ([]TextEdit, error) {
+	var texts []string
+	for _, item := range e.items {
+		text, err := e.text(item)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	end := len(e.source)
+	start := len(bytes.TrimRight(e.source, " \t\r\n"))
+
+	var prefix string
+	if start > 0 {
+		prefix = "\n\n"
+	}
+
+	edit := TextEdit{Start: start, End: end, NewText: prefix + strings.Join(texts, "\n\n") + "\n"}
+	return []TextEdit{minimizeTextEdit(e.source, edit)}, nil
+}
+
+// anchors returns original indices of items which form
+// the longest increasing subsequence.
+func (e *sourceEditor) anchors() []int {
+	var origs []int
+	for _, item := range e.items {
+		if item.orig >= 0 {
+			origs = append(origs, item.orig)
+		}
+	}
+	if len(origs) == 0 {
+		return nil
+	}
+
+	// Patience sorting with predecessors to reconstruct the sequence.
+	tails := []int{}
+	prev := make([]int, len(origs))
+	for i, v := range origs {
+		pos, _ := slices.BinarySearchFunc(tails, v, func(idx, target int) int { return origs[idx] - target })
+		if pos > 0 {
+			prev[i] = tails[pos-1]
+		} else {
+			prev[i] = -1
+		}
+		if pos == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[pos] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = origs[k]
+	}
+	return result
+}
+
+// minimizeTextEdit narrows the edit by skipping
+// the common prefix and suffix of the old and new text.
+func minimizeTextEdit(source []byte, edit TextEdit) TextEdit {
+	old := source[edit.Start:edit.End]
+	newText := []byte(edit.NewText)
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(newText) && old[prefix] == newText[prefix] {
+		prefix++
+	}
+	// Do not split multi-byte characters; the new text must be valid UTF-8.
+	for prefix > 0 && (!isRuneBoundary(old, prefix) || !isRuneBoundary(newText, prefix)) {
+		prefix--
+	}
+
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(newText)-prefix && old[len(old)-1-suffix] == newText[len(newText)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && (!isRuneBoundary(old, len(old)-suffix) || !isRuneBoundary(newText, len(newText)-suffix)) {
+		suffix--
+	}
+
+	return TextEdit{
+		Start:   edit.Start + prefix,
+		End:     edit.End - suffix,
+		NewText: string(newText[prefix : len(newText)-suffix]),
+	}
+}
+
+// ApplyTextEdits applies text edits returned by [ApplyEdits] to the source.
+func ApplyTextEdits(source []byte, edits []TextEdit) []byte {
+	var buf bytes.Buffer
+	pos := 0
+	for _, edit := range edits {
+		_, _ = buf.Write(source[pos:edit.Start])
+		_, _ = buf.WriteString(edit.NewText)
+		pos = edit.End
+	}
+	_, _ = buf.Write(source[pos:])
+	return buf.Bytes()
+}
+
+// isRuneBoundary returns true if i is not in the middle of a multi-byte character.
+func isRuneBoundary(b []byte, i int) bool {
+	return i >= len(b) || utf8.RuneStart(b[i])
+}
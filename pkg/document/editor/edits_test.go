@@ -0,0 +1,196 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEdits(t *testing.T) {
+	// The source uses formatting which would be normalized
+	// by Serialize, like table alignment and list markers.
+	source := strings.Join([]string{
+		"---",
+		"shell: bash",
+		"---",
+		"",
+		"# Title",
+		"",
+		"| a | b   |",
+		"|---|-----|",
+		"| 1 | 234 |",
+		"",
+		"```sh { name=first }",
+		"echo first",
+		"```",
+		"",
+		"* item _one_",
+		"* item __two__",
+		"",
+		"```js",
+		"console.log(1)",
+		"```",
+		"",
+		"+ Nested",
+		"",
+		"  ```sh {\"name\":\"nested\"}",
+		"  echo nested",
+		"  ```",
+		"",
+	}, "\n")
+
+	apply := func(t *testing.T, edits ...CellEdit) string {
+		t.Helper()
+		textEdits, err := ApplyEdits([]byte(source), edits, Options{IdentityResolver: identityResolverNone})
+		require.NoError(t, err)
+		return string(ApplyTextEdits([]byte(source), textEdits))
+	}
+
+	t.Run("NoEdits", func(t *testing.T) {
+		textEdits, err := ApplyEdits([]byte(source), nil, Options{IdentityResolver: identityResolverNone})
+		require.NoError(t, err)
+		assert.Empty(t, textEdits)
+	})
+
+	t.Run("UpdateValue", func(t *testing.T) {
+		textEdits, err := ApplyEdits(
+			[]byte(source),
+			[]CellEdit{{Kind: UpdateCellValueEdit, Index: 2, Value: "echo updated\necho twice"}},
+			Options{IdentityResolver: identityResolverNone},
+		)
+		require.NoError(t, err)
+		require.Len(t, textEdits, 1)
+		assert.Equal(t, strings.Replace(source, "echo first", "echo updated\necho twice", 1), string(ApplyTextEdits([]byte(source), textEdits)))
+	})
+
+	t.Run("UpdateValueNested", func(t *testing.T) {
+		result := apply(t, CellEdit{Kind: UpdateCellValueEdit, Index: 6, Value: "echo 1\n\necho 2"})
+		assert.Equal(t, strings.Replace(source, "  echo nested", "  echo 1\n\n  echo 2", 1), result)
+	})
+
+	t.Run("UpdateValueMarkup", func(t *testing.T) {
+		result := apply(t, CellEdit{Kind: UpdateCellValueEdit, Index: 0, Value: "# New title\n"})
+		assert.Equal(t, strings.Replace(source, "# Title", "# New title", 1), result)
+	})
+
+	t.Run("UpdateAttributes", func(t *testing.T) {
+		result := apply(
+			t,
+			CellEdit{Kind: UpdateCellAttributesEdit, Index: 2, Attributes: map[string]string{"name": "renamed", "interactive": "false"}},
+			CellEdit{Kind: UpdateCellAttributesEdit, Index: 4, Attributes: map[string]string{"name": "js"}},
+			CellEdit{Kind: UpdateCellAttributesEdit, Index: 6, Attributes: map[string]string{"name": "nested"}},
+		)
+		// The original format of attributes is retained.
+		expected := strings.Replace(source, "```sh { name=first }", "```sh { name=renamed interactive=false }", 1)
+		expected = strings.Replace(expected, "```js\n", "```js {\"name\":\"js\"}\n", 1)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		result := apply(t, CellEdit{Kind: DeleteCellEdit, Index: 2})
+		assert.Equal(t, strings.Replace(source, "```sh { name=first }\necho first\n```\n\n", "", 1), result)
+	})
+
+	t.Run("DeleteLast", func(t *testing.T) {
+		result := apply(t, CellEdit{Kind: DeleteCellEdit, Index: 6}, CellEdit{Kind: DeleteCellEdit, Index: 5})
+		assert.Equal(t, source[:strings.Index(source, "\n\n+ Nested")]+"\n", result)
+	})
+
+	t.Run("Insert", func(t *testing.T) {
+		result := apply(
+			t,
+			CellEdit{Kind: InsertCellEdit, Index: 3, Cell: &Cell{Kind: CodeKind, LanguageID: "sh", Value: "echo inserted"}},
+			CellEdit{Kind: UpdateCellAttributesEdit, Index: 3, Attributes: map[string]string{"name": "inserted"}},
+			CellEdit{Kind: InsertCellEdit, Index: 0, Cell: &Cell{Kind: MarkupKind, Value: "Intro"}},
+		)
+		expected := strings.Replace(source, "# Title", "Intro\n\n# Title", 1)
+		expected = strings.Replace(expected, "* item _one_", "```sh {\"name\":\"inserted\"}\necho inserted\n```\n\n* item _one_", 1)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("InsertAtEnd", func(t *testing.T) {
+		result := apply(t, CellEdit{Kind: InsertCellEdit, Index: 7, Cell: &Cell{Kind: MarkupKind, Value: "The end"}})
+		assert.Equal(t, source+"\nThe end\n", result)
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		textEdits, err := ApplyEdits(
+			[]byte(source),
+			[]CellEdit{
+				{Kind: UpdateCellValueEdit, Index: 4, Value: "console.log(2)"},
+				{Kind: MoveCellEdit, Index: 4, ToIndex: 1},
+			},
+			Options{IdentityResolver: identityResolverNone},
+		)
+		require.NoError(t, err)
+
+		expected := strings.Replace(source, "```js\nconsole.log(1)\n```\n\n", "", 1)
+		expected = strings.Replace(expected, "| a | b   |", "```js\nconsole.log(2)\n```\n\n| a | b   |", 1)
+		assert.Equal(t, expected, string(ApplyTextEdits([]byte(source), textEdits)))
+
+		// The table is left untouched.
+		for _, edit := range textEdits {
+			assert.False(t, edit.Start < strings.Index(source, "| 1 | 234 |") && edit.End > strings.Index(source, "| a |"))
+		}
+	})
+
+	t.Run("EmptySource", func(t *testing.T) {
+		textEdits, err := ApplyEdits(nil, []CellEdit{{Kind: InsertCellEdit, Cell: &Cell{Kind: MarkupKind, Value: "# Hello"}}}, Options{IdentityResolver: identityResolverNone})
+		require.NoError(t, err)
+		assert.Equal(t, "# Hello\n", string(ApplyTextEdits(nil, textEdits)))
+	})
+
+	t.Run("InvalidIndex", func(t *testing.T) {
+		_, err := ApplyEdits([]byte(source), []CellEdit{{Kind: DeleteCellEdit, Index: 7}}, Options{IdentityResolver: identityResolverNone})
+		assert.EqualError(t, err, "failed to apply edit 0: cell index 7 out of range")
+
+		_, err = ApplyEdits([]byte(source), []CellEdit{{Kind: UpdateCellAttributesEdit, Index: 0}}, Options{IdentityResolver: identityResolverNone})
+		assert.EqualError(t, err, "failed to apply edit 0: cell 0 is not a code cell")
+	})
+}
+
+func TestMinimizeTextEdit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		newText  string
+		expected TextEdit
+	}{
+		{
+			name:     "ASCII",
+			source:   "echo first",
+			newText:  "echo fast",
+			expected: TextEdit{Start: 6, End: 8, NewText: "a"},
+		},
+		{
+			name:     "SharedLeadingByte",
+			source:   "café",
+			newText:  "cafè",
+			expected: TextEdit{Start: 3, End: 5, NewText: "è"},
+		},
+		{
+			name:     "SharedTrailingByte",
+			source:   "é!",
+			newText:  "ĩ!",
+			expected: TextEdit{Start: 0, End: 2, NewText: "ĩ"},
+		},
+		{
+			name:     "Emoji",
+			source:   "run 🚀 now",
+			newText:  "run 🚁 now",
+			expected: TextEdit{Start: 4, End: 8, NewText: "🚁"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			edit := minimizeTextEdit([]byte(tc.source), TextEdit{Start: 0, End: len(tc.source), NewText: tc.newText})
+			assert.Equal(t, tc.expected, edit)
+			assert.True(t, utf8.ValidString(edit.NewText))
+			assert.Equal(t, tc.newText, string(ApplyTextEdits([]byte(tc.source), []TextEdit{edit})))
+		})
+	}
+}
@@ -114,6 +114,12 @@ func CollectCodeBlocks(node *Node) (result CodeBlocks) {
 	return
 }
 
+// CollectAllCodeBlocks returns code blocks including ignored ones.
+func CollectAllCodeBlocks(node *Node) (result CodeBlocks) {
+	collectCodeBlocks(node, &result)
+	return
+}
+
 func collectCodeBlocks(node *Node, result *CodeBlocks, filters ...NodeFilter) {
 	if node == nil {
 		return
@@ -0,0 +1,232 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/stateful/runme/v3/pkg/document/constants"
+)
+
+// Span is a range in the source. End is exclusive.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsZero returns true if the span is not set,
+// for example, unfenced code blocks have no fences.
+func (s Span) IsZero() bool {
+	return s == Span{}
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%s", s.Start, s.End)
+}
+
+// CodeBlockSpans describes where parts of the code block are located
+// in the source. All positions are relative to the whole source,
+// including frontmatter.
+type CodeBlockSpans struct {
+	// Fence is the opening fence line without leading indentation,
+	// for example, "```sh { name=hello }".
+	Fence Span
+	// Info is the info string following the opening fence,
+	// for example, "sh { name=hello }".
+	Info Span
+	// Attributes are the attributes within the info string,
+	// for example, "{ name=hello }".
+	Attributes Span
+	// Content spans from the first character of the code
+	// to the end of its last line, excluding the line break.
+	Content Span
+	// ClosingFence is the closing fence line. It is zero
+	// if the code block is not closed.
+	ClosingFence Span
+}
+
+// Spans returns positions of the code block parts in the source.
+func (b *CodeBlock) Spans() (result CodeBlockSpans) {
+	doc := b.document
+	if doc == nil || doc.contentOffset < 0 {
+		return
+	}
+
+	span := func(start, end int) Span {
+		return Span{
+			Start: positionAt(doc.source, start+doc.contentOffset),
+			End:   positionAt(doc.source, end+doc.contentOffset),
+		}
+	}
+
+	if seg, ok := fenceSegment(b.inner, constants.OpeningFenceKey); ok {
+		result.Fence = span(seg.Start, seg.Stop)
+	}
+	if seg, ok := fenceSegment(b.inner, constants.ClosingFenceKey); ok {
+		result.ClosingFence = span(seg.Start, seg.Stop)
+	}
+
+	if infoStart, info := b.info(); len(info) > 0 {
+		result.Info = span(infoStart, infoStart+len(info))
+
+		if attrs := extractAttributes(info); len(attrs) > 0 {
+			if idx := bytes.Index(info, attrs); idx >= 0 {
+				result.Attributes = span(infoStart+idx, infoStart+idx+len(attrs))
+			}
+		}
+	}
+
+	lines := b.inner.Lines()
+	if lines.Len() > 0 {
+		first, last := lines.At(0), lines.At(lines.Len()-1)
+		end := last.Stop
+		for end > last.Start && (doc.content[end-1] == '\n' || doc.content[end-1] == '\r') {
+			end--
+		}
+		result.Content = span(first.Start, end)
+	} else if seg, ok := fenceSegment(b.inner, constants.ClosingFenceKey); ok {
+		// Empty code block; the content is located right before the closing fence.
+		result.Content = span(seg.Start, seg.Start)
+	} else if !result.Fence.IsZero() {
+		result.Content = Span{Start: result.Fence.End, End: result.Fence.End}
+	}
+
+	return
+}
+
+// Position returns the position of the code block in the source.
+// For fenced code blocks, it is the position of the opening fence.
+func (b *CodeBlock) Position() Position {
+	spans := b.Spans()
+	if !spans.Fence.IsZero() {
+		return spans.Fence.Start
+	}
+	return spans.Content.Start
+}
+
+// FrontmatterSpan returns the span of the raw frontmatter,
+// including delimiters. It returns false if there is no frontmatter.
+func (d *Document) FrontmatterSpan() (Span, bool) {
+	d.splitSource()
+
+	raw := d.frontmatterRaw
+	if len(raw) == 0 || d.frontmatterOffset < 0 {
+		return Span{}, false
+	}
+
+	return Span{
+		Start: positionAt(d.source, d.frontmatterOffset),
+		End:   positionAt(d.source, d.frontmatterOffset+len(raw)),
+	}, true
+}
+
+func fenceSegment(node ast.Node, key string) (text.Segment, bool) {
+	v, ok := node.AttributeString(key)
+	if !ok {
+		return text.Segment{}, false
+	}
+	seg, ok := v.(text.Segment)
+	return seg, ok
+}
+
+// BlockStart returns an offset in the source of the beginning of the line
+// on which the block node starts. It returns -1 if the position is unknown.
+func (d *Document) BlockStart(node ast.Node) int {
+	if d.contentOffset < 0 {
+		return -1
+	}
+
+	start := -1
+	if v, ok := node.AttributeString(constants.BlockStartKey); ok {
+		start, _ = v.(int)
+	}
+	if node.Type() == ast.TypeBlock && node.Lines().Len() > 0 {
+		// Some blocks, like setext headings, are opened on their last line.
+		if lineStart := node.Lines().At(0).Start; start < 0 || lineStart < start {
+			start = lineStart
+		}
+	}
+	if start < 0 {
+		return -1
+	}
+
+	start = bytes.LastIndexByte(d.content[:start], '\n') + 1
+	return start + d.contentOffset
+}
+
+// newParser returns goldmark's default parser which additionally
+// records positions of blocks and fences, which goldmark does not retain.
+func newParser() parser.Parser {
+	blockParsers := parser.DefaultBlockParsers()
+	for i, p := range blockParsers {
+		blockParsers[i] = util.Prioritized(
+			&positionParser{
+				BlockParser: p.Value.(parser.BlockParser),
+				fenced:      p.Value == parser.NewFencedCodeBlockParser(),
+			},
+			p.Priority,
+		)
+	}
+
+	return parser.NewParser(
+		parser.WithBlockParsers(blockParsers...),
+		parser.WithInlineParsers(parser.DefaultInlineParsers()...),
+		parser.WithParagraphTransformers(parser.DefaultParagraphTransformers()...),
+	)
+}
+
+// positionParser wraps a block parser and stores the start offset
+// of opened blocks in the node's attributes. For fenced code blocks,
+// it also stores segments of the opening and closing fences.
+// Offsets are relative to the content. Segments exclude leading
+// indentation and trailing whitespace.
+type positionParser struct {
+	parser.BlockParser
+	fenced bool
+}
+
+func (p *positionParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	node, state := p.BlockParser.Open(parent, reader, pc)
+	if node == nil {
+		return node, state
+	}
+
+	fence := fenceLineSegment(line, segment)
+	node.SetAttributeString(constants.BlockStartKey, fence.Start)
+	if p.fenced {
+		node.SetAttributeString(constants.OpeningFenceKey, fence)
+	}
+	return node, state
+}
+
+func (p *positionParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	if !p.fenced {
+		return p.BlockParser.Continue(node, reader, pc)
+	}
+
+	line, segment := reader.PeekLine()
+	state := p.BlockParser.Continue(node, reader, pc)
+	if state == parser.Close {
+		node.SetAttributeString(constants.ClosingFenceKey, fenceLineSegment(line, segment))
+	}
+	return state
+}
+
+// fenceLineSegment returns a segment of the line
+// without leading and trailing whitespace.
+func fenceLineSegment(line []byte, segment text.Segment) text.Segment {
+	start := util.FirstNonSpacePosition(line)
+	if start < 0 {
+		start = 0
+	}
+	// Padding is a number of virtual spaces prepended to the line
+	// which are not present in the source.
+	start = segment.Start + max(0, start-segment.Padding)
+	stop := segment.Start + max(0, len(bytes.TrimRight(line, " \t\r\n"))-segment.Padding)
+	return text.NewSegment(start, max(start, stop))
+}
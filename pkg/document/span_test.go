@@ -0,0 +1,111 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stateful/runme/v3/pkg/document/identity"
+)
+
+func TestCodeBlock_Spans(t *testing.T) {
+	source := []byte(`---
+shell: bash
+---
+
+# Spans
+
+` + "```sh { name=top }" + `
+echo top
+echo second
+` + "```" + `
+
+1. Item
+
+   ` + "```js {\"name\":\"nested\"}" + `
+   console.log("nested")
+   ` + "```" + `
+
+> ` + "```sh" + `
+> echo quoted
+> ` + "```" + `
+
+` + "```sh { name=empty }" + `
+` + "```" + `
+
+    echo indented
+`)
+
+	doc := New(source, identity.NewResolver(identity.UnspecifiedLifecycleIdentity))
+	node, err := doc.Root()
+	require.NoError(t, err)
+
+	blocks := CollectCodeBlocks(node)
+	require.Len(t, blocks, 5)
+
+	text := func(s Span) string {
+		return string(source[s.Start.Offset:s.End.Offset])
+	}
+
+	t.Run("Frontmatter", func(t *testing.T) {
+		span, ok := doc.FrontmatterSpan()
+		require.True(t, ok)
+		assert.Equal(t, Position{Line: 1, Column: 1, Offset: 0}, span.Start)
+		assert.Equal(t, "---\nshell: bash\n---", text(span)[:19])
+	})
+
+	t.Run("TopLevel", func(t *testing.T) {
+		spans := blocks[0].Spans()
+		assert.Equal(t, "```sh { name=top }", text(spans.Fence))
+		assert.Equal(t, Position{Line: 7, Column: 1, Offset: 30}, spans.Fence.Start)
+		assert.Equal(t, "sh { name=top }", text(spans.Info))
+		assert.Equal(t, Position{Line: 7, Column: 4, Offset: 33}, spans.Info.Start)
+		assert.Equal(t, "{ name=top }", text(spans.Attributes))
+		assert.Equal(t, "echo top\necho second", text(spans.Content))
+		assert.Equal(t, Position{Line: 8, Column: 1, Offset: 49}, spans.Content.Start)
+		assert.Equal(t, "```", text(spans.ClosingFence))
+		assert.Equal(t, 10, spans.ClosingFence.Start.Line)
+		assert.Equal(t, spans.Fence.Start, blocks[0].Position())
+	})
+
+	t.Run("NestedInList", func(t *testing.T) {
+		spans := blocks[1].Spans()
+		assert.Equal(t, "```js {\"name\":\"nested\"}", text(spans.Fence))
+		assert.Equal(t, Position{Line: 14, Column: 4, Offset: 87}, spans.Fence.Start)
+		assert.Equal(t, `{"name":"nested"}`, text(spans.Attributes))
+		assert.Equal(t, `console.log("nested")`, text(spans.Content))
+		assert.Equal(t, 15, spans.Content.Start.Line)
+		assert.Equal(t, 4, spans.Content.Start.Column)
+		assert.Equal(t, 16, spans.ClosingFence.Start.Line)
+		assert.Equal(t, 4, spans.ClosingFence.Start.Column)
+	})
+
+	t.Run("NestedInBlockquote", func(t *testing.T) {
+		spans := blocks[2].Spans()
+		assert.Equal(t, "```sh", text(spans.Fence))
+		assert.Equal(t, 18, spans.Fence.Start.Line)
+		assert.Equal(t, 3, spans.Fence.Start.Column)
+		assert.True(t, spans.Attributes.IsZero())
+		assert.Equal(t, "echo quoted", text(spans.Content))
+		assert.Equal(t, Position{Line: 19, Column: 3}, Position{Line: spans.Content.Start.Line, Column: spans.Content.Start.Column})
+		assert.Equal(t, "```", text(spans.ClosingFence))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		spans := blocks[3].Spans()
+		assert.Equal(t, "```sh { name=empty }", text(spans.Fence))
+		assert.Equal(t, "", text(spans.Content))
+		assert.Equal(t, spans.ClosingFence.Start, spans.Content.Start)
+	})
+
+	t.Run("Unfenced", func(t *testing.T) {
+		spans := blocks[4].Spans()
+		assert.True(t, spans.Fence.IsZero())
+		assert.True(t, spans.ClosingFence.IsZero())
+		assert.True(t, spans.Info.IsZero())
+		assert.Equal(t, "echo indented", text(spans.Content))
+		assert.Equal(t, Position{Line: 25, Column: 5, Offset: 203}, spans.Content.Start)
+		assert.Equal(t, spans.Content.Start, blocks[4].Position())
+	})
+}
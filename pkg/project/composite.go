@@ -0,0 +1,301 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/internal/shell"
+)
+
+// CompositeTask is an alias or a composite of tasks found in the project,
+// typically defined in the "tasks" section of runme.yaml.
+//
+// Steps reference tasks by name, optionally prefixed by a file name like
+// in "runme run README.md/build". Steps are run in order, or concurrently
+// if Parallel is set. Env overrides environment variables of the steps.
+// An alias is a composite task with a single step.
+type CompositeTask struct {
+	Name        string
+	Description string
+	Steps       []string
+	Parallel    bool
+	Env         map[string]string
+}
+
+// WithCompositeTasks adds composite tasks defined in the source file
+// to tasks found in the project. Composite tasks are run from the directory
+// of the source file. A relative source is relative to the project root.
+func WithCompositeTasks(source string, tasks ...CompositeTask) ProjectOption {
+	return func(p *Project) {
+		p.compositeSource = source
+		p.compositeTasks = append(p.compositeTasks, tasks...)
+	}
+}
+
+// WithRunmeCommand sets the command which composite tasks use to run
+// their steps, for example, the path to the current executable followed
+// by flags to forward. By default, "runme" is looked up in PATH.
+func WithRunmeCommand(path string, args ...string) ProjectOption {
+	return func(p *Project) {
+		p.runmeCommand = append([]string{path}, args...)
+	}
+}
+
+// CompositeTasks returns composite tasks added to the project.
+func (p *Project) CompositeTasks() []CompositeTask {
+	return p.compositeTasks
+}
+
+// loadWithCompositeTasks forwards events of the project and, once
+// all tasks are found, emits composite tasks which reference them.
+func (p *Project) loadWithCompositeTasks(
+	ctx context.Context,
+	eventc chan<- LoadEvent,
+	options LoadOptions,
+) {
+	innerc := make(chan LoadEvent)
+
+	go func() {
+		defer close(innerc)
+		p.loadSources(ctx, innerc, options)
+	}()
+
+	var names []string
+
+	for event := range innerc {
+		if event.Type == LoadEventFoundTask {
+			data := ExtractDataFromLoadEvent[LoadEventFoundTaskData](event)
+			names = append(names, data.Task.CodeBlock.Name())
+		}
+		p.send(ctx, eventc, event)
+	}
+
+	tasks, errs := p.resolveCompositeTasks(names)
+
+	for _, err := range errs {
+		p.send(ctx, eventc, LoadEvent{
+			Type: LoadEventError,
+			Data: LoadEventErrorData{Err: err},
+		})
+	}
+
+	for _, task := range tasks {
+		p.send(ctx, eventc, LoadEvent{
+			Type: LoadEventFoundTask,
+			Data: LoadEventFoundTaskData{Task: task},
+		})
+	}
+}
+
+// resolveCompositeTasks validates composite tasks against names of found
+// tasks and converts valid ones into [Task]. Composite tasks which conflict
+// with found tasks, reference unknown tasks, or form a cycle are reported as errors.
+func (p *Project) resolveCompositeTasks(names []string) ([]Task, []error) {
+	var errs []error
+
+	composites := make(map[string]CompositeTask, len(p.compositeTasks))
+	for _, c := range p.compositeTasks {
+		switch {
+		case c.Name == "":
+			errs = append(errs, errors.Errorf("composite task in %s: name is required", p.compositeSource))
+			continue
+		case len(c.Steps) == 0:
+			errs = append(errs, errors.Errorf("composite task %q: no steps", c.Name))
+			continue
+		case slices.Contains(names, c.Name):
+			errs = append(errs, errors.Errorf("composite task %q: conflicts with a task with the same name", c.Name))
+			continue
+		}
+		if key := invalidEnvKey(c.Env); key != "" {
+			errs = append(errs, errors.Errorf("composite task %q: invalid env name %q", c.Name, key))
+			continue
+		}
+		if _, ok := composites[c.Name]; ok {
+			errs = append(errs, errors.Errorf("composite task %q: defined more than once", c.Name))
+			continue
+		}
+		composites[c.Name] = c
+	}
+
+	invalid := make(map[string]bool)
+
+	for _, c := range p.compositeTasks {
+		if _, ok := composites[c.Name]; !ok {
+			continue
+		}
+		for _, step := range c.Steps {
+			name := compositeStepName(step)
+			if _, ok := composites[name]; !ok && !slices.Contains(names, name) {
+				errs = append(errs, errors.Errorf("composite task %q: unknown task %q", c.Name, step))
+				invalid[c.Name] = true
+			}
+		}
+	}
+
+	for _, cycle := range findCompositeCycles(composites) {
+		errs = append(errs, errors.Errorf("composite task %q: cycle %s", cycle[0], strings.Join(cycle, " -> ")))
+		for _, name := range cycle {
+			invalid[name] = true
+		}
+	}
+
+	// Composite tasks referencing invalid ones are invalid too.
+	for changed := true; changed; {
+		changed = false
+		for name, c := range composites {
+			if invalid[name] {
+				continue
+			}
+			for _, step := range c.Steps {
+				if invalid[compositeStepName(step)] {
+					invalid[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	source := p.compositeSource
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(p.Root(), source)
+	}
+	relPath, _ := p.relPath(source)
+
+	var tasks []Task
+
+	for _, c := range p.compositeTasks {
+		if _, ok := composites[c.Name]; !ok || invalid[c.Name] {
+			continue
+		}
+
+		block, err := newVirtualCodeBlock(c.Name, c.Description, compositeCommand(c, p.runmeCommand))
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "composite task %q", c.Name))
+			continue
+		}
+
+		tasks = append(tasks, Task{
+			CodeBlock:       block,
+			DocumentPath:    source,
+			RelDocumentPath: relPath,
+			Source:          TaskSourceConfig,
+		})
+	}
+
+	return tasks, errs
+}
+
+// compositeStepName returns the name of the task referenced by the step.
+func compositeStepName(step string) string {
+	if _, name, ok := strings.Cut(step, "/"); ok {
+		return name
+	}
+	return step
+}
+
+// findCompositeCycles returns cycles of composite tasks
+// referencing each other. Each cycle starts and ends with the same name.
+func findCompositeCycles(composites map[string]CompositeTask) (cycles [][]string) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(composites))
+
+	var (
+		path  []string
+		visit func(string)
+	)
+
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, step := range composites[name].Steps {
+			next := compositeStepName(step)
+			if _, ok := composites[next]; !ok {
+				continue
+			}
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				idx := slices.Index(path, next)
+				cycle := append(slices.Clone(path[idx:]), next)
+				cycles = append(cycles, cycle)
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	names := make([]string, 0, len(composites))
+	for name := range composites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return cycles
+}
+
+// compositeCommand returns a command like "CI=true runme run --parallel lint test".
+// runme is the command, with arguments, to run the steps with; if it is empty,
+// "runme" is used. Arguments are placed after "run".
+func compositeCommand(c CompositeTask, runme []string) string {
+	if len(runme) == 0 {
+		runme = []string{"runme"}
+	}
+
+	var args []string
+
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("%s=%s", key, shell.Quote(c.Env[key])))
+	}
+
+	args = append(args, shell.Quote(runme[0]), "run")
+	for _, arg := range runme[1:] {
+		args = append(args, shell.Quote(arg))
+	}
+	if c.Parallel {
+		args = append(args, "--parallel")
+	}
+	for _, step := range c.Steps {
+		args = append(args, shell.Quote(step))
+	}
+
+	return strings.Join(args, " ")
+}
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func invalidEnvKey(env map[string]string) string {
+	for key := range env {
+		if !envKey.MatchString(key) {
+			return key
+		}
+	}
+	return ""
+}
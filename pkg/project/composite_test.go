@@ -0,0 +1,116 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompositeTasks(t *testing.T) {
+	dir := t.TempDir()
+	content := "```sh {\"name\":\"lint\"}\necho lint\n```\n\n```sh {\"name\":\"test\"}\necho test\n```\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(content), 0o600))
+
+	load := func(t *testing.T, composites ...CompositeTask) (tasks []Task, errs []error) {
+		t.Helper()
+
+		proj, err := NewDirProject(dir, WithCompositeTasks(filepath.Join(dir, "runme.yaml"), composites...))
+		require.NoError(t, err)
+
+		eventc := make(chan LoadEvent)
+		go proj.Load(context.Background(), eventc, false)
+
+		for event := range eventc {
+			switch event.Type {
+			case LoadEventFoundTask:
+				tasks = append(tasks, ExtractDataFromLoadEvent[LoadEventFoundTaskData](event).Task)
+			case LoadEventError:
+				errs = append(errs, ExtractDataFromLoadEvent[LoadEventErrorData](event).Err)
+			}
+		}
+		return
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		tasks, errs := load(
+			t,
+			CompositeTask{
+				Name:        "ci",
+				Description: "Lint and test.",
+				Steps:       []string{"check", "README.md/test"},
+				Env:         map[string]string{"CI": "true", "MSG": "hello world"},
+			},
+			CompositeTask{Name: "check", Steps: []string{"lint", "test"}, Parallel: true},
+		)
+		require.Empty(t, errs)
+		require.Len(t, tasks, 4)
+
+		ci := tasks[2]
+		assert.Equal(t, "ci", ci.CodeBlock.Name())
+		assert.Equal(t, "Lint and test.", ci.CodeBlock.Intro())
+		assert.Equal(t, []string{"CI=true MSG='hello world' runme run check README.md/test"}, ci.CodeBlock.Lines())
+		assert.True(t, ci.CodeBlock.ExcludeFromRunAll())
+		assert.Equal(t, filepath.Join(dir, "runme.yaml"), ci.DocumentPath)
+		assert.Equal(t, "runme.yaml", ci.RelDocumentPath)
+
+		check := tasks[3]
+		assert.Equal(t, []string{"runme run --parallel lint test"}, check.CodeBlock.Lines())
+	})
+
+	t.Run("RunmeCommand", func(t *testing.T) {
+		proj, err := NewDirProject(
+			dir,
+			WithCompositeTasks(filepath.Join(dir, "runme.yaml"), CompositeTask{Name: "check", Steps: []string{"lint"}}),
+			WithRunmeCommand("/opt/my runme/runme", "--server=localhost:7890", "--insecure"),
+		)
+		require.NoError(t, err)
+
+		tasks, err := LoadTasks(context.Background(), proj)
+		require.NoError(t, err)
+		require.Len(t, tasks, 3)
+		assert.Equal(t, []string{"'/opt/my runme/runme' run --server=localhost:7890 --insecure lint"}, tasks[2].CodeBlock.Lines())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tasks, errs := load(
+			t,
+			CompositeTask{Name: "lint", Steps: []string{"test"}},
+			CompositeTask{Name: "unknown", Steps: []string{"deploy"}},
+			CompositeTask{Name: "a", Steps: []string{"b"}},
+			CompositeTask{Name: "b", Steps: []string{"a"}},
+			CompositeTask{Name: "c", Steps: []string{"a", "test"}},
+			CompositeTask{Name: "env", Steps: []string{"test"}, Env: map[string]string{"A B": "x"}},
+			CompositeTask{Name: "ok", Steps: []string{"test"}},
+		)
+
+		var names []string
+		for _, task := range tasks {
+			names = append(names, task.CodeBlock.Name())
+		}
+		assert.Equal(t, []string{"lint", "test", "ok"}, names)
+
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		assert.ElementsMatch(t, []string{
+			`composite task "lint": conflicts with a task with the same name`,
+			`composite task "env": invalid env name "A B"`,
+			`composite task "unknown": unknown task "deploy"`,
+			`composite task "a": cycle a -> b -> a`,
+		}, messages)
+	})
+
+	t.Run("OnlyFiles", func(t *testing.T) {
+		proj, err := NewDirProject(dir, WithCompositeTasks(filepath.Join(dir, "runme.yaml"), CompositeTask{Name: "ok", Steps: []string{"test"}}))
+		require.NoError(t, err)
+
+		files, err := LoadFiles(context.Background(), proj)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "README.md")}, files)
+	})
+}
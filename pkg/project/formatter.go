@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/stateful/runme/v3/pkg/document"
 	"github.com/stateful/runme/v3/pkg/document/editor"
 	"github.com/stateful/runme/v3/pkg/document/identity"
 )
@@ -45,6 +46,62 @@ func FormatFiles(files []string, options *FormatOptions) error {
 	return nil
 }
 
+// CheckResult contains problems found in a file by [CheckFiles].
+type CheckResult struct {
+	File        string
+	Diagnostics []document.Diagnostic
+	// Unformatted is true if the file is not in the canonical format.
+	Unformatted bool
+}
+
+// HasErrors returns true if the file is unformatted
+// or contains diagnostics with the error severity.
+func (r CheckResult) HasErrors() bool {
+	if r.Unformatted {
+		return true
+	}
+	for _, d := range r.Diagnostics {
+		if d.Severity == document.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFiles validates files and checks if they are in the canonical format.
+// It never modifies the files. Only files with problems are returned.
+func CheckFiles(files []string, options *FormatOptions) ([]CheckResult, error) {
+	var result []CheckResult
+
+	for _, file := range files {
+		data, err := readMarkdown(file)
+		if err != nil {
+			return nil, err
+		}
+
+		diagnostics, err := document.New(data, options.IdentityResolver).Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to validate %s", file)
+		}
+
+		formatted, err := formatFile(data, options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to format %s", file)
+		}
+
+		checkResult := CheckResult{
+			File:        file,
+			Diagnostics: diagnostics,
+			Unformatted: !bytes.Equal(data, formatted),
+		}
+		if checkResult.Unformatted || len(checkResult.Diagnostics) > 0 {
+			result = append(result, checkResult)
+		}
+	}
+
+	return result, nil
+}
+
 func formatFile(data []byte, options *FormatOptions) ([]byte, error) {
 	var formatted []byte
 
@@ -0,0 +1,255 @@
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/stateful/runme/v3/pkg/document"
+)
+
+const taskIndexVersion = 2
+
+// TaskIndex is an on-disk cache of tasks found in markdown files.
+// Entries are keyed by the file path and are valid as long as the
+// modification time and size, or the hash of the content, do not change.
+//
+// Files which are known to contain no tasks are not parsed again
+// which is the majority of files in large repositories. Tasks of other
+// files can be served from the index when loading with [LoadOptions.FromIndex].
+type TaskIndex struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]*IndexedFile
+	// seen contains files looked up since the index was opened.
+	// It is used to prune entries of removed files.
+	seen  map[string]bool
+	dirty bool
+}
+
+// IndexedFile is an entry of [TaskIndex].
+type IndexedFile struct {
+	ModTime time.Time     `json:"mod_time"`
+	Size    int64         `json:"size"`
+	Hash    string        `json:"hash"`
+	Tasks   []IndexedTask `json:"tasks,omitempty"`
+}
+
+// IndexedTask is a summary of a task found in an indexed file.
+type IndexedTask struct {
+	Name            string `json:"name"`
+	IsNameGenerated bool   `json:"is_name_generated,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Line            int    `json:"line,omitempty"`
+	// Source is the markdown source of the code block
+	// including the fence and attributes.
+	Source string `json:"source,omitempty"`
+}
+
+type taskIndexFile struct {
+	Version int                     `json:"version"`
+	Files   map[string]*IndexedFile `json:"files"`
+}
+
+// DefaultTaskIndexDir returns a directory within the user's cache
+// directory in which task indices of projects are stored.
+func DefaultTaskIndexDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "runme", "index"), nil
+}
+
+// taskIndexPath returns a path to the task index
+// of the project rooted at root stored in dir.
+func taskIndexPath(dir, root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// OpenTaskIndex reads the task index from path. If the file does not exist
+// or it was written by an incompatible version, the index is empty.
+func OpenTaskIndex(path string) (*TaskIndex, error) {
+	idx := &TaskIndex{
+		path:  path,
+		files: make(map[string]*IndexedFile),
+		seen:  make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var content taskIndexFile
+	if err := json.Unmarshal(data, &content); err == nil && content.Version == taskIndexVersion && content.Files != nil {
+		idx.files = content.Files
+	} else {
+		// A corrupted or outdated index is discarded.
+		idx.dirty = true
+	}
+
+	return idx, nil
+}
+
+// lookup returns the entry for path if it is up to date. data is
+// the content of the file, or nil if it has not been read yet.
+func (i *TaskIndex) lookup(path string, info fs.FileInfo, data []byte) (*IndexedFile, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.seen[path] = true
+
+	entry, ok := i.files[path]
+	if !ok {
+		return nil, false
+	}
+
+	if entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+		return entry, true
+	}
+
+	if data == nil || entry.Hash != hashContent(data) {
+		return nil, false
+	}
+
+	// The file was touched but the content did not change.
+	entry.ModTime = info.ModTime()
+	entry.Size = info.Size()
+	i.dirty = true
+
+	return entry, true
+}
+
+func (i *TaskIndex) update(path string, info fs.FileInfo, data []byte, blocks document.CodeBlocks) {
+	entry := &IndexedFile{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Hash:    hashContent(data),
+	}
+	for _, b := range blocks {
+		entry.Tasks = append(entry.Tasks, IndexedTask{
+			Name:            b.Name(),
+			IsNameGenerated: b.IsUnnamed(),
+			Language:        b.Language(),
+			Line:            b.Position().Line,
+			Source:          string(b.Value()),
+		})
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.seen[path] = true
+	i.files[path] = entry
+	i.dirty = true
+}
+
+// prune removes entries of files which were not looked up
+// since the index was opened.
+func (i *TaskIndex) prune() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for path := range i.files {
+		if !i.seen[path] {
+			delete(i.files, path)
+			i.dirty = true
+		}
+	}
+}
+
+// Save writes the index to disk if it changed.
+func (i *TaskIndex) Save() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(taskIndexFile{Version: taskIndexVersion, Files: i.files})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(i.path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Write to a temporary file first to avoid leaving a partially
+	// written index behind if multiple processes save it concurrently.
+	tmp, err := os.CreateTemp(filepath.Dir(i.path), filepath.Base(i.path)+".*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmp.Name(), i.path); err != nil {
+		return errors.WithStack(err)
+	}
+
+	i.dirty = false
+	return nil
+}
+
+// codeBlocks reconstructs code blocks of the entry from their sources
+// without reading the file. Each code block is placed on its original line
+// so that positions are retained, but the frontmatter and prose, including
+// intros, are not. It returns false if the code blocks could not be
+// reconstructed faithfully, for example, because of nesting.
+func (f *IndexedFile) codeBlocks() (document.CodeBlocks, bool) {
+	var buf bytes.Buffer
+	line := 1
+	for _, t := range f.Tasks {
+		if t.Source == "" {
+			return nil, false
+		}
+		// Separate code blocks by at least one empty line.
+		if buf.Len() > 0 {
+			_ = buf.WriteByte('\n')
+			line++
+		}
+		for ; line < t.Line; line++ {
+			_ = buf.WriteByte('\n')
+		}
+		_, _ = buf.WriteString(t.Source)
+		line += strings.Count(t.Source, "\n")
+	}
+
+	blocks, err := getCodeBlocks(buf.Bytes())
+	if err != nil || len(blocks) != len(f.Tasks) {
+		return nil, false
+	}
+	for i, b := range blocks {
+		if b.Name() != f.Tasks[i].Name || b.IsUnnamed() != f.Tasks[i].IsNameGenerated {
+			return nil, false
+		}
+	}
+	return blocks, true
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
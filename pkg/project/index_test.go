@@ -0,0 +1,137 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskIndex(t *testing.T) {
+	dir := t.TempDir()
+	indexDir := t.TempDir()
+
+	withTasks := filepath.Join(dir, "tasks.md")
+	withoutTasks := filepath.Join(dir, "prose.md")
+	require.NoError(t, os.WriteFile(withTasks, []byte("```sh {\"name\":\"hello\"}\necho hello\n```\n"), 0o600))
+	require.NoError(t, os.WriteFile(withoutTasks, []byte("# Prose\n\nNo tasks here.\n"), 0o600))
+
+	load := func(t *testing.T) (*Project, []Task) {
+		t.Helper()
+		proj, err := NewDirProject(dir, WithTaskIndex(indexDir))
+		require.NoError(t, err)
+		require.NotNil(t, proj.index)
+		tasks, err := LoadTasks(context.Background(), proj)
+		require.NoError(t, err)
+		return proj, tasks
+	}
+
+	_, tasks := load(t)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "hello", tasks[0].CodeBlock.Name())
+
+	indexPath := taskIndexPath(indexDir, dir)
+	require.FileExists(t, indexPath)
+
+	idx, err := OpenTaskIndex(indexPath)
+	require.NoError(t, err)
+	require.Len(t, idx.files, 2)
+	assert.Equal(t, []IndexedTask{{Name: "hello", Language: "sh", Line: 1, Source: "```sh {\"name\":\"hello\"}\necho hello\n```\n"}}, idx.files[withTasks].Tasks)
+	assert.Empty(t, idx.files[withoutTasks].Tasks)
+
+	t.Run("UnchangedContent", func(t *testing.T) {
+		// Touching the file invalidates the modification time,
+		// but the entry is still valid thanks to the hash.
+		later := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(withoutTasks, later, later))
+
+		info, err := os.Stat(withoutTasks)
+		require.NoError(t, err)
+
+		idx, err := OpenTaskIndex(indexPath)
+		require.NoError(t, err)
+
+		_, ok := idx.lookup(withoutTasks, info, nil)
+		assert.False(t, ok)
+
+		data, err := os.ReadFile(withoutTasks)
+		require.NoError(t, err)
+		entry, ok := idx.lookup(withoutTasks, info, data)
+		require.True(t, ok)
+		assert.True(t, entry.ModTime.Equal(info.ModTime()))
+	})
+
+	t.Run("FromIndex", func(t *testing.T) {
+		// The entry is made up so that it is clear the tasks
+		// are served from the index and the file is not parsed.
+		idx, err := OpenTaskIndex(indexPath)
+		require.NoError(t, err)
+		idx.files[withTasks].Tasks = []IndexedTask{
+			{Name: "cached", Language: "sh", Line: 3, Source: "```sh {\"name\":\"cached\"}\necho cached\n```\n"},
+			{Name: "echo-unnamed", IsNameGenerated: true, Language: "sh", Line: 7, Source: "```sh\necho unnamed\n```\n"},
+		}
+		idx.dirty = true
+		require.NoError(t, idx.Save())
+
+		proj, err := NewDirProject(dir, WithTaskIndex(indexDir))
+		require.NoError(t, err)
+
+		tasks, err := LoadTasks(context.Background(), fromIndexLoader{proj})
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+		assert.Equal(t, "cached", tasks[0].CodeBlock.Name())
+		assert.Equal(t, []string{"echo cached"}, tasks[0].CodeBlock.Lines())
+		assert.Equal(t, 3, tasks[0].CodeBlock.Position().Line)
+		assert.Equal(t, "echo-unnamed", tasks[1].CodeBlock.Name())
+		assert.True(t, tasks[1].CodeBlock.IsUnnamed())
+		assert.Equal(t, 7, tasks[1].CodeBlock.Position().Line)
+
+		// Without the option, files with tasks are parsed.
+		tasks, err = LoadTasks(context.Background(), proj)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "hello", tasks[0].CodeBlock.Name())
+	})
+
+	t.Run("ChangedContent", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(withoutTasks, []byte("```sh {\"name\":\"added\"}\necho added\n```\n"), 0o600))
+
+		_, tasks := load(t)
+		require.Len(t, tasks, 2)
+	})
+
+	t.Run("RemovedFile", func(t *testing.T) {
+		require.NoError(t, os.Remove(withoutTasks))
+
+		_, tasks := load(t)
+		require.Len(t, tasks, 1)
+
+		idx, err := OpenTaskIndex(indexPath)
+		require.NoError(t, err)
+		assert.Len(t, idx.files, 1)
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(indexPath, []byte("{invalid"), 0o600))
+
+		_, tasks := load(t)
+		require.Len(t, tasks, 1)
+
+		idx, err := OpenTaskIndex(indexPath)
+		require.NoError(t, err)
+		assert.Len(t, idx.files, 1)
+	})
+}
+
+type fromIndexLoader struct {
+	*Project
+}
+
+func (l fromIndexLoader) LoadWithOptions(ctx context.Context, eventc chan<- LoadEvent, options LoadOptions) {
+	options.FromIndex = true
+	l.Project.LoadWithOptions(ctx, eventc, options)
+}
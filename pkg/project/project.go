@@ -121,6 +121,14 @@ func WithEnvFilesReadOrder(order []string) ProjectOption {
 	}
 }
 
+// WithTaskIndex enables the on-disk [TaskIndex] stored in dir
+// for dir-based projects. The index is saved after the project is loaded.
+func WithTaskIndex(dir string) ProjectOption {
+	return func(p *Project) {
+		p.indexDir = dir
+	}
+}
+
 func WithLogger(logger *zap.Logger) ProjectOption {
 	return func(p *Project) {
 		p.logger = logger
@@ -152,6 +160,21 @@ type Project struct {
 	// enable or disable direnv handling
 	envDirEnvEnabled bool
 
+	// index, if not nil, is used to skip parsing of unchanged files.
+	// It is opened from indexDir for dir-based projects.
+	index    *TaskIndex
+	indexDir string
+
+	// providers discover tasks in files other than markdown.
+	providers []TaskProvider
+
+	// compositeTasks are emitted after tasks found in files.
+	compositeTasks  []CompositeTask
+	compositeSource string
+	// runmeCommand is the command, with arguments, which
+	// composite tasks use to run their steps.
+	runmeCommand []string
+
 	logger *zap.Logger
 }
 
@@ -219,6 +242,13 @@ func NewDirProject(
 		p.logger = zap.NewNop()
 	}
 
+	if p.indexDir != "" {
+		p.index, err = OpenTaskIndex(taskIndexPath(p.indexDir, p.fs.Root()))
+		if err != nil {
+			p.logger.Info("failed to open task index", zap.Error(err))
+		}
+	}
+
 	return p, nil
 }
 
@@ -276,6 +306,11 @@ func (p *Project) relPath(path string) (string, error) {
 
 type LoadOptions struct {
 	OnlyFiles bool
+	// FromIndex serves tasks of files which did not change since they were
+	// indexed from the [TaskIndex] without parsing the files. Code blocks of
+	// such tasks lack the frontmatter and intros of their documents so
+	// it should be used only to list tasks.
+	FromIndex bool
 }
 
 func (p *Project) Load(
@@ -301,6 +336,19 @@ func (p *Project) load(
 ) {
 	defer close(eventc)
 
+	if len(p.compositeTasks) > 0 && !options.OnlyFiles {
+		p.loadWithCompositeTasks(ctx, eventc, options)
+		return
+	}
+
+	p.loadSources(ctx, eventc, options)
+}
+
+func (p *Project) loadSources(
+	ctx context.Context,
+	eventc chan<- LoadEvent,
+	options LoadOptions,
+) {
 	switch {
 	case p.repo != nil:
 		// The logic is identical to a dir-based project because
@@ -373,6 +421,10 @@ func (p *Project) loadFromDirectory(
 		}
 	}
 
+	// Files read by task providers are not reported as found
+	// because they are not documents.
+	providedFiles := make(map[string]TaskProvider)
+
 	ignorePatterns := p.getAllIgnorePatterns()
 	ignoreMatcher := gitignore.NewMatcher(ignorePatterns)
 
@@ -410,6 +462,9 @@ func (p *Project) loadFromDirectory(
 				})
 
 				onFileFound(absPath)
+			} else if provider := p.findTaskProvider(path); provider != nil && !options.OnlyFiles {
+				providedFiles[absPath] = provider
+				filesToSearchBlocks = append(filesToSearchBlocks, absPath)
 			}
 		} else if info.IsDir() {
 			return filepath.SkipDir
@@ -433,7 +488,16 @@ func (p *Project) loadFromDirectory(
 	}
 
 	for _, file := range filesToSearchBlocks {
-		p.extractTasksFromFile(ctx, eventc, file)
+		if provider, ok := providedFiles[file]; ok {
+			p.extractProvidedTasks(ctx, eventc, file, provider)
+			continue
+		}
+		p.extractTasksFromFile(ctx, eventc, file, options)
+	}
+
+	if p.index != nil && ctx.Err() == nil {
+		p.index.prune()
+		p.saveIndex()
 	}
 }
 
@@ -456,20 +520,31 @@ func (p *Project) loadFromFile(
 		return
 	}
 
-	p.extractTasksFromFile(ctx, eventc, path)
+	p.extractTasksFromFile(ctx, eventc, path, options)
+
+	if p.index != nil {
+		p.saveIndex()
+	}
+}
+
+func (p *Project) saveIndex() {
+	if err := p.index.Save(); err != nil {
+		p.logger.Info("failed to save task index", zap.Error(err))
+	}
 }
 
 func (p *Project) extractTasksFromFile(
 	ctx context.Context,
 	eventc chan<- LoadEvent,
 	path string,
+	options LoadOptions,
 ) {
 	p.send(ctx, eventc, LoadEvent{
 		Type: LoadEventStartedParsingDocument,
 		Data: LoadEventStartedParsingDocumentData{Path: path},
 	})
 
-	codeBlocks, err := getCodeBlocksFromFile(path)
+	codeBlocks, err := p.getCodeBlocksFromFile(path, options.FromIndex)
 
 	p.send(ctx, eventc, LoadEvent{
 		Type: LoadEventFinishedParsingDocument,
@@ -501,12 +576,59 @@ func (p *Project) extractTasksFromFile(
 					CodeBlock:       b,
 					DocumentPath:    path,
 					RelDocumentPath: relPath,
+					Source:          TaskSourceMarkdown,
 				},
 			},
 		})
 	}
 }
 
+// getCodeBlocksFromFile returns code blocks of the file at path.
+// If the index has an up-to-date entry for the file, the file is not
+// parsed if it has no tasks, or if fromIndex is true.
+func (p *Project) getCodeBlocksFromFile(path string, fromIndex bool) (document.CodeBlocks, error) {
+	if p.index == nil {
+		return getCodeBlocksFromFile(path)
+	}
+
+	fromEntry := func(entry *IndexedFile) (document.CodeBlocks, bool) {
+		if len(entry.Tasks) == 0 {
+			return nil, true
+		}
+		if !fromIndex {
+			return nil, false
+		}
+		return entry.codeBlocks()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := p.index.lookup(path, info, nil); ok {
+		if blocks, ok := fromEntry(entry); ok {
+			return blocks, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := p.index.lookup(path, info, data); ok {
+		if blocks, ok := fromEntry(entry); ok {
+			return blocks, nil
+		}
+	}
+
+	blocks, err := getCodeBlocks(data)
+	if err != nil {
+		return nil, err
+	}
+	p.index.update(path, info, data, blocks)
+	return blocks, nil
+}
+
 func getCodeBlocksFromFile(path string) (document.CodeBlocks, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,419 @@
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stateful/runme/v3/internal/shell"
+	"github.com/stateful/runme/v3/pkg/document"
+)
+
+// TaskSource identifies where a task comes from.
+type TaskSource string
+
+const (
+	TaskSourceMarkdown    TaskSource = "markdown"
+	TaskSourceConfig      TaskSource = "config"
+	TaskSourceMakefile    TaskSource = "makefile"
+	TaskSourcePackageJSON TaskSource = "package.json"
+	TaskSourceTaskfile    TaskSource = "taskfile"
+)
+
+// ReadOnly returns true if the task does not come from a markdown
+// file, hence, it cannot be edited as a document.
+func (s TaskSource) ReadOnly() bool {
+	return s != "" && s != TaskSourceMarkdown
+}
+
+// TaskProvider discovers tasks in files other than markdown,
+// for example, targets of a Makefile. Provided tasks are read-only;
+// each of them is a code block which invokes the respective tool
+// from the directory of the file.
+type TaskProvider interface {
+	Source() TaskSource
+	// Match returns true if the provider reads files with the base name.
+	Match(name string) bool
+	Tasks(path string, data []byte) ([]ProvidedTask, error)
+}
+
+type ProvidedTask struct {
+	Name        string
+	Description string
+	Command     string
+}
+
+// WithTaskProviders enables discovering tasks with providers
+// for dir-based projects.
+func WithTaskProviders(providers ...TaskProvider) ProjectOption {
+	return func(p *Project) {
+		p.providers = append(p.providers, providers...)
+	}
+}
+
+// DefaultTaskProviders returns providers of Makefile targets,
+// package.json scripts, and Taskfile tasks.
+func DefaultTaskProviders() []TaskProvider {
+	return []TaskProvider{
+		MakefileProvider(),
+		PackageJSONProvider(),
+		TaskfileProvider(),
+	}
+}
+
+func (p *Project) findTaskProvider(path string) TaskProvider {
+	name := filepath.Base(path)
+	for _, provider := range p.providers {
+		if provider.Match(name) {
+			return provider
+		}
+	}
+	return nil
+}
+
+func (p *Project) extractProvidedTasks(
+	ctx context.Context,
+	eventc chan<- LoadEvent,
+	path string,
+	provider TaskProvider,
+) {
+	p.send(ctx, eventc, LoadEvent{
+		Type: LoadEventStartedParsingDocument,
+		Data: LoadEventStartedParsingDocumentData{Path: path},
+	})
+
+	provided, err := readProvidedTasks(path, provider)
+
+	p.send(ctx, eventc, LoadEvent{
+		Type: LoadEventFinishedParsingDocument,
+		Data: LoadEventFinishedParsingDocumentData{Path: path},
+	})
+
+	if err != nil {
+		p.send(ctx, eventc, LoadEvent{
+			Type: LoadEventError,
+			Data: LoadEventErrorData{Err: errors.WithMessagef(err, "failed to read tasks from %s", path)},
+		})
+		return
+	}
+
+	relPath, _ := p.relPath(path)
+
+	for _, t := range provided {
+		block, err := newVirtualCodeBlock(t.Name, t.Description, t.Command)
+		if err != nil {
+			p.send(ctx, eventc, LoadEvent{
+				Type: LoadEventError,
+				Data: LoadEventErrorData{Err: err},
+			})
+			continue
+		}
+
+		p.send(ctx, eventc, LoadEvent{
+			Type: LoadEventFoundTask,
+			Data: LoadEventFoundTaskData{
+				Task: Task{
+					CodeBlock:       block,
+					DocumentPath:    path,
+					RelDocumentPath: relPath,
+					Source:          provider.Source(),
+				},
+			},
+		})
+	}
+}
+
+func readProvidedTasks(path string, provider TaskProvider) ([]ProvidedTask, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return provider.Tasks(path, data)
+}
+
+// newVirtualCodeBlock creates a code block which is not a part of
+// any file. It is excluded from running all tasks.
+func newVirtualCodeBlock(name, description string, lines ...string) (*document.CodeBlock, error) {
+	var buf bytes.Buffer
+
+	if description != "" {
+		_, _ = buf.WriteString(description + "\n\n")
+	}
+
+	_, _ = buf.WriteString("```sh ")
+	err := document.WriteAttributes(&buf, document.NewAttributes(map[string]string{
+		"name":              name,
+		"excludeFromRunAll": "true",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	_, _ = buf.WriteString("\n" + strings.Join(lines, "\n") + "\n```\n")
+
+	blocks, err := getCodeBlocks(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != 1 {
+		return nil, errors.Errorf("invariant violation: expected exactly one code block for %q", name)
+	}
+	return blocks[0], nil
+}
+
+type makefileProvider struct{}
+
+// MakefileProvider provides targets of GNU Makefiles. Special targets,
+// pattern rules, and targets containing variables are skipped. A comment
+// directly above the target or a "## comment" after it is its description.
+func MakefileProvider() TaskProvider { return makefileProvider{} }
+
+func (makefileProvider) Source() TaskSource { return TaskSourceMakefile }
+
+func (makefileProvider) Match(name string) bool {
+	return name == "Makefile" || name == "makefile" || name == "GNUmakefile"
+}
+
+var makeTargetRe = regexp.MustCompile(`^([^\s:=#][^:=#]*?)\s*::?(?:[^=]|$)`)
+
+func (makefileProvider) Tasks(_ string, data []byte) ([]ProvidedTask, error) {
+	var (
+		result  []ProvidedTask
+		seen    = make(map[string]bool)
+		comment []string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if text, ok := strings.CutPrefix(line, "#"); ok {
+			comment = append(comment, strings.TrimSpace(strings.TrimLeft(text, "#")))
+			continue
+		}
+
+		m := makeTargetRe.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(line, "\t") || strings.Contains(line, ":=") {
+			comment = nil
+			continue
+		}
+
+		description := strings.Join(comment, " ")
+		comment = nil
+		if _, inline, ok := strings.Cut(line, "##"); ok {
+			description = strings.TrimSpace(inline)
+		}
+
+		for _, target := range strings.Fields(m[1]) {
+			if strings.HasPrefix(target, ".") || strings.ContainsAny(target, "%$()") || seen[target] {
+				continue
+			}
+			seen[target] = true
+
+			result = append(result, ProvidedTask{
+				Name:        target,
+				Description: description,
+				Command:     "make " + shell.Quote(target),
+			})
+		}
+	}
+
+	return result, errors.WithStack(scanner.Err())
+}
+
+type packageJSONProvider struct{}
+
+// PackageJSONProvider provides scripts of package.json files. Scripts
+// are run with the package manager inferred from the lock file.
+// Pre and post hooks of other scripts are skipped.
+func PackageJSONProvider() TaskProvider { return packageJSONProvider{} }
+
+func (packageJSONProvider) Source() TaskSource { return TaskSourcePackageJSON }
+
+func (packageJSONProvider) Match(name string) bool { return name == "package.json" }
+
+func (packageJSONProvider) Tasks(path string, data []byte) ([]ProvidedTask, error) {
+	scripts, err := readPackageJSONScripts(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(scripts))
+	for _, s := range scripts {
+		names[s[0]] = true
+	}
+
+	manager := packageManager(filepath.Dir(path))
+
+	var result []ProvidedTask
+	for _, s := range scripts {
+		name, script := s[0], s[1]
+
+		if hook, ok := strings.CutPrefix(name, "pre"); ok && names[hook] {
+			continue
+		}
+		if hook, ok := strings.CutPrefix(name, "post"); ok && names[hook] {
+			continue
+		}
+
+		result = append(result, ProvidedTask{
+			Name:        name,
+			Description: script,
+			Command:     manager + " run " + shell.Quote(name),
+		})
+	}
+	return result, nil
+}
+
+// readPackageJSONScripts returns name and command pairs
+// of scripts in the order of the file.
+func readPackageJSONScripts(data []byte) ([][2]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if key != "scripts" {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			continue
+		}
+
+		if err := expectJSONDelim(decoder, '{'); err != nil {
+			return nil, err
+		}
+
+		var result [][2]string
+		for decoder.More() {
+			name, err := decoder.Token()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			var script string
+			if err := decoder.Decode(&script); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			result = append(result, [2]string{name.(string), script})
+		}
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if token != delim {
+		return errors.Errorf("expected %q, got %v", delim, token)
+	}
+	return nil
+}
+
+func packageManager(dir string) string {
+	for _, lock := range []struct {
+		file    string
+		manager string
+	}{
+		{"pnpm-lock.yaml", "pnpm"},
+		{"yarn.lock", "yarn"},
+		{"bun.lockb", "bun"},
+		{"bun.lock", "bun"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, lock.file)); err == nil {
+			return lock.manager
+		}
+	}
+	return "npm"
+}
+
+type taskfileProvider struct{}
+
+// TaskfileProvider provides tasks of Taskfiles (https://taskfile.dev).
+// Internal tasks are skipped.
+func TaskfileProvider() TaskProvider { return taskfileProvider{} }
+
+func (taskfileProvider) Source() TaskSource { return TaskSourceTaskfile }
+
+func (taskfileProvider) Match(name string) bool {
+	switch name {
+	case "Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml",
+		"Taskfile.dist.yml", "Taskfile.dist.yaml", "taskfile.dist.yml", "taskfile.dist.yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+func (taskfileProvider) Tasks(path string, data []byte) ([]ProvidedTask, error) {
+	var doc struct {
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.WithStack(err)
+	}
+
+	if doc.Tasks.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	// Other names are also found by task, but only if Taskfile.yml does not exist.
+	command := "task"
+	if base := filepath.Base(path); base != "Taskfile.yml" {
+		command += " --taskfile " + shell.Quote(base)
+	}
+
+	var result []ProvidedTask
+
+	// Content of a mapping node alternates keys and values.
+	for i := 0; i+1 < len(doc.Tasks.Content); i += 2 {
+		name := doc.Tasks.Content[i].Value
+
+		var spec struct {
+			Desc     string `yaml:"desc"`
+			Summary  string `yaml:"summary"`
+			Internal bool   `yaml:"internal"`
+		}
+		// Tasks can be also defined as a string or a list of commands.
+		if value := doc.Tasks.Content[i+1]; value.Kind == yaml.MappingNode {
+			if err := value.Decode(&spec); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		if spec.Internal {
+			continue
+		}
+
+		description := spec.Desc
+		if description == "" {
+			description = strings.TrimSpace(spec.Summary)
+		}
+
+		result = append(result, ProvidedTask{
+			Name:        name,
+			Description: description,
+			Command:     command + " " + shell.Quote(name),
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,153 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakefileProvider(t *testing.T) {
+	data := []byte(`VERSION := 1.0
+OUT = bin
+
+.PHONY: build test
+
+# Build the binary.
+build: deps ## Build it.
+	go build -o $(OUT) .
+
+# Run tests
+# with race detector.
+test lint:
+	go test -race ./...
+
+%.o: %.c
+	cc -c $<
+
+$(OUT)/app: build
+
+install-tools::
+	go install tool
+`)
+
+	tasks, err := MakefileProvider().Tasks("Makefile", data)
+	require.NoError(t, err)
+	assert.Equal(t, []ProvidedTask{
+		{Name: "build", Description: "Build it.", Command: "make build"},
+		{Name: "test", Description: "Run tests with race detector.", Command: "make test"},
+		{Name: "lint", Description: "Run tests with race detector.", Command: "make lint"},
+		{Name: "install-tools", Command: "make install-tools"},
+	}, tasks)
+}
+
+func TestPackageJSONProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "package.json")
+	data := []byte(`{
+  "name": "app",
+  "scripts": {
+    "pretest": "npm run lint",
+    "test": "jest",
+    "lint": "eslint .",
+    "build:prod": "vite build"
+  },
+  "dependencies": {"vite": "5"}
+}`)
+
+	tasks, err := PackageJSONProvider().Tasks(path, data)
+	require.NoError(t, err)
+	assert.Equal(t, []ProvidedTask{
+		{Name: "test", Description: "jest", Command: "npm run test"},
+		{Name: "lint", Description: "eslint .", Command: "npm run lint"},
+		{Name: "build:prod", Description: "vite build", Command: "npm run build:prod"},
+	}, tasks)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pnpm-lock.yaml"), nil, 0o600))
+	tasks, err = PackageJSONProvider().Tasks(path, data)
+	require.NoError(t, err)
+	assert.Equal(t, "pnpm run test", tasks[0].Command)
+
+	tasks, err = PackageJSONProvider().Tasks(path, []byte(`{"name": "no-scripts"}`))
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+
+	_, err = PackageJSONProvider().Tasks(path, []byte(`[]`))
+	require.Error(t, err)
+}
+
+func TestTaskfileProvider(t *testing.T) {
+	data := []byte(`version: "3"
+tasks:
+  build:
+    desc: Build the app
+    cmds:
+      - go build
+  setup:
+    internal: true
+    cmds:
+      - go mod download
+  fmt: gofmt -w .
+`)
+
+	tasks, err := TaskfileProvider().Tasks("Taskfile.yml", data)
+	require.NoError(t, err)
+	assert.Equal(t, []ProvidedTask{
+		{Name: "build", Description: "Build the app", Command: "task build"},
+		{Name: "fmt", Command: "task fmt"},
+	}, tasks)
+
+	tasks, err = TaskfileProvider().Tasks("Taskfile.dist.yaml", data)
+	require.NoError(t, err)
+	assert.Equal(t, "task --taskfile Taskfile.dist.yaml build", tasks[0].Command)
+}
+
+func TestProjectLoadWithTaskProviders(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":        "```sh {\"name\":\"hello\"}\necho hello\n```\n",
+		"Makefile":         "build:\n\tgo build\n",
+		"web/package.json": `{"scripts": {"dev": "vite"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	proj, err := NewDirProject(dir, WithTaskProviders(DefaultTaskProviders()...))
+	require.NoError(t, err)
+
+	tasks, err := LoadTasks(context.Background(), proj)
+	require.NoError(t, err)
+
+	type result struct {
+		Name   string
+		Path   string
+		Source TaskSource
+		Lines  []string
+	}
+	var results []result
+	for _, task := range tasks {
+		assert.Equal(t, task.Source != TaskSourceMarkdown, task.CodeBlock.ExcludeFromRunAll())
+		results = append(results, result{
+			Name:   task.CodeBlock.Name(),
+			Path:   task.RelDocumentPath,
+			Source: task.Source,
+			Lines:  task.CodeBlock.Lines(),
+		})
+	}
+	assert.ElementsMatch(t, []result{
+		{Name: "hello", Path: "README.md", Source: TaskSourceMarkdown, Lines: []string{"echo hello"}},
+		{Name: "build", Path: "Makefile", Source: TaskSourceMakefile, Lines: []string{"make build"}},
+		{Name: "dev", Path: filepath.Join("web", "package.json"), Source: TaskSourcePackageJSON, Lines: []string{"npm run dev"}},
+	}, results)
+
+	// Files read by providers are not documents.
+	docs, err := LoadFiles(context.Background(), proj)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "README.md")}, docs)
+}
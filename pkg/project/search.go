@@ -0,0 +1,247 @@
+package project
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// SearchField is a part of a task which is matched against a search query.
+type SearchField uint8
+
+const (
+	SearchFieldName SearchField = iota + 1
+	SearchFieldTags
+	SearchFieldIntro
+	SearchFieldPath
+	SearchFieldCode
+)
+
+func (f SearchField) String() string {
+	switch f {
+	case SearchFieldName:
+		return "name"
+	case SearchFieldTags:
+		return "tags"
+	case SearchFieldIntro:
+		return "intro"
+	case SearchFieldPath:
+		return "path"
+	case SearchFieldCode:
+		return "code"
+	default:
+		return "unknown"
+	}
+}
+
+// weight returns how much a match in the field contributes to the score.
+func (f SearchField) weight() int {
+	switch f {
+	case SearchFieldName:
+		return 5
+	case SearchFieldTags:
+		return 4
+	case SearchFieldIntro:
+		return 3
+	case SearchFieldPath:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// fuzzy returns true if the field is short enough
+// to be matched fuzzily as a whole.
+func (f SearchField) fuzzy() bool {
+	return f == SearchFieldName || f == SearchFieldPath
+}
+
+type SearchResult struct {
+	Task  Task
+	Score int
+	// Fields contains fields which matched at least one term of the query.
+	Fields []SearchField
+}
+
+// searchStopWords are ignored in queries
+// like "the block that rotates the TLS certs".
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "block": true, "for": true,
+	"in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "to": true, "which": true,
+	"with": true,
+}
+
+type searchDocument struct {
+	task   Task
+	fields []searchDocumentField
+}
+
+type searchDocumentField struct {
+	field SearchField
+	text  string
+	words []string
+}
+
+// SearchTasks ranks tasks against the query. The query is split into terms
+// which are matched against words of the name, tags, intro, path, and code
+// of each task. Names and paths are also matched fuzzily, hence, "dplprod"
+// finds "deploy-prod".
+//
+// Tasks matching more terms rank higher, then tasks with the higher score.
+// Tasks which match no terms are omitted. If the query has no terms,
+// all tasks are returned in the original order.
+func SearchTasks(tasks []Task, query string) []SearchResult {
+	terms := searchTerms(query)
+
+	results := make([]SearchResult, 0, len(tasks))
+	matched := make([]int, 0, len(tasks))
+
+	for _, task := range tasks {
+		if len(terms) == 0 {
+			results = append(results, SearchResult{Task: task})
+			continue
+		}
+
+		doc := newSearchDocument(task)
+
+		var (
+			result = SearchResult{Task: task}
+			count  int
+		)
+
+		for _, term := range terms {
+			score, field := doc.match(term)
+			if score == 0 {
+				continue
+			}
+			count++
+			result.Score += score
+			if !slices.Contains(result.Fields, field) {
+				result.Fields = append(result.Fields, field)
+			}
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		slices.Sort(result.Fields)
+		results = append(results, result)
+		matched = append(matched, count)
+	}
+
+	if len(terms) == 0 {
+		return results
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if matched[a] != matched[b] {
+			return matched[b] - matched[a]
+		}
+		return results[b].Score - results[a].Score
+	})
+
+	sorted := make([]SearchResult, 0, len(results))
+	for _, i := range order {
+		sorted = append(sorted, results[i])
+	}
+	return sorted
+}
+
+func newSearchDocument(task Task) searchDocument {
+	block := task.CodeBlock
+
+	path := task.RelDocumentPath
+	if path == "" {
+		path = task.DocumentPath
+	}
+
+	doc := searchDocument{task: task}
+	for _, f := range []struct {
+		field SearchField
+		text  string
+	}{
+		{SearchFieldName, block.Name()},
+		{SearchFieldTags, strings.Join(block.Tags(), " ")},
+		{SearchFieldIntro, block.Intro()},
+		{SearchFieldPath, path},
+		{SearchFieldCode, strings.Join(block.Lines(), "\n")},
+	} {
+		text := strings.ToLower(f.text)
+		doc.fields = append(doc.fields, searchDocumentField{
+			field: f.field,
+			text:  text,
+			words: splitWords(text),
+		})
+	}
+	return doc
+}
+
+// match returns the best score of the term and the field which yielded it.
+func (d searchDocument) match(term string) (int, SearchField) {
+	var (
+		best  int
+		field SearchField
+	)
+
+	for _, f := range d.fields {
+		score := 0
+		for _, word := range f.words {
+			switch {
+			case word == term:
+				score = max(score, 3*f.field.weight())
+			case stemMatch(word, term):
+				score = max(score, 2*f.field.weight())
+			}
+		}
+		if score == 0 && f.field.fuzzy() && len(term) >= 3 && len(fuzzy.Find(term, []string{f.text})) > 0 {
+			score = f.field.weight()
+		}
+		if score > best {
+			best, field = score, f.field
+		}
+	}
+
+	return best, field
+}
+
+func searchTerms(query string) (result []string) {
+	for _, word := range splitWords(strings.ToLower(query)) {
+		if !searchStopWords[word] {
+			result = append(result, word)
+		}
+	}
+	return
+}
+
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stemMatch returns true if words share the same stem, for example,
+// "rotates" and "rotate", or "certs" and "certificates".
+func stemMatch(a, b string) bool {
+	a, b = stem(a), stem(b)
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return len(a) >= 3 && strings.HasPrefix(b, a)
+}
+
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "es", "ed", "s"} {
+		if trimmed, ok := strings.CutSuffix(word, suffix); ok && len(trimmed) >= 3 {
+			return trimmed
+		}
+	}
+	return word
+}
@@ -0,0 +1,69 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTasks(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(`# Ops
+
+Rotate TLS certificates of the ingress.
+
+`+"```sh {\"name\":\"renew\",\"tag\":\"security\"}\ncertbot renew\n```"+`
+
+Deploy to production.
+
+`+"```sh {\"name\":\"deploy-prod\"}\nkubectl apply -f prod.yaml\n```"+`
+
+Run tests.
+
+`+"```sh {\"name\":\"test\"}\ngo test ./...\n```"+`
+`), 0o600))
+
+	proj, err := NewDirProject(dir)
+	require.NoError(t, err)
+	tasks, err := LoadTasks(context.Background(), proj)
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+
+	names := func(results []SearchResult) (result []string) {
+		for _, r := range results {
+			result = append(result, r.Task.CodeBlock.Name())
+		}
+		return
+	}
+
+	t.Run("Intro", func(t *testing.T) {
+		results := SearchTasks(tasks, "the block that rotates the TLS certs")
+		require.NotEmpty(t, results)
+		assert.Equal(t, "renew", results[0].Task.CodeBlock.Name())
+		assert.Equal(t, []SearchField{SearchFieldIntro}, results[0].Fields)
+	})
+
+	t.Run("Fuzzy", func(t *testing.T) {
+		assert.Equal(t, []string{"deploy-prod"}, names(SearchTasks(tasks, "dplprod")))
+	})
+
+	t.Run("Ranking", func(t *testing.T) {
+		// The name outweighs the code which also contains "prod".
+		results := SearchTasks(tasks, "prod")
+		assert.Equal(t, []string{"deploy-prod"}, names(results[:1]))
+		assert.Equal(t, []SearchField{SearchFieldName}, results[0].Fields)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		assert.Equal(t, []string{"renew"}, names(SearchTasks(tasks, "security")))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, []string{"renew", "deploy-prod", "test"}, names(SearchTasks(tasks, "")))
+	})
+}
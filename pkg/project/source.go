@@ -0,0 +1,476 @@
+package project
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/pkg/errors"
+)
+
+// Remote project sources are fetched into a [SourceCache] and
+// then loaded as dir-based projects. Supported sources are:
+//
+//   - git URLs: "git+https://github.com/org/runbooks.git#main:docs",
+//     where the fragment is an optional ref (branch, tag, or commit)
+//     and an optional path within the repository,
+//   - local bare git repositories: "/srv/git/runbooks.git#v1.0:docs",
+//   - tar (optionally gzipped) and zip archives, local or available over
+//     HTTP(S): "https://example.com/runbooks.tar.gz#docs", where the
+//     fragment is an optional path within the archive.
+const gitSourcePrefix = "git+"
+
+var archiveExtensions = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// IsRemoteSource returns true if source is not a plain local
+// directory or file and must be fetched with [SourceCache.Fetch].
+func IsRemoteSource(source string) bool {
+	if strings.HasPrefix(source, gitSourcePrefix) {
+		return true
+	}
+
+	location, _, _ := strings.Cut(source, "#")
+
+	if isArchive(location) {
+		return true
+	}
+
+	return isBareRepo(location)
+}
+
+// SourceCache is a directory containing fetched remote sources.
+// Entries are addressed by the hash of the content, hence, the same
+// content is stored once and fetching it again is cheap.
+type SourceCache struct {
+	dir string
+}
+
+func NewSourceCache(dir string) *SourceCache {
+	return &SourceCache{dir: dir}
+}
+
+// DefaultSourceCacheDir returns a directory within the user's
+// cache directory in which remote sources are stored.
+func DefaultSourceCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "runme", "sources"), nil
+}
+
+// FetchSource fetches the source into the cache
+// in [DefaultSourceCacheDir].
+func FetchSource(ctx context.Context, source string) (string, error) {
+	dir, err := DefaultSourceCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return NewSourceCache(dir).Fetch(ctx, source)
+}
+
+// Fetch fetches the source if it is not cached yet and returns
+// a path to the local directory containing its content.
+func (c *SourceCache) Fetch(ctx context.Context, source string) (string, error) {
+	var (
+		dir string
+		sub string
+		err error
+	)
+
+	switch location, fragment, _ := strings.Cut(source, "#"); {
+	case strings.HasPrefix(location, gitSourcePrefix):
+		ref, path := parseGitFragment(fragment)
+		sub = path
+		dir, err = c.fetchGit(ctx, strings.TrimPrefix(location, gitSourcePrefix), ref, false)
+	case isArchive(location):
+		sub = fragment
+		dir, err = c.fetchArchive(ctx, location)
+	case isBareRepo(location):
+		ref, path := parseGitFragment(fragment)
+		sub = path
+		dir, err = c.fetchGit(ctx, location, ref, true)
+	default:
+		return "", errors.Errorf("unsupported project source %q", source)
+	}
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed to fetch %q", source)
+	}
+
+	if sub == "" {
+		return dir, nil
+	}
+
+	path, err := securePath(dir, sub)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Wrapf(err, "path %q not found in %q", sub, source)
+	}
+	return path, nil
+}
+
+// parseGitFragment parses "ref:path", "ref", or ":path".
+func parseGitFragment(fragment string) (ref, path string) {
+	ref, path, _ = strings.Cut(fragment, ":")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return ref, path
+}
+
+func (c *SourceCache) fetchGit(ctx context.Context, url, ref string, local bool) (string, error) {
+	var (
+		repo *git.Repository
+		err  error
+	)
+
+	if local {
+		repo, err = git.PlainOpen(url)
+	} else {
+		repo, err = cloneGit(ctx, url, ref)
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	hash, err := resolveGitRef(repo, ref)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return c.store("git-"+tree.Hash.String(), func(dir string) error {
+		return extractGitTree(tree, dir)
+	})
+}
+
+// cloneGit clones only the last commit of ref if it is a branch or a tag.
+// Other revisions, for example, commit hashes, require a full clone.
+func cloneGit(ctx context.Context, url, ref string) (*git.Repository, error) {
+	var names []plumbing.ReferenceName
+	switch {
+	case ref == "HEAD":
+		// An empty name means the remote HEAD.
+		names = []plumbing.ReferenceName{""}
+	case !plumbing.IsHash(ref):
+		names = []plumbing.ReferenceName{
+			plumbing.NewBranchReferenceName(ref),
+			plumbing.NewTagReferenceName(ref),
+		}
+	}
+
+	for _, name := range names {
+		repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+			URL:           url,
+			ReferenceName: name,
+			SingleBranch:  true,
+			Depth:         1,
+			Tags:          git.NoTags,
+		})
+		if err == nil {
+			return repo, nil
+		}
+		if !isGitRefNotFound(err) {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:  url,
+		Tags: git.AllTags,
+	})
+	return repo, errors.WithStack(err)
+}
+
+func isGitRefNotFound(err error) bool {
+	var refSpecErr git.NoMatchingRefSpecError
+	return errors.As(err, &refSpecErr) || errors.Is(err, plumbing.ErrReferenceNotFound)
+}
+
+func resolveGitRef(repo *git.Repository, ref string) (*plumbing.Hash, error) {
+	// Branches of cloned repositories are available only as remote references.
+	for _, rev := range []string{ref, "origin/" + ref} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+		if err == nil {
+			return hash, nil
+		}
+	}
+	return nil, errors.Errorf("failed to resolve ref %q", ref)
+}
+
+func extractGitTree(tree *object.Tree, dir string) error {
+	return tree.Files().ForEach(func(f *object.File) error {
+		var perm os.FileMode = 0o644
+		switch f.Mode {
+		case filemode.Executable:
+			perm = 0o755
+		case filemode.Regular, filemode.Deprecated:
+		default:
+			// Symlinks and submodules are skipped.
+			return nil
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer func() { _ = r.Close() }()
+
+		return writeSourceFile(dir, f.Name, r, perm)
+	})
+}
+
+func (c *SourceCache) fetchArchive(ctx context.Context, location string) (string, error) {
+	path := location
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		tmp, err := downloadArchive(ctx, location)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = os.Remove(tmp) }()
+		path = tmp
+	}
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return c.store("archive-"+sum, func(dir string) error {
+		if strings.HasSuffix(location, ".zip") {
+			return extractZip(path, dir)
+		}
+		return extractTar(path, strings.HasSuffix(location, ".gz") || strings.HasSuffix(location, ".tgz"), dir)
+	})
+}
+
+func downloadArchive(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	f, err := os.CreateTemp("", "runme-source-*")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", errors.WithStack(err)
+	}
+
+	return f.Name(), nil
+}
+
+func extractTar(path string, gzipped bool, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if gzipped {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer func() { _ = gr.Close() }()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// Directories are created along with files; links are skipped.
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := writeSourceFile(dir, hdr.Name, tr, archivePerm(hdr.FileInfo().Mode())); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(path string, dir string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		err = writeSourceFile(dir, f.Name, r, archivePerm(f.Mode()))
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func archivePerm(mode os.FileMode) os.FileMode {
+	if mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+// store calls fill with a temporary directory and moves it to the cache
+// under key. If the key already exists, fill is not called at all.
+func (c *SourceCache) store(key string, fill func(dir string) error) (string, error) {
+	dir := filepath.Join(c.dir, key)
+
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	tmp, err := os.MkdirTemp(c.dir, ".tmp-"+key+"-*")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	if err := fill(tmp); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another process might have stored the same content in the meantime.
+		if _, serr := os.Stat(dir); serr == nil {
+			return dir, nil
+		}
+		return "", errors.WithStack(err)
+	}
+
+	return dir, nil
+}
+
+func writeSourceFile(dir, name string, r io.Reader, perm os.FileMode) error {
+	path, err := securePath(dir, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = io.Copy(f, r) // #nosec G110; sources are trusted like any project
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return errors.WithStack(err)
+}
+
+// securePath joins dir and name making sure that
+// the result does not escape dir.
+func securePath(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("path %q escapes the source directory", name)
+	}
+	return path, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isArchive(location string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(location, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBareRepo returns true if path is a directory
+// containing a bare git repository.
+func isBareRepo(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,172 @@
+package project
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sourceTestReadme = "```sh {\"name\":\"hello\"}\necho hello\n```\n"
+
+func TestSourceCache_Git(t *testing.T) {
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	require.NoError(t, err)
+
+	commit := func(t *testing.T, name, content string) {
+		t.Helper()
+		path := filepath.Join(repoDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		_, err = wt.Add(name)
+		require.NoError(t, err)
+		_, err = wt.Commit("update "+name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+	}
+
+	commit(t, "docs/README.md", sourceTestReadme)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1", head.Hash(), nil)
+	require.NoError(t, err)
+	commit(t, "docs/README.md", "No tasks anymore.\n")
+
+	cache := NewSourceCache(t.TempDir())
+
+	t.Run("URL", func(t *testing.T) {
+		source := "git+file://" + repoDir + "#v1:docs"
+		require.True(t, IsRemoteSource(source))
+
+		dir, err := cache.Fetch(context.Background(), source)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+
+		proj, err := NewDirProject(dir)
+		require.NoError(t, err)
+		tasks, err := LoadTasks(context.Background(), proj)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "hello", tasks[0].CodeBlock.Name())
+
+		// The same tree is stored once.
+		again, err := cache.Fetch(context.Background(), source)
+		require.NoError(t, err)
+		assert.Equal(t, dir, again)
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		dir, err := cache.Fetch(context.Background(), "git+file://"+repoDir)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, "docs", "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "No tasks anymore.\n", string(data))
+	})
+
+	t.Run("BareRepo", func(t *testing.T) {
+		bareDir := filepath.Join(t.TempDir(), "runbooks.git")
+		_, err := git.PlainClone(bareDir, true, &git.CloneOptions{URL: repoDir})
+		require.NoError(t, err)
+
+		source := bareDir + "#v1:docs"
+		require.True(t, IsRemoteSource(source))
+		require.False(t, IsRemoteSource(repoDir))
+
+		dir, err := cache.Fetch(context.Background(), source)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+	})
+
+	t.Run("ShallowClone", func(t *testing.T) {
+		for _, ref := range []string{"HEAD", "master", "v1"} {
+			repo, err := cloneGit(context.Background(), "file://"+repoDir, ref)
+			require.NoError(t, err, ref)
+			shallow, err := repo.Storer.Shallow()
+			require.NoError(t, err)
+			assert.NotEmpty(t, shallow, ref)
+		}
+	})
+
+	t.Run("CommitHash", func(t *testing.T) {
+		dir, err := cache.Fetch(context.Background(), "git+file://"+repoDir+"#"+head.Hash().String()+":docs")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		_, err := cache.Fetch(context.Background(), "git+file://"+repoDir+"#unknown")
+		require.ErrorContains(t, err, `failed to resolve ref "unknown"`)
+	})
+}
+
+func TestSourceCache_Archive(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	tarPath := filepath.Join(archiveDir, "runbooks.tar.gz")
+	{
+		f, err := os.Create(tarPath)
+		require.NoError(t, err)
+		gw := gzip.NewWriter(f)
+		tw := tar.NewWriter(gw)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/README.md", Mode: 0o644, Size: int64(len(sourceTestReadme)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write([]byte(sourceTestReadme))
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, gw.Close())
+		require.NoError(t, f.Close())
+	}
+
+	zipPath := filepath.Join(archiveDir, "evil.zip")
+	{
+		f, err := os.Create(zipPath)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("../escaped.md")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(sourceTestReadme))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+	}
+
+	cache := NewSourceCache(t.TempDir())
+
+	t.Run("Tar", func(t *testing.T) {
+		dir, err := cache.Fetch(context.Background(), tarPath+"#docs")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		server := httptest.NewServer(http.FileServer(http.Dir(archiveDir)))
+		defer server.Close()
+
+		local, err := cache.Fetch(context.Background(), tarPath)
+		require.NoError(t, err)
+
+		// Content-addressed, hence, the same directory as for the local file.
+		dir, err := cache.Fetch(context.Background(), server.URL+"/runbooks.tar.gz")
+		require.NoError(t, err)
+		assert.Equal(t, local, dir)
+	})
+
+	t.Run("PathEscape", func(t *testing.T) {
+		_, err := cache.Fetch(context.Background(), zipPath)
+		require.ErrorContains(t, err, "escapes the source directory")
+	})
+}
@@ -2,6 +2,7 @@ package project
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"slices"
@@ -19,10 +20,45 @@ type Task struct {
 	CodeBlock       *document.CodeBlock `json:"code_block"`
 	DocumentPath    string              `json:"document_path"`
 	RelDocumentPath string              `json:"rel_document_path"`
+	// Root is a name of the [Workspace] root the task belongs to.
+	// It is empty for tasks loaded from a single project.
+	Root string `json:"root,omitempty"`
+	// Source is where the task comes from, for example, a markdown file
+	// or a Makefile. Tasks from sources other than markdown are read-only.
+	Source TaskSource `json:"source,omitempty"`
 }
 
 func (t Task) ID() string {
-	return t.RelDocumentPath + ":" + t.CodeBlock.Name()
+	id := t.RelDocumentPath + ":" + t.CodeBlock.Name()
+	if t.Root != "" {
+		id = t.Root + ":" + id
+	}
+	return id
+}
+
+// QualifiedName returns the name of the task prefixed with
+// the name of its workspace root, for example, "infra:deploy".
+func (t Task) QualifiedName() string {
+	if t.Root == "" {
+		return t.CodeBlock.Name()
+	}
+	return t.Root + ":" + t.CodeBlock.Name()
+}
+
+// Location returns the location of the task's code block in the form
+// of "path:line:column", for example, "README.md:42:3".
+func (t Task) Location() string {
+	path := t.RelDocumentPath
+	if path == "" {
+		path = t.DocumentPath
+	}
+
+	pos := t.CodeBlock.Position()
+	if pos.Line == 0 {
+		return path
+	}
+
+	return fmt.Sprintf("%s:%s", path, pos)
 }
 
 // LoadFiles returns a list of file names found in the project.
@@ -52,10 +88,15 @@ func LoadFiles(ctx context.Context, p *Project) ([]string, error) {
 	return result, err
 }
 
-func LoadTasks(ctx context.Context, p *Project) ([]Task, error) {
+// Loader is implemented by [Project] and [Workspace].
+type Loader interface {
+	LoadWithOptions(ctx context.Context, eventc chan<- LoadEvent, options LoadOptions)
+}
+
+func LoadTasks(ctx context.Context, p Loader) ([]Task, error) {
 	eventc := make(chan LoadEvent)
 
-	go p.Load(ctx, eventc, false)
+	go p.LoadWithOptions(ctx, eventc, LoadOptions{})
 
 	var (
 		result []Task
@@ -0,0 +1,316 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type WatchEventType uint8
+
+const (
+	WatchEventTaskAdded WatchEventType = iota + 1
+	WatchEventTaskChanged
+	WatchEventTaskRemoved
+	// WatchEventSynced is sent once after all tasks
+	// found initially were sent as added.
+	WatchEventSynced
+	WatchEventError
+)
+
+type WatchEvent struct {
+	Type WatchEventType
+	// Task is set for task events. For removed tasks,
+	// it is the task as it was last seen.
+	Task Task
+	// Previous is set for [WatchEventTaskChanged] and it is
+	// the task as it was last seen, for example, before it was renamed.
+	Previous *Task
+	// Err is set for WatchEventError.
+	Err error
+}
+
+// watchDebounce is the time to wait for more file system events
+// before re-parsing files; editors often emit several events on save.
+const watchDebounce = 100 * time.Millisecond
+
+type watchedTask struct {
+	task Task
+	// index is the position of the task in its file.
+	index int
+	intro string
+	value []byte
+}
+
+// changed reports whether the task differs from other. The value contains
+// the fence with attributes so any edit of the code block is detected.
+func (t watchedTask) changed(other watchedTask) bool {
+	return t.task.CodeBlock.Name() != other.task.CodeBlock.Name() ||
+		t.intro != other.intro ||
+		!bytes.Equal(t.value, other.value)
+}
+
+type watcher struct {
+	project *Project
+	fsw     *fsnotify.Watcher
+	matcher gitignore.Matcher
+	eventc  chan<- WatchEvent
+	// files contains tasks of watched files keyed by the file path
+	// and the task name.
+	files map[string]map[string]watchedTask
+}
+
+// Watch sends all tasks of the project as added, followed by [WatchEventSynced],
+// and then watches files of the project for changes, sending events about added,
+// changed, and removed tasks. It blocks until ctx is done and closes eventc.
+func (p *Project) Watch(ctx context.Context, eventc chan<- WatchEvent) error {
+	defer close(eventc)
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = fsw.Close() }()
+
+	w := &watcher{
+		project: p,
+		fsw:     fsw,
+		eventc:  eventc,
+		files:   make(map[string]map[string]watchedTask),
+	}
+
+	if p.fs != nil {
+		w.matcher = gitignore.NewMatcher(p.getAllIgnorePatterns())
+	}
+
+	if err := w.load(ctx); err != nil {
+		return err
+	}
+
+	w.send(ctx, WatchEvent{Type: WatchEventSynced})
+
+	return w.run(ctx)
+}
+
+func (w *watcher) send(ctx context.Context, event WatchEvent) {
+	select {
+	case w.eventc <- event:
+	case <-ctx.Done():
+	}
+}
+
+func (w *watcher) load(ctx context.Context) error {
+	p := w.project
+
+	if p.filePath != "" {
+		// fsnotify does not track files replaced by editors
+		// on save, hence, the parent directory is watched.
+		if err := w.fsw.Add(filepath.Dir(p.filePath)); err != nil {
+			return errors.WithStack(err)
+		}
+		w.update(ctx, p.filePath)
+		return nil
+	}
+
+	return w.addDir(ctx, p.Root())
+}
+
+// addDir watches the directory and its subdirectories
+// and updates all markdown files within them.
+func (w *watcher) addDir(ctx context.Context, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory might have been removed in the meantime.
+			return nil
+		}
+		if w.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return errors.WithStack(w.fsw.Add(path))
+		}
+		if isMarkdown(path) {
+			w.update(ctx, path)
+		}
+		return ctx.Err()
+	})
+}
+
+func (w *watcher) ignored(path string, isDir bool) bool {
+	p := w.project
+
+	if p.filePath != "" {
+		return path != p.filePath
+	}
+
+	rel, err := p.relPath(path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return w.matcher.Match(strings.Split(rel, string(filepath.Separator)), isDir)
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(watchDebounce)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.send(ctx, WatchEvent{Type: WatchEventError, Err: err})
+		case <-timer.C:
+			for path := range pending {
+				w.handle(ctx, path)
+			}
+			clear(pending)
+
+			if w.project.index != nil {
+				w.project.saveIndex()
+			}
+		}
+	}
+}
+
+// handle processes a path which was created, changed, or removed.
+func (w *watcher) handle(ctx context.Context, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		// Removed; path can be a file or a directory.
+		prefix := path + string(filepath.Separator)
+		for file := range w.files {
+			if file == path || strings.HasPrefix(file, prefix) {
+				w.remove(ctx, file)
+			}
+		}
+		return
+	}
+
+	if w.ignored(path, info.IsDir()) {
+		return
+	}
+
+	if info.IsDir() {
+		if err := w.addDir(ctx, path); err != nil {
+			w.send(ctx, WatchEvent{Type: WatchEventError, Err: err})
+		}
+		return
+	}
+
+	if isMarkdown(path) {
+		w.update(ctx, path)
+	}
+}
+
+func (w *watcher) remove(ctx context.Context, path string) {
+	for _, t := range w.files[path] {
+		w.send(ctx, WatchEvent{Type: WatchEventTaskRemoved, Task: t.task})
+	}
+	delete(w.files, path)
+}
+
+// update parses the file and sends events for tasks
+// which differ from the previously seen ones.
+func (w *watcher) update(ctx context.Context, path string) {
+	p := w.project
+
+	blocks, err := p.getCodeBlocksFromFile(path, false)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			w.send(ctx, WatchEvent{Type: WatchEventError, Err: errors.Wrapf(err, "failed to parse %q", path)})
+		}
+		p.logger.Debug("failed to get code blocks", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	relPath, _ := p.relPath(path)
+
+	prev := w.files[path]
+	next := make(map[string]watchedTask, len(blocks))
+	keys := make([]string, 0, len(blocks))
+
+	for idx, b := range blocks {
+		key := b.Name()
+		// Generated names are not guaranteed to be unique.
+		for i := 1; ; i++ {
+			if _, ok := next[key]; !ok {
+				break
+			}
+			key = b.Name() + "#" + strconv.Itoa(i)
+		}
+
+		next[key] = watchedTask{
+			task: Task{
+				CodeBlock:       b,
+				DocumentPath:    path,
+				RelDocumentPath: relPath,
+			},
+			index: idx,
+			intro: b.Intro(),
+			value: b.Value(),
+		}
+		keys = append(keys, key)
+	}
+
+	// A task which is gone and a new task at the same position
+	// in the file are considered the same task which was renamed.
+	removed := make(map[int]string)
+	for key, old := range prev {
+		if _, ok := next[key]; !ok {
+			removed[old.index] = key
+		}
+	}
+
+	for _, key := range keys {
+		t := next[key]
+
+		old, ok := prev[key]
+		if !ok {
+			oldKey, renamed := removed[t.index]
+			if !renamed {
+				w.send(ctx, WatchEvent{Type: WatchEventTaskAdded, Task: t.task})
+				continue
+			}
+			delete(removed, t.index)
+			old = prev[oldKey]
+		}
+
+		if t.changed(old) {
+			previous := old.task
+			w.send(ctx, WatchEvent{Type: WatchEventTaskChanged, Task: t.task, Previous: &previous})
+		}
+	}
+
+	for _, key := range removed {
+		w.send(ctx, WatchEvent{Type: WatchEventTaskRemoved, Task: prev[key].task})
+	}
+
+	w.files[path] = next
+}
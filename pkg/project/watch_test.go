@@ -0,0 +1,107 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectWatch(t *testing.T) {
+	dir := t.TempDir()
+
+	readme := filepath.Join(dir, "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"first\"}\necho first\n```\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored/\n"), 0o600))
+
+	proj, err := NewDirProject(dir, WithRespectGitignore(true))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventc := make(chan WatchEvent)
+	errc := make(chan error, 1)
+	go func() { errc <- proj.Watch(ctx, eventc) }()
+
+	next := func(t *testing.T) WatchEvent {
+		t.Helper()
+		select {
+		case event := <-eventc:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return WatchEvent{}
+		}
+	}
+
+	event := next(t)
+	assert.Equal(t, WatchEventTaskAdded, event.Type)
+	assert.Equal(t, "first", event.Task.CodeBlock.Name())
+	assert.Equal(t, "README.md", event.Task.RelDocumentPath)
+	assert.Equal(t, WatchEventSynced, next(t).Type)
+
+	t.Run("Changed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"first\"}\necho changed\n```\n"), 0o600))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskChanged, event.Type)
+		assert.Equal(t, "first", event.Task.CodeBlock.Name())
+	})
+
+	t.Run("Renamed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"renamed\"}\necho changed\n```\n"), 0o600))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskChanged, event.Type)
+		assert.Equal(t, "renamed", event.Task.CodeBlock.Name())
+		require.NotNil(t, event.Previous)
+		assert.Equal(t, "first", event.Previous.CodeBlock.Name())
+	})
+
+	t.Run("AttributesChanged", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(readme, []byte("```sh {\"name\":\"renamed\",\"background\":\"true\"}\necho changed\n```\n"), 0o600))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskChanged, event.Type)
+		assert.True(t, event.Task.CodeBlock.Background())
+	})
+
+	t.Run("IntroChanged", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(readme, []byte("Runs in the background.\n\n```sh {\"name\":\"renamed\",\"background\":\"true\"}\necho changed\n```\n"), 0o600))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskChanged, event.Type)
+		assert.Equal(t, "Runs in the background.", event.Task.CodeBlock.Intro())
+	})
+
+	t.Run("AddedInNewDir", func(t *testing.T) {
+		// Ignored directories are not watched.
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "ignored"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored", "doc.md"), []byte("```sh {\"name\":\"ignored\"}\necho ignored\n```\n"), 0o600))
+
+		nested := filepath.Join(dir, "nested")
+		require.NoError(t, os.MkdirAll(nested, 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(nested, "doc.md"), []byte("```sh {\"name\":\"second\"}\necho second\n```\n"), 0o600))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskAdded, event.Type)
+		assert.Equal(t, "second", event.Task.CodeBlock.Name())
+		assert.Equal(t, filepath.Join("nested", "doc.md"), event.Task.RelDocumentPath)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "nested")))
+
+		event := next(t)
+		assert.Equal(t, WatchEventTaskRemoved, event.Type)
+		assert.Equal(t, "second", event.Task.CodeBlock.Name())
+	})
+
+	cancel()
+	require.NoError(t, <-errc)
+}
@@ -0,0 +1,96 @@
+package project
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WorkspaceRoot is a named project within a [Workspace].
+type WorkspaceRoot struct {
+	// Name is used to namespace tasks, for example, "infra:deploy".
+	Name    string
+	Project *Project
+}
+
+// Workspace groups multiple projects, for example, repositories
+// checked out side by side. Each project keeps its own ignore rules
+// and env sources.
+type Workspace struct {
+	roots []WorkspaceRoot
+}
+
+func NewWorkspace(roots ...WorkspaceRoot) (*Workspace, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("workspace requires at least one root")
+	}
+
+	seen := make(map[string]bool, len(roots))
+
+	for _, root := range roots {
+		if root.Name == "" {
+			return nil, errors.New("workspace root name cannot be empty")
+		}
+		if strings.ContainsAny(root.Name, ":/") {
+			return nil, errors.Errorf("workspace root name %q cannot contain %q or %q", root.Name, ":", "/")
+		}
+		if seen[root.Name] {
+			return nil, errors.Errorf("duplicate workspace root name %q", root.Name)
+		}
+		if root.Project == nil {
+			return nil, errors.Errorf("workspace root %q has no project", root.Name)
+		}
+		seen[root.Name] = true
+	}
+
+	return &Workspace{roots: roots}, nil
+}
+
+func (w *Workspace) Roots() []WorkspaceRoot {
+	return w.roots
+}
+
+// Project returns the project of the root with the given name.
+func (w *Workspace) Project(name string) (*Project, bool) {
+	for _, root := range w.roots {
+		if root.Name == name {
+			return root.Project, true
+		}
+	}
+	return nil, false
+}
+
+// LoadWithOptions loads projects of all roots one by one and sends
+// their events to eventc. Found tasks have [Task.Root] set.
+// Similarly to [Project.LoadWithOptions], it closes eventc when done.
+func (w *Workspace) LoadWithOptions(
+	ctx context.Context,
+	eventc chan<- LoadEvent,
+	options LoadOptions,
+) {
+	defer close(eventc)
+
+	for _, root := range w.roots {
+		rootEventc := make(chan LoadEvent)
+
+		go root.Project.LoadWithOptions(ctx, rootEventc, options)
+
+		for event := range rootEventc {
+			if event.Type == LoadEventFoundTask {
+				data := ExtractDataFromLoadEvent[LoadEventFoundTaskData](event)
+				data.Task.Root = root.Name
+				event.Data = data
+			}
+
+			select {
+			case eventc <- event:
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	newProject := func(t *testing.T, content string) *Project {
+		t.Helper()
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(content), 0o600))
+		proj, err := NewDirProject(dir)
+		require.NoError(t, err)
+		return proj
+	}
+
+	infra := newProject(t, "```sh {\"name\":\"deploy\"}\necho infra\n```\n")
+	app := newProject(t, "```sh {\"name\":\"deploy\"}\necho app\n```\n\n```sh {\"name\":\"test\"}\necho test\n```\n")
+
+	t.Run("InvalidRoots", func(t *testing.T) {
+		_, err := NewWorkspace()
+		require.Error(t, err)
+
+		_, err = NewWorkspace(WorkspaceRoot{Name: "in:fra", Project: infra})
+		require.ErrorContains(t, err, "cannot contain")
+
+		_, err = NewWorkspace(
+			WorkspaceRoot{Name: "infra", Project: infra},
+			WorkspaceRoot{Name: "infra", Project: app},
+		)
+		require.ErrorContains(t, err, "duplicate workspace root name")
+	})
+
+	ws, err := NewWorkspace(
+		WorkspaceRoot{Name: "infra", Project: infra},
+		WorkspaceRoot{Name: "app", Project: app},
+	)
+	require.NoError(t, err)
+
+	tasks, err := LoadTasks(context.Background(), ws)
+	require.NoError(t, err)
+
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.QualifiedName())
+	}
+	assert.Equal(t, []string{"infra:deploy", "app:deploy", "app:test"}, names)
+	assert.Equal(t, "infra:README.md:deploy", tasks[0].ID())
+
+	proj, ok := ws.Project("app")
+	require.True(t, ok)
+	assert.Equal(t, app, proj)
+}